@@ -73,6 +73,10 @@ You can find more information in https://goployer.dev`,
 	rootCmd.AddCommand(NewAddCommand())
 	rootCmd.AddCommand(NewUpdateCommand())
 	rootCmd.AddCommand(NewRefreshCommand())
+	rootCmd.AddCommand(NewPlanCommand())
+	rootCmd.AddCommand(NewRollbackCommand())
+	rootCmd.AddCommand(NewResumeCommand())
+	rootCmd.AddCommand(NewUnlockCommand())
 
 	rootCmd.PersistentFlags().StringVarP(&v, "log-level", "v", constants.DefaultLogLevel.String(), "Log level (debug, info, warn, error, fatal, panic)")
 
@@ -50,13 +50,17 @@ var zeroTimeout = 0 * time.Minute
 var zeroPollingInterval = 0 * time.Second
 
 var flagKey = map[string]string{
-	"deploy":  "deploySet",
-	"delete":  "fullSet",
-	"init":    "initSet",
-	"status":  "statusSet",
-	"update":  "updateSet",
-	"add":     "addSet",
-	"refresh": "refreshSet",
+	"deploy":   "deploySet",
+	"delete":   "fullSet",
+	"init":     "initSet",
+	"status":   "statusSet",
+	"update":   "updateSet",
+	"add":      "addSet",
+	"refresh":  "refreshSet",
+	"plan":     "planSet",
+	"rollback": "rollbackSet",
+	"resume":   "resumeSet",
+	"unlock":   "unlockSet",
 }
 
 var CommonFlagRegistry = []Flag{
@@ -446,6 +450,296 @@ var FlagRegistry = map[string][]Flag{
 			FlagAddMethod: "DurationVar",
 		},
 	},
+	"planSet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack that should be planned.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "ami",
+			Usage:         "Amazon AMI to use.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "env",
+			Usage:         "The environment that is being deployed into.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "assume-role",
+			Usage:         "The Role ARN to assume into.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region to plan, if undefined, then the plan will show all regions for the given environment.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "log-level",
+			Usage:         "Level of logging",
+			Shorthand:     "v",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      "warning",
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "extra-tags",
+			Usage:         "Extra tags to add to autoscaling group tags",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "ansible-extra-vars",
+			Usage:         "Extra variables for ansible",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "override-instance-type",
+			Usage:         "Instance Type to override",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "override-spot-types",
+			Usage:         "Spot Instance Type to override",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "disable-metrics",
+			Usage:         "Disable gathering metrics.",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "force-manifest-capacity",
+			Usage:         "Force-apply the capacity of instances in the manifest file",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "complete-canary",
+			Usage:         "Show the plan for completing canary deployment",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "output",
+			Shorthand:     "o",
+			Usage:         "Output format of plan (text, json)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.TextOutput,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "timeout",
+			Usage:         "Time to wait for deploy to finish before timing out (default 60m)",
+			Value:         &zeroTimeout,
+			DefValue:      timeout,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+		{
+			Name:          "polling-interval",
+			Usage:         "Time to interval for polling health check (default 60s)",
+			Value:         &zeroPollingInterval,
+			DefValue:      pollingInterval,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+	},
+	"rollbackSet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack that should be rolled back. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "to",
+			Usage:         "Version to roll back to (ex. v012), if undefined, then the latest previous version will be used.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "env",
+			Usage:         "The environment that is being rolled back.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "assume-role",
+			Usage:         "The Role ARN to assume into.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region to roll back, if undefined, then all regions of the stack will be rolled back.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "log-level",
+			Usage:         "Level of logging",
+			Shorthand:     "v",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      "warning",
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "slack-off",
+			Usage:         "Turn off slack alarm",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "disable-metrics",
+			Usage:         "Disable gathering metrics.",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "timeout",
+			Usage:         "Time to wait for rollback to finish before timing out (default 60m)",
+			Value:         &zeroTimeout,
+			DefValue:      timeout,
+			FlagAddMethod: "DurationVar",
+		},
+		{
+			Name:          "polling-interval",
+			Usage:         "Time to interval for polling health check (default 60s)",
+			Value:         &zeroPollingInterval,
+			DefValue:      pollingInterval,
+			FlagAddMethod: "DurationVar",
+		},
+		{
+			Name:          "auto-apply",
+			Usage:         "Apply command without confirmation from local terminal",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+	},
+	"resumeSet": {
+		{
+			Name:          "log-level",
+			Usage:         "Level of logging",
+			Shorthand:     "v",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      "warning",
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "slack-off",
+			Usage:         "Turn off slack alarm",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "disable-metrics",
+			Usage:         "Disable gathering metrics. Checkpoints are read from local files.",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "auto-apply",
+			Usage:         "Apply command without confirmation from local terminal",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+	},
+	"unlockSet": {
+		{
+			Name:          "force",
+			Usage:         "Release the lock even if it is not expired yet",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "log-level",
+			Usage:         "Level of logging",
+			Shorthand:     "v",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      "warning",
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "disable-metrics",
+			Usage:         "Disable gathering metrics. Locks are read from local files.",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "auto-apply",
+			Usage:         "Apply command without confirmation from local terminal",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+	},
 	"refreshSet": {
 		{
 			Name:          "region",
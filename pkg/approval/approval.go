@@ -0,0 +1,308 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package approval
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/slack"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// ErrRejected is returned when the new version is rejected by an operator
+var ErrRejected = errors.New("new version is rejected")
+
+// stdin is shared by every prompt of approval in the process
+var stdin = newConsole(os.Stdin)
+
+// console reads lines of input in a single goroutine so that a prompt can stop waiting without leaving a reader behind
+type console struct {
+	input   io.Reader
+	once    sync.Once
+	lines   chan string
+	mu      sync.Mutex
+	cancels map[string]chan struct{}
+
+	// only one prompt is shown at a time in the terminal
+	prompt chan struct{}
+}
+
+// newConsole creates a console which reads lines from the input
+func newConsole(input io.Reader) *console {
+	return &console{
+		input:   input,
+		lines:   make(chan string),
+		cancels: map[string]chan struct{}{},
+		prompt:  make(chan struct{}, 1),
+	}
+}
+
+// readLines starts the reader of input only once and returns the channel of lines
+func (c *console) readLines() <-chan string {
+	c.once.Do(func() {
+		go func() {
+			scanner := bufio.NewScanner(c.input)
+			for scanner.Scan() {
+				c.lines <- scanner.Text()
+			}
+			close(c.lines)
+		}()
+	})
+
+	return c.lines
+}
+
+// Request is a request for approval of the new version of stack
+type Request struct {
+	ID            string        `json:"id"`
+	Application   string        `json:"application"`
+	Stack         string        `json:"stack"`
+	Message       string        `json:"message"`
+	Timeout       time.Duration `json:"timeout"`
+	DefaultAction string        `json:"default_action"`
+}
+
+// Decision is the result of approval
+type Decision struct {
+	Action   string
+	TimedOut bool
+}
+
+// Approver asks an operator whether the new version can be promoted
+type Approver interface {
+	// Request starts asking for the decision and returns the channel which receives it
+	Request(req Request) (<-chan string, error)
+
+	// Cancel stops asking for the decision, and is called after the decision is received as well
+	Cancel(req Request)
+}
+
+// CLIApprover asks for the decision in the terminal
+type CLIApprover struct {
+	console *console
+}
+
+// DefaultApprover decides with the default action without asking anyone
+type DefaultApprover struct{}
+
+// ServerApprover serves approval API in this process and waits for the decision requested to it.
+// Nothing is served if address is empty, so the decision should be resolved in the registry directly.
+type ServerApprover struct {
+	Registry *Registry
+	Address  string
+	Logger   *Logger.Logger
+}
+
+// SlackApprover sends an interactive message to slack and waits for the decision through approval API of this process
+type SlackApprover struct {
+	ServerApprover
+	Slack slack.Slack
+}
+
+// NewRequest creates an approval request with defaults of approval configuration
+func NewRequest(application, stack, message string, a schemas.Approval) Request {
+	req := Request{
+		ID:            fmt.Sprintf("%s/%s", application, stack),
+		Application:   application,
+		Stack:         stack,
+		Message:       message,
+		Timeout:       a.Timeout,
+		DefaultAction: a.DefaultAction,
+	}
+
+	if req.Timeout == 0 {
+		req.Timeout = constants.DefaultApprovalTimeout
+	}
+
+	if len(req.DefaultAction) == 0 {
+		req.DefaultAction = constants.RejectAction
+	}
+
+	return req
+}
+
+// NewApprover returns an approver of the method.
+// Nobody can answer the prompt with auto-apply, so the default action is applied immediately.
+func NewApprover(a schemas.Approval, s slack.Slack, autoApply bool, logger *Logger.Logger) Approver {
+	server := ServerApprover{Registry: Pending, Address: a.Address, Logger: logger}
+	if len(server.Address) == 0 {
+		server.Address = constants.DefaultApprovalAddress
+	}
+
+	switch a.Method {
+	case constants.ServerApproval:
+		return server
+	case constants.SlackApproval:
+		return SlackApprover{ServerApprover: server, Slack: s}
+	}
+
+	if autoApply {
+		return DefaultApprover{}
+	}
+
+	return CLIApprover{console: stdin}
+}
+
+// Wait waits for the decision until timeout, and returns the default action if nobody decides
+func Wait(a Approver, req Request) (Decision, error) {
+	decisions, err := a.Request(req)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer a.Cancel(req)
+
+	timer := time.NewTimer(req.Timeout)
+	defer timer.Stop()
+
+	select {
+	case action := <-decisions:
+		return Decision{Action: action}, nil
+	case <-timer.C:
+		return Decision{Action: req.DefaultAction, TimedOut: true}, nil
+	}
+}
+
+// Request shows a prompt in the terminal
+func (c CLIApprover) Request(req Request) (<-chan string, error) {
+	cancel := make(chan struct{})
+	c.console.mu.Lock()
+	if _, ok := c.console.cancels[req.ID]; ok {
+		c.console.mu.Unlock()
+		return nil, fmt.Errorf("approval is already requested: %s", req.ID)
+	}
+	c.console.cancels[req.ID] = cancel
+	c.console.mu.Unlock()
+
+	decisions := make(chan string, 1)
+	go func() {
+		defer c.console.release(req.ID, cancel)
+
+		// waiting for another prompt is also stopped by cancel
+		select {
+		case c.console.prompt <- struct{}{}:
+		case <-cancel:
+			return
+		}
+		defer func() { <-c.console.prompt }()
+
+		fmt.Println(req.Message)
+		fmt.Printf("Do you approve the new version of %s? (default %s in %s) ", req.Stack, req.DefaultAction, req.Timeout)
+
+		select {
+		case line, ok := <-c.console.readLines():
+			if ok && tool.IsStringInArray(strings.ToLower(strings.TrimSpace(line)), constants.AllowedAnswerYes) {
+				decisions <- constants.ApproveAction
+				return
+			}
+			decisions <- constants.RejectAction
+		case <-cancel:
+			fmt.Println()
+		}
+	}()
+
+	return decisions, nil
+}
+
+// Cancel stops the prompt and releases the terminal for the next prompt
+func (c CLIApprover) Cancel(req Request) {
+	c.console.mu.Lock()
+	defer c.console.mu.Unlock()
+
+	if cancel, ok := c.console.cancels[req.ID]; ok {
+		close(cancel)
+		delete(c.console.cancels, req.ID)
+	}
+}
+
+// release removes the cancel of finished prompt unless it is already replaced by a new request
+func (c *console) release(id string, cancel chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancels[id] == cancel {
+		delete(c.cancels, id)
+	}
+}
+
+// Request returns the default action
+func (d DefaultApprover) Request(req Request) (<-chan string, error) {
+	decisions := make(chan string, 1)
+	decisions <- req.DefaultAction
+
+	return decisions, nil
+}
+
+// Cancel does nothing
+func (d DefaultApprover) Cancel(_ Request) {}
+
+// Request adds the request to the registry of pending requests and serves approval API until it is cancelled
+func (s ServerApprover) Request(req Request) (<-chan string, error) {
+	decisions, err := s.Registry.Add(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.Address) == 0 {
+		return decisions, nil
+	}
+
+	if err := endpoints.open(s.Address, req.ID, Handler{Registry: s.Registry, Logger: s.Logger}); err != nil {
+		s.Registry.Remove(req.ID)
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+// Cancel removes the request from the registry and stops approval API if no other request is waiting
+func (s ServerApprover) Cancel(req Request) {
+	s.Registry.Remove(req.ID)
+
+	if len(s.Address) == 0 {
+		return
+	}
+
+	if err := endpoints.close(s.Address, req.ID); err != nil {
+		s.Logger.Warnf("approval API on %s is not stopped cleanly: %s", s.Address, err.Error())
+	}
+}
+
+// Request adds the request to the registry and sends an interactive message to slack
+func (s SlackApprover) Request(req Request) (<-chan string, error) {
+	decisions, err := s.ServerApprover.Request(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Slack.SendApprovalMessage(req.ID, req.Message, fmt.Sprintf("%s in %s", req.DefaultAction, req.Timeout)); err != nil {
+		s.ServerApprover.Cancel(req)
+		return nil, err
+	}
+
+	return decisions, nil
+}
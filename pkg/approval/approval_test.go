@@ -0,0 +1,159 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package approval
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestNewRequest(t *testing.T) {
+	req := NewRequest("hello", "artd", "new version", schemas.Approval{})
+
+	expected := Request{
+		ID:            "hello/artd",
+		Application:   "hello",
+		Stack:         "artd",
+		Message:       "new version",
+		Timeout:       constants.DefaultApprovalTimeout,
+		DefaultAction: constants.RejectAction,
+	}
+
+	if diff := deep.Equal(req, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestWait(t *testing.T) {
+	req := Request{ID: "hello/artd", Timeout: 50 * time.Millisecond, DefaultAction: constants.ApproveAction}
+
+	testData := []struct {
+		name     string
+		approver Approver
+		resolve  string
+		expected Decision
+	}{
+		{
+			name:     "default approver",
+			approver: DefaultApprover{},
+			expected: Decision{Action: constants.ApproveAction},
+		},
+		{
+			name:     "rejected through server",
+			approver: ServerApprover{Registry: NewRegistry()},
+			resolve:  constants.RejectAction,
+			expected: Decision{Action: constants.RejectAction},
+		},
+		{
+			name:     "timed out",
+			approver: ServerApprover{Registry: NewRegistry()},
+			expected: Decision{Action: constants.ApproveAction, TimedOut: true},
+		},
+	}
+
+	for _, td := range testData {
+		if len(td.resolve) > 0 {
+			registry := td.approver.(ServerApprover).Registry
+			go func(action string) {
+				for len(registry.List()) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				if err := registry.Resolve(req.ID, action); err != nil {
+					t.Error(err)
+				}
+			}(td.resolve)
+		}
+
+		decision, err := Wait(td.approver, req)
+		if err != nil {
+			t.Fatalf("%s: %v", td.name, err)
+		}
+
+		if diff := deep.Equal(decision, td.expected); diff != nil {
+			t.Errorf("%s: %v", td.name, diff)
+		}
+
+		if s, ok := td.approver.(ServerApprover); ok && len(s.Registry.List()) != 0 {
+			t.Errorf("%s: request remains in registry", td.name)
+		}
+	}
+}
+
+func TestCLIApprover(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	approver := CLIApprover{console: newConsole(reader)}
+	req := Request{ID: "hello/artd", Timeout: 20 * time.Millisecond, DefaultAction: constants.RejectAction}
+
+	// prompt which timed out should not keep the terminal or consume the next answer
+	decision, err := Wait(approver, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(decision, Decision{Action: constants.RejectAction, TimedOut: true}); diff != nil {
+		t.Error(diff)
+	}
+
+	req.Timeout = time.Second
+	go func() {
+		if _, err := writer.Write([]byte("y\n")); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	decision, err = Wait(approver, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(decision, Decision{Action: constants.ApproveAction}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	req := Request{ID: "hello/artd"}
+
+	if _, err := r.Add(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Add(req); err == nil {
+		t.Error("duplicated request should not be added")
+	}
+
+	if err := r.Resolve(req.ID, "skip"); err == nil || err.Error() != "action should be approve or reject: skip" {
+		t.Errorf("invalid action should not be accepted: %v", err)
+	}
+
+	if err := r.Resolve("hello/unknown", constants.ApproveAction); err == nil || err.Error() != "no approval is requested: hello/unknown" {
+		t.Errorf("unknown request should not be resolved: %v", err)
+	}
+
+	if err := r.Resolve(req.ID, constants.ApproveAction); err != nil {
+		t.Error(err)
+	}
+}
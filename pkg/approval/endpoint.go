@@ -0,0 +1,230 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package approval
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	Logger "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// shutdownTimeout is how long the approval API waits for the response of the last decision before it stops
+const shutdownTimeout = 5 * time.Second
+
+// endpoints are approval APIs served by this process, keyed by listen address
+var endpoints = &listeners{servers: map[string]*endpoint{}}
+
+// Body is the request body of approval API
+type Body struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+}
+
+// Handler serves decisions of pending requests in the registry
+type Handler struct {
+	Registry *Registry
+	Logger   *Logger.Logger
+}
+
+// listeners keeps approval APIs which are open while any request is waiting
+type listeners struct {
+	mu      sync.Mutex
+	servers map[string]*endpoint
+}
+
+// endpoint is an approval API on one address and requests waiting through it
+type endpoint struct {
+	server   *http.Server
+	requests map[string]bool
+}
+
+// Approvals lists pending approval requests with GET, and approves or rejects one with POST
+func (h Handler) Approvals(w http.ResponseWriter, req *http.Request) {
+	if err := checkApprovalToken(req); err != nil {
+		h.Logger.Warnf("%s %s", req.RemoteAddr, err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.Registry.List()); err != nil {
+			h.Logger.Error(err.Error())
+		}
+	case http.MethodPost:
+		body := Body{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.Registry.Resolve(body.ID, body.Action); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.Logger.Infof("%s %s %s", req.RemoteAddr, body.Action, body.ID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// SlackActions receives the decision from buttons of slack approval message
+func (h Handler) SlackActions(w http.ResponseWriter, req *http.Request) {
+	payload, err := readSlackPayload(req)
+	if err != nil {
+		h.Logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	callback := slack.InteractionCallback{}
+	if err := json.Unmarshal([]byte(payload), &callback); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		if err := h.Registry.Resolve(action.Value, action.ActionID); err != nil {
+			h.Logger.Warn(err.Error())
+			fmt.Fprintf(w, "%s", err.Error())
+			return
+		}
+		h.Logger.Infof("%s %s %s", callback.User.Name, action.ActionID, action.Value)
+		fmt.Fprintf(w, "%s: %s by %s", action.Value, action.ActionID, callback.User.Name)
+	}
+}
+
+// open starts the approval API on the address unless it is already served for another request
+func (l *listeners) open(addr, id string, h Handler) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.servers[addr]; ok {
+		e.requests[id] = true
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("approval API cannot listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/approvals", h.Approvals)
+	mux.HandleFunc("/slack/actions", h.SlackActions)
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.Logger.Errorf("approval API on %s is stopped: %s", addr, err.Error())
+		}
+	}()
+
+	l.servers[addr] = &endpoint{server: server, requests: map[string]bool{id: true}}
+	h.Logger.Infof("Approval API is served on %s", addr)
+
+	return nil
+}
+
+// close stops the approval API on the address after no request is waiting through it
+func (l *listeners) close(addr, id string) error {
+	l.mu.Lock()
+	e, ok := l.servers[addr]
+	if !ok {
+		l.mu.Unlock()
+		return nil
+	}
+
+	delete(e.requests, id)
+	if len(e.requests) > 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	delete(l.servers, addr)
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return e.server.Shutdown(ctx)
+}
+
+// checkApprovalToken checks if the request has the shared token of approval API in bearer authorization header.
+// Every request is rejected if the token is not set.
+func checkApprovalToken(req *http.Request) error {
+	token := os.Getenv(constants.ApprovalToken)
+	if len(token) == 0 {
+		return fmt.Errorf("approval API is disabled because %s is not set", constants.ApprovalToken)
+	}
+
+	given := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		return errors.New("invalid approval token")
+	}
+
+	return nil
+}
+
+// readSlackPayload verifies the signature of request from slack and returns the payload.
+// Every request is rejected if the signing secret is not set.
+func readSlackPayload(req *http.Request) (string, error) {
+	secret := os.Getenv(constants.SlackSigningSecret)
+	if len(secret) == 0 {
+		return "", fmt.Errorf("slack actions are disabled because %s is not set", constants.SlackSigningSecret)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+
+	verifier, err := slack.NewSecretsVerifier(req.Header, secret)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := verifier.Write(body); err != nil {
+		return "", err
+	}
+
+	if err := verifier.Ensure(); err != nil {
+		return "", err
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", err
+	}
+
+	return values.Get("payload"), nil
+}
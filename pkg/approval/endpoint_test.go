@@ -0,0 +1,117 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package approval
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+func TestApprovalsRequireToken(t *testing.T) {
+	h := Handler{Registry: NewRegistry(), Logger: Logger.New()}
+	testData := []struct {
+		name     string
+		token    string
+		header   string
+		expected int
+	}{
+		{name: "token is not set", token: "", header: "", expected: http.StatusUnauthorized},
+		{name: "token is not given", token: "secret", header: "", expected: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer wrong", expected: http.StatusUnauthorized},
+		{name: "valid token", token: "secret", header: "Bearer secret", expected: http.StatusOK},
+	}
+
+	for _, td := range testData {
+		t.Setenv(constants.ApprovalToken, td.token)
+
+		req := httptest.NewRequest(http.MethodGet, "/approvals", nil)
+		if len(td.header) > 0 {
+			req.Header.Set("Authorization", td.header)
+		}
+
+		w := httptest.NewRecorder()
+		h.Approvals(w, req)
+		if w.Code != td.expected {
+			t.Errorf("%s: expected %d, got %d", td.name, td.expected, w.Code)
+		}
+	}
+}
+
+func TestSlackActionsRequireSigningSecret(t *testing.T) {
+	h := Handler{Registry: NewRegistry(), Logger: Logger.New()}
+	t.Setenv(constants.SlackSigningSecret, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/slack/actions", strings.NewReader(`payload={"type":"block_actions","actions":[{"action_id":"approve","value":"hello/artd"}]}`))
+	w := httptest.NewRecorder()
+	h.SlackActions(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("slack action without signing secret should be rejected: %d", w.Code)
+	}
+}
+
+func TestServerApproverServesApprovals(t *testing.T) {
+	t.Setenv(constants.ApprovalToken, "secret")
+
+	// find a free port for approval API
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	approver := ServerApprover{Registry: NewRegistry(), Address: addr, Logger: Logger.New()}
+	req := Request{ID: "hello/artd", Timeout: 5 * time.Second, DefaultAction: constants.RejectAction}
+
+	go func() {
+		for len(approver.Registry.List()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		r, _ := http.NewRequest(http.MethodPost, "http://"+addr+"/approvals", strings.NewReader(`{"id": "hello/artd", "action": "approve"}`))
+		r.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}()
+
+	decision, err := Wait(approver, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(decision, Decision{Action: constants.ApproveAction}); diff != nil {
+		t.Error(diff)
+	}
+
+	// approval API stops after no request is waiting
+	if _, err := http.Get("http://" + addr + "/approvals"); err == nil {
+		t.Error("approval API should be stopped after the decision")
+	}
+}
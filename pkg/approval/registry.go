@@ -0,0 +1,107 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package approval
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// Pending is the registry shared by deployments in this process and its approval API
+var Pending = NewRegistry()
+
+// Registry keeps approval requests which are waiting for the decision
+type Registry struct {
+	mu       sync.Mutex
+	requests map[string]Request
+	channels map[string]chan string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		requests: map[string]Request{},
+		channels: map[string]chan string{},
+	}
+}
+
+// Add registers the request and returns the channel which receives the decision
+func (r *Registry) Add(req Request) (<-chan string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.requests[req.ID]; ok {
+		return nil, fmt.Errorf("approval is already requested: %s", req.ID)
+	}
+
+	decisions := make(chan string, 1)
+	r.requests[req.ID] = req
+	r.channels[req.ID] = decisions
+
+	return decisions, nil
+}
+
+// Resolve sends the decision to the request and removes it from the registry
+func (r *Registry) Resolve(id, action string) error {
+	if !tool.IsStringInArray(action, []string{constants.ApproveAction, constants.RejectAction}) {
+		return fmt.Errorf("action should be approve or reject: %s", action)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	decisions, ok := r.channels[id]
+	if !ok {
+		return fmt.Errorf("no approval is requested: %s", id)
+	}
+
+	decisions <- action
+	delete(r.requests, id)
+	delete(r.channels, id)
+
+	return nil
+}
+
+// Remove deletes the request without decision
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.requests, id)
+	delete(r.channels, id)
+}
+
+// List returns pending requests sorted by id
+func (r *Registry) List() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ret []Request
+	for _, req := range r.requests {
+		ret = append(ret, req)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID < ret[j].ID
+	})
+
+	return ret
+}
@@ -27,24 +27,38 @@ import (
 
 type Client struct {
 	Region            string
-	EC2Service        EC2Client
-	ELBV2Service      ELBV2Client
-	ELBService        ELBClient
-	CloudWatchService CloudWatchClient
-	SSMService        SSMClient
+	EC2Service        EC2API
+	ELBV2Service      ELBV2API
+	ELBService        ELBAPI
+	CloudWatchService CloudWatchAPI
+	SSMService        SSMAPI
 }
 
 type MetricClient struct {
 	Region            string
-	DynamoDBService   DynamoDBClient
-	CloudWatchService CloudWatchClient
+	DynamoDBService   DynamoDBAPI
+	CloudWatchService CloudWatchAPI
 }
 
 type ManifestClient struct {
 	Region    string
-	S3Service S3Client
+	S3Service S3API
 }
 
+// ClientFactory creates a Client for the region.
+// BootstrapServices is the default and tests can swap in an in-memory backend.
+type ClientFactory func(region string, assumeRole string) Client
+
+var (
+	_ EC2API        = EC2Client{}
+	_ ELBV2API      = ELBV2Client{}
+	_ ELBAPI        = ELBClient{}
+	_ CloudWatchAPI = CloudWatchClient{}
+	_ SSMAPI        = SSMClient{}
+	_ DynamoDBAPI   = DynamoDBClient{}
+	_ S3API         = S3Client{}
+)
+
 // GetAwsSession generates new aws session
 func GetAwsSession() *session.Session {
 	profile := viper.GetString("profile")
@@ -18,6 +18,8 @@ package aws
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -32,6 +34,18 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
+// CloudWatchAPI is the set of CloudWatch operations used by goployer
+type CloudWatchAPI interface {
+	CreateScalingAlarms(asgName string, alarms []schemas.AlarmConfigs, policyArns map[string]string) error
+	CreateCloudWatchAlarm(asgName string, alarm schemas.AlarmConfigs) error
+	GetTargetGroupRequestStatistics(tgs []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error)
+	GetLoadBalancerRequestStatistics(loadbalancers []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error)
+	GetOneDayStatisticsOfTargetGroup(tg string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error)
+	GetOneDayStatisticsOfLoadBalancer(lb string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error)
+	GetMetricValue(namespace, metric, statistic string, dimensions map[string]string, startTime, endTime time.Time) (float64, error)
+	GetAlarmStates(names []string) (map[string]string, error)
+}
+
 type CloudWatchClient struct {
 	Client *cloudwatch.CloudWatch
 }
@@ -344,6 +358,107 @@ func (c CloudWatchClient) GetOneDayStatisticsOfLoadBalancer(lb string, startTime
 	return ret, sum, nil
 }
 
+// GetMetricValue returns a single value of metric between start time and end time.
+// Sum and SampleCount of each period are added up, and the largest value is used for other statistics.
+func (c CloudWatchClient) GetMetricValue(namespace, metric, statistic string, dimensions map[string]string, startTime, endTime time.Time) (float64, error) {
+	var keys []string
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var dims []*cloudwatch.Dimension
+	for _, k := range keys {
+		dims = append(dims, &cloudwatch.Dimension{
+			Name:  aws.String(k),
+			Value: aws.String(dimensions[k]),
+		})
+	}
+
+	// period should be a multiple of 60 seconds
+	period := int64(math.Ceil(endTime.Sub(startTime).Minutes())) * 60
+	if period < 60 {
+		period = 60
+	}
+
+	input := &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(startTime),
+		EndTime:   aws.Time(endTime),
+		MetricDataQueries: []*cloudwatch.MetricDataQuery{
+			{
+				Id: aws.String("canary"),
+				MetricStat: &cloudwatch.MetricStat{
+					Metric: &cloudwatch.Metric{
+						Dimensions: dims,
+						MetricName: aws.String(metric),
+						Namespace:  aws.String(namespace),
+					},
+					Period: aws.Int64(period),
+					Stat:   aws.String(statistic),
+				},
+			},
+		},
+	}
+
+	result, err := c.Client.GetMetricData(input)
+	if err != nil {
+		return 0, err
+	}
+
+	// If no result exists, then set it to zero
+	if len(result.MetricDataResults) == 0 {
+		return 0, nil
+	}
+
+	ret := float64(0)
+	for i, v := range result.MetricDataResults[0].Values {
+		if statistic == "Sum" || statistic == "SampleCount" {
+			ret += *v
+			continue
+		}
+
+		if i == 0 || *v > ret {
+			ret = *v
+		}
+	}
+
+	return ret, nil
+}
+
+// GetAlarmStates returns states of alarms with names. Alarms which do not exist are not included.
+func (c CloudWatchClient) GetAlarmStates(names []string) (map[string]string, error) {
+	ret := map[string]string{}
+	input := &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: aws.StringSlice(names),
+	}
+
+	err := c.Client.DescribeAlarmsPages(input, func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+		for _, alarm := range page.MetricAlarms {
+			ret[*alarm.AlarmName] = *alarm.StateValue
+		}
+
+		for _, alarm := range page.CompositeAlarms {
+			ret[*alarm.AlarmName] = *alarm.StateValue
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// TargetGroupDimension returns the value of TargetGroup dimension from target group ARN
+func TargetGroupDimension(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+// LoadBalancerDimension returns the value of LoadBalancer dimension from load balancer ARN
+func LoadBalancerDimension(arn string) string {
+	return strings.TrimPrefix(arn[strings.LastIndex(arn, ":")+1:], "loadbalancer/")
+}
+
 // CheckMetricTimeValidation validates metric time
 func CheckMetricTimeValidation(startTime time.Time, endTime time.Time) bool {
 	return endTime.Sub(startTime) > 0
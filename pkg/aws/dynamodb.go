@@ -18,6 +18,7 @@ package aws
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -31,6 +32,18 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
+// DynamoDBAPI is the set of DynamoDB operations used for deployment metrics
+type DynamoDBAPI interface {
+	CheckTableExists(tableName string) (bool, error)
+	CreateTable(tableName string) error
+	MakeRecord(stack, config, tags string, asg string, tableName string, status, timezone string, additionalFields map[string]string) error
+	UpdateRecord(updateKey, asg string, tableName string, status, timezone string, updateFields map[string]interface{}) error
+	GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error)
+	UpdateStatistics(asg string, tableName, timezone string, updateFields map[string]interface{}) error
+	PutLock(tableName, key, holder string, acquiredAt, expiresAt time.Time) error
+	DeleteLock(tableName, key, holder string) error
+}
+
 type DynamoDBClient struct {
 	Client *dynamodb.DynamoDB
 }
@@ -321,3 +334,68 @@ func (d DynamoDBClient) UpdateStatistics(asg string, tableName, timezone string,
 
 	return nil
 }
+
+// PutLock writes the lock item if it does not exist, it is expired or it is held by the same holder
+func (d DynamoDBClient) PutLock(tableName, key, holder string, acquiredAt, expiresAt time.Time) error {
+	input := &dynamodb.PutItemInput{
+		ConditionExpression: aws.String("attribute_not_exists(#K) OR #E < :now OR #H = :holder"),
+		ExpressionAttributeNames: map[string]*string{
+			"#K": aws.String(constants.HashKey),
+			"#E": aws.String(constants.LockExpiresAtKey),
+			"#H": aws.String(constants.LockHolderKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {
+				N: aws.String(strconv.FormatInt(time.Now().Unix(), 10)),
+			},
+			":holder": {
+				S: aws.String(holder),
+			},
+		},
+		Item: map[string]*dynamodb.AttributeValue{
+			constants.HashKey: {
+				S: aws.String(key),
+			},
+			constants.LockHolderKey: {
+				S: aws.String(holder),
+			},
+			constants.LockAcquiredAtKey: {
+				S: aws.String(acquiredAt.UTC().Format(time.RFC3339)),
+			},
+			constants.LockExpiresAtKey: {
+				N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10)),
+			},
+		},
+		TableName: aws.String(tableName),
+	}
+
+	_, err := d.Client.PutItem(input)
+	return err
+}
+
+// DeleteLock deletes the lock item of the holder. If holder is empty, the lock is deleted regardless of its holder.
+func (d DynamoDBClient) DeleteLock(tableName, key, holder string) error {
+	input := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.HashKey: {
+				S: aws.String(key),
+			},
+		},
+		TableName: aws.String(tableName),
+	}
+
+	if len(holder) > 0 {
+		input.ConditionExpression = aws.String("#H = :holder")
+		input.ExpressionAttributeNames = map[string]*string{
+			"#H": aws.String(constants.LockHolderKey),
+		}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":holder": {
+				S: aws.String(holder),
+			},
+		}
+	}
+
+	_, err := d.Client.DeleteItem(input)
+	return err
+}
@@ -37,6 +37,61 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
+// EC2API is the set of EC2, autoscaling and KMS operations used by goployer
+type EC2API interface {
+	GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error)
+	GetMatchingLaunchTemplate(ltID string) (*ec2.LaunchTemplateVersion, error)
+	GetSecurityGroupDetails(sgIds []*string) ([]*ec2.SecurityGroup, error)
+	DeleteLaunchConfigurations(asgName string) error
+	DeleteLaunchTemplates(asgName string) error
+	DeleteAutoscalingSet(asgName string) error
+	GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error)
+	CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) bool
+	ValidateSecurityGroupsConfig(securityGroups []*string, primaryENI *schemas.ENIConfig, secondaryENIs []*schemas.ENIConfig) error
+	CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions *schemas.InstanceMarketOptions, detailedMonitoringEnabled bool, primaryENI *schemas.ENIConfig, secondaryENIs []*schemas.ENIConfig, tags []string, httpPutResponseHopLimit int64) error
+	GetSecurityGroupList(vpc string, sgList []string) ([]*string, error)
+	MakeBlockDevices(blocks []schemas.BlockDevice) []*autoscaling.BlockDeviceMapping
+	MakeLaunchTemplateBlockDeviceMappings(blocks []schemas.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest
+	GetVPCId(vpc string) (string, error)
+	CreateAutoScalingGroup(name, launchTemplateName, healthcheckType string, healthcheckGracePeriod int64, capacity schemas.Capacity, loadbalancers, availabilityZones []string, targetGroupArns, terminationPolicies []*string, tags []*autoscaling.Tag, subnets []string, mixedInstancePolicy schemas.MixedInstancesPolicy, hooks []*autoscaling.LifecycleHookSpecification, warmPool *schemas.WarmPool) error
+	PutWarmPool(asg string, warmPool schemas.WarmPool) error
+	DeleteWarmPool(asg string) error
+	DescribeWarmPool(asg string) (*autoscaling.WarmPoolConfiguration, []*autoscaling.Instance, error)
+	GetAvailabilityZones(vpc string, azs []string) ([]string, error)
+	GetSubnets(vpc string, usePublicSubnets bool, azs []string) ([]string, error)
+	UpdateAutoScalingGroupSize(asg string, min, max, desired, retry int64) (int64, error)
+	CreateScalingPolicy(policy schemas.ScalePolicy, asgName string) (*string, error)
+	EnableMetrics(asgName string) error
+	GenerateLifecycleHooks(hooks schemas.LifecycleHooks) []*autoscaling.LifecycleHookSpecification
+	CompleteLifecycleAction(asg, hookName, instanceID, result string) error
+	GetTargetGroups(asgName string) ([]*string, error)
+	UpdateAutoScalingGroup(asg string, capacity schemas.Capacity) error
+	CreateScheduledActions(asg string, actions []schemas.ScheduledAction) error
+	AttachAsgToTargetGroups(asg string, targetGroups []*string) error
+	DetachAsgFromTargetGroups(asg string, targetGroups []*string) error
+	CreateSecurityGroup(sgName string, vpcID *string) (*string, error)
+	GetSecurityGroup(sgName string) (*string, error)
+	UpdateInboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error
+	UpdateInboundRulesWithGroup(sgID, protocol, description string, fromSg *string, fromPort, toPort int64) error
+	UpdateOutboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error
+	DeleteSecurityGroup(sg string) error
+	RevokeInboundRulesWithGroup(sgID, protocol string, fromSg *string, fromPort, toPort int64) error
+	DeleteCanaryTag(asg string) error
+	DescribeInstances(instanceIds []*string) ([]*ec2.Instance, error)
+	ModifyNetworkInterfaces(eni *string, groups []*string) error
+	CreateNewLaunchTemplateVersion(lt *ec2.LaunchTemplateVersion, sgs []*string) (*ec2.LaunchTemplateVersion, error)
+	UpdateAutoScalingLaunchTemplate(asg string, lt *ec2.LaunchTemplateVersion) error
+	DetachLoadBalancerTargetGroup(asg string, tgARNs []*string) error
+	StartInstanceRefresh(name *string, instanceWarmup, minHealthyPercentage int64) (*string, error)
+	CreateLaunchTemplateVersionWithImage(ltID, sourceVersion, ami, instanceType, userdata string, sgs []*string) (*ec2.LaunchTemplateVersion, error)
+	StartLaunchTemplateRefresh(name *string, lt *autoscaling.LaunchTemplateSpecification, preferences *autoscaling.RefreshPreferences) (*string, error)
+	RollbackInstanceRefresh(name *string) (*string, error)
+	DescribeInstanceRefreshes(name, id *string) (*autoscaling.InstanceRefresh, error)
+	DescribeScalingActivities(asg string) ([]*autoscaling.Activity, error)
+	DescribeInstanceTypes() ([]string, error)
+	DescribeAMIArchitecture(amiID string) (string, error)
+}
+
 type EC2Client struct {
 	Client    *ec2.EC2
 	AsClient  *autoscaling.AutoScaling
@@ -722,7 +777,8 @@ func (e EC2Client) CreateAutoScalingGroup(name, launchTemplateName, healthcheckT
 	tags []*autoscaling.Tag,
 	subnets []string,
 	mixedInstancePolicy schemas.MixedInstancesPolicy,
-	hooks []*autoscaling.LifecycleHookSpecification) error {
+	hooks []*autoscaling.LifecycleHookSpecification,
+	warmPool *schemas.WarmPool) error {
 	lt := autoscaling.LaunchTemplateSpecification{
 		LaunchTemplateName: aws.String(launchTemplateName),
 	}
@@ -749,32 +805,7 @@ func (e EC2Client) CreateAutoScalingGroup(name, launchTemplateName, healthcheckT
 	}
 
 	if mixedInstancePolicy.Enabled {
-		input.MixedInstancesPolicy = &autoscaling.MixedInstancesPolicy{
-			InstancesDistribution: &autoscaling.InstancesDistribution{
-				OnDemandBaseCapacity:   aws.Int64(mixedInstancePolicy.OnDemandBaseCapacity),
-				SpotAllocationStrategy: aws.String(mixedInstancePolicy.SpotAllocationStrategy),
-				SpotInstancePools:      aws.Int64(mixedInstancePolicy.SpotInstancePools),
-				SpotMaxPrice:           aws.String(mixedInstancePolicy.SpotMaxPrice),
-			},
-			LaunchTemplate: &autoscaling.LaunchTemplate{
-				LaunchTemplateSpecification: &lt,
-			},
-		}
-
-		if mixedInstancePolicy.OnDemandPercentage >= 0 {
-			input.MixedInstancesPolicy.InstancesDistribution.OnDemandPercentageAboveBaseCapacity = aws.Int64(mixedInstancePolicy.OnDemandPercentage)
-		}
-
-		if len(mixedInstancePolicy.Override) != 0 {
-			var overrides []*autoscaling.LaunchTemplateOverrides
-			for _, o := range mixedInstancePolicy.Override {
-				overrides = append(overrides, &autoscaling.LaunchTemplateOverrides{
-					InstanceType: aws.String(o),
-				})
-			}
-
-			input.MixedInstancesPolicy.LaunchTemplate.Overrides = overrides
-		}
+		input.MixedInstancesPolicy = MakeMixedInstancesPolicy(&lt, mixedInstancePolicy)
 	} else {
 		input.LaunchTemplate = &lt
 	}
@@ -789,9 +820,177 @@ func (e EC2Client) CreateAutoScalingGroup(name, launchTemplateName, healthcheckT
 	}
 
 	Logger.Info("Successfully create new autoscaling group : ", name)
+
+	if warmPool != nil {
+		if err := e.PutWarmPool(name, *warmPool); err != nil {
+			return err
+		}
+		Logger.Info("Successfully create warm pool : ", name)
+	}
+
 	return nil
 }
 
+// PutWarmPool creates or updates the warm pool of autoscaling group
+func (e EC2Client) PutWarmPool(asg string, warmPool schemas.WarmPool) error {
+	poolState := warmPool.PoolState
+	if len(poolState) == 0 {
+		poolState = constants.DefaultWarmPoolState
+	}
+
+	input := &autoscaling.PutWarmPoolInput{
+		AutoScalingGroupName: aws.String(asg),
+		MinSize:              aws.Int64(warmPool.MinSize),
+		PoolState:            aws.String(poolState),
+		InstanceReusePolicy: &autoscaling.InstanceReusePolicy{
+			ReuseOnScaleIn: aws.Bool(warmPool.ReuseOnScaleIn),
+		},
+	}
+
+	if warmPool.MaxPreparedCapacity > 0 {
+		input.MaxGroupPreparedCapacity = aws.Int64(warmPool.MaxPreparedCapacity)
+	}
+
+	_, err := e.AsClient.PutWarmPool(input)
+	return err
+}
+
+// DeleteWarmPool deletes the warm pool of autoscaling group with instances in it
+func (e EC2Client) DeleteWarmPool(asg string) error {
+	input := &autoscaling.DeleteWarmPoolInput{
+		AutoScalingGroupName: aws.String(asg),
+		ForceDelete:          aws.Bool(true),
+	}
+
+	_, err := e.AsClient.DeleteWarmPool(input)
+	return err
+}
+
+// DescribeWarmPool retrieves the configuration and instances of warm pool
+func (e EC2Client) DescribeWarmPool(asg string) (*autoscaling.WarmPoolConfiguration, []*autoscaling.Instance, error) {
+	input := &autoscaling.DescribeWarmPoolInput{
+		AutoScalingGroupName: aws.String(asg),
+	}
+
+	var instances []*autoscaling.Instance
+	var config *autoscaling.WarmPoolConfiguration
+	for {
+		result, err := e.AsClient.DescribeWarmPool(input)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		config = result.WarmPoolConfiguration
+		instances = append(instances, result.Instances...)
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return config, instances, nil
+}
+
+// CompleteLifecycleAction completes the lifecycle action of instance waiting in the lifecycle hook with CONTINUE or ABANDON
+func (e EC2Client) CompleteLifecycleAction(asg, hookName, instanceID, result string) error {
+	input := &autoscaling.CompleteLifecycleActionInput{
+		AutoScalingGroupName:  aws.String(asg),
+		LifecycleHookName:     aws.String(hookName),
+		InstanceId:            aws.String(instanceID),
+		LifecycleActionResult: aws.String(result),
+	}
+
+	_, err := e.AsClient.CompleteLifecycleAction(input)
+	return err
+}
+
+// MakeMixedInstancesPolicy creates mixed instances policy of autoscaling group with instance type or attribute-based overrides
+func MakeMixedInstancesPolicy(lt *autoscaling.LaunchTemplateSpecification, mixedInstancePolicy schemas.MixedInstancesPolicy) *autoscaling.MixedInstancesPolicy {
+	policy := &autoscaling.MixedInstancesPolicy{
+		InstancesDistribution: &autoscaling.InstancesDistribution{
+			OnDemandBaseCapacity:   aws.Int64(mixedInstancePolicy.OnDemandBaseCapacity),
+			SpotAllocationStrategy: aws.String(mixedInstancePolicy.SpotAllocationStrategy),
+			SpotInstancePools:      aws.Int64(mixedInstancePolicy.SpotInstancePools),
+			SpotMaxPrice:           aws.String(mixedInstancePolicy.SpotMaxPrice),
+		},
+		LaunchTemplate: &autoscaling.LaunchTemplate{
+			LaunchTemplateSpecification: lt,
+		},
+	}
+
+	if mixedInstancePolicy.OnDemandPercentage >= 0 {
+		policy.InstancesDistribution.OnDemandPercentageAboveBaseCapacity = aws.Int64(mixedInstancePolicy.OnDemandPercentage)
+	}
+
+	if mixedInstancePolicy.InstanceRequirements != nil {
+		policy.LaunchTemplate.Overrides = []*autoscaling.LaunchTemplateOverrides{
+			{
+				InstanceRequirements: makeInstanceRequirements(*mixedInstancePolicy.InstanceRequirements),
+			},
+		}
+
+		return policy
+	}
+
+	if len(mixedInstancePolicy.Override) != 0 {
+		var overrides []*autoscaling.LaunchTemplateOverrides
+		for _, o := range mixedInstancePolicy.Override {
+			override := &autoscaling.LaunchTemplateOverrides{
+				InstanceType: aws.String(o),
+			}
+
+			if weight, ok := mixedInstancePolicy.WeightedCapacity[o]; ok {
+				override.WeightedCapacity = aws.String(strconv.FormatInt(weight, 10))
+			}
+
+			overrides = append(overrides, override)
+		}
+
+		policy.LaunchTemplate.Overrides = overrides
+	}
+
+	return policy
+}
+
+// makeInstanceRequirements converts instance requirements of manifest to the request of autoscaling group
+func makeInstanceRequirements(requirements schemas.InstanceRequirements) *autoscaling.InstanceRequirements {
+	ret := &autoscaling.InstanceRequirements{
+		VCpuCount: &autoscaling.VCpuCountRequest{
+			Min: aws.Int64(requirements.VCpuCount.Min),
+		},
+		MemoryMiB: &autoscaling.MemoryMiBRequest{
+			Min: aws.Int64(requirements.MemoryMiB.Min),
+		},
+	}
+
+	if requirements.VCpuCount.Max > 0 {
+		ret.VCpuCount.Max = aws.Int64(requirements.VCpuCount.Max)
+	}
+
+	if requirements.MemoryMiB.Max > 0 {
+		ret.MemoryMiB.Max = aws.Int64(requirements.MemoryMiB.Max)
+	}
+
+	if len(requirements.CPUManufacturers) > 0 {
+		ret.CpuManufacturers = aws.StringSlice(requirements.CPUManufacturers)
+	}
+
+	if len(requirements.BurstablePerformance) > 0 {
+		ret.BurstablePerformance = aws.String(requirements.BurstablePerformance)
+	}
+
+	if len(requirements.InstanceGenerations) > 0 {
+		ret.InstanceGenerations = aws.StringSlice(requirements.InstanceGenerations)
+	}
+
+	if requirements.SpotMaxPricePercentage > 0 {
+		ret.SpotMaxPricePercentageOverLowestPrice = aws.Int64(requirements.SpotMaxPricePercentage)
+	}
+
+	return ret
+}
+
 // GetAvailabilityZones get all available availability zones
 func (e EC2Client) GetAvailabilityZones(vpc string, azs []string) ([]string, error) {
 	var ret []string
@@ -1284,11 +1483,13 @@ func (e EC2Client) DescribeInstances(instanceIds []*string) ([]*ec2.Instance, er
 		return nil, err
 	}
 
-	if len(result.Reservations) == 0 {
-		return nil, nil
+	// instances launched by autoscaling group belong to different reservations
+	var ret []*ec2.Instance
+	for _, reservation := range result.Reservations {
+		ret = append(ret, reservation.Instances...)
 	}
 
-	return result.Reservations[0].Instances, nil
+	return ret, nil
 }
 
 // ModifyNetworkInterfaces modifies network interface attributes
@@ -1376,6 +1577,65 @@ func (e EC2Client) StartInstanceRefresh(name *string, instanceWarmup, minHealthy
 	return result.InstanceRefreshId, nil
 }
 
+// CreateLaunchTemplateVersionWithImage creates new version of launch template with image, instance type and userdata
+func (e EC2Client) CreateLaunchTemplateVersionWithImage(ltID, sourceVersion, ami, instanceType, userdata string, sgs []*string) (*ec2.LaunchTemplateVersion, error) {
+	data := &ec2.RequestLaunchTemplateData{
+		ImageId:      aws.String(ami),
+		InstanceType: aws.String(instanceType),
+		UserData:     aws.String(userdata),
+	}
+
+	if len(sgs) > 0 {
+		data.SecurityGroupIds = sgs
+	}
+
+	input := &ec2.CreateLaunchTemplateVersionInput{
+		LaunchTemplateData: data,
+		LaunchTemplateId:   aws.String(ltID),
+		SourceVersion:      aws.String(sourceVersion),
+		VersionDescription: aws.String("Instance Refresh"),
+	}
+
+	result, err := e.Client.CreateLaunchTemplateVersion(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.LaunchTemplateVersion, nil
+}
+
+// StartLaunchTemplateRefresh starts instance refresh which replaces instances with the launch template
+func (e EC2Client) StartLaunchTemplateRefresh(name *string, lt *autoscaling.LaunchTemplateSpecification, preferences *autoscaling.RefreshPreferences) (*string, error) {
+	input := &autoscaling.StartInstanceRefreshInput{
+		AutoScalingGroupName: name,
+		DesiredConfiguration: &autoscaling.DesiredConfiguration{
+			LaunchTemplate: lt,
+		},
+		Preferences: preferences,
+	}
+
+	result, err := e.AsClient.StartInstanceRefresh(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.InstanceRefreshId, nil
+}
+
+// RollbackInstanceRefresh rolls back the in-progress instance refresh to the previous configuration
+func (e EC2Client) RollbackInstanceRefresh(name *string) (*string, error) {
+	input := &autoscaling.RollbackInstanceRefreshInput{
+		AutoScalingGroupName: name,
+	}
+
+	result, err := e.AsClient.RollbackInstanceRefresh(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.InstanceRefreshId, nil
+}
+
 // DescribeInstanceRefreshes describes instance refresh information
 func (e EC2Client) DescribeInstanceRefreshes(name, id *string) (*autoscaling.InstanceRefresh, error) {
 	input := &autoscaling.DescribeInstanceRefreshesInput{
@@ -1405,6 +1665,21 @@ func (e EC2Client) DescribeInstanceRefreshes(name, id *string) (*autoscaling.Ins
 	return targets[0], nil
 }
 
+// DescribeScalingActivities returns recent scaling activities of autoscaling group, newest first
+func (e EC2Client) DescribeScalingActivities(asg string) ([]*autoscaling.Activity, error) {
+	input := &autoscaling.DescribeScalingActivitiesInput{
+		AutoScalingGroupName: aws.String(asg),
+		MaxRecords:           aws.Int64(100),
+	}
+
+	result, err := e.AsClient.DescribeScalingActivities(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Activities, nil
+}
+
 // aws ec2 describe-instance-types --filters Name=processor-info.supported-architecture,Values=arm64 --query "InstanceTypes[*].InstanceType"
 func (e EC2Client) DescribeInstanceTypes() ([]string, error) {
 	var instanceTypeList []string
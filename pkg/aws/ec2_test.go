@@ -353,6 +353,63 @@ func TestRegionConfigHopLimit(t *testing.T) {
 	}
 }
 
+func TestMakeMixedInstancesPolicy(t *testing.T) {
+	lt := &autoscaling.LaunchTemplateSpecification{LaunchTemplateName: aws.String("hello-dev_apnortheast2-v001")}
+
+	tests := []struct {
+		name     string
+		policy   schemas.MixedInstancesPolicy
+		expected []*autoscaling.LaunchTemplateOverrides
+	}{
+		{
+			name: "Instance types with weights",
+			policy: schemas.MixedInstancesPolicy{
+				Enabled:          true,
+				Override:         []string{"c5.large", "c5.xlarge"},
+				WeightedCapacity: map[string]int64{"c5.large": 1, "c5.xlarge": 2},
+			},
+			expected: []*autoscaling.LaunchTemplateOverrides{
+				{InstanceType: aws.String("c5.large"), WeightedCapacity: aws.String("1")},
+				{InstanceType: aws.String("c5.xlarge"), WeightedCapacity: aws.String("2")},
+			},
+		},
+		{
+			name: "Instance requirements",
+			policy: schemas.MixedInstancesPolicy{
+				Enabled: true,
+				InstanceRequirements: &schemas.InstanceRequirements{
+					VCpuCount:              schemas.RequirementRange{Min: 2, Max: 8},
+					MemoryMiB:              schemas.RequirementRange{Min: 4096},
+					CPUManufacturers:       []string{"intel", "amd"},
+					BurstablePerformance:   "excluded",
+					InstanceGenerations:    []string{"current"},
+					SpotMaxPricePercentage: 50,
+				},
+			},
+			expected: []*autoscaling.LaunchTemplateOverrides{
+				{
+					InstanceRequirements: &autoscaling.InstanceRequirements{
+						VCpuCount:                             &autoscaling.VCpuCountRequest{Min: aws.Int64(2), Max: aws.Int64(8)},
+						MemoryMiB:                             &autoscaling.MemoryMiBRequest{Min: aws.Int64(4096)},
+						CpuManufacturers:                      aws.StringSlice([]string{"intel", "amd"}),
+						BurstablePerformance:                  aws.String("excluded"),
+						InstanceGenerations:                   aws.StringSlice([]string{"current"}),
+						SpotMaxPricePercentageOverLowestPrice: aws.Int64(50),
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MakeMixedInstancesPolicy(lt, tt.policy)
+			assert.Equal(t, lt, result.LaunchTemplate.LaunchTemplateSpecification)
+			assert.Equal(t, tt.expected, result.LaunchTemplate.Overrides)
+		})
+	}
+}
+
 // Helper functions for creating pointers
 func stringPtr(s string) *string {
 	return &s
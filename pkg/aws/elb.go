@@ -27,6 +27,11 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
+// ELBAPI is the set of classic load balancer operations used by goployer
+type ELBAPI interface {
+	GetHealthyHostInELB(group *autoscaling.Group, elbName string) ([]HealthcheckHost, error)
+}
+
 type ELBClient struct {
 	Client *elb.ELB
 }
@@ -17,8 +17,11 @@ limitations under the license.
 package aws
 
 import (
+	"math"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
@@ -28,6 +31,27 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
+// ELBV2API is the set of application load balancer operations used by goployer
+type ELBV2API interface {
+	GetTargetGroupARNs(targetGroups []string) ([]*string, error)
+	GetHostInTarget(group *autoscaling.Group, targetGroupArn *string, isUpdate, downSizingUpdate bool) ([]HealthcheckHost, error)
+	GetLoadBalancerFromTG(targetGroups []*string) ([]*string, error)
+	CreateTargetGroup(tg *elbv2.TargetGroup, tgName string) (*elbv2.TargetGroup, error)
+	DescribeTargetGroups(targetGroups []*string) ([]*elbv2.TargetGroup, error)
+	DeleteTargetGroup(targetGroup *string) error
+	DeleteLoadBalancer(lb string) error
+	DescribeLoadBalancers() ([]*elbv2.LoadBalancer, error)
+	GetMatchingLoadBalancer(lb string) (*elbv2.LoadBalancer, error)
+	CreateLoadBalancer(app string, subnets []string, groupID *string) (*elbv2.LoadBalancer, error)
+	CreateNewListener(loadBalancerArn string, targetGroupArn string) error
+	DescribeListeners(loadBalancerArn string) ([]*elbv2.Listener, error)
+	ModifyListener(listenerArn *string, targetGroupArn string) error
+	GetListenersForTargetGroup(targetGroupArn *string) ([]*elbv2.Listener, error)
+	ModifyListenerActions(listenerArn *string, actions []*elbv2.Action) error
+	GetRulesForTargetGroup(targetGroupArn *string) ([]*elbv2.Rule, error)
+	ModifyRule(ruleArn *string, actions []*elbv2.Action) error
+}
+
 type ELBV2Client struct {
 	Client *elbv2.ELBV2
 }
@@ -37,6 +61,7 @@ type HealthcheckHost struct {
 	LifecycleState string
 	TargetStatus   string
 	HealthStatus   string
+	ProbeStatus    string
 	Valid          bool
 }
 
@@ -88,14 +113,21 @@ func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, targetGroupArn *s
 		return nil, err
 	}
 
+	targetStates := map[string]string{}
+	for _, hd := range result.TargetHealthDescriptions {
+		targetStates[*hd.Target.Id] = *hd.TargetHealth.State
+	}
+
+	return MakeHealthcheckHosts(group, targetStates, isUpdate, downSizingUpdate), nil
+}
+
+// MakeHealthcheckHosts decides validity of each instance in the autoscaling group with target states
+func MakeHealthcheckHosts(group *autoscaling.Group, targetStates map[string]string, isUpdate, downSizingUpdate bool) []HealthcheckHost {
 	ret := []HealthcheckHost{}
 	for _, instance := range group.Instances {
-		targetState := constants.InitialStatus
-		for _, hd := range result.TargetHealthDescriptions {
-			if *hd.Target.Id == *instance.InstanceId {
-				targetState = *hd.TargetHealth.State
-				break
-			}
+		targetState, ok := targetStates[*instance.InstanceId]
+		if !ok {
+			targetState = constants.InitialStatus
 		}
 
 		var valid bool
@@ -113,7 +145,7 @@ func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, targetGroupArn *s
 			Valid:          valid,
 		})
 	}
-	return ret, nil
+	return ret
 }
 
 // GetLoadBalancerFromTG returns list of loadbalancer from target groups
@@ -315,3 +347,202 @@ func (e ELBV2Client) ModifyListener(listenerArn *string, targetGroupArn string)
 
 	return nil
 }
+
+// GetListenersForTargetGroup returns listeners whose default action forwards to the target group
+func (e ELBV2Client) GetListenersForTargetGroup(targetGroupArn *string) ([]*elbv2.Listener, error) {
+	lbs, err := e.GetLoadBalancerFromTG([]*string{targetGroupArn})
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*elbv2.Listener
+	for _, lb := range lbs {
+		listeners, err := e.DescribeListeners(*lb)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range listeners {
+			if tool.IsStringInArray(*targetGroupArn, ForwardTargetGroupArns(l.DefaultActions)) {
+				ret = append(ret, l)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// ModifyListenerActions replaces default actions of the listener
+func (e ELBV2Client) ModifyListenerActions(listenerArn *string, actions []*elbv2.Action) error {
+	input := &elbv2.ModifyListenerInput{
+		DefaultActions: actions,
+		ListenerArn:    listenerArn,
+	}
+
+	_, err := e.Client.ModifyListener(input)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetRulesForTargetGroup returns listener rules except default ones whose actions forward to the target group
+func (e ELBV2Client) GetRulesForTargetGroup(targetGroupArn *string) ([]*elbv2.Rule, error) {
+	lbs, err := e.GetLoadBalancerFromTG([]*string{targetGroupArn})
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*elbv2.Rule
+	for _, lb := range lbs {
+		listeners, err := e.DescribeListeners(*lb)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range listeners {
+			input := &elbv2.DescribeRulesInput{
+				ListenerArn: l.ListenerArn,
+			}
+
+			for {
+				result, err := e.Client.DescribeRules(input)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, r := range result.Rules {
+					if !aws.BoolValue(r.IsDefault) && tool.IsStringInArray(*targetGroupArn, ForwardTargetGroupArns(r.Actions)) {
+						ret = append(ret, r)
+					}
+				}
+
+				if result.NextMarker == nil {
+					break
+				}
+				input.Marker = result.NextMarker
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// ModifyRule replaces actions of the listener rule
+func (e ELBV2Client) ModifyRule(ruleArn *string, actions []*elbv2.Action) error {
+	input := &elbv2.ModifyRuleInput{
+		Actions: actions,
+		RuleArn: ruleArn,
+	}
+
+	_, err := e.Client.ModifyRule(input)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ForwardTargetGroupArns returns target groups which actions forward to
+func ForwardTargetGroupArns(actions []*elbv2.Action) []string {
+	var ret []string
+	for _, a := range actions {
+		if a.TargetGroupArn != nil && !tool.IsStringInArray(*a.TargetGroupArn, ret) {
+			ret = append(ret, *a.TargetGroupArn)
+		}
+
+		if a.ForwardConfig == nil {
+			continue
+		}
+
+		for _, tg := range a.ForwardConfig.TargetGroups {
+			if tg.TargetGroupArn != nil && !tool.IsStringInArray(*tg.TargetGroupArn, ret) {
+				ret = append(ret, *tg.TargetGroupArn)
+			}
+		}
+	}
+
+	return ret
+}
+
+// WeightForwardActions returns copies of actions in which the share of original target group is split with canary target group by weight.
+// Other target groups and settings of actions like stickiness are kept as they are.
+func WeightForwardActions(actions []*elbv2.Action, original, canary string, weight int64) []*elbv2.Action {
+	var ret []*elbv2.Action
+	for _, a := range actions {
+		action := awsutil.CopyOf(a).(*elbv2.Action)
+		ret = append(ret, action)
+
+		if !tool.IsStringInArray(original, ForwardTargetGroupArns([]*elbv2.Action{action})) {
+			continue
+		}
+
+		if action.ForwardConfig == nil || len(action.ForwardConfig.TargetGroups) == 0 {
+			if action.ForwardConfig == nil {
+				action.ForwardConfig = &elbv2.ForwardActionConfig{}
+			}
+			action.ForwardConfig.TargetGroups = []*elbv2.TargetGroupTuple{{TargetGroupArn: aws.String(original)}}
+		}
+		// target group of forward config is used instead
+		action.TargetGroupArn = nil
+
+		var tuples []*elbv2.TargetGroupTuple
+		var originalWeight int64
+		for _, t := range action.ForwardConfig.TargetGroups {
+			if aws.StringValue(t.TargetGroupArn) == canary {
+				continue
+			}
+
+			if aws.StringValue(t.TargetGroupArn) == original {
+				originalWeight = forwardWeight(t, len(action.ForwardConfig.TargetGroups))
+			}
+			tuples = append(tuples, t)
+		}
+
+		// weights are scaled so that the original target group alone gets 100 - weight and the canary gets weight
+		scale := float64(100)
+		if originalWeight > 0 {
+			scale = float64(100) / float64(originalWeight)
+		}
+
+		var largest float64
+		for _, t := range tuples {
+			if aws.StringValue(t.TargetGroupArn) != original {
+				largest = math.Max(largest, float64(forwardWeight(t, len(tuples)))*scale)
+			}
+		}
+		if largest > maxForwardWeight {
+			scale = scale * maxForwardWeight / largest
+		}
+
+		for _, t := range tuples {
+			if aws.StringValue(t.TargetGroupArn) == original {
+				t.Weight = aws.Int64(int64(math.Round(float64(originalWeight) * scale * float64(100-weight) / 100)))
+				continue
+			}
+			t.Weight = aws.Int64(int64(math.Round(float64(forwardWeight(t, len(tuples))) * scale)))
+		}
+
+		action.ForwardConfig.TargetGroups = append(tuples, &elbv2.TargetGroupTuple{
+			TargetGroupArn: aws.String(canary),
+			Weight:         aws.Int64(int64(math.Round(float64(originalWeight) * scale * float64(weight) / 100))),
+		})
+	}
+
+	return ret
+}
+
+// maxForwardWeight is the largest weight of target group in forward action
+const maxForwardWeight = 999
+
+// forwardWeight returns weight of target group. A single target group without weight receives all traffic
+func forwardWeight(t *elbv2.TargetGroupTuple, count int) int64 {
+	if t.Weight == nil {
+		if count == 1 {
+			return 1
+		}
+		return 0
+	}
+	return *t.Weight
+}
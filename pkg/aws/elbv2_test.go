@@ -0,0 +1,136 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightForwardActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  []*elbv2.Action
+		weight   int64
+		expected []*elbv2.Action
+	}{
+		{
+			name: "Forward to the original target group",
+			actions: []*elbv2.Action{
+				{Type: aws.String("forward"), TargetGroupArn: aws.String("original")},
+			},
+			weight: 25,
+			expected: []*elbv2.Action{
+				{
+					Type: aws.String("forward"),
+					ForwardConfig: &elbv2.ForwardActionConfig{
+						TargetGroups: []*elbv2.TargetGroupTuple{
+							{TargetGroupArn: aws.String("original"), Weight: aws.Int64(75)},
+							{TargetGroupArn: aws.String("canary"), Weight: aws.Int64(25)},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Keep stickiness and other target groups",
+			actions: []*elbv2.Action{
+				{
+					Type:  aws.String("forward"),
+					Order: aws.Int64(1),
+					ForwardConfig: &elbv2.ForwardActionConfig{
+						TargetGroupStickinessConfig: &elbv2.TargetGroupStickinessConfig{Enabled: aws.Bool(true), DurationSeconds: aws.Int64(300)},
+						TargetGroups: []*elbv2.TargetGroupTuple{
+							{TargetGroupArn: aws.String("original"), Weight: aws.Int64(1)},
+							{TargetGroupArn: aws.String("other"), Weight: aws.Int64(1)},
+						},
+					},
+				},
+			},
+			weight: 50,
+			expected: []*elbv2.Action{
+				{
+					Type:  aws.String("forward"),
+					Order: aws.Int64(1),
+					ForwardConfig: &elbv2.ForwardActionConfig{
+						TargetGroupStickinessConfig: &elbv2.TargetGroupStickinessConfig{Enabled: aws.Bool(true), DurationSeconds: aws.Int64(300)},
+						TargetGroups: []*elbv2.TargetGroupTuple{
+							{TargetGroupArn: aws.String("original"), Weight: aws.Int64(50)},
+							{TargetGroupArn: aws.String("other"), Weight: aws.Int64(100)},
+							{TargetGroupArn: aws.String("canary"), Weight: aws.Int64(50)},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Replace weight of the canary target group",
+			actions: []*elbv2.Action{
+				{
+					Type: aws.String("forward"),
+					ForwardConfig: &elbv2.ForwardActionConfig{
+						TargetGroups: []*elbv2.TargetGroupTuple{
+							{TargetGroupArn: aws.String("original"), Weight: aws.Int64(90)},
+							{TargetGroupArn: aws.String("canary"), Weight: aws.Int64(10)},
+						},
+					},
+				},
+			},
+			weight: 100,
+			expected: []*elbv2.Action{
+				{
+					Type: aws.String("forward"),
+					ForwardConfig: &elbv2.ForwardActionConfig{
+						TargetGroups: []*elbv2.TargetGroupTuple{
+							{TargetGroupArn: aws.String("original"), Weight: aws.Int64(0)},
+							{TargetGroupArn: aws.String("canary"), Weight: aws.Int64(100)},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Leave actions for other target groups",
+			actions: []*elbv2.Action{
+				{Type: aws.String("authenticate-oidc"), Order: aws.Int64(1)},
+				{Type: aws.String("forward"), TargetGroupArn: aws.String("other"), Order: aws.Int64(2)},
+			},
+			weight: 25,
+			expected: []*elbv2.Action{
+				{Type: aws.String("authenticate-oidc"), Order: aws.Int64(1)},
+				{Type: aws.String("forward"), TargetGroupArn: aws.String("other"), Order: aws.Int64(2)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var original []*elbv2.Action
+			for _, a := range test.actions {
+				original = append(original, awsutil.CopyOf(a).(*elbv2.Action))
+			}
+
+			result := WeightForwardActions(test.actions, "original", "canary", test.weight)
+			assert.Equal(t, test.expected, result)
+			assert.Equal(t, original, test.actions, "actions should not be changed")
+		})
+	}
+}
@@ -0,0 +1,1153 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+// Package fake provides an in-memory implementation of the AWS services used by goployer.
+// A Backend keeps the state of a single region so that deployments can run end-to-end in unit tests.
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+const (
+	accountID  = "123456789012"
+	defaultVPC = "vpc-00000000000000000"
+
+	pendingState   = "Pending"
+	healthyState   = "healthy"
+	unhealthyState = "unhealthy"
+)
+
+// Command is a record of SSM command sent to instances
+type Command struct {
+	ID          string
+	InstanceIDs []string
+	Commands    []string
+}
+
+type launchTemplate struct {
+	ID       string
+	Name     string
+	Versions []*ec2.LaunchTemplateVersion
+}
+
+// Backend is an in-memory AWS region
+type Backend struct {
+	// Region of this backend
+	Region string
+
+	// PendingPolls is the number of times an instance is observed in Pending state before it becomes InService
+	PendingPolls int
+
+	// UnhealthyImages is the list of AMIs whose instances never pass target group health checks
+	UnhealthyImages []string
+
+	// ArmInstanceTypes is returned by DescribeInstanceTypes
+	ArmInstanceTypes []string
+
+	// FailingCommands is the list of SSM commands which exit with non-zero code on instances
+	FailingCommands []string
+
+	// HangingCommands is the list of SSM commands which never finish on instances
+	HangingCommands []string
+
+	// UnregisteredSends is how many SSM commands are rejected with InvalidInstanceId on each instance waiting in launch lifecycle hooks,
+	// as its agent is not registered yet
+	UnregisteredSends int
+
+	// ThrottledCompletions is how many calls of CompleteLifecycleAction are throttled on each instance before it succeeds
+	ThrottledCompletions int
+
+	// CrashingImages is the list of AMIs whose instances are replaced for failed health check right after they become InService
+	CrashingImages []string
+
+	// LaunchErrors maps AMIs to status messages of failed launch activities. Instances of these AMIs are never launched
+	LaunchErrors map[string]string
+
+	// PrivateIPAddress is assigned to every launched instance instead of generated one if it is set
+	PrivateIPAddress string
+
+	mu               sync.Mutex
+	clock            time.Time
+	seq              int
+	groups           map[string]*autoscaling.Group
+	launchTemplates  map[string]*launchTemplate
+	instances        map[string]*ec2.Instance
+	pending          map[string]int
+	targetGroups     map[string]*elbv2.TargetGroup
+	targetHealth     map[string]map[string]string
+	loadBalancers    map[string]*elbv2.LoadBalancer
+	listeners        map[string][]*elbv2.Listener
+	rules            map[string][]*elbv2.Rule
+	weightChanges    map[string][]map[string]int64
+	securityGroups   map[string]*ec2.SecurityGroup
+	images           map[string]string
+	policies         map[string][]string
+	alarms           map[string][]string
+	alarmStates      map[string]string
+	metrics          map[string]map[string]float64
+	scheduledActions map[string][]schemas.ScheduledAction
+	refreshes        map[string][]*autoscaling.InstanceRefresh
+	warmPools        map[string][]*autoscaling.Instance
+	launchHooks      map[string][]string
+	hookWaits        map[string][]string
+	activities       map[string][]*autoscaling.Activity
+	commands         []Command
+	rejectedSends    map[string]int
+	throttled        map[string]int
+	tables           map[string]map[string]map[string]*dynamodb.AttributeValue
+	objects          map[string][]byte
+}
+
+// NewBackend creates an empty in-memory region
+func NewBackend(region string) *Backend {
+	return &Backend{
+		Region:            region,
+		PendingPolls:      1,
+		UnregisteredSends: 1,
+		ArmInstanceTypes:  []string{"a1", "c6g", "m6g", "r6g", "t4g"},
+		clock:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		groups:            map[string]*autoscaling.Group{},
+		launchTemplates:   map[string]*launchTemplate{},
+		instances:         map[string]*ec2.Instance{},
+		pending:           map[string]int{},
+		rejectedSends:     map[string]int{},
+		throttled:         map[string]int{},
+		targetGroups:      map[string]*elbv2.TargetGroup{},
+		targetHealth:      map[string]map[string]string{},
+		loadBalancers:     map[string]*elbv2.LoadBalancer{},
+		listeners:         map[string][]*elbv2.Listener{},
+		rules:             map[string][]*elbv2.Rule{},
+		weightChanges:     map[string][]map[string]int64{},
+		securityGroups:    map[string]*ec2.SecurityGroup{},
+		images:            map[string]string{},
+		policies:          map[string][]string{},
+		alarms:            map[string][]string{},
+		alarmStates:       map[string]string{},
+		metrics:           map[string]map[string]float64{},
+		scheduledActions:  map[string][]schemas.ScheduledAction{},
+		refreshes:         map[string][]*autoscaling.InstanceRefresh{},
+		warmPools:         map[string][]*autoscaling.Instance{},
+		launchHooks:       map[string][]string{},
+		hookWaits:         map[string][]string{},
+		activities:        map[string][]*autoscaling.Activity{},
+		tables:            map[string]map[string]map[string]*dynamodb.AttributeValue{},
+		objects:           map[string][]byte{},
+	}
+}
+
+// Client returns aws client backed by this region
+func (b *Backend) Client() gaws.Client {
+	return gaws.Client{
+		Region:            b.Region,
+		EC2Service:        EC2{backend: b},
+		ELBV2Service:      ELBV2{backend: b},
+		ELBService:        ELB{backend: b},
+		CloudWatchService: CloudWatch{backend: b},
+		SSMService:        SSM{backend: b},
+	}
+}
+
+// MetricClient returns metric client backed by this region
+func (b *Backend) MetricClient() gaws.MetricClient {
+	return gaws.MetricClient{
+		Region:            b.Region,
+		DynamoDBService:   DynamoDB{backend: b},
+		CloudWatchService: CloudWatch{backend: b},
+	}
+}
+
+// ManifestClient returns manifest client backed by this region
+func (b *Backend) ManifestClient() gaws.ManifestClient {
+	return gaws.ManifestClient{
+		Region:    b.Region,
+		S3Service: S3{backend: b},
+	}
+}
+
+// ClientFactory returns a factory which hands out clients of the given backends by region
+func ClientFactory(backends ...*Backend) gaws.ClientFactory {
+	return func(region string, assumeRole string) gaws.Client {
+		for _, b := range backends {
+			if b.Region == region {
+				return b.Client()
+			}
+		}
+		panic(fmt.Sprintf("no fake backend exists for region: %s", region))
+	}
+}
+
+// AddTargetGroup creates a target group and returns its ARN
+func (b *Backend) AddTargetGroup(name string, port int64) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return *b.createTargetGroup(name, port, aws.String(defaultVPC)).TargetGroupArn
+}
+
+// AddLoadBalancer creates an application load balancer whose listener forwards to the target group and returns its ARN
+func (b *Backend) AddLoadBalancer(name, targetGroup string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.createLoadBalancer(name, nil)
+	if tg := b.findTargetGroup(targetGroup); tg != nil {
+		b.createListener(*lb.LoadBalancerArn, tg)
+	}
+
+	return *lb.LoadBalancerArn
+}
+
+// SetListenerStickiness makes default actions of listeners in the load balancer forward with target group stickiness
+func (b *Backend) SetListenerStickiness(lbName string, seconds int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.findLoadBalancer(lbName)
+	if lb == nil {
+		panic(fmt.Sprintf("load balancer does not exist: %s", lbName))
+	}
+
+	for _, l := range b.listeners[*lb.LoadBalancerArn] {
+		for _, a := range l.DefaultActions {
+			if a.TargetGroupArn == nil {
+				continue
+			}
+
+			a.ForwardConfig = &elbv2.ForwardActionConfig{
+				TargetGroups: []*elbv2.TargetGroupTuple{{TargetGroupArn: a.TargetGroupArn, Weight: aws.Int64(1)}},
+				TargetGroupStickinessConfig: &elbv2.TargetGroupStickinessConfig{
+					Enabled:         aws.Bool(true),
+					DurationSeconds: aws.Int64(seconds),
+				},
+			}
+			a.TargetGroupArn = nil
+		}
+	}
+}
+
+// AddListenerRule creates a rule in the first listener of the load balancer which forwards to the target group and returns its ARN
+func (b *Backend) AddListenerRule(lbName, targetGroup string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.findLoadBalancer(lbName)
+	tg := b.findTargetGroup(targetGroup)
+	if lb == nil || tg == nil || len(b.listeners[*lb.LoadBalancerArn]) == 0 {
+		panic(fmt.Sprintf("listener rule cannot be created: %s/%s", lbName, targetGroup))
+	}
+
+	listener := b.listeners[*lb.LoadBalancerArn][0]
+	rule := &elbv2.Rule{
+		RuleArn:   aws.String(fmt.Sprintf("%s/%s", strings.Replace(*listener.ListenerArn, ":listener/", ":listener-rule/", 1), b.nextID(16))),
+		IsDefault: aws.Bool(false),
+		Priority:  aws.String(fmt.Sprintf("%d", len(b.rules[*listener.ListenerArn])+1)),
+		Actions: []*elbv2.Action{
+			{
+				TargetGroupArn: aws.String(*tg.TargetGroupArn),
+				Type:           aws.String(elbv2.ActionTypeEnumForward),
+			},
+		},
+	}
+	b.rules[*listener.ListenerArn] = append(b.rules[*listener.ListenerArn], rule)
+	b.linkTargetGroup(tg, *lb.LoadBalancerArn)
+
+	return *rule.RuleArn
+}
+
+// SetMetric sets the value of metric returned for the target group or autoscaling group
+func (b *Backend) SetMetric(metric, target string, value float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.metrics[metric] == nil {
+		b.metrics[metric] = map[string]float64{}
+	}
+	b.metrics[metric][target] = value
+}
+
+// SetAlarmState sets the state of CloudWatch alarm like OK or ALARM
+func (b *Backend) SetAlarmState(name, state string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.alarmStates[name] = state
+}
+
+// AddSecurityGroup creates a security group and returns its ID
+func (b *Backend) AddSecurityGroup(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return *b.createSecurityGroup(name, aws.String(defaultVPC)).GroupId
+}
+
+// AddImage registers architecture of AMI
+func (b *Backend) AddImage(ami, architecture string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.images[ami] = architecture
+}
+
+// AddAutoScalingGroup creates an autoscaling group with its launch template and InService instances
+func (b *Backend) AddAutoScalingGroup(name, ami string, capacity schemas.Capacity, targetGroups ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ltName := tool.GenerateLcName(name)
+	b.createLaunchTemplate(ltName, &ec2.ResponseLaunchTemplateData{
+		ImageId:      aws.String(ami),
+		InstanceType: aws.String("t3.micro"),
+	})
+
+	var arns []*string
+	for _, tg := range targetGroups {
+		if t := b.findTargetGroup(tg); t != nil {
+			arns = append(arns, t.TargetGroupArn)
+		}
+	}
+
+	g := b.createGroup(name, ltName, capacity, arns, nil, nil, schemas.MixedInstancesPolicy{})
+	for _, instance := range g.Instances {
+		b.markInService(g, instance)
+	}
+}
+
+// PutObject stores an object in s3
+func (b *Backend) PutObject(bucket, key string, body []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.objects[bucket+"/"+key] = body
+}
+
+// AutoScalingGroup returns a copy of autoscaling group, or nil if it does not exist
+func (b *Backend) AutoScalingGroup(name string) *autoscaling.Group {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g, ok := b.groups[name]
+	if !ok {
+		return nil
+	}
+
+	return copyGroup(g)
+}
+
+// AutoScalingGroupNames returns sorted names of all autoscaling groups
+func (b *Backend) AutoScalingGroupNames() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ret []string
+	for name := range b.groups {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// WarmPoolInstances returns instances in the warm pool of autoscaling group
+func (b *Backend) WarmPoolInstances(name string) []*autoscaling.Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ret []*autoscaling.Instance
+	for _, instance := range b.warmPools[name] {
+		ret = append(ret, awsutil.CopyOf(instance).(*autoscaling.Instance))
+	}
+
+	return ret
+}
+
+// LaunchTemplateNames returns sorted names of all launch templates
+func (b *Backend) LaunchTemplateNames() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ret []string
+	for name := range b.launchTemplates {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// TargetHealth returns target states of target group by instance id
+func (b *Backend) TargetHealth(tgName string) map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ret := map[string]string{}
+	tg := b.findTargetGroup(tgName)
+	if tg == nil {
+		return ret
+	}
+
+	for id, state := range b.targetHealth[*tg.TargetGroupArn] {
+		ret[id] = state
+	}
+
+	return ret
+}
+
+// ListenerTargetGroups returns names of target groups which the default action of load balancer forwards to
+func (b *Backend) ListenerTargetGroups(lbName string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.findLoadBalancer(lbName)
+	if lb == nil {
+		return nil
+	}
+
+	var ret []string
+	for _, l := range b.listeners[*lb.LoadBalancerArn] {
+		for _, arn := range gaws.ForwardTargetGroupArns(l.DefaultActions) {
+			ret = append(ret, *b.targetGroups[arn].TargetGroupName)
+		}
+	}
+
+	return ret
+}
+
+// ListenerDefaultActions returns copies of default actions of listeners in the load balancer
+func (b *Backend) ListenerDefaultActions(lbName string) []*elbv2.Action {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.findLoadBalancer(lbName)
+	if lb == nil {
+		return nil
+	}
+
+	var ret []*elbv2.Action
+	for _, l := range b.listeners[*lb.LoadBalancerArn] {
+		for _, a := range l.DefaultActions {
+			ret = append(ret, awsutil.CopyOf(a).(*elbv2.Action))
+		}
+	}
+
+	return ret
+}
+
+// RuleTargetGroups returns target groups which listener rules of the load balancer forward to
+func (b *Backend) RuleTargetGroups(lbName string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.findLoadBalancer(lbName)
+	if lb == nil {
+		return nil
+	}
+
+	var ret []string
+	for _, l := range b.listeners[*lb.LoadBalancerArn] {
+		for _, r := range b.rules[*l.ListenerArn] {
+			for _, arn := range gaws.ForwardTargetGroupArns(r.Actions) {
+				ret = append(ret, *b.targetGroups[arn].TargetGroupName)
+			}
+		}
+	}
+
+	return ret
+}
+
+// WeightChanges returns weights of target groups applied to the load balancer in order
+func (b *Backend) WeightChanges(lbName string) []map[string]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.findLoadBalancer(lbName)
+	if lb == nil {
+		return nil
+	}
+
+	return b.weightChanges[*lb.LoadBalancerArn]
+}
+
+// Commands returns all SSM commands sent
+func (b *Backend) Commands() []Command {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Command{}, b.commands...)
+}
+
+// Item returns a record of metric table
+func (b *Backend) Item(table, asg string) map[string]*dynamodb.AttributeValue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.tables[table]; !ok {
+		return nil
+	}
+
+	return b.tables[table][asg]
+}
+
+// sendCommand records SSM command and returns its id
+func (b *Backend) sendCommand(targets, commands []string) string {
+	id := b.nextID(36)
+	b.commands = append(b.commands, Command{
+		ID:          id,
+		InstanceIDs: targets,
+		Commands:    commands,
+	})
+
+	return id
+}
+
+// now returns monotonic fake time
+func (b *Backend) now() time.Time {
+	b.clock = b.clock.Add(time.Second)
+	return b.clock
+}
+
+// nextID returns hex identifier with given length
+func (b *Backend) nextID(length int) string {
+	b.seq++
+	return fmt.Sprintf("%0*x", length, b.seq)
+}
+
+// createGroup creates autoscaling group and launches instances for the desired capacity
+func (b *Backend) createGroup(name, ltName string, capacity schemas.Capacity, targetGroups []*string, loadBalancers []string, tags []*autoscaling.Tag, mixedInstancesPolicy schemas.MixedInstancesPolicy) *autoscaling.Group {
+	g := &autoscaling.Group{
+		AutoScalingGroupName: aws.String(name),
+		AutoScalingGroupARN:  aws.String(fmt.Sprintf("arn:aws:autoscaling:%s:%s:autoScalingGroup:%s:autoScalingGroupName/%s", b.Region, accountID, b.nextID(8), name)),
+		CreatedTime:          aws.Time(b.now()),
+		MinSize:              aws.Int64(capacity.Min),
+		MaxSize:              aws.Int64(capacity.Max),
+		DesiredCapacity:      aws.Int64(capacity.Desired),
+		TargetGroupARNs:      targetGroups,
+		LoadBalancerNames:    aws.StringSlice(loadBalancers),
+		Instances:            []*autoscaling.Instance{},
+	}
+
+	if lt, ok := b.launchTemplates[ltName]; ok {
+		g.LaunchTemplate = &autoscaling.LaunchTemplateSpecification{
+			LaunchTemplateId:   aws.String(lt.ID),
+			LaunchTemplateName: aws.String(lt.Name),
+			Version:            aws.String("$Default"),
+		}
+	}
+
+	if mixedInstancesPolicy.Enabled {
+		g.MixedInstancesPolicy = gaws.MakeMixedInstancesPolicy(g.LaunchTemplate, mixedInstancesPolicy)
+	}
+
+	for _, t := range tags {
+		g.Tags = append(g.Tags, &autoscaling.TagDescription{
+			Key:               t.Key,
+			Value:             t.Value,
+			ResourceId:        aws.String(name),
+			ResourceType:      aws.String("auto-scaling-group"),
+			PropagateAtLaunch: aws.Bool(true),
+		})
+	}
+
+	b.groups[name] = g
+	b.scale(g)
+
+	return g
+}
+
+// scale launches or terminates instances until the group meets its desired capacity
+func (b *Backend) scale(g *autoscaling.Group) {
+	for capacityOf(g) < *g.DesiredCapacity {
+		if !b.launch(g) {
+			break
+		}
+	}
+
+	for len(g.Instances) > 0 && capacityOf(g)-weightOf(g.Instances[0]) >= *g.DesiredCapacity {
+		b.terminate(g, g.Instances[0])
+	}
+
+	b.prepareWarmPool(g)
+}
+
+// capacityOf returns units of capacity which instances in the group provide
+func capacityOf(g *autoscaling.Group) int64 {
+	ret := int64(0)
+	for _, instance := range g.Instances {
+		ret += weightOf(instance)
+	}
+
+	return ret
+}
+
+// weightOf returns the weighted capacity of instance
+func weightOf(instance *autoscaling.Instance) int64 {
+	if instance.WeightedCapacity == nil {
+		return 1
+	}
+
+	weight, err := strconv.ParseInt(*instance.WeightedCapacity, 10, 64)
+	if err != nil {
+		return 1
+	}
+
+	return weight
+}
+
+// launch starts a new instance in the group and records the activity. It returns false if the launch fails
+func (b *Backend) launch(g *autoscaling.Group) bool {
+	data := b.launchTemplateData(g.LaunchTemplate)
+
+	if data.ImageId != nil {
+		if message, ok := b.LaunchErrors[*data.ImageId]; ok {
+			b.recordActivity(g, "Launching a new EC2 instance.  Status Reason: "+message, "an instance was started in response to a difference between desired and actual capacity", autoscaling.ScalingActivityStatusCodeFailed, message)
+			return false
+		}
+	}
+
+	// instance types in overrides of mixed instances policy are used in turn
+	var weightedCapacity *string
+	if g.MixedInstancesPolicy != nil && g.MixedInstancesPolicy.LaunchTemplate != nil {
+		var overrides []*autoscaling.LaunchTemplateOverrides
+		for _, o := range g.MixedInstancesPolicy.LaunchTemplate.Overrides {
+			if o.InstanceType != nil {
+				overrides = append(overrides, o)
+			}
+		}
+
+		if len(overrides) > 0 {
+			override := overrides[len(g.Instances)%len(overrides)]
+			data = awsutil.CopyOf(data).(*ec2.ResponseLaunchTemplateData)
+			data.InstanceType = override.InstanceType
+			weightedCapacity = override.WeightedCapacity
+		}
+	}
+	id := fmt.Sprintf("i-%s", b.nextID(17))
+	az := fmt.Sprintf("%sa", b.Region)
+	privateIP := fmt.Sprintf("10.0.%d.%d", b.seq/250, b.seq%250+1)
+	if len(b.PrivateIPAddress) > 0 {
+		privateIP = b.PrivateIPAddress
+	}
+	eni := fmt.Sprintf("eni-%s", b.nextID(17))
+
+	var groups []*ec2.GroupIdentifier
+	for _, sg := range data.SecurityGroupIds {
+		groups = append(groups, &ec2.GroupIdentifier{GroupId: aws.String(*sg)})
+	}
+
+	b.instances[id] = &ec2.Instance{
+		InstanceId:       aws.String(id),
+		ImageId:          data.ImageId,
+		InstanceType:     data.InstanceType,
+		LaunchTime:       aws.Time(b.now()),
+		PrivateIpAddress: aws.String(privateIP),
+		Placement:        &ec2.Placement{AvailabilityZone: aws.String(az)},
+		State:            &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNamePending)},
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				NetworkInterfaceId: aws.String(eni),
+				Groups:             groups,
+			},
+		},
+	}
+
+	g.Instances = append(g.Instances, &autoscaling.Instance{
+		InstanceId:       aws.String(id),
+		InstanceType:     data.InstanceType,
+		AvailabilityZone: aws.String(az),
+		LifecycleState:   aws.String(pendingState),
+		HealthStatus:     aws.String("Healthy"),
+		LaunchTemplate:   g.LaunchTemplate,
+		WeightedCapacity: weightedCapacity,
+	})
+	b.pending[id] = 0
+
+	for _, tg := range g.TargetGroupARNs {
+		b.register(*tg, id, "initial")
+	}
+	b.recordActivity(g, "Launching a new EC2 instance: "+id, "an instance was started in response to a difference between desired and actual capacity", autoscaling.ScalingActivityStatusCodeSuccessful, "")
+
+	return true
+}
+
+// recordActivity adds a scaling activity of the group. Activities use wall clock so that they can be compared with the start of deployment
+func (b *Backend) recordActivity(g *autoscaling.Group, description, cause, statusCode, statusMessage string) {
+	activity := &autoscaling.Activity{
+		ActivityId:           aws.String(fmt.Sprintf("%s-%s", *g.AutoScalingGroupName, b.nextID(8))),
+		AutoScalingGroupName: g.AutoScalingGroupName,
+		Description:          aws.String(description),
+		Cause:                aws.String(fmt.Sprintf("At %s %s.", time.Now().UTC().Format(time.RFC3339), cause)),
+		StartTime:            aws.Time(time.Now()),
+		StatusCode:           aws.String(statusCode),
+	}
+
+	if len(statusMessage) > 0 {
+		activity.StatusMessage = aws.String(statusMessage)
+	}
+
+	name := *g.AutoScalingGroupName
+	b.activities[name] = append([]*autoscaling.Activity{activity}, b.activities[name]...)
+}
+
+// putWarmPool configures the warm pool of the group and prepares instances in it
+func (b *Backend) putWarmPool(g *autoscaling.Group, warmPool schemas.WarmPool) {
+	poolState := warmPool.PoolState
+	if len(poolState) == 0 {
+		poolState = constants.DefaultWarmPoolState
+	}
+
+	g.WarmPoolConfiguration = &autoscaling.WarmPoolConfiguration{
+		MinSize:   aws.Int64(warmPool.MinSize),
+		PoolState: aws.String(poolState),
+		InstanceReusePolicy: &autoscaling.InstanceReusePolicy{
+			ReuseOnScaleIn: aws.Bool(warmPool.ReuseOnScaleIn),
+		},
+	}
+
+	if warmPool.MaxPreparedCapacity > 0 {
+		g.WarmPoolConfiguration.MaxGroupPreparedCapacity = aws.Int64(warmPool.MaxPreparedCapacity)
+	}
+
+	b.prepareWarmPool(g)
+}
+
+// prepareWarmPool launches or terminates warm instances until the pool meets its size
+func (b *Backend) prepareWarmPool(g *autoscaling.Group) {
+	config := g.WarmPoolConfiguration
+	if config == nil {
+		return
+	}
+
+	prepared := *g.MaxSize
+	if config.MaxGroupPreparedCapacity != nil && *config.MaxGroupPreparedCapacity >= 0 {
+		prepared = *config.MaxGroupPreparedCapacity
+	}
+
+	size := prepared - *g.DesiredCapacity
+	if size < *config.MinSize {
+		size = *config.MinSize
+	}
+
+	pool := b.warmPools[*g.AutoScalingGroupName]
+	for int64(len(pool)) < size {
+		pool = append(pool, &autoscaling.Instance{
+			InstanceId:       aws.String(fmt.Sprintf("i-%s", b.nextID(17))),
+			AvailabilityZone: aws.String(fmt.Sprintf("%sa", b.Region)),
+			LifecycleState:   aws.String(constants.WarmedLifecycleStatePrefix + *config.PoolState),
+			HealthStatus:     aws.String("Healthy"),
+			LaunchTemplate:   g.LaunchTemplate,
+		})
+	}
+
+	if int64(len(pool)) > size {
+		pool = pool[:size]
+	}
+	b.warmPools[*g.AutoScalingGroupName] = pool
+}
+
+// terminate removes an instance from the group
+func (b *Backend) terminate(g *autoscaling.Group, target *autoscaling.Instance) {
+	id := *target.InstanceId
+
+	var remained []*autoscaling.Instance
+	for _, instance := range g.Instances {
+		if *instance.InstanceId != id {
+			remained = append(remained, instance)
+		}
+	}
+	g.Instances = remained
+
+	if instance, ok := b.instances[id]; ok {
+		instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)}
+	}
+	delete(b.pending, id)
+	delete(b.hookWaits, id)
+
+	for _, states := range b.targetHealth {
+		delete(states, id)
+	}
+}
+
+// observe moves pending instances forward every time the group is described
+func (b *Backend) observe(g *autoscaling.Group) {
+	// instances of crashing images are replaced after they are seen InService once
+	var crashed []*autoscaling.Instance
+	for _, instance := range g.Instances {
+		if *instance.LifecycleState != constants.InServiceStatus {
+			continue
+		}
+
+		if i, ok := b.instances[*instance.InstanceId]; ok && i.ImageId != nil && tool.IsStringInArray(*i.ImageId, b.CrashingImages) {
+			crashed = append(crashed, instance)
+		}
+	}
+
+	for _, instance := range crashed {
+		b.terminate(g, instance)
+		b.recordActivity(g, "Terminating EC2 instance: "+*instance.InstanceId, "an instance was taken out of service in response to an EC2 health check indicating it has been terminated or stopped", autoscaling.ScalingActivityStatusCodeSuccessful, "")
+	}
+
+	if len(crashed) > 0 {
+		b.scale(g)
+	}
+
+	for _, instance := range g.Instances {
+		if *instance.LifecycleState != pendingState {
+			continue
+		}
+
+		b.pending[*instance.InstanceId]++
+		if b.pending[*instance.InstanceId] < b.PendingPolls {
+			continue
+		}
+
+		// instances wait until all launch lifecycle actions are completed
+		if hooks := b.launchHooks[*g.AutoScalingGroupName]; len(hooks) > 0 {
+			instance.LifecycleState = aws.String(autoscaling.LifecycleStatePendingWait)
+			b.hookWaits[*instance.InstanceId] = append([]string{}, hooks...)
+			continue
+		}
+		b.markInService(g, instance)
+	}
+}
+
+// completeLifecycleAction moves the instance forward when all launch lifecycle actions are completed, or replaces it when abandoned
+func (b *Backend) completeLifecycleAction(g *autoscaling.Group, hookName, id, result string) error {
+	var target *autoscaling.Instance
+	for _, instance := range g.Instances {
+		if *instance.InstanceId == id && *instance.LifecycleState == autoscaling.LifecycleStatePendingWait {
+			target = instance
+		}
+	}
+
+	if target == nil || !tool.IsStringInArray(hookName, b.hookWaits[id]) {
+		return fmt.Errorf("no active lifecycle action found with instance ID %s", id)
+	}
+
+	if result == constants.LifecycleActionAbandon {
+		b.terminate(g, target)
+		b.scale(g)
+		return nil
+	}
+
+	var remained []string
+	for _, hook := range b.hookWaits[id] {
+		if hook != hookName {
+			remained = append(remained, hook)
+		}
+	}
+	b.hookWaits[id] = remained
+
+	if len(remained) == 0 {
+		delete(b.hookWaits, id)
+		b.markInService(g, target)
+	}
+
+	return nil
+}
+
+// markInService changes instance state to InService and registers it to target groups
+func (b *Backend) markInService(g *autoscaling.Group, instance *autoscaling.Instance) {
+	id := *instance.InstanceId
+	instance.LifecycleState = aws.String(constants.InServiceStatus)
+	delete(b.pending, id)
+
+	if i, ok := b.instances[id]; ok {
+		i.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	}
+
+	for _, tg := range g.TargetGroupARNs {
+		b.register(*tg, id, b.targetState(id))
+	}
+}
+
+// targetState returns health state of an InService instance
+func (b *Backend) targetState(id string) string {
+	instance, ok := b.instances[id]
+	if ok && instance.ImageId != nil && tool.IsStringInArray(*instance.ImageId, b.UnhealthyImages) {
+		return unhealthyState
+	}
+
+	return healthyState
+}
+
+// register sets target state of instance in the target group
+func (b *Backend) register(tgArn, id, state string) {
+	if _, ok := b.targetHealth[tgArn]; !ok {
+		b.targetHealth[tgArn] = map[string]string{}
+	}
+	b.targetHealth[tgArn][id] = state
+}
+
+// attach registers all instances of the group to target groups
+func (b *Backend) attach(g *autoscaling.Group, targetGroups []*string) {
+	for _, tg := range targetGroups {
+		if !tool.IsStringInPointerArray(*tg, g.TargetGroupARNs) {
+			g.TargetGroupARNs = append(g.TargetGroupARNs, aws.String(*tg))
+		}
+
+		for _, instance := range g.Instances {
+			state := "initial"
+			if *instance.LifecycleState == constants.InServiceStatus {
+				state = b.targetState(*instance.InstanceId)
+			}
+			b.register(*tg, *instance.InstanceId, state)
+		}
+	}
+}
+
+// detach deregisters all instances of the group from target groups
+func (b *Backend) detach(g *autoscaling.Group, targetGroups []*string) {
+	var remained []*string
+	for _, tg := range g.TargetGroupARNs {
+		if !tool.IsStringInPointerArray(*tg, targetGroups) {
+			remained = append(remained, tg)
+		}
+	}
+	g.TargetGroupARNs = remained
+
+	for _, tg := range targetGroups {
+		for _, instance := range g.Instances {
+			delete(b.targetHealth[*tg], *instance.InstanceId)
+		}
+	}
+}
+
+// createLaunchTemplate creates the first version of launch template
+func (b *Backend) createLaunchTemplate(name string, data *ec2.ResponseLaunchTemplateData) *launchTemplate {
+	lt := &launchTemplate{
+		ID:   fmt.Sprintf("lt-%s", b.nextID(17)),
+		Name: name,
+	}
+	lt.Versions = []*ec2.LaunchTemplateVersion{
+		{
+			LaunchTemplateId:   aws.String(lt.ID),
+			LaunchTemplateName: aws.String(name),
+			VersionNumber:      aws.Int64(1),
+			DefaultVersion:     aws.Bool(true),
+			CreateTime:         aws.Time(b.now()),
+			LaunchTemplateData: data,
+		},
+	}
+	b.launchTemplates[name] = lt
+
+	return lt
+}
+
+// findLaunchTemplate finds launch template with name or id
+func (b *Backend) findLaunchTemplate(key string) *launchTemplate {
+	if lt, ok := b.launchTemplates[key]; ok {
+		return lt
+	}
+
+	for _, lt := range b.launchTemplates {
+		if lt.ID == key {
+			return lt
+		}
+	}
+
+	return nil
+}
+
+// launchTemplateData returns data of launch template version the group uses
+func (b *Backend) launchTemplateData(spec *autoscaling.LaunchTemplateSpecification) *ec2.ResponseLaunchTemplateData {
+	if spec == nil {
+		return &ec2.ResponseLaunchTemplateData{}
+	}
+
+	var lt *launchTemplate
+	if spec.LaunchTemplateId != nil {
+		lt = b.findLaunchTemplate(*spec.LaunchTemplateId)
+	} else if spec.LaunchTemplateName != nil {
+		lt = b.findLaunchTemplate(*spec.LaunchTemplateName)
+	}
+
+	if lt == nil {
+		return &ec2.ResponseLaunchTemplateData{}
+	}
+
+	return lt.version(aws.StringValue(spec.Version)).LaunchTemplateData
+}
+
+// version returns launch template version with number, $Latest or $Default
+func (lt *launchTemplate) version(key string) *ec2.LaunchTemplateVersion {
+	version := lt.Versions[0]
+	switch key {
+	case "$Latest":
+		version = lt.Versions[len(lt.Versions)-1]
+	case "$Default", "":
+	default:
+		for _, v := range lt.Versions {
+			if fmt.Sprintf("%d", *v.VersionNumber) == key {
+				version = v
+			}
+		}
+	}
+
+	return version
+}
+
+// createTargetGroup creates a target group
+func (b *Backend) createTargetGroup(name string, port int64, vpcID *string) *elbv2.TargetGroup {
+	tg := &elbv2.TargetGroup{
+		TargetGroupName: aws.String(name),
+		TargetGroupArn:  aws.String(fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:targetgroup/%s/%s", b.Region, accountID, name, b.nextID(16))),
+		Port:            aws.Int64(port),
+		Protocol:        aws.String("HTTP"),
+		VpcId:           vpcID,
+	}
+	b.targetGroups[*tg.TargetGroupArn] = tg
+	b.targetHealth[*tg.TargetGroupArn] = map[string]string{}
+
+	return tg
+}
+
+// findTargetGroup finds target group with name or ARN
+func (b *Backend) findTargetGroup(key string) *elbv2.TargetGroup {
+	if tg, ok := b.targetGroups[key]; ok {
+		return tg
+	}
+
+	for _, tg := range b.targetGroups {
+		if *tg.TargetGroupName == key {
+			return tg
+		}
+	}
+
+	return nil
+}
+
+// createSecurityGroup creates a security group
+func (b *Backend) createSecurityGroup(name string, vpcID *string) *ec2.SecurityGroup {
+	sg := &ec2.SecurityGroup{
+		GroupId:   aws.String(fmt.Sprintf("sg-%s", b.nextID(17))),
+		GroupName: aws.String(name),
+		VpcId:     vpcID,
+	}
+	b.securityGroups[*sg.GroupId] = sg
+
+	return sg
+}
+
+// findSecurityGroup finds security group with name or id
+func (b *Backend) findSecurityGroup(key string) *ec2.SecurityGroup {
+	if sg, ok := b.securityGroups[key]; ok {
+		return sg
+	}
+
+	for _, sg := range b.securityGroups {
+		if *sg.GroupName == key {
+			return sg
+		}
+	}
+
+	return nil
+}
+
+// createLoadBalancer creates an application load balancer
+func (b *Backend) createLoadBalancer(name string, subnets []string) *elbv2.LoadBalancer {
+	lb := &elbv2.LoadBalancer{
+		LoadBalancerName: aws.String(name),
+		LoadBalancerArn:  aws.String(fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:loadbalancer/app/%s/%s", b.Region, accountID, name, b.nextID(16))),
+		DNSName:          aws.String(fmt.Sprintf("%s.%s.elb.amazonaws.com", name, b.Region)),
+		Type:             aws.String(elbv2.LoadBalancerTypeEnumApplication),
+		VpcId:            aws.String(defaultVPC),
+		CreatedTime:      aws.Time(b.now()),
+		State:            &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)},
+	}
+
+	for _, subnet := range subnets {
+		lb.AvailabilityZones = append(lb.AvailabilityZones, &elbv2.AvailabilityZone{SubnetId: aws.String(subnet)})
+	}
+	b.loadBalancers[*lb.LoadBalancerArn] = lb
+
+	return lb
+}
+
+// createListener creates a listener which forwards to the target group
+func (b *Backend) createListener(lbArn string, tg *elbv2.TargetGroup) *elbv2.Listener {
+	listener := &elbv2.Listener{
+		ListenerArn:     aws.String(fmt.Sprintf("%s/%s", lbArn, b.nextID(16))),
+		LoadBalancerArn: aws.String(lbArn),
+		Port:            aws.Int64(80),
+		Protocol:        aws.String(elbv2.ProtocolEnumHttp),
+		DefaultActions: []*elbv2.Action{
+			{
+				TargetGroupArn: aws.String(*tg.TargetGroupArn),
+				Type:           aws.String(elbv2.ActionTypeEnumForward),
+			},
+		},
+	}
+	b.listeners[lbArn] = append(b.listeners[lbArn], listener)
+	b.linkTargetGroup(tg, lbArn)
+
+	return listener
+}
+
+// findLoadBalancer finds load balancer with name or ARN
+func (b *Backend) findLoadBalancer(key string) *elbv2.LoadBalancer {
+	if lb, ok := b.loadBalancers[key]; ok {
+		return lb
+	}
+
+	for _, lb := range b.loadBalancers {
+		if *lb.LoadBalancerName == key {
+			return lb
+		}
+	}
+
+	return nil
+}
+
+// groupsWithPrefix returns sorted autoscaling groups whose name starts with prefix
+func (b *Backend) groupsWithPrefix(prefix string) []*autoscaling.Group {
+	var names []string
+	for name := range b.groups {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var ret []*autoscaling.Group
+	for _, name := range names {
+		ret = append(ret, b.groups[name])
+	}
+
+	return ret
+}
+
+// copyGroup returns deep copy of autoscaling group so that callers cannot modify the backend
+func copyGroup(g *autoscaling.Group) *autoscaling.Group {
+	return awsutil.CopyOf(g).(*autoscaling.Group)
+}
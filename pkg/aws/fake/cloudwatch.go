@@ -0,0 +1,114 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"strings"
+	"time"
+
+	Logger "github.com/sirupsen/logrus"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// CloudWatch is an in-memory implementation of aws.CloudWatchAPI
+type CloudWatch struct {
+	backend *Backend
+}
+
+var _ gaws.CloudWatchAPI = CloudWatch{}
+
+// CreateScalingAlarms records alarms of autoscaling group
+func (c CloudWatch) CreateScalingAlarms(asgName string, alarms []schemas.AlarmConfigs, policyArns map[string]string) error {
+	for _, alarm := range alarms {
+		if err := c.CreateCloudWatchAlarm(asgName, alarm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateCloudWatchAlarm records an alarm of autoscaling group
+func (c CloudWatch) CreateCloudWatchAlarm(asgName string, alarm schemas.AlarmConfigs) error {
+	c.backend.mu.Lock()
+	defer c.backend.mu.Unlock()
+
+	c.backend.alarms[asgName] = append(c.backend.alarms[asgName], alarm.Name)
+
+	return nil
+}
+
+// GetTargetGroupRequestStatistics returns empty request statistics
+func (c CloudWatch) GetTargetGroupRequestStatistics(tgs []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error) {
+	return emptyStatistics(tgs), nil
+}
+
+// GetLoadBalancerRequestStatistics returns empty request statistics
+func (c CloudWatch) GetLoadBalancerRequestStatistics(loadbalancers []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error) {
+	return emptyStatistics(loadbalancers), nil
+}
+
+// GetOneDayStatisticsOfTargetGroup returns empty request statistics
+func (c CloudWatch) GetOneDayStatisticsOfTargetGroup(tg string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error) {
+	return map[string]float64{}, 0, nil
+}
+
+// GetOneDayStatisticsOfLoadBalancer returns empty request statistics
+func (c CloudWatch) GetOneDayStatisticsOfLoadBalancer(lb string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error) {
+	return map[string]float64{}, 0, nil
+}
+
+// GetMetricValue returns the value set by SetMetric for the target of dimensions
+func (c CloudWatch) GetMetricValue(namespace, metric, statistic string, dimensions map[string]string, startTime, endTime time.Time) (float64, error) {
+	c.backend.mu.Lock()
+	defer c.backend.mu.Unlock()
+
+	target := dimensions["AutoScalingGroupName"]
+	if tg, ok := dimensions["TargetGroup"]; ok {
+		// targetgroup/<name>/<id>
+		target = strings.Split(tg, "/")[1]
+	}
+
+	return c.backend.metrics[metric][target], nil
+}
+
+// GetAlarmStates returns states of alarms set by SetAlarmState
+func (c CloudWatch) GetAlarmStates(names []string) (map[string]string, error) {
+	c.backend.mu.Lock()
+	defer c.backend.mu.Unlock()
+
+	ret := map[string]string{}
+	for _, name := range names {
+		if state, ok := c.backend.alarmStates[name]; ok {
+			ret[name] = state
+		}
+	}
+
+	return ret, nil
+}
+
+// emptyStatistics returns statistics without any data point for each target
+func emptyStatistics(targets []*string) map[string]map[string]float64 {
+	ret := map[string]map[string]float64{}
+	for _, t := range targets {
+		ret[*t] = map[string]float64{}
+	}
+
+	return ret
+}
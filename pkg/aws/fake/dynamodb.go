@@ -0,0 +1,229 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// DynamoDB is an in-memory implementation of aws.DynamoDBAPI
+type DynamoDB struct {
+	backend *Backend
+}
+
+var _ gaws.DynamoDBAPI = DynamoDB{}
+
+// CheckTableExists checks whether the table exists
+func (d DynamoDB) CheckTableExists(tableName string) (bool, error) {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	_, ok := d.backend.tables[tableName]
+
+	return ok, nil
+}
+
+// CreateTable creates an empty table
+func (d DynamoDB) CreateTable(tableName string) error {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	if _, ok := d.backend.tables[tableName]; ok {
+		return awserr.New(dynamodb.ErrCodeResourceInUseException, fmt.Sprintf("table already exists: %s", tableName), nil)
+	}
+	d.backend.tables[tableName] = map[string]map[string]*dynamodb.AttributeValue{}
+
+	return nil
+}
+
+// MakeRecord puts a new deployment record
+func (d DynamoDB) MakeRecord(stack, config, tags string, asg string, tableName string, status, timezone string, additionalFields map[string]string) error {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	table, err := d.backend.table(tableName)
+	if err != nil {
+		return err
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		constants.HashKey:   {S: aws.String(asg)},
+		"deployment_status": {S: aws.String(status)},
+		"stack":             {S: aws.String(stack)},
+		"config":            {S: aws.String(config)},
+		"start_date":        {S: aws.String(tool.GetBaseTimeWithTimezone(timezone).Format(time.RFC3339))},
+		"tag":               {S: aws.String(tags)},
+	}
+
+	for k, v := range additionalFields {
+		item[k] = &dynamodb.AttributeValue{S: aws.String(v)}
+	}
+	table[asg] = item
+
+	return nil
+}
+
+// UpdateRecord updates status and fields of deployment record
+func (d DynamoDB) UpdateRecord(updateKey, asg string, tableName string, status, timezone string, updateFields map[string]interface{}) error {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	item, err := d.backend.item(tableName, asg)
+	if err != nil {
+		return err
+	}
+
+	item[updateKey] = &dynamodb.AttributeValue{S: aws.String(status)}
+	item[constants.StatusTimeStampKey[status]] = &dynamodb.AttributeValue{S: aws.String(tool.GetBaseTimeWithTimezone(timezone).Format(time.RFC3339))}
+
+	for k, v := range updateFields {
+		if k == "requestSum" {
+			item[k] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%f", v.(float64)))}
+			continue
+		}
+		item[k] = &dynamodb.AttributeValue{S: aws.String(v.(string))}
+	}
+
+	return nil
+}
+
+// GetSingleItem retrieves single item for single autoscaling group
+func (d DynamoDB) GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error) {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	table, err := d.backend.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return table[asg], nil
+}
+
+// UpdateStatistics updates request statistics of deployment record
+func (d DynamoDB) UpdateStatistics(asg string, tableName, timezone string, updateFields map[string]interface{}) error {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	item, err := d.backend.item(tableName, asg)
+	if err != nil {
+		return err
+	}
+
+	item["statistics_record_time"] = &dynamodb.AttributeValue{S: aws.String(tool.GetBaseTimeWithTimezone(timezone).Format(time.RFC3339))}
+
+	for k, v := range updateFields {
+		if !tool.IsStringInArray(k, []string{"tg_request_count", "lb_request_count"}) {
+			item[k] = &dynamodb.AttributeValue{S: aws.String(v.(string))}
+			continue
+		}
+
+		refined := map[string]*dynamodb.AttributeValue{}
+		for target, stat := range v.(map[string]map[string]float64) {
+			temp := map[string]*dynamodb.AttributeValue{}
+			for id, value := range stat {
+				temp[id] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%f", value))}
+			}
+			refined[target] = &dynamodb.AttributeValue{M: temp}
+		}
+		item[k] = &dynamodb.AttributeValue{M: refined}
+	}
+
+	return nil
+}
+
+// PutLock writes the lock item if it does not exist, it is expired or it is held by the same holder
+func (d DynamoDB) PutLock(tableName, key, holder string, acquiredAt, expiresAt time.Time) error {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	table, err := d.backend.table(tableName)
+	if err != nil {
+		return err
+	}
+
+	if item, ok := table[key]; ok && *item[constants.LockHolderKey].S != holder {
+		expires, _ := strconv.ParseInt(*item[constants.LockExpiresAtKey].N, 10, 64)
+		if expires >= time.Now().Unix() {
+			return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+		}
+	}
+
+	table[key] = map[string]*dynamodb.AttributeValue{
+		constants.HashKey:           {S: aws.String(key)},
+		constants.LockHolderKey:     {S: aws.String(holder)},
+		constants.LockAcquiredAtKey: {S: aws.String(acquiredAt.UTC().Format(time.RFC3339))},
+		constants.LockExpiresAtKey:  {N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))},
+	}
+
+	return nil
+}
+
+// DeleteLock deletes the lock item of the holder, or any lock item if holder is empty
+func (d DynamoDB) DeleteLock(tableName, key, holder string) error {
+	d.backend.mu.Lock()
+	defer d.backend.mu.Unlock()
+
+	table, err := d.backend.table(tableName)
+	if err != nil {
+		return err
+	}
+
+	item, ok := table[key]
+	if len(holder) > 0 && (!ok || *item[constants.LockHolderKey].S != holder) {
+		return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	delete(table, key)
+
+	return nil
+}
+
+// table returns the table or ResourceNotFound error
+func (b *Backend) table(tableName string) (map[string]map[string]*dynamodb.AttributeValue, error) {
+	table, ok := b.tables[tableName]
+	if !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, fmt.Sprintf("requested resource not found: %s", tableName), nil)
+	}
+
+	return table, nil
+}
+
+// item returns the record of autoscaling group, creating it if it does not exist like UpdateItem does
+func (b *Backend) item(tableName, asg string) (map[string]*dynamodb.AttributeValue, error) {
+	table, err := b.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := table[asg]; !ok {
+		table[asg] = map[string]*dynamodb.AttributeValue{
+			constants.HashKey: {S: aws.String(asg)},
+		}
+	}
+
+	return table[asg], nil
+}
@@ -0,0 +1,875 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// EC2 is an in-memory implementation of aws.EC2API
+type EC2 struct {
+	backend *Backend
+}
+
+var _ gaws.EC2API = EC2{}
+
+// GetMatchingAutoscalingGroup returns only one matching autoscaling group information
+func (e EC2) GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[name]
+	if !ok {
+		return nil, fmt.Errorf("no autoscaling group exists with name: %s", name)
+	}
+	e.backend.observe(g)
+
+	return copyGroup(g), nil
+}
+
+// GetMatchingLaunchTemplate returns the first version of launch template
+func (e EC2) GetMatchingLaunchTemplate(ltID string) (*ec2.LaunchTemplateVersion, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	lt := e.backend.findLaunchTemplate(ltID)
+	if lt == nil {
+		return nil, awserr.New("InvalidLaunchTemplateId.NotFound", fmt.Sprintf("launch template does not exist: %s", ltID), nil)
+	}
+
+	return awsutil.CopyOf(lt.Versions[0]).(*ec2.LaunchTemplateVersion), nil
+}
+
+// GetSecurityGroupDetails returns detailed information about security group
+func (e EC2) GetSecurityGroupDetails(sgIds []*string) ([]*ec2.SecurityGroup, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var ret []*ec2.SecurityGroup
+	for _, id := range sgIds {
+		sg, ok := e.backend.securityGroups[*id]
+		if !ok {
+			return nil, awserr.New("InvalidGroup.NotFound", fmt.Sprintf("security group does not exist: %s", *id), nil)
+		}
+		ret = append(ret, awsutil.CopyOf(sg).(*ec2.SecurityGroup))
+	}
+
+	return ret, nil
+}
+
+// DeleteLaunchConfigurations deletes launch configurations; fake backend only supports launch templates
+func (e EC2) DeleteLaunchConfigurations(asgName string) error {
+	return nil
+}
+
+// DeleteLaunchTemplates deletes all launch templates whose name starts with autoscaling group name
+func (e EC2) DeleteLaunchTemplates(asgName string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	for name := range e.backend.launchTemplates {
+		if strings.HasPrefix(name, asgName) {
+			delete(e.backend.launchTemplates, name)
+		}
+	}
+
+	return nil
+}
+
+// DeleteAutoscalingSet deletes autoscaling group which has no instance left
+func (e EC2) DeleteAutoscalingSet(asgName string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asgName]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asgName), nil)
+	}
+
+	if len(g.Instances) > 0 {
+		return awserr.New(autoscaling.ErrCodeResourceInUseFault, fmt.Sprintf("you cannot delete an AutoScalingGroup while there are instances still in the group: %s", asgName), nil)
+	}
+
+	if g.WarmPoolConfiguration != nil {
+		return awserr.New(autoscaling.ErrCodeResourceInUseFault, fmt.Sprintf("you cannot delete an AutoScalingGroup while there is a warm pool: %s", asgName), nil)
+	}
+
+	delete(e.backend.groups, asgName)
+	delete(e.backend.policies, asgName)
+	delete(e.backend.scheduledActions, asgName)
+	delete(e.backend.refreshes, asgName)
+	delete(e.backend.launchHooks, asgName)
+	delete(e.backend.activities, asgName)
+
+	return nil
+}
+
+// GetAllMatchingAutoscalingGroupsWithPrefix returns all autoscaling groups with prefix
+func (e EC2) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var ret []*autoscaling.Group
+	for _, g := range e.backend.groupsWithPrefix(prefix) {
+		ret = append(ret, copyGroup(g))
+	}
+
+	return ret, nil
+}
+
+// CreateNewLaunchConfiguration is not supported by fake backend
+func (e EC2) CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) bool {
+	return false
+}
+
+// ValidateSecurityGroupsConfig validates security group configuration
+func (e EC2) ValidateSecurityGroupsConfig(securityGroups []*string, primaryENI *schemas.ENIConfig, secondaryENIs []*schemas.ENIConfig) error {
+	return gaws.EC2Client{}.ValidateSecurityGroupsConfig(securityGroups, primaryENI, secondaryENIs)
+}
+
+// CreateNewLaunchTemplate creates a new launch template
+func (e EC2) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions *schemas.InstanceMarketOptions, detailedMonitoringEnabled bool, primaryENI *schemas.ENIConfig, secondaryENIs []*schemas.ENIConfig, tags []string, httpPutResponseHopLimit int64) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.launchTemplates[name]; ok {
+		return awserr.New("InvalidLaunchTemplateName.AlreadyExistsException", fmt.Sprintf("launch template name already in use: %s", name), nil)
+	}
+
+	data := &ec2.ResponseLaunchTemplateData{
+		ImageId:          aws.String(ami),
+		InstanceType:     aws.String(instanceType),
+		SecurityGroupIds: aws.StringSlice(aws.StringValueSlice(securityGroups)),
+		UserData:         aws.String(userdata),
+		EbsOptimized:     aws.Bool(ebsOptimized),
+	}
+
+	if len(keyName) > 0 {
+		data.KeyName = aws.String(keyName)
+	}
+
+	if len(iamProfileName) > 0 {
+		data.IamInstanceProfile = &ec2.LaunchTemplateIamInstanceProfileSpecification{Name: aws.String(iamProfileName)}
+	}
+
+	e.backend.createLaunchTemplate(name, data)
+
+	return nil
+}
+
+// GetSecurityGroupList returns IDs of security groups with name or id
+func (e EC2) GetSecurityGroupList(vpc string, sgList []string) ([]*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var ret []*string
+	for _, key := range sgList {
+		sg := e.backend.findSecurityGroup(key)
+		if sg == nil {
+			return nil, fmt.Errorf("security group does not exist: %s", key)
+		}
+		ret = append(ret, aws.String(*sg.GroupId))
+	}
+
+	return ret, nil
+}
+
+// MakeBlockDevices returns list of block device mapping for launch configuration
+func (e EC2) MakeBlockDevices(blocks []schemas.BlockDevice) []*autoscaling.BlockDeviceMapping {
+	return gaws.EC2Client{}.MakeBlockDevices(blocks)
+}
+
+// MakeLaunchTemplateBlockDeviceMappings returns list of block device mappings for launch template
+func (e EC2) MakeLaunchTemplateBlockDeviceMappings(blocks []schemas.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest {
+	return gaws.EC2Client{}.MakeLaunchTemplateBlockDeviceMappings(blocks)
+}
+
+// GetVPCId returns VPC ID; every name is resolved to the default VPC
+func (e EC2) GetVPCId(vpc string) (string, error) {
+	if strings.HasPrefix(vpc, "vpc-") {
+		return vpc, nil
+	}
+
+	return defaultVPC, nil
+}
+
+// CreateAutoScalingGroup creates autoscaling group and launches instances
+func (e EC2) CreateAutoScalingGroup(name, launchTemplateName, healthcheckType string, healthcheckGracePeriod int64, capacity schemas.Capacity, loadbalancers, availabilityZones []string, targetGroupArns, terminationPolicies []*string, tags []*autoscaling.Tag, subnets []string, mixedInstancePolicy schemas.MixedInstancesPolicy, hooks []*autoscaling.LifecycleHookSpecification, warmPool *schemas.WarmPool) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.groups[name]; ok {
+		return awserr.New(autoscaling.ErrCodeAlreadyExistsFault, fmt.Sprintf("AutoScalingGroup by this name already exists - %s", name), nil)
+	}
+
+	if _, ok := e.backend.launchTemplates[launchTemplateName]; !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("launch template does not exist: %s", launchTemplateName), nil)
+	}
+
+	for _, hook := range hooks {
+		if aws.StringValue(hook.LifecycleTransition) == "autoscaling:EC2_INSTANCE_LAUNCHING" {
+			e.backend.launchHooks[name] = append(e.backend.launchHooks[name], *hook.LifecycleHookName)
+		}
+	}
+
+	g := e.backend.createGroup(name, launchTemplateName, capacity, aws.StringSlice(aws.StringValueSlice(targetGroupArns)), loadbalancers, tags, mixedInstancePolicy)
+	if warmPool != nil {
+		e.backend.putWarmPool(g, *warmPool)
+	}
+
+	return nil
+}
+
+// PutWarmPool creates or updates the warm pool of autoscaling group
+func (e EC2) PutWarmPool(asg string, warmPool schemas.WarmPool) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+	e.backend.putWarmPool(g, warmPool)
+
+	return nil
+}
+
+// DeleteWarmPool deletes the warm pool and its instances
+func (e EC2) DeleteWarmPool(asg string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+
+	if g.WarmPoolConfiguration == nil {
+		return awserr.New("ValidationError", fmt.Sprintf("warm pool does not exist: %s", asg), nil)
+	}
+
+	g.WarmPoolConfiguration = nil
+	delete(e.backend.warmPools, asg)
+
+	return nil
+}
+
+// DescribeWarmPool returns the configuration and instances of warm pool
+func (e EC2) DescribeWarmPool(asg string) (*autoscaling.WarmPoolConfiguration, []*autoscaling.Instance, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return nil, nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+
+	if g.WarmPoolConfiguration == nil {
+		return nil, nil, nil
+	}
+
+	var instances []*autoscaling.Instance
+	for _, instance := range e.backend.warmPools[asg] {
+		instances = append(instances, awsutil.CopyOf(instance).(*autoscaling.Instance))
+	}
+
+	return awsutil.CopyOf(g.WarmPoolConfiguration).(*autoscaling.WarmPoolConfiguration), instances, nil
+}
+
+// GetAvailabilityZones returns availability zones of the region
+func (e EC2) GetAvailabilityZones(vpc string, azs []string) ([]string, error) {
+	if len(azs) > 0 {
+		return azs, nil
+	}
+
+	return []string{fmt.Sprintf("%sa", e.backend.Region), fmt.Sprintf("%sc", e.backend.Region)}, nil
+}
+
+// GetSubnets returns one subnet per availability zone
+func (e EC2) GetSubnets(vpc string, usePublicSubnets bool, azs []string) ([]string, error) {
+	subnetType := "private"
+	if usePublicSubnets {
+		subnetType = "public"
+	}
+
+	var ret []string
+	for _, az := range azs {
+		ret = append(ret, fmt.Sprintf("subnet-%s-%s", subnetType, az))
+	}
+
+	return ret, nil
+}
+
+// UpdateAutoScalingGroupSize updates autoscaling group size
+func (e EC2) UpdateAutoScalingGroupSize(asg string, min, max, desired, retry int64) (int64, error) {
+	if err := e.UpdateAutoScalingGroup(asg, schemas.Capacity{Min: min, Max: max, Desired: desired}); err != nil {
+		return retry - 1, err
+	}
+
+	return 0, nil
+}
+
+// CreateScalingPolicy creates scaling policy
+func (e EC2) CreateScalingPolicy(policy schemas.ScalePolicy, asgName string) (*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.groups[asgName]; !ok {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asgName), nil)
+	}
+
+	e.backend.policies[asgName] = append(e.backend.policies[asgName], policy.Name)
+
+	return aws.String(fmt.Sprintf("arn:aws:autoscaling:%s:%s:scalingPolicy:%s:autoScalingGroupName/%s:policyName/%s", e.backend.Region, accountID, e.backend.nextID(8), asgName, policy.Name)), nil
+}
+
+// EnableMetrics enables metric monitoring of autoscaling group
+func (e EC2) EnableMetrics(asgName string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asgName]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asgName), nil)
+	}
+
+	g.EnabledMetrics = []*autoscaling.EnabledMetric{
+		{Granularity: aws.String("1Minute")},
+	}
+
+	return nil
+}
+
+// CompleteLifecycleAction completes the launch lifecycle action of instance
+func (e EC2) CompleteLifecycleAction(asg, hookName, instanceID, result string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+
+	if e.backend.throttled[instanceID] < e.backend.ThrottledCompletions {
+		e.backend.throttled[instanceID]++
+		return awserr.New("Throttling", "Rate exceeded", nil)
+	}
+
+	return e.backend.completeLifecycleAction(g, hookName, instanceID, result)
+}
+
+// GenerateLifecycleHooks generates lifecycle hooks
+func (e EC2) GenerateLifecycleHooks(hooks schemas.LifecycleHooks) []*autoscaling.LifecycleHookSpecification {
+	return gaws.EC2Client{}.GenerateLifecycleHooks(hooks)
+}
+
+// GetTargetGroups returns list of target group ARN of autoscaling group
+func (e EC2) GetTargetGroups(asgName string) ([]*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asgName]
+	if !ok {
+		return nil, nil
+	}
+
+	return aws.StringSlice(aws.StringValueSlice(g.TargetGroupARNs)), nil
+}
+
+// UpdateAutoScalingGroup updates capacity of autoscaling group and launches or terminates instances
+func (e EC2) UpdateAutoScalingGroup(asg string, capacity schemas.Capacity) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+
+	if capacity.Min > capacity.Desired || capacity.Desired > capacity.Max {
+		return awserr.New("ValidationError", fmt.Sprintf("desired capacity:%d must be between the specified min size:%d and max size:%d", capacity.Desired, capacity.Min, capacity.Max), nil)
+	}
+
+	g.MinSize = aws.Int64(capacity.Min)
+	g.MaxSize = aws.Int64(capacity.Max)
+	g.DesiredCapacity = aws.Int64(capacity.Desired)
+	e.backend.scale(g)
+
+	return nil
+}
+
+// CreateScheduledActions creates scheduled actions
+func (e EC2) CreateScheduledActions(asg string, actions []schemas.ScheduledAction) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	e.backend.scheduledActions[asg] = append(e.backend.scheduledActions[asg], actions...)
+
+	return nil
+}
+
+// AttachAsgToTargetGroups attaches autoscaling group to target groups of ELB
+func (e EC2) AttachAsgToTargetGroups(asg string, targetGroups []*string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+	e.backend.attach(g, targetGroups)
+
+	return nil
+}
+
+// DetachAsgFromTargetGroups detaches autoscaling group from target groups of ELB
+func (e EC2) DetachAsgFromTargetGroups(asg string, targetGroups []*string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+	e.backend.detach(g, targetGroups)
+
+	return nil
+}
+
+// CreateSecurityGroup creates new security group
+func (e EC2) CreateSecurityGroup(sgName string, vpcID *string) (*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if e.backend.findSecurityGroup(sgName) != nil {
+		return nil, awserr.New("InvalidGroup.Duplicate", fmt.Sprintf("the security group '%s' already exists", sgName), nil)
+	}
+
+	return aws.String(*e.backend.createSecurityGroup(sgName, vpcID).GroupId), nil
+}
+
+// GetSecurityGroup returns security group ID with name
+func (e EC2) GetSecurityGroup(sgName string) (*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	sg := e.backend.findSecurityGroup(sgName)
+	if sg == nil {
+		return nil, fmt.Errorf("checked duplicated but cannot find the security group: %s", sgName)
+	}
+
+	return aws.String(*sg.GroupId), nil
+}
+
+// UpdateInboundRules adds inbound rule with cidr
+func (e EC2) UpdateInboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
+	return e.updatePermissions(sgID, true, &ec2.IpPermission{
+		IpProtocol: aws.String(protocol),
+		FromPort:   aws.Int64(fromPort),
+		ToPort:     aws.Int64(toPort),
+		IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(cidr), Description: aws.String(description)}},
+	})
+}
+
+// UpdateInboundRulesWithGroup adds inbound rule with source security group
+func (e EC2) UpdateInboundRulesWithGroup(sgID, protocol, description string, fromSg *string, fromPort, toPort int64) error {
+	return e.updatePermissions(sgID, true, &ec2.IpPermission{
+		IpProtocol:       aws.String(protocol),
+		FromPort:         aws.Int64(fromPort),
+		ToPort:           aws.Int64(toPort),
+		UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String(*fromSg), Description: aws.String(description)}},
+	})
+}
+
+// UpdateOutboundRules adds outbound rule with cidr
+func (e EC2) UpdateOutboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
+	return e.updatePermissions(sgID, false, &ec2.IpPermission{
+		IpProtocol: aws.String(protocol),
+		FromPort:   aws.Int64(fromPort),
+		ToPort:     aws.Int64(toPort),
+		IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(cidr), Description: aws.String(description)}},
+	})
+}
+
+// DeleteSecurityGroup deletes security group
+func (e EC2) DeleteSecurityGroup(sg string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.securityGroups[sg]; !ok {
+		return awserr.New("InvalidGroup.NotFound", fmt.Sprintf("security group does not exist: %s", sg), nil)
+	}
+	delete(e.backend.securityGroups, sg)
+
+	return nil
+}
+
+// RevokeInboundRulesWithGroup removes inbound rule with source security group
+func (e EC2) RevokeInboundRulesWithGroup(sgID, protocol string, fromSg *string, fromPort, toPort int64) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	sg, ok := e.backend.securityGroups[sgID]
+	if !ok {
+		return awserr.New("InvalidGroup.NotFound", fmt.Sprintf("security group does not exist: %s", sgID), nil)
+	}
+
+	var remained []*ec2.IpPermission
+	for _, p := range sg.IpPermissions {
+		if len(p.UserIdGroupPairs) > 0 && *p.UserIdGroupPairs[0].GroupId == *fromSg && *p.FromPort == fromPort && *p.ToPort == toPort {
+			continue
+		}
+		remained = append(remained, p)
+	}
+	sg.IpPermissions = remained
+
+	return nil
+}
+
+// DeleteCanaryTag deletes canary tag from autoscaling group
+func (e EC2) DeleteCanaryTag(asg string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+
+	var remained []*autoscaling.TagDescription
+	for _, t := range g.Tags {
+		if *t.Key == constants.DeploymentTagKey && *t.Value == constants.CanaryDeployment {
+			continue
+		}
+		remained = append(remained, t)
+	}
+	g.Tags = remained
+
+	return nil
+}
+
+// DescribeInstances returns instances with IDs
+func (e EC2) DescribeInstances(instanceIds []*string) ([]*ec2.Instance, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var ret []*ec2.Instance
+	for _, id := range instanceIds {
+		instance, ok := e.backend.instances[*id]
+		if !ok {
+			return nil, awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("the instance ID '%s' does not exist", *id), nil)
+		}
+		ret = append(ret, awsutil.CopyOf(instance).(*ec2.Instance))
+	}
+
+	return ret, nil
+}
+
+// ModifyNetworkInterfaces replaces security groups of network interface
+func (e EC2) ModifyNetworkInterfaces(eni *string, groups []*string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	for _, instance := range e.backend.instances {
+		for _, ni := range instance.NetworkInterfaces {
+			if *ni.NetworkInterfaceId != *eni {
+				continue
+			}
+
+			var identifiers []*ec2.GroupIdentifier
+			for _, g := range groups {
+				identifiers = append(identifiers, &ec2.GroupIdentifier{GroupId: aws.String(*g)})
+			}
+			ni.Groups = identifiers
+
+			return nil
+		}
+	}
+
+	return awserr.New("InvalidNetworkInterfaceID.NotFound", fmt.Sprintf("the networkInterface ID '%s' does not exist", *eni), nil)
+}
+
+// CreateNewLaunchTemplateVersion creates a new version of launch template with security groups
+func (e EC2) CreateNewLaunchTemplateVersion(lt *ec2.LaunchTemplateVersion, sgs []*string) (*ec2.LaunchTemplateVersion, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	target := e.backend.findLaunchTemplate(*lt.LaunchTemplateId)
+	if target == nil {
+		return nil, awserr.New("InvalidLaunchTemplateId.NotFound", fmt.Sprintf("launch template does not exist: %s", *lt.LaunchTemplateId), nil)
+	}
+
+	data := awsutil.CopyOf(target.Versions[0].LaunchTemplateData).(*ec2.ResponseLaunchTemplateData)
+	data.SecurityGroupIds = aws.StringSlice(aws.StringValueSlice(sgs))
+
+	version := &ec2.LaunchTemplateVersion{
+		LaunchTemplateId:   aws.String(target.ID),
+		LaunchTemplateName: aws.String(target.Name),
+		VersionNumber:      aws.Int64(int64(len(target.Versions) + 1)),
+		VersionDescription: aws.String("Canary Completion"),
+		DefaultVersion:     aws.Bool(false),
+		CreateTime:         aws.Time(e.backend.now()),
+		LaunchTemplateData: data,
+	}
+	target.Versions = append(target.Versions, version)
+
+	return awsutil.CopyOf(version).(*ec2.LaunchTemplateVersion), nil
+}
+
+// UpdateAutoScalingLaunchTemplate changes launch template version of autoscaling group
+func (e EC2) UpdateAutoScalingLaunchTemplate(asg string, lt *ec2.LaunchTemplateVersion) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[asg]
+	if !ok {
+		return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", asg), nil)
+	}
+
+	g.LaunchTemplate = &autoscaling.LaunchTemplateSpecification{
+		LaunchTemplateId:   aws.String(*lt.LaunchTemplateId),
+		LaunchTemplateName: lt.LaunchTemplateName,
+		Version:            aws.String(fmt.Sprintf("%d", *lt.VersionNumber)),
+	}
+
+	return nil
+}
+
+// DetachLoadBalancerTargetGroup detaches target groups from autoscaling group
+func (e EC2) DetachLoadBalancerTargetGroup(asg string, tgARNs []*string) error {
+	return e.DetachAsgFromTargetGroups(asg, tgARNs)
+}
+
+// StartInstanceRefresh starts instance refresh which replaces every instance of the group immediately
+func (e EC2) StartInstanceRefresh(name *string, instanceWarmup, minHealthyPercentage int64) (*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[*name]
+	if !ok {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", *name), nil)
+	}
+
+	for _, ir := range e.backend.refreshes[*name] {
+		if ir.EndTime == nil {
+			return nil, awserr.New(autoscaling.ErrCodeInstanceRefreshInProgressFault, fmt.Sprintf("an instance refresh is already in progress: %s", *ir.InstanceRefreshId), nil)
+		}
+	}
+
+	previous := append([]*autoscaling.Instance{}, g.Instances...)
+	for _, instance := range previous {
+		e.backend.terminate(g, instance)
+		e.backend.launch(g)
+	}
+
+	now := e.backend.now()
+	ir := &autoscaling.InstanceRefresh{
+		AutoScalingGroupName: aws.String(*name),
+		InstanceRefreshId:    aws.String(e.backend.nextID(32)),
+		PercentageComplete:   aws.Int64(100),
+		StartTime:            aws.Time(now),
+		EndTime:              aws.Time(now),
+		Status:               aws.String(autoscaling.InstanceRefreshStatusSuccessful),
+	}
+	e.backend.refreshes[*name] = append(e.backend.refreshes[*name], ir)
+
+	return aws.String(*ir.InstanceRefreshId), nil
+}
+
+// CreateLaunchTemplateVersionWithImage creates a new version of launch template based on the source version
+func (e EC2) CreateLaunchTemplateVersionWithImage(ltID, sourceVersion, ami, instanceType, userdata string, sgs []*string) (*ec2.LaunchTemplateVersion, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	target := e.backend.findLaunchTemplate(ltID)
+	if target == nil {
+		return nil, awserr.New("InvalidLaunchTemplateId.NotFound", fmt.Sprintf("launch template does not exist: %s", ltID), nil)
+	}
+
+	data := awsutil.CopyOf(target.version(sourceVersion).LaunchTemplateData).(*ec2.ResponseLaunchTemplateData)
+	data.ImageId = aws.String(ami)
+	data.InstanceType = aws.String(instanceType)
+	data.UserData = aws.String(userdata)
+	if len(sgs) > 0 {
+		data.SecurityGroupIds = aws.StringSlice(aws.StringValueSlice(sgs))
+	}
+
+	version := &ec2.LaunchTemplateVersion{
+		LaunchTemplateId:   aws.String(target.ID),
+		LaunchTemplateName: aws.String(target.Name),
+		VersionNumber:      aws.Int64(int64(len(target.Versions) + 1)),
+		VersionDescription: aws.String("Instance Refresh"),
+		DefaultVersion:     aws.Bool(false),
+		CreateTime:         aws.Time(e.backend.now()),
+		LaunchTemplateData: data,
+	}
+	target.Versions = append(target.Versions, version)
+
+	return awsutil.CopyOf(version).(*ec2.LaunchTemplateVersion), nil
+}
+
+// StartLaunchTemplateRefresh replaces instances of the group with the launch template immediately.
+// When the new image is unhealthy, the refresh fails or is rolled back with auto rollback preference.
+func (e EC2) StartLaunchTemplateRefresh(name *string, lt *autoscaling.LaunchTemplateSpecification, preferences *autoscaling.RefreshPreferences) (*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	g, ok := e.backend.groups[*name]
+	if !ok {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", *name), nil)
+	}
+
+	for _, ir := range e.backend.refreshes[*name] {
+		if ir.EndTime == nil {
+			return nil, awserr.New(autoscaling.ErrCodeInstanceRefreshInProgressFault, fmt.Sprintf("an instance refresh is already in progress: %s", *ir.InstanceRefreshId), nil)
+		}
+	}
+
+	previous := g.LaunchTemplate
+	g.LaunchTemplate = &autoscaling.LaunchTemplateSpecification{
+		LaunchTemplateId:   aws.String(*lt.LaunchTemplateId),
+		LaunchTemplateName: previous.LaunchTemplateName,
+		Version:            aws.String(aws.StringValue(lt.Version)),
+	}
+
+	var replaced []*autoscaling.Instance
+	for _, instance := range append([]*autoscaling.Instance{}, g.Instances...) {
+		if aws.BoolValue(preferences.SkipMatching) && instance.LaunchTemplate != nil && aws.StringValue(instance.LaunchTemplate.Version) == *g.LaunchTemplate.Version {
+			continue
+		}
+		e.backend.terminate(g, instance)
+		e.backend.launch(g)
+		replaced = append(replaced, g.Instances[len(g.Instances)-1])
+	}
+
+	status := autoscaling.InstanceRefreshStatusSuccessful
+	if data := e.backend.launchTemplateData(g.LaunchTemplate); data.ImageId != nil && tool.IsStringInArray(*data.ImageId, e.backend.UnhealthyImages) {
+		status = autoscaling.InstanceRefreshStatusFailed
+		if aws.BoolValue(preferences.AutoRollback) {
+			g.LaunchTemplate = previous
+			for _, instance := range replaced {
+				e.backend.terminate(g, instance)
+				e.backend.launch(g)
+			}
+			status = autoscaling.InstanceRefreshStatusRollbackSuccessful
+		}
+	}
+
+	now := e.backend.now()
+	ir := &autoscaling.InstanceRefresh{
+		AutoScalingGroupName: aws.String(*name),
+		InstanceRefreshId:    aws.String(e.backend.nextID(32)),
+		PercentageComplete:   aws.Int64(100),
+		StartTime:            aws.Time(now),
+		EndTime:              aws.Time(now),
+		Status:               aws.String(status),
+	}
+	e.backend.refreshes[*name] = append(e.backend.refreshes[*name], ir)
+
+	return aws.String(*ir.InstanceRefreshId), nil
+}
+
+// RollbackInstanceRefresh rolls back in-progress instance refresh, which never exists because refreshes finish immediately
+func (e EC2) RollbackInstanceRefresh(name *string) (*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	for _, ir := range e.backend.refreshes[*name] {
+		if ir.EndTime == nil {
+			ir.EndTime = aws.Time(e.backend.now())
+			ir.Status = aws.String(autoscaling.InstanceRefreshStatusRollbackSuccessful)
+			return aws.String(*ir.InstanceRefreshId), nil
+		}
+	}
+
+	return nil, awserr.New(autoscaling.ErrCodeActiveInstanceRefreshNotFoundFault, fmt.Sprintf("no active instance refresh exists: %s", *name), nil)
+}
+
+// DescribeInstanceRefreshes returns in-progress instance refresh or the one with ID
+func (e EC2) DescribeInstanceRefreshes(name, id *string) (*autoscaling.InstanceRefresh, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	for _, ir := range e.backend.refreshes[*name] {
+		if ir.EndTime == nil || (id != nil && *ir.InstanceRefreshId == *id) {
+			return awsutil.CopyOf(ir).(*autoscaling.InstanceRefresh), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no instance refresh exists: %s", *name)
+}
+
+// DescribeScalingActivities returns scaling activities of autoscaling group, newest first
+func (e EC2) DescribeScalingActivities(asg string) ([]*autoscaling.Activity, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var ret []*autoscaling.Activity
+	for _, activity := range e.backend.activities[asg] {
+		ret = append(ret, awsutil.CopyOf(activity).(*autoscaling.Activity))
+	}
+
+	return ret, nil
+}
+
+// DescribeInstanceTypes returns instance families supporting arm64
+func (e EC2) DescribeInstanceTypes() ([]string, error) {
+	return append([]string{}, e.backend.ArmInstanceTypes...), nil
+}
+
+// DescribeAMIArchitecture returns architecture of AMI
+func (e EC2) DescribeAMIArchitecture(amiID string) (string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if arch, ok := e.backend.images[amiID]; ok {
+		return arch, nil
+	}
+
+	return ec2.ArchitectureValuesX8664, nil
+}
+
+// updatePermissions appends ingress or egress rule to security group
+func (e EC2) updatePermissions(sgID string, inbound bool, permission *ec2.IpPermission) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	sg, ok := e.backend.securityGroups[sgID]
+	if !ok {
+		return awserr.New("InvalidGroup.NotFound", fmt.Sprintf("security group does not exist: %s", sgID), nil)
+	}
+
+	if inbound {
+		sg.IpPermissions = append(sg.IpPermissions, permission)
+	} else {
+		sg.IpPermissionsEgress = append(sg.IpPermissionsEgress, permission)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// ELB is an in-memory implementation of aws.ELBAPI
+type ELB struct {
+	backend *Backend
+}
+
+var _ gaws.ELBAPI = ELB{}
+
+// GetHealthyHostInELB returns instances of the group; classic load balancer regards every InService instance as healthy
+func (e ELB) GetHealthyHostInELB(group *autoscaling.Group, elbName string) ([]gaws.HealthcheckHost, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	ret := []gaws.HealthcheckHost{}
+	for _, instance := range group.Instances {
+		state := *instance.LifecycleState
+		if state == constants.InServiceStatus && e.backend.targetState(*instance.InstanceId) != healthyState {
+			state = "OutOfService"
+		}
+
+		ret = append(ret, gaws.HealthcheckHost{
+			InstanceID:     *instance.InstanceId,
+			LifecycleState: state,
+			Valid:          state == constants.InServiceStatus,
+		})
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,427 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// ELBV2 is an in-memory implementation of aws.ELBV2API
+type ELBV2 struct {
+	backend *Backend
+}
+
+var _ gaws.ELBV2API = ELBV2{}
+
+// GetTargetGroupARNs returns arn list of target groups
+func (e ELBV2) GetTargetGroupARNs(targetGroups []string) ([]*string, error) {
+	if len(targetGroups) == 0 {
+		return nil, nil
+	}
+
+	tgs, err := e.DescribeTargetGroups(aws.StringSlice(targetGroups))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*string
+	for _, tg := range tgs {
+		ret = append(ret, tg.TargetGroupArn)
+	}
+
+	return ret, nil
+}
+
+// GetHostInTarget gets host instance with target states of the target group
+func (e ELBV2) GetHostInTarget(group *autoscaling.Group, targetGroupArn *string, isUpdate, downSizingUpdate bool) ([]gaws.HealthcheckHost, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	states, ok := e.backend.targetHealth[*targetGroupArn]
+	if !ok {
+		return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", *targetGroupArn), nil)
+	}
+
+	return gaws.MakeHealthcheckHosts(group, states, isUpdate, downSizingUpdate), nil
+}
+
+// GetLoadBalancerFromTG returns list of loadbalancer from target groups
+func (e ELBV2) GetLoadBalancerFromTG(targetGroups []*string) ([]*string, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var lbs []string
+	for _, arn := range targetGroups {
+		tg, ok := e.backend.targetGroups[*arn]
+		if !ok {
+			return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", *arn), nil)
+		}
+
+		for _, lb := range tg.LoadBalancerArns {
+			if !tool.IsStringInArray(*lb, lbs) {
+				lbs = append(lbs, *lb)
+			}
+		}
+	}
+
+	return aws.StringSlice(lbs), nil
+}
+
+// CreateTargetGroup creates a new target group
+func (e ELBV2) CreateTargetGroup(tg *elbv2.TargetGroup, tgName string) (*elbv2.TargetGroup, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if e.backend.findTargetGroup(tgName) != nil {
+		return nil, awserr.New(elbv2.ErrCodeDuplicateTargetGroupNameException, fmt.Sprintf("a target group with the same name '%s' exists", tgName), nil)
+	}
+
+	return awsutil.CopyOf(e.backend.createTargetGroup(tgName, aws.Int64Value(tg.Port), tg.VpcId)).(*elbv2.TargetGroup), nil
+}
+
+// DescribeTargetGroups returns target groups with names
+func (e ELBV2) DescribeTargetGroups(targetGroups []*string) ([]*elbv2.TargetGroup, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var ret []*elbv2.TargetGroup
+	for _, name := range targetGroups {
+		tg := e.backend.findTargetGroup(*name)
+		if tg == nil {
+			return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("one or more target groups not found: %s", *name), nil)
+		}
+		ret = append(ret, awsutil.CopyOf(tg).(*elbv2.TargetGroup))
+	}
+
+	return ret, nil
+}
+
+// DeleteTargetGroup deletes a target group
+func (e ELBV2) DeleteTargetGroup(targetGroup *string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.targetGroups[*targetGroup]; !ok {
+		return awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", *targetGroup), nil)
+	}
+
+	for lbArn, listeners := range e.backend.listeners {
+		for _, l := range listeners {
+			if tool.IsStringInArray(*targetGroup, gaws.ForwardTargetGroupArns(l.DefaultActions)) {
+				return awserr.New(elbv2.ErrCodeResourceInUseException, fmt.Sprintf("target group '%s' is currently in use by a listener of %s", *targetGroup, lbArn), nil)
+			}
+		}
+	}
+
+	for listenerArn, rules := range e.backend.rules {
+		for _, r := range rules {
+			if tool.IsStringInArray(*targetGroup, gaws.ForwardTargetGroupArns(r.Actions)) {
+				return awserr.New(elbv2.ErrCodeResourceInUseException, fmt.Sprintf("target group '%s' is currently in use by a rule of %s", *targetGroup, listenerArn), nil)
+			}
+		}
+	}
+
+	delete(e.backend.targetGroups, *targetGroup)
+	delete(e.backend.targetHealth, *targetGroup)
+
+	return nil
+}
+
+// DeleteLoadBalancer deletes a load balancer and its listeners
+func (e ELBV2) DeleteLoadBalancer(lb string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.loadBalancers[lb]; !ok {
+		return awserr.New(elbv2.ErrCodeLoadBalancerNotFoundException, fmt.Sprintf("load balancer does not exist: %s", lb), nil)
+	}
+
+	for _, tg := range e.backend.targetGroups {
+		var remained []*string
+		for _, arn := range tg.LoadBalancerArns {
+			if *arn != lb {
+				remained = append(remained, arn)
+			}
+		}
+		tg.LoadBalancerArns = remained
+	}
+
+	for _, l := range e.backend.listeners[lb] {
+		delete(e.backend.rules, *l.ListenerArn)
+	}
+	delete(e.backend.loadBalancers, lb)
+	delete(e.backend.listeners, lb)
+
+	return nil
+}
+
+// DescribeLoadBalancers retrieves all load balancers
+func (e ELBV2) DescribeLoadBalancers() ([]*elbv2.LoadBalancer, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	var ret []*elbv2.LoadBalancer
+	for _, lb := range e.backend.loadBalancers {
+		ret = append(ret, awsutil.CopyOf(lb).(*elbv2.LoadBalancer))
+	}
+
+	return ret, nil
+}
+
+// GetMatchingLoadBalancer retrieves matching load balancer, or nil if it does not exist
+func (e ELBV2) GetMatchingLoadBalancer(lb string) (*elbv2.LoadBalancer, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	ret := e.backend.findLoadBalancer(lb)
+	if ret == nil {
+		return nil, nil
+	}
+
+	return awsutil.CopyOf(ret).(*elbv2.LoadBalancer), nil
+}
+
+// CreateLoadBalancer creates an application load balancer for canary deployment
+func (e ELBV2) CreateLoadBalancer(app string, subnets []string, groupID *string) (*elbv2.LoadBalancer, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if e.backend.findLoadBalancer(app) != nil {
+		return nil, awserr.New(elbv2.ErrCodeDuplicateLoadBalancerNameException, fmt.Sprintf("a load balancer with the same name '%s' exists", app), nil)
+	}
+
+	lb := e.backend.createLoadBalancer(app, subnets)
+	if groupID != nil {
+		lb.SecurityGroups = []*string{aws.String(*groupID)}
+	}
+
+	return awsutil.CopyOf(lb).(*elbv2.LoadBalancer), nil
+}
+
+// CreateNewListener creates a new listener and attach target group to load balancer
+func (e ELBV2) CreateNewListener(loadBalancerArn string, targetGroupArn string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.loadBalancers[loadBalancerArn]; !ok {
+		return awserr.New(elbv2.ErrCodeLoadBalancerNotFoundException, fmt.Sprintf("load balancer does not exist: %s", loadBalancerArn), nil)
+	}
+
+	tg, ok := e.backend.targetGroups[targetGroupArn]
+	if !ok {
+		return awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", targetGroupArn), nil)
+	}
+
+	e.backend.createListener(loadBalancerArn, tg)
+
+	return nil
+}
+
+// DescribeListeners describes all listeners in the load balancer
+func (e ELBV2) DescribeListeners(loadBalancerArn string) ([]*elbv2.Listener, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	if _, ok := e.backend.loadBalancers[loadBalancerArn]; !ok {
+		return nil, awserr.New(elbv2.ErrCodeLoadBalancerNotFoundException, fmt.Sprintf("load balancer does not exist: %s", loadBalancerArn), nil)
+	}
+
+	var ret []*elbv2.Listener
+	for _, l := range e.backend.listeners[loadBalancerArn] {
+		ret = append(ret, awsutil.CopyOf(l).(*elbv2.Listener))
+	}
+
+	return ret, nil
+}
+
+// ModifyListener changes default action of the listener to forward to the target group
+func (e ELBV2) ModifyListener(listenerArn *string, targetGroupArn string) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	tg, ok := e.backend.targetGroups[targetGroupArn]
+	if !ok {
+		return awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", targetGroupArn), nil)
+	}
+
+	for lbArn, listeners := range e.backend.listeners {
+		for _, l := range listeners {
+			if *l.ListenerArn != *listenerArn {
+				continue
+			}
+
+			l.DefaultActions = []*elbv2.Action{
+				{
+					TargetGroupArn: aws.String(targetGroupArn),
+					Type:           aws.String(elbv2.ActionTypeEnumForward),
+				},
+			}
+			e.backend.linkTargetGroup(tg, lbArn)
+
+			return nil
+		}
+	}
+
+	return awserr.New(elbv2.ErrCodeListenerNotFoundException, fmt.Sprintf("listener does not exist: %s", *listenerArn), nil)
+}
+
+// GetListenersForTargetGroup returns listeners whose default action forwards to the target group
+func (e ELBV2) GetListenersForTargetGroup(targetGroupArn *string) ([]*elbv2.Listener, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	tg, ok := e.backend.targetGroups[*targetGroupArn]
+	if !ok {
+		return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", *targetGroupArn), nil)
+	}
+
+	var ret []*elbv2.Listener
+	for _, lb := range tg.LoadBalancerArns {
+		for _, l := range e.backend.listeners[*lb] {
+			if tool.IsStringInArray(*targetGroupArn, gaws.ForwardTargetGroupArns(l.DefaultActions)) {
+				ret = append(ret, awsutil.CopyOf(l).(*elbv2.Listener))
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// ModifyListenerActions replaces default actions of the listener
+func (e ELBV2) ModifyListenerActions(listenerArn *string, actions []*elbv2.Action) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	for _, arn := range gaws.ForwardTargetGroupArns(actions) {
+		if _, ok := e.backend.targetGroups[arn]; !ok {
+			return awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", arn), nil)
+		}
+	}
+
+	for lbArn, listeners := range e.backend.listeners {
+		for _, l := range listeners {
+			if *l.ListenerArn != *listenerArn {
+				continue
+			}
+
+			l.DefaultActions = nil
+			for _, a := range actions {
+				l.DefaultActions = append(l.DefaultActions, awsutil.CopyOf(a).(*elbv2.Action))
+			}
+
+			for _, arn := range gaws.ForwardTargetGroupArns(actions) {
+				e.backend.linkTargetGroup(e.backend.targetGroups[arn], lbArn)
+			}
+			e.backend.recordWeights(lbArn, actions)
+
+			return nil
+		}
+	}
+
+	return awserr.New(elbv2.ErrCodeListenerNotFoundException, fmt.Sprintf("listener does not exist: %s", *listenerArn), nil)
+}
+
+// recordWeights records weights of target groups in forward actions
+func (b *Backend) recordWeights(lbArn string, actions []*elbv2.Action) {
+	for _, a := range actions {
+		if a.ForwardConfig == nil || len(a.ForwardConfig.TargetGroups) < 2 {
+			continue
+		}
+
+		weights := map[string]int64{}
+		for _, t := range a.ForwardConfig.TargetGroups {
+			weights[*b.targetGroups[*t.TargetGroupArn].TargetGroupName] = aws.Int64Value(t.Weight)
+		}
+		b.weightChanges[lbArn] = append(b.weightChanges[lbArn], weights)
+	}
+}
+
+// linkTargetGroup records that target group is used by the load balancer
+func (b *Backend) linkTargetGroup(tg *elbv2.TargetGroup, lbArn string) {
+	if !tool.IsStringInPointerArray(lbArn, tg.LoadBalancerArns) {
+		tg.LoadBalancerArns = append(tg.LoadBalancerArns, aws.String(lbArn))
+	}
+}
+
+// GetRulesForTargetGroup returns listener rules whose actions forward to the target group
+func (e ELBV2) GetRulesForTargetGroup(targetGroupArn *string) ([]*elbv2.Rule, error) {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	tg, ok := e.backend.targetGroups[*targetGroupArn]
+	if !ok {
+		return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", *targetGroupArn), nil)
+	}
+
+	var ret []*elbv2.Rule
+	for _, lb := range tg.LoadBalancerArns {
+		for _, l := range e.backend.listeners[*lb] {
+			for _, r := range e.backend.rules[*l.ListenerArn] {
+				if tool.IsStringInArray(*targetGroupArn, gaws.ForwardTargetGroupArns(r.Actions)) {
+					ret = append(ret, awsutil.CopyOf(r).(*elbv2.Rule))
+				}
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// ModifyRule replaces actions of the listener rule
+func (e ELBV2) ModifyRule(ruleArn *string, actions []*elbv2.Action) error {
+	e.backend.mu.Lock()
+	defer e.backend.mu.Unlock()
+
+	for _, arn := range gaws.ForwardTargetGroupArns(actions) {
+		if _, ok := e.backend.targetGroups[arn]; !ok {
+			return awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("target group does not exist: %s", arn), nil)
+		}
+	}
+
+	for lbArn, listeners := range e.backend.listeners {
+		for _, l := range listeners {
+			for _, r := range e.backend.rules[*l.ListenerArn] {
+				if *r.RuleArn != *ruleArn {
+					continue
+				}
+
+				r.Actions = nil
+				for _, a := range actions {
+					r.Actions = append(r.Actions, awsutil.CopyOf(a).(*elbv2.Action))
+				}
+
+				for _, arn := range gaws.ForwardTargetGroupArns(actions) {
+					e.backend.linkTargetGroup(e.backend.targetGroups[arn], lbArn)
+				}
+				e.backend.recordWeights(lbArn, actions)
+
+				return nil
+			}
+		}
+	}
+
+	return awserr.New(elbv2.ErrCodeRuleNotFoundException, fmt.Sprintf("rule does not exist: %s", *ruleArn), nil)
+}
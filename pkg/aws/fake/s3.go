@@ -0,0 +1,46 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+)
+
+// S3 is an in-memory implementation of aws.S3API
+type S3 struct {
+	backend *Backend
+}
+
+var _ gaws.S3API = S3{}
+
+// GetManifest returns object stored with PutObject
+func (s S3) GetManifest(bucket, key string) ([]byte, error) {
+	s.backend.mu.Lock()
+	defer s.backend.mu.Unlock()
+
+	body, ok := s.backend.objects[bucket+"/"+key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, fmt.Sprintf("the specified key does not exist: s3://%s/%s", bucket, key), nil)
+	}
+
+	return append([]byte{}, body...), nil
+}
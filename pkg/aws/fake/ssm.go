@@ -0,0 +1,90 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+)
+
+// SSM is an in-memory implementation of aws.SSMAPI
+type SSM struct {
+	backend *Backend
+}
+
+var _ gaws.SSMAPI = SSM{}
+
+// StartCommand records commands sent to instances and returns the command id
+func (s SSM) StartCommand(targets []string, commands []string, comment string, timeout time.Duration) (string, error) {
+	s.backend.mu.Lock()
+	defer s.backend.mu.Unlock()
+
+	for _, target := range targets {
+		if len(s.backend.hookWaits[target]) > 0 && s.backend.rejectedSends[target] < s.backend.UnregisteredSends {
+			s.backend.rejectedSends[target]++
+			return "", awserr.New(ssm.ErrCodeInvalidInstanceId, fmt.Sprintf("instance is not registered to systems manager: %s", target), nil)
+		}
+	}
+
+	return s.backend.sendCommand(targets, commands), nil
+}
+
+// GetCommandInvocation returns the result of command, which fails or never finishes when it contains any of failing or hanging commands of backend
+func (s SSM) GetCommandInvocation(commandID, instanceID string) (gaws.CommandInvocation, error) {
+	s.backend.mu.Lock()
+	defer s.backend.mu.Unlock()
+
+	for _, c := range s.backend.commands {
+		if c.ID != commandID {
+			continue
+		}
+
+		invocation := gaws.CommandInvocation{
+			CommandID:  commandID,
+			InstanceID: instanceID,
+			Status:     ssm.CommandInvocationStatusSuccess,
+		}
+
+		for _, command := range c.Commands {
+			for _, hanging := range s.backend.HangingCommands {
+				if strings.Contains(command, hanging) {
+					invocation.Status = ssm.CommandInvocationStatusInProgress
+					return invocation, nil
+				}
+			}
+
+			for _, failing := range s.backend.FailingCommands {
+				if strings.Contains(command, failing) {
+					invocation.Status = ssm.CommandInvocationStatusFailed
+					invocation.ResponseCode = 1
+					invocation.StandardError = fmt.Sprintf("%s: command failed", command)
+					return invocation, nil
+				}
+			}
+		}
+
+		return invocation, nil
+	}
+
+	return gaws.CommandInvocation{}, fmt.Errorf("command does not exist: %s", commandID)
+}
@@ -25,6 +25,11 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+// S3API is the set of S3 operations used by goployer
+type S3API interface {
+	GetManifest(bucket, key string) ([]byte, error)
+}
+
 type S3Client struct {
 	Client *s3.S3
 }
@@ -17,12 +17,42 @@ limitations under the license.
 package aws
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
+// SSMAPI is the set of systems manager operations used by goployer
+type SSMAPI interface {
+	StartCommand(targets []string, commands []string, comment string, timeout time.Duration) (string, error)
+	GetCommandInvocation(commandID, instanceID string) (CommandInvocation, error)
+}
+
+// CommandInvocation is the result of SSM command on an instance
+type CommandInvocation struct {
+	CommandID      string
+	InstanceID     string
+	Status         string
+	ResponseCode   int64
+	StandardOutput string
+	StandardError  string
+}
+
+// Done returns whether the command has finished on the instance
+func (c CommandInvocation) Done() bool {
+	switch c.Status {
+	case ssm.CommandInvocationStatusSuccess, ssm.CommandInvocationStatusFailed, ssm.CommandInvocationStatusTimedOut, ssm.CommandInvocationStatusCancelled:
+		return true
+	}
+
+	return false
+}
+
 type SSMClient struct {
 	Client *ssm.SSM
 }
@@ -40,21 +70,60 @@ func getSsmClientFn(session client.ConfigProvider, region string, creds *credent
 	return ssm.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
 }
 
-// SSM Send command
-func (s SSMClient) SendCommand(target []*string, commands []*string) bool {
+// StartCommand sends shell commands to instances and returns the command id without waiting.
+// Commands are stopped on the instance when they run longer than timeout.
+func (s SSMClient) StartCommand(targets []string, commands []string, comment string, timeout time.Duration) (string, error) {
 	input := &ssm.SendCommandInput{
 		DocumentName:   aws.String("AWS-RunShellScript"),
 		TimeoutSeconds: aws.Int64(3600),
-		InstanceIds:    target,
-		Comment:        aws.String("goployer lifecycle callbacks"),
+		InstanceIds:    aws.StringSlice(targets),
+		Comment:        aws.String(comment),
 		Parameters: map[string][]*string{
-			"commands": commands,
+			"commands":         aws.StringSlice(commands),
+			"executionTimeout": {aws.String(fmt.Sprintf("%.0f", timeout.Seconds()))},
 		},
 	}
 
-	if _, err := s.Client.SendCommand(input); err != nil {
-		return false
+	result, err := s.Client.SendCommand(input)
+	if err != nil {
+		return "", err
+	}
+
+	return *result.Command.CommandId, nil
+}
+
+// IsInstanceNotManaged checks if the command is rejected because SSM agent of the instance is not registered yet
+func IsInstanceNotManaged(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == ssm.ErrCodeInvalidInstanceId
+}
+
+// GetCommandInvocation retrieves the status and output of command on the instance
+func (s SSMClient) GetCommandInvocation(commandID, instanceID string) (CommandInvocation, error) {
+	input := &ssm.GetCommandInvocationInput{
+		CommandId:  aws.String(commandID),
+		InstanceId: aws.String(instanceID),
+	}
+
+	result, err := s.Client.GetCommandInvocation(input)
+	if err != nil {
+		// invocation is not visible for a while right after the command is sent
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeInvocationDoesNotExist {
+			return CommandInvocation{
+				CommandID:  commandID,
+				InstanceID: instanceID,
+				Status:     ssm.CommandInvocationStatusPending,
+			}, nil
+		}
+		return CommandInvocation{}, err
 	}
 
-	return true
+	return CommandInvocation{
+		CommandID:      commandID,
+		InstanceID:     instanceID,
+		Status:         aws.StringValue(result.Status),
+		ResponseCode:   aws.Int64Value(result.ResponseCode),
+		StandardOutput: aws.StringValue(result.StandardOutputContent),
+		StandardError:  aws.StringValue(result.StandardErrorContent),
+	}, nil
 }
@@ -25,6 +25,7 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -151,6 +152,7 @@ func (b Builder) SetStacks(stacks []schemas.Stack) Builder {
 		}
 
 		stacks[i].ReplacementType = strings.ToLower(stack.ReplacementType)
+		stacks[i].RegionRollout = strings.ToLower(stack.RegionRollout)
 		if stacks[i].ReplacementType == constants.RollingUpdateDeployment && stacks[i].RollingUpdateInstanceCount == 0 {
 			stacks[i].RollingUpdateInstanceCount = 1
 		}
@@ -316,6 +318,24 @@ func (b Builder) CheckValidation() error {
 		stackMap[stack.Env]++
 	}
 
+	if err := ValidStackDependencies(b.Stacks); err != nil {
+		return err
+	}
+
+	if b.AwsConfig.Hooks != nil {
+		if err := ValidHooks(*b.AwsConfig.Hooks); err != nil {
+			return err
+		}
+	}
+
+	if err := ValidEventSinks(b.AwsConfig.EventSinks); err != nil {
+		return err
+	}
+
+	if err := ValidNotifiers(b.AwsConfig.Notifiers); err != nil {
+		return err
+	}
+
 	// check validations in API test templates
 	if len(b.APITestTemplates) > 0 {
 		for _, att := range b.APITestTemplates {
@@ -439,6 +459,12 @@ func (b Builder) CheckValidation() error {
 					if l.HeartbeatTimeout == 0 {
 						Logger.Warnf("you didn't specify the heartbeat timeout. you might have to wait too long time.")
 					}
+
+					if l.Validation != nil {
+						if err := ValidLifecycleHookValidation(l, b.Config.PollingInterval); err != nil {
+							return err
+						}
+					}
 				}
 			}
 
@@ -455,10 +481,42 @@ func (b Builder) CheckValidation() error {
 					if l.HeartbeatTimeout == 0 {
 						Logger.Warnf("you didn't specify the heartbeat timeout. you might have to wait too long time.")
 					}
+
+					if l.Validation != nil {
+						return fmt.Errorf("validation can only be used in launch_transition: %s", l.LifecycleHookName)
+					}
 				}
 			}
 		}
 
+		if stack.LifecycleCallbacks != nil {
+			if err := ValidLifecycleCallbacks(*stack.LifecycleCallbacks); err != nil {
+				return err
+			}
+		}
+
+		if stack.InstanceHealthCheck != nil {
+			if err := ValidInstanceHealthCheck(*stack.InstanceHealthCheck); err != nil {
+				return err
+			}
+		}
+
+		if stack.HealthyFor < 0 {
+			return fmt.Errorf("healthy_for cannot be negative: %s", stack.Stack)
+		}
+
+		if stack.HealthyFor > 0 && stack.HealthyFor >= b.Config.Timeout {
+			return fmt.Errorf("healthy_for should be smaller than timeout: %s", stack.HealthyFor)
+		}
+
+		if stack.MaxInstanceReplacements < 0 {
+			return fmt.Errorf("max_instance_replacements cannot be negative: %s", stack.Stack)
+		}
+
+		if err := ValidNotifiers(stack.Notifiers); err != nil {
+			return fmt.Errorf("%s: %s", err.Error(), stack.Stack)
+		}
+
 		if stack.ReplacementType == constants.BlueGreenDeployment {
 			if stack.TerminationDelayRate > 100 {
 				return fmt.Errorf("termination_delay_rate cannot exceed 100. It should be 0<=x<=100")
@@ -469,6 +527,150 @@ func (b Builder) CheckValidation() error {
 			}
 		}
 
+		if len(stack.MaxSurge) > 0 || len(stack.MaxUnavailable) > 0 {
+			if stack.ReplacementType != constants.RollingUpdateDeployment {
+				return fmt.Errorf("max_surge and max_unavailable can only be used with rollingupdate replacement type: %s", stack.Stack)
+			}
+
+			if _, err := tool.ParseCountOrPercentage(stack.MaxSurge, stack.Capacity.Desired, true); err != nil {
+				return fmt.Errorf("invalid max_surge: %s", err.Error())
+			}
+
+			if _, err := tool.ParseCountOrPercentage(stack.MaxUnavailable, stack.Capacity.Desired, false); err != nil {
+				return fmt.Errorf("invalid max_unavailable: %s", err.Error())
+			}
+		}
+
+		if len(stack.RegionRollout) > 0 && !tool.IsStringInArray(stack.RegionRollout, []string{constants.ParallelRollout, constants.SequentialRollout}) {
+			return fmt.Errorf("region_rollout should be parallel or sequential: %s", stack.RegionRollout)
+		}
+
+		if stack.RegionRollout != constants.SequentialRollout && (stack.PauseBetweenRegions != 0 || stack.RegionGate != nil) {
+			return fmt.Errorf("pause_between_regions and region_gate can only be used with sequential region_rollout: %s", stack.Stack)
+		}
+
+		if stack.PauseBetweenRegions < 0 {
+			return fmt.Errorf("pause_between_regions cannot be negative: %s", stack.PauseBetweenRegions)
+		}
+
+		if stack.RegionGate != nil && !stack.RegionGate.HealthCheck && len(stack.RegionGate.Alarms) == 0 {
+			return fmt.Errorf("you have to enable health_check or specify alarms of region_gate: %s", stack.Stack)
+		}
+
+		if stack.Approval != nil {
+			if len(stack.Approval.Method) > 0 && !tool.IsStringInArray(stack.Approval.Method, []string{constants.CLIApproval, constants.ServerApproval, constants.SlackApproval}) {
+				return fmt.Errorf("approval method is not supported: %s", stack.Approval.Method)
+			}
+
+			if len(stack.Approval.DefaultAction) > 0 && !tool.IsStringInArray(stack.Approval.DefaultAction, []string{constants.ApproveAction, constants.RejectAction}) {
+				return fmt.Errorf("default_action of approval should be approve or reject: %s", stack.Approval.DefaultAction)
+			}
+
+			if stack.Approval.Timeout < 0 {
+				return fmt.Errorf("timeout of approval cannot be negative: %s", stack.Approval.Timeout)
+			}
+
+			if len(stack.Approval.Address) > 0 {
+				if _, _, err := net.SplitHostPort(stack.Approval.Address); err != nil {
+					return fmt.Errorf("address of approval should be host:port: %s", stack.Approval.Address)
+				}
+			}
+		}
+
+		if stack.Bake != nil {
+			if stack.ReplacementType != constants.BlueGreenDeployment {
+				return fmt.Errorf("bake can only be used with bluegreen replacement type: %s", stack.Stack)
+			}
+
+			if err := ValidBake(*stack.Bake, b.Config.Timeout); err != nil {
+				return err
+			}
+
+			for _, m := range stack.Bake.Metrics {
+				if m.Dimension == constants.AutoScalingGroupDimension {
+					continue
+				}
+
+				for _, region := range stack.Regions {
+					if len(region.HealthcheckTargetGroup) == 0 && len(region.BlueGreenTargetGroups) == 0 {
+						return fmt.Errorf("healthcheck_target_group is required for target_group dimension of bake metric: %s", region.Region)
+					}
+				}
+			}
+		}
+
+		if stack.InstanceRefresh != nil {
+			if stack.ReplacementType != constants.RefreshDeployment {
+				return fmt.Errorf("instance_refresh can only be used with refresh replacement type: %s", stack.Stack)
+			}
+
+			if err := ValidInstanceRefresh(*stack.InstanceRefresh); err != nil {
+				return err
+			}
+		}
+
+		if stack.ReplacementType == constants.RefreshDeployment && stack.MixedInstancesPolicy.Enabled {
+			return fmt.Errorf("mixed_instances_policy cannot be used with refresh replacement type: %s", stack.Stack)
+		}
+
+		if stack.WarmPool != nil {
+			if stack.MixedInstancesPolicy.Enabled || stack.InstanceMarketOptions != nil {
+				return fmt.Errorf("warm_pool cannot be used with mixed_instances_policy or spot instances: %s", stack.Stack)
+			}
+
+			if err := ValidWarmPool(*stack.WarmPool); err != nil {
+				return err
+			}
+		}
+
+		if stack.CanaryTrafficShifting != nil {
+			if stack.ReplacementType != constants.CanaryDeployment {
+				return fmt.Errorf("canary_traffic_shifting can only be used with canary replacement type: %s", stack.Stack)
+			}
+
+			if len(stack.CanaryTrafficShifting.Steps) == 0 {
+				return errors.New("you have to specify at least one step of canary_traffic_shifting")
+			}
+
+			prevWeight := int64(0)
+			totalPause := time.Duration(0)
+			for _, step := range stack.CanaryTrafficShifting.Steps {
+				if step.Weight <= prevWeight || step.Weight > 100 {
+					return fmt.Errorf("weights of canary_traffic_shifting steps should increase within 0<x<=100: %d", step.Weight)
+				}
+
+				if step.Pause < 0 {
+					return fmt.Errorf("pause of canary_traffic_shifting step cannot be negative: %s", step.Pause)
+				}
+				prevWeight = step.Weight
+				totalPause += step.Pause
+			}
+
+			// health check and canary analysis are done at the end of pause, so traffic is not shifted again without them.
+			// The last step under 100 is followed by full traffic, so it also needs pause.
+			for _, step := range stack.CanaryTrafficShifting.Steps {
+				if step.Pause == 0 && step.Weight < 100 {
+					return fmt.Errorf("pause of canary_traffic_shifting step is required unless weight is 100: %d", step.Weight)
+				}
+			}
+
+			if totalPause >= b.Config.Timeout {
+				return fmt.Errorf("total pause of canary_traffic_shifting should be smaller than timeout: %s", totalPause)
+			}
+
+			for _, region := range stack.Regions {
+				if len(region.HealthcheckTargetGroup) == 0 {
+					return fmt.Errorf("healthcheck_target_group is required for canary_traffic_shifting: %s", region.Region)
+				}
+			}
+
+			if stack.CanaryTrafficShifting.Analysis != nil {
+				if err := ValidCanaryAnalysis(*stack.CanaryTrafficShifting); err != nil {
+					return err
+				}
+			}
+		}
+
 		for _, region := range stack.Regions {
 			// Check ami id
 			if len(targetAmi) == 0 && len(region.AmiID) == 0 {
@@ -480,8 +682,29 @@ func (b Builder) CheckValidation() error {
 				return errors.New("you have to specify the instance type")
 			}
 
+			// Check blue/green target groups
+			if len(region.BlueGreenTargetGroups) > 0 {
+				if stack.ReplacementType != constants.BlueGreenDeployment {
+					return fmt.Errorf("blue_green_target_groups can only be used with bluegreen replacement type: %s", stack.Stack)
+				}
+
+				if len(region.BlueGreenTargetGroups) != 2 || region.BlueGreenTargetGroups[0] == region.BlueGreenTargetGroups[1] {
+					return fmt.Errorf("blue_green_target_groups should have two different target groups: %s", region.Region)
+				}
+
+				for _, tg := range region.BlueGreenTargetGroups {
+					if tool.IsStringInArray(tg, region.TargetGroups) {
+						return fmt.Errorf("target group of blue_green_target_groups cannot be in target_groups: %s", tg)
+					}
+				}
+
+				if region.HealthcheckTargetGroup != "" || region.HealthcheckLB != "" {
+					return errors.New("health check is done with the idle target group of blue_green_target_groups")
+				}
+			}
+
 			// Check target group
-			if len(region.TargetGroups) > 0 && region.HealthcheckTargetGroup == "" {
+			if len(region.TargetGroups) > 0 && region.HealthcheckTargetGroup == "" && len(region.BlueGreenTargetGroups) == 0 {
 				return errors.New("you have to choose one target group as healthcheck_target_group")
 			}
 
@@ -532,9 +755,23 @@ func (b Builder) CheckValidation() error {
 				return errors.New("you can only set spot_instance_pools with lowest-price spot_allocation_strategy")
 			}
 
-			if len(stack.MixedInstancesPolicy.Override) == 0 {
+			if stack.MixedInstancesPolicy.InstanceRequirements != nil {
+				if len(stack.MixedInstancesPolicy.Override) > 0 || len(stack.MixedInstancesPolicy.WeightedCapacity) > 0 {
+					return fmt.Errorf("instance_requirements cannot be used with override_instance_types or weighted_capacity: %s", stack.Stack)
+				}
+
+				if err := ValidInstanceRequirements(*stack.MixedInstancesPolicy.InstanceRequirements); err != nil {
+					return err
+				}
+			} else if len(stack.MixedInstancesPolicy.Override) == 0 {
 				return errors.New("you have to set at least one instance type to use in override")
 			}
+
+			if err := ValidWeightedCapacity(stack.MixedInstancesPolicy, stack.Capacity); err != nil {
+				return err
+			}
+		} else if len(stack.MixedInstancesPolicy.WeightedCapacity) > 0 || stack.MixedInstancesPolicy.InstanceRequirements != nil {
+			return fmt.Errorf("weighted_capacity and instance_requirements can only be used with enabled mixed_instances_policy: %s", stack.Stack)
 		}
 
 		if stack.APITestEnabled {
@@ -641,6 +878,9 @@ func buildStructFromYaml(yamlFile []byte) (schemas.AWSConfig, []schemas.Stack, [
 		Userdata:         yamlConfig.Userdata,
 		Tags:             yamlConfig.Tags,
 		ScheduledActions: yamlConfig.ScheduledActions,
+		Hooks:            yamlConfig.Hooks,
+		EventSinks:       yamlConfig.EventSinks,
+		Notifiers:        yamlConfig.Notifiers,
 	}
 
 	Stacks := yamlConfig.Stacks
@@ -767,6 +1007,371 @@ func ContainsActions(target string, sas []schemas.ScheduledAction) bool {
 	return false
 }
 
+// ValidCanaryAnalysis checks metrics of canary analysis
+func ValidCanaryAnalysis(shifting schemas.CanaryTrafficShifting) error {
+	if len(shifting.Analysis.Metrics) == 0 {
+		return errors.New("you have to specify at least one metric of canary analysis")
+	}
+
+	// analysis is done at the end of pause so at least one step should wait with partial traffic
+	analyzed := false
+	for _, step := range shifting.Steps {
+		if step.Pause > 0 && step.Weight < 100 {
+			analyzed = true
+		}
+	}
+	if !analyzed {
+		return errors.New("canary analysis needs at least one step which has pause and weight under 100")
+	}
+
+	for _, m := range shifting.Analysis.Metrics {
+		if len(m.Metric) == 0 {
+			return errors.New("metric name of canary analysis is required")
+		}
+
+		if !validMetricStatistic(m.Statistic) {
+			return fmt.Errorf("statistic of canary analysis is not allowed: %s", m.Statistic)
+		}
+
+		if len(m.Dimension) > 0 && !tool.IsStringInArray(m.Dimension, []string{constants.TargetGroupDimension, constants.AutoScalingGroupDimension}) {
+			return fmt.Errorf("dimension of canary analysis should be %s or %s: %s", constants.TargetGroupDimension, constants.AutoScalingGroupDimension, m.Dimension)
+		}
+
+		switch m.Comparison {
+		case constants.AbsoluteComparison, constants.DifferenceComparison:
+		case constants.RatioComparison:
+			if m.Threshold <= 0 {
+				return fmt.Errorf("threshold of ratio comparison should be positive: %s", m.Metric)
+			}
+		default:
+			return fmt.Errorf("comparison of canary analysis should be one of absolute, ratio and difference: %s", m.Comparison)
+		}
+	}
+
+	return nil
+}
+
+// ValidLifecycleHookValidation checks validation of launch lifecycle hook which goployer completes by itself
+func ValidLifecycleHookValidation(hook schemas.LifecycleHookSpecification, pollingInterval time.Duration) error {
+	if len(hook.NotificationTargetARN) > 0 {
+		return fmt.Errorf("validation cannot be used with notification_target_arn: %s", hook.LifecycleHookName)
+	}
+
+	if len(hook.Validation.Commands) == 0 {
+		return fmt.Errorf("commands of validation are required: %s", hook.LifecycleHookName)
+	}
+
+	if hook.Validation.Timeout < 0 {
+		return fmt.Errorf("timeout of validation cannot be negative: %s", hook.LifecycleHookName)
+	}
+
+	// lifecycle action is completed with default result by autoscaling group when heartbeat timeout comes first.
+	// The result of validation is applied in the next polling after timeout.
+	if hook.HeartbeatTimeout > 0 && hook.Validation.Timeout > 0 && hook.Validation.Timeout+pollingInterval >= time.Duration(hook.HeartbeatTimeout)*time.Second {
+		return fmt.Errorf("timeout of validation should be smaller than heartbeat_timeout by more than polling interval: %s", hook.LifecycleHookName)
+	}
+
+	return nil
+}
+
+// ValidHooks checks that every hook has either a command or an HTTP request
+func ValidHooks(hooks schemas.Hooks) error {
+	steps := map[string][]schemas.Hook{
+		constants.BeforeDeployHook:     hooks.BeforeDeploy,
+		constants.AfterHealthCheckHook: hooks.AfterHealthCheck,
+		constants.BeforeCleanupHook:    hooks.BeforeCleanup,
+		constants.AfterDeployHook:      hooks.AfterDeploy,
+		constants.OnFailureHook:        hooks.OnFailure,
+	}
+
+	for _, step := range []string{constants.BeforeDeployHook, constants.AfterHealthCheckHook, constants.BeforeCleanupHook, constants.AfterDeployHook, constants.OnFailureHook} {
+		for _, h := range steps[step] {
+			if len(h.Command) == 0 && h.HTTP == nil {
+				return fmt.Errorf("either command or http is required for %s hook", step)
+			}
+
+			if len(h.Command) > 0 && h.HTTP != nil {
+				return fmt.Errorf("command and http cannot be used together in %s hook: %s", step, h.Command)
+			}
+
+			if h.Timeout < 0 {
+				return fmt.Errorf("timeout of %s hook cannot be negative", step)
+			}
+
+			if h.HTTP == nil {
+				continue
+			}
+
+			if len(h.HTTP.URL) == 0 {
+				return fmt.Errorf("url of http is required for %s hook", step)
+			}
+
+			if len(h.HTTP.Method) > 0 && !tool.IsStringInArray(strings.ToUpper(h.HTTP.Method), constants.AllowedRequestMethod) {
+				return fmt.Errorf("method of %s hook is not allowed: %s", step, h.HTTP.Method)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidEventSinks checks that every event sink has a destination for its type
+func ValidEventSinks(sinks []schemas.EventSink) error {
+	for _, sink := range sinks {
+		switch sink.Type {
+		case constants.FileEventSink:
+			if len(sink.Path) == 0 {
+				return errors.New("path is required for file event sink")
+			}
+		case constants.WebhookEventSink:
+			if !strings.HasPrefix(sink.URL, "http://") && !strings.HasPrefix(sink.URL, "https://") {
+				return fmt.Errorf("url of webhook event sink should start with http:// or https://: %s", sink.URL)
+			}
+		default:
+			return fmt.Errorf("type of event sink is not allowed: %s", sink.Type)
+		}
+	}
+
+	return nil
+}
+
+// ValidLifecycleCallbacks checks timeout and failure policy of lifecycle callbacks
+func ValidLifecycleCallbacks(callbacks schemas.LifecycleCallbacks) error {
+	if callbacks.Timeout < 0 {
+		return errors.New("timeout of lifecycle_callbacks cannot be negative")
+	}
+
+	if len(callbacks.FailurePolicy) > 0 && !tool.IsStringInArray(callbacks.FailurePolicy, constants.AllowedFailurePolicies) {
+		return fmt.Errorf("failure_policy of lifecycle_callbacks is not allowed: %s", callbacks.FailurePolicy)
+	}
+
+	if callbacks.Retries < 0 {
+		return errors.New("retries of lifecycle_callbacks cannot be negative")
+	}
+
+	if callbacks.Retries > 0 && callbacks.FailurePolicy != constants.RetryPolicy {
+		return fmt.Errorf("retries of lifecycle_callbacks can only be used with %s policy", constants.RetryPolicy)
+	}
+
+	return nil
+}
+
+// ValidNotifiers checks that every notifier has a destination for its type
+func ValidNotifiers(notifiers []schemas.Notifier) error {
+	for _, n := range notifiers {
+		switch n.Type {
+		case constants.TeamsNotifier, constants.DiscordNotifier, constants.WebhookNotifier:
+			url := os.ExpandEnv(n.URL)
+			if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+				return fmt.Errorf("url of %s notifier should start with http:// or https://: %s", n.Type, n.URL)
+			}
+		case constants.EmailNotifier:
+			if n.SMTP == nil || len(n.SMTP.Host) == 0 {
+				return errors.New("smtp host is required for email notifier")
+			}
+
+			if n.SMTP.Port < 0 || n.SMTP.Port > 65535 {
+				return fmt.Errorf("smtp port of email notifier should be 1<=x<=65535: %d", n.SMTP.Port)
+			}
+
+			if len(n.SMTP.From) == 0 || len(n.SMTP.To) == 0 {
+				return errors.New("from and to are required for email notifier")
+			}
+		default:
+			return fmt.Errorf("type of notifier is not allowed: %s", n.Type)
+		}
+	}
+
+	return nil
+}
+
+// ValidInstanceHealthCheck checks request and expected response of instance health check
+func ValidInstanceHealthCheck(check schemas.InstanceHealthCheck) error {
+	if !strings.HasPrefix(check.Path, "/") {
+		return fmt.Errorf("path of instance_health_check should start with /: %s", check.Path)
+	}
+
+	if check.Port <= 0 || check.Port > 65535 {
+		return fmt.Errorf("port of instance_health_check should be 1<=x<=65535: %d", check.Port)
+	}
+
+	if check.ExpectedStatus != 0 && (check.ExpectedStatus < 100 || check.ExpectedStatus > 599) {
+		return fmt.Errorf("expected_status of instance_health_check is not a valid status code: %d", check.ExpectedStatus)
+	}
+
+	if len(check.BodyRegex) > 0 {
+		if _, err := regexp.Compile(check.BodyRegex); err != nil {
+			return fmt.Errorf("body_regex of instance_health_check is not valid: %s", err.Error())
+		}
+	}
+
+	if check.Timeout < 0 {
+		return errors.New("timeout of instance_health_check cannot be negative")
+	}
+
+	return nil
+}
+
+// ValidBake checks duration and watched metrics of bake period
+func ValidBake(bake schemas.Bake, timeout time.Duration) error {
+	if bake.Duration <= 0 {
+		return errors.New("duration of bake should be positive")
+	}
+
+	if bake.Duration >= timeout {
+		return fmt.Errorf("duration of bake should be smaller than timeout: %s", bake.Duration)
+	}
+
+	if len(bake.Alarms) == 0 && len(bake.Metrics) == 0 {
+		return errors.New("you have to specify at least one alarm or metric of bake")
+	}
+
+	for _, m := range bake.Metrics {
+		if len(m.Metric) == 0 {
+			return errors.New("metric name of bake is required")
+		}
+
+		if !validMetricStatistic(m.Statistic) {
+			return fmt.Errorf("statistic of bake metric is not allowed: %s", m.Statistic)
+		}
+
+		if len(m.Dimension) > 0 && !tool.IsStringInArray(m.Dimension, []string{constants.TargetGroupDimension, constants.AutoScalingGroupDimension}) {
+			return fmt.Errorf("dimension of bake metric should be %s or %s: %s", constants.TargetGroupDimension, constants.AutoScalingGroupDimension, m.Dimension)
+		}
+	}
+
+	return nil
+}
+
+// validMetricStatistic checks if the statistic is one of CloudWatch statistics or a percentile
+func validMetricStatistic(statistic string) bool {
+	percentile := regexp.MustCompile(`^p\d{1,2}(\.\d+)?$`)
+	return tool.IsStringInArray(statistic, constants.AllowedCanaryMetricStatistics) || percentile.MatchString(statistic)
+}
+
+// ValidInstanceRefresh checks preferences of instance refresh
+func ValidInstanceRefresh(refresh schemas.InstanceRefresh) error {
+	if refresh.InstanceWarmup < 0 {
+		return fmt.Errorf("instance_warmup of instance_refresh cannot be negative: %d", refresh.InstanceWarmup)
+	}
+
+	if refresh.MinHealthyPercentage < 0 || refresh.MinHealthyPercentage > 100 {
+		return fmt.Errorf("min_healthy_percentage of instance_refresh should be 0<=x<=100: %d", refresh.MinHealthyPercentage)
+	}
+
+	prev := int64(0)
+	for _, p := range refresh.CheckpointPercentages {
+		if p <= prev || p > 100 {
+			return fmt.Errorf("checkpoint_percentages of instance_refresh should increase within 0<x<=100: %d", p)
+		}
+		prev = p
+	}
+
+	if refresh.CheckpointDelay < 0 {
+		return fmt.Errorf("checkpoint_delay of instance_refresh cannot be negative: %s", refresh.CheckpointDelay)
+	}
+
+	if refresh.CheckpointDelay > 0 && len(refresh.CheckpointPercentages) == 0 {
+		return errors.New("checkpoint_delay of instance_refresh needs checkpoint_percentages")
+	}
+
+	return nil
+}
+
+// ValidWarmPool checks configuration of warm pool
+func ValidWarmPool(warmPool schemas.WarmPool) error {
+	if warmPool.MinSize < 0 {
+		return fmt.Errorf("min_size of warm_pool cannot be negative: %d", warmPool.MinSize)
+	}
+
+	if warmPool.MaxPreparedCapacity < 0 {
+		return fmt.Errorf("max_prepared_capacity of warm_pool cannot be negative: %d", warmPool.MaxPreparedCapacity)
+	}
+
+	if len(warmPool.PoolState) > 0 && !tool.IsStringInArray(warmPool.PoolState, constants.AllowedWarmPoolStates) {
+		return fmt.Errorf("pool_state of warm_pool should be one of %s: %s", strings.Join(constants.AllowedWarmPoolStates, ", "), warmPool.PoolState)
+	}
+
+	return nil
+}
+
+// ValidInstanceRequirements checks attributes of instance types for attribute-based instance type selection
+func ValidInstanceRequirements(requirements schemas.InstanceRequirements) error {
+	if requirements.VCpuCount.Min <= 0 {
+		return fmt.Errorf("min of vcpu_count should be larger than 0: %d", requirements.VCpuCount.Min)
+	}
+
+	if requirements.VCpuCount.Max > 0 && requirements.VCpuCount.Max < requirements.VCpuCount.Min {
+		return fmt.Errorf("max of vcpu_count cannot be smaller than min: %d < %d", requirements.VCpuCount.Max, requirements.VCpuCount.Min)
+	}
+
+	if requirements.MemoryMiB.Min <= 0 {
+		return fmt.Errorf("min of memory_mib should be larger than 0: %d", requirements.MemoryMiB.Min)
+	}
+
+	if requirements.MemoryMiB.Max > 0 && requirements.MemoryMiB.Max < requirements.MemoryMiB.Min {
+		return fmt.Errorf("max of memory_mib cannot be smaller than min: %d < %d", requirements.MemoryMiB.Max, requirements.MemoryMiB.Min)
+	}
+
+	for _, m := range requirements.CPUManufacturers {
+		if !tool.IsStringInArray(m, constants.AllowedCPUManufacturers) {
+			return fmt.Errorf("cpu_manufacturers should be one of %s: %s", strings.Join(constants.AllowedCPUManufacturers, ", "), m)
+		}
+	}
+
+	if len(requirements.BurstablePerformance) > 0 && !tool.IsStringInArray(requirements.BurstablePerformance, constants.AllowedBurstablePerformance) {
+		return fmt.Errorf("burstable_performance should be one of %s: %s", strings.Join(constants.AllowedBurstablePerformance, ", "), requirements.BurstablePerformance)
+	}
+
+	for _, g := range requirements.InstanceGenerations {
+		if !tool.IsStringInArray(g, constants.AllowedInstanceGenerations) {
+			return fmt.Errorf("instance_generations should be one of %s: %s", strings.Join(constants.AllowedInstanceGenerations, ", "), g)
+		}
+	}
+
+	if requirements.SpotMaxPricePercentage < 0 {
+		return fmt.Errorf("spot_max_price_percentage cannot be negative: %d", requirements.SpotMaxPricePercentage)
+	}
+
+	return nil
+}
+
+// ValidWeightedCapacity checks that every instance type in override has its weight and the capacity can hold the largest one.
+// Capacity of autoscaling group is counted in units of weight when weights are specified.
+func ValidWeightedCapacity(policy schemas.MixedInstancesPolicy, capacity schemas.Capacity) error {
+	if len(policy.WeightedCapacity) == 0 {
+		return nil
+	}
+
+	for instanceType := range policy.WeightedCapacity {
+		if !tool.IsStringInArray(instanceType, policy.Override) {
+			return fmt.Errorf("instance type in weighted_capacity does not exist in override_instance_types: %s", instanceType)
+		}
+	}
+
+	largest := int64(0)
+	for _, instanceType := range policy.Override {
+		weight, ok := policy.WeightedCapacity[instanceType]
+		if !ok {
+			return fmt.Errorf("weighted_capacity should be specified for every instance type in override_instance_types: %s", instanceType)
+		}
+
+		if weight < 1 || weight > 999 {
+			return fmt.Errorf("weighted_capacity should be 1<=x<=999: %s / %d", instanceType, weight)
+		}
+
+		if weight > largest {
+			largest = weight
+		}
+	}
+
+	if capacity.Max < largest {
+		return fmt.Errorf("max capacity cannot be smaller than the largest weighted_capacity: %d < %d", capacity.Max, largest)
+	}
+
+	return nil
+}
+
 // ValidCronExpression checks if the cron expression is valid or not
 // It should be [Minute] [Hour] [Day_of_Month] [Month_of_Year] [Day_of_Week]
 func ValidCronExpression(expression string) (bool, error) {
@@ -406,8 +406,113 @@ func TestCheckValidationStack(t *testing.T) {
 	if err := b.CheckValidation(); err == nil || err.Error() != "notification_target_arn is needed if role_arn is not empty: test" {
 		t.Errorf("validation failed: lifecycle hook role")
 	}
+
+	b.Stacks[0].LifecycleHooks = &schemas.LifecycleHooks{
+		LaunchTransition: []schemas.LifecycleHookSpecification{
+			{
+				LifecycleHookName: "test",
+				HeartbeatTimeout:  300,
+				Validation:        &schemas.LifecycleHookValidation{},
+			},
+		},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "commands of validation are required: test" {
+		t.Errorf("validation failed: lifecycle hook validation commands")
+	}
+
+	b.Stacks[0].LifecycleHooks.LaunchTransition[0].Validation = &schemas.LifecycleHookValidation{
+		Commands: []string{"curl -f localhost:8080/health"},
+		Timeout:  5 * time.Minute,
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "timeout of validation should be smaller than heartbeat_timeout by more than polling interval: test" {
+		t.Errorf("validation failed: lifecycle hook validation timeout")
+	}
+
+	// result after timeout is applied in the next polling, which comes after heartbeat timeout
+	b.Stacks[0].LifecycleHooks.LaunchTransition[0].Validation.Timeout = 5*time.Minute - b.Config.PollingInterval
+	if err := b.CheckValidation(); err == nil || err.Error() != "timeout of validation should be smaller than heartbeat_timeout by more than polling interval: test" {
+		t.Errorf("validation failed: lifecycle hook validation timeout with polling interval")
+	}
+
+	b.Stacks[0].LifecycleHooks.LaunchTransition[0].NotificationTargetARN = "arn:test"
+	b.Stacks[0].LifecycleHooks.LaunchTransition[0].RoleARN = "arn:test"
+	b.Stacks[0].LifecycleHooks.LaunchTransition[0].Validation.Timeout = time.Minute
+	if err := b.CheckValidation(); err == nil || err.Error() != "validation cannot be used with notification_target_arn: test" {
+		t.Errorf("validation failed: lifecycle hook validation with notification")
+	}
+
+	b.Stacks[0].LifecycleHooks = &schemas.LifecycleHooks{
+		TerminateTransition: []schemas.LifecycleHookSpecification{
+			{
+				LifecycleHookName: "test",
+				Validation:        &schemas.LifecycleHookValidation{Commands: []string{"true"}},
+			},
+		},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "validation can only be used in launch_transition: test" {
+		t.Errorf("validation failed: lifecycle hook validation in terminate transition")
+	}
 	b.Stacks[0].LifecycleHooks = nil
 
+	b.Stacks[0].LifecycleCallbacks = &schemas.LifecycleCallbacks{
+		PreTerminatePastClusters: []string{"service hello stop"},
+		FailurePolicy:            "ignore",
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "failure_policy of lifecycle_callbacks is not allowed: ignore" {
+		t.Errorf("validation failed: lifecycle callbacks failure policy")
+	}
+
+	b.Stacks[0].LifecycleCallbacks.FailurePolicy = constants.AbortPolicy
+	b.Stacks[0].LifecycleCallbacks.Retries = 3
+	if err := b.CheckValidation(); err == nil || err.Error() != "retries of lifecycle_callbacks can only be used with retry policy" {
+		t.Errorf("validation failed: lifecycle callbacks retries")
+	}
+	b.Stacks[0].LifecycleCallbacks = nil
+
+	b.Stacks[0].InstanceHealthCheck = &schemas.InstanceHealthCheck{
+		Path: "health",
+		Port: 8080,
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "path of instance_health_check should start with /: health" {
+		t.Errorf("validation failed: instance health check path")
+	}
+
+	b.Stacks[0].InstanceHealthCheck.Path = "/health"
+	b.Stacks[0].InstanceHealthCheck.Port = 0
+	if err := b.CheckValidation(); err == nil || err.Error() != "port of instance_health_check should be 1<=x<=65535: 0" {
+		t.Errorf("validation failed: instance health check port")
+	}
+
+	b.Stacks[0].InstanceHealthCheck.Port = 8080
+	b.Stacks[0].InstanceHealthCheck.ExpectedStatus = 1000
+	if err := b.CheckValidation(); err == nil || err.Error() != "expected_status of instance_health_check is not a valid status code: 1000" {
+		t.Errorf("validation failed: instance health check expected status")
+	}
+
+	b.Stacks[0].InstanceHealthCheck.ExpectedStatus = 200
+	b.Stacks[0].InstanceHealthCheck.BodyRegex = "(ok"
+	if err := b.CheckValidation(); err == nil || !strings.HasPrefix(err.Error(), "body_regex of instance_health_check is not valid") {
+		t.Errorf("validation failed: instance health check body regex")
+	}
+	b.Stacks[0].InstanceHealthCheck = nil
+
+	b.Stacks[0].HealthyFor = -time.Second
+	if err := b.CheckValidation(); err == nil || err.Error() != "healthy_for cannot be negative: "+b.Stacks[0].Stack {
+		t.Errorf("validation failed: negative healthy_for")
+	}
+
+	b.Stacks[0].HealthyFor = b.Config.Timeout
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("healthy_for should be smaller than timeout: %s", b.Config.Timeout) {
+		t.Errorf("validation failed: healthy_for longer than timeout")
+	}
+	b.Stacks[0].HealthyFor = 0
+
+	b.Stacks[0].MaxInstanceReplacements = -1
+	if err := b.CheckValidation(); err == nil || err.Error() != "max_instance_replacements cannot be negative: "+b.Stacks[0].Stack {
+		t.Errorf("validation failed: negative max_instance_replacements")
+	}
+	b.Stacks[0].MaxInstanceReplacements = 0
+
 	b.Stacks[0].ReplacementType = constants.BlueGreenDeployment
 	b.Stacks[0].TerminationDelayRate = 101
 	if err := b.CheckValidation(); err == nil || err.Error() != "termination_delay_rate cannot exceed 100. It should be 0<=x<=100" {
@@ -485,6 +590,43 @@ func TestCheckValidationStack(t *testing.T) {
 	}
 	b.Stacks[0].MixedInstancesPolicy.Override = []string{"t3.large"}
 
+	b.Stacks[0].MixedInstancesPolicy.Override = []string{"t3.large", "t3.xlarge"}
+	b.Stacks[0].MixedInstancesPolicy.WeightedCapacity = map[string]int64{"t3.large": 1}
+	if err := b.CheckValidation(); err == nil || err.Error() != "weighted_capacity should be specified for every instance type in override_instance_types: t3.xlarge" {
+		t.Errorf("validation failed: weighted capacity of every override")
+	}
+	b.Stacks[0].MixedInstancesPolicy.WeightedCapacity["t3.xlarge"] = 2
+	b.Stacks[0].Capacity = schemas.Capacity{Min: 1, Max: 1, Desired: 1}
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "max capacity cannot be smaller than the largest weighted_capacity: 1 < 2" {
+		t.Errorf("validation failed: max capacity with weighted capacity")
+	}
+	b.Stacks[0].Capacity = schemas.Capacity{}
+	b.Stacks[0].MixedInstancesPolicy.Override = []string{"t3.large"}
+	b.Stacks[0].MixedInstancesPolicy.WeightedCapacity = nil
+
+	b.Stacks[0].MixedInstancesPolicy.InstanceRequirements = &schemas.InstanceRequirements{
+		VCpuCount: schemas.RequirementRange{Min: 2, Max: 4},
+		MemoryMiB: schemas.RequirementRange{Min: 4096},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("instance_requirements cannot be used with override_instance_types or weighted_capacity: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: instance requirements with override")
+	}
+	b.Stacks[0].MixedInstancesPolicy.Override = nil
+	b.Stacks[0].MixedInstancesPolicy.InstanceRequirements.CPUManufacturers = []string{"arm"}
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "cpu_manufacturers should be one of intel, amd, amazon-web-services: arm" {
+		t.Errorf("validation failed: instance requirements cpu manufacturers")
+	}
+	b.Stacks[0].MixedInstancesPolicy.InstanceRequirements.CPUManufacturers = []string{"intel"}
+	b.Stacks[0].MixedInstancesPolicy.InstanceRequirements.VCpuCount.Max = 1
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "max of vcpu_count cannot be smaller than min: 1 < 2" {
+		t.Errorf("validation failed: instance requirements vcpu count")
+	}
+	b.Stacks[0].MixedInstancesPolicy.InstanceRequirements = nil
+	b.Stacks[0].MixedInstancesPolicy.Override = []string{"t3.large"}
+
 	b.Stacks[0].APITestEnabled = true
 	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("you have to specify the name of template for api test: %s", b.Stacks[0].Stack) {
 		t.Errorf("validation failed: stack api_test_enabled but no manifest")
@@ -496,6 +638,280 @@ func TestCheckValidationStack(t *testing.T) {
 	}
 	b.Stacks[0].APITestTemplate = "api-test"
 
+	b.Stacks[0].CanaryTrafficShifting = &schemas.CanaryTrafficShifting{
+		Steps: []schemas.CanaryStep{{Weight: 50}, {Weight: 20}},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("canary_traffic_shifting can only be used with canary replacement type: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: canary_traffic_shifting without canary")
+	}
+	b.Stacks[0].ReplacementType = constants.CanaryDeployment
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "weights of canary_traffic_shifting steps should increase within 0<x<=100: 20" {
+		t.Errorf("validation failed: canary_traffic_shifting weights")
+	}
+	b.Stacks[0].CanaryTrafficShifting.Steps[1].Weight = 100
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "pause of canary_traffic_shifting step is required unless weight is 100: 50" {
+		t.Errorf("validation failed: canary_traffic_shifting step without pause")
+	}
+	b.Stacks[0].CanaryTrafficShifting.Steps[0].Pause = time.Minute
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].CanaryTrafficShifting.Steps = []schemas.CanaryStep{{Weight: 100}}
+	b.Stacks[0].CanaryTrafficShifting.Analysis = &schemas.CanaryAnalysis{
+		Metrics: []schemas.CanaryMetric{
+			{
+				Metric:     "HTTPCode_Target_5XX_Count",
+				Statistic:  "Sum",
+				Comparison: "less",
+			},
+		},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "canary analysis needs at least one step which has pause and weight under 100" {
+		t.Errorf("validation failed: canary analysis without pause")
+	}
+	b.Stacks[0].CanaryTrafficShifting.Steps = []schemas.CanaryStep{{Weight: 50, Pause: time.Minute}, {Weight: 100}}
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "comparison of canary analysis should be one of absolute, ratio and difference: less" {
+		t.Errorf("validation failed: canary analysis comparison")
+	}
+	b.Stacks[0].CanaryTrafficShifting.Analysis.Metrics[0].Comparison = constants.RatioComparison
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "threshold of ratio comparison should be positive: HTTPCode_Target_5XX_Count" {
+		t.Errorf("validation failed: canary analysis ratio threshold")
+	}
+	b.Stacks[0].CanaryTrafficShifting.Analysis.Metrics[0].Threshold = 1.5
+
+	b.Stacks[0].CanaryTrafficShifting.Analysis.Metrics[0].Statistic = "p999"
+	if err := b.CheckValidation(); err == nil || err.Error() != "statistic of canary analysis is not allowed: p999" {
+		t.Errorf("validation failed: canary analysis statistic")
+	}
+	b.Stacks[0].CanaryTrafficShifting.Analysis.Metrics[0].Statistic = "p99.9"
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].MaxSurge = "2"
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("max_surge and max_unavailable can only be used with rollingupdate replacement type: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: max_surge without rolling update")
+	}
+	b.Stacks[0].ReplacementType = constants.RollingUpdateDeployment
+	b.Stacks[0].CanaryTrafficShifting = nil
+
+	b.Stacks[0].MaxUnavailable = "half%"
+	if err := b.CheckValidation(); err == nil || err.Error() != "invalid max_unavailable: percentage should be an integer between 0% and 100%: half%" {
+		t.Errorf("validation failed: wrong max_unavailable")
+	}
+	b.Stacks[0].MaxUnavailable = "25%"
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].Regions[0].BlueGreenTargetGroups = []string{"test-blue"}
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("blue_green_target_groups can only be used with bluegreen replacement type: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: blue_green_target_groups without blue/green")
+	}
+	b.Stacks[0].ReplacementType = constants.BlueGreenDeployment
+	b.Stacks[0].MaxSurge = ""
+	b.Stacks[0].MaxUnavailable = ""
+
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("blue_green_target_groups should have two different target groups: %s", b.Stacks[0].Regions[0].Region) {
+		t.Errorf("validation failed: blue_green_target_groups count")
+	}
+	b.Stacks[0].Regions[0].BlueGreenTargetGroups = []string{"test-blue", "test-green"}
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "health check is done with the idle target group of blue_green_target_groups" {
+		t.Errorf("validation failed: blue_green_target_groups with healthcheck target group")
+	}
+	b.Stacks[0].Regions[0].HealthcheckTargetGroup = ""
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].Approval = &schemas.Approval{Method: "email"}
+	if err := b.CheckValidation(); err == nil || err.Error() != "approval method is not supported: email" {
+		t.Errorf("validation failed: approval method")
+	}
+	b.Stacks[0].Approval.Method = constants.SlackApproval
+
+	b.Stacks[0].Approval.DefaultAction = "skip"
+	if err := b.CheckValidation(); err == nil || err.Error() != "default_action of approval should be approve or reject: skip" {
+		t.Errorf("validation failed: approval default action")
+	}
+	b.Stacks[0].Approval.DefaultAction = constants.RejectAction
+
+	b.Stacks[0].Approval.Address = "localhost"
+	if err := b.CheckValidation(); err == nil || err.Error() != "address of approval should be host:port: localhost" {
+		t.Errorf("validation failed: approval address")
+	}
+	b.Stacks[0].Approval.Address = ":9037"
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].InstanceRefresh = &schemas.InstanceRefresh{CheckpointPercentages: []int64{50, 30}}
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("instance_refresh can only be used with refresh replacement type: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: instance_refresh without refresh")
+	}
+	b.Stacks[0].ReplacementType = constants.RefreshDeployment
+	b.Stacks[0].Regions[0].BlueGreenTargetGroups = nil
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "checkpoint_percentages of instance_refresh should increase within 0<x<=100: 30" {
+		t.Errorf("validation failed: instance_refresh checkpoint percentages")
+	}
+	b.Stacks[0].InstanceRefresh.CheckpointPercentages = []int64{50, 100}
+
+	b.Stacks[0].InstanceRefresh.MinHealthyPercentage = 120
+	if err := b.CheckValidation(); err == nil || err.Error() != "min_healthy_percentage of instance_refresh should be 0<=x<=100: 120" {
+		t.Errorf("validation failed: instance_refresh min healthy percentage")
+	}
+	b.Stacks[0].InstanceRefresh.MinHealthyPercentage = 50
+	b.Stacks[0].InstanceRefresh.CheckpointDelay = 10 * time.Minute
+
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("mixed_instances_policy cannot be used with refresh replacement type: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: refresh with mixed instances policy")
+	}
+	b.Stacks[0].MixedInstancesPolicy.Enabled = false
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].Bake = &schemas.Bake{Duration: constants.DefaultDeploymentTimeout}
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("bake can only be used with bluegreen replacement type: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: bake without blue/green")
+	}
+	b.Stacks[0].ReplacementType = constants.BlueGreenDeployment
+	b.Stacks[0].InstanceRefresh = nil
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "duration of bake should be smaller than timeout: 1h0m0s" {
+		t.Errorf("validation failed: bake duration")
+	}
+	b.Stacks[0].Bake.Duration = 10 * time.Minute
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "you have to specify at least one alarm or metric of bake" {
+		t.Errorf("validation failed: bake without alarms")
+	}
+	b.Stacks[0].Bake.Alarms = []string{"hello-5xx"}
+	b.Stacks[0].Bake.Metrics = []schemas.BakeMetric{
+		{
+			Metric:    "HTTPCode_Target_5XX_Count",
+			Statistic: "Sum",
+			Dimension: "instance",
+		},
+	}
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "dimension of bake metric should be target_group or autoscaling_group: instance" {
+		t.Errorf("validation failed: bake metric dimension")
+	}
+	b.Stacks[0].Bake.Metrics[0].Dimension = constants.TargetGroupDimension
+
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("healthcheck_target_group is required for target_group dimension of bake metric: %s", b.Stacks[0].Regions[0].Region) {
+		t.Errorf("validation failed: bake metric without target group")
+	}
+	b.Stacks[0].Bake.Metrics[0].Dimension = constants.AutoScalingGroupDimension
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].RegionRollout = "canary"
+	if err := b.CheckValidation(); err == nil || err.Error() != "region_rollout should be parallel or sequential: canary" {
+		t.Errorf("validation failed: region rollout")
+	}
+	b.Stacks[0].RegionRollout = constants.ParallelRollout
+	b.Stacks[0].PauseBetweenRegions = 5 * time.Minute
+
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("pause_between_regions and region_gate can only be used with sequential region_rollout: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: pause between regions without sequential rollout")
+	}
+	b.Stacks[0].RegionRollout = constants.SequentialRollout
+	b.Stacks[0].RegionGate = &schemas.RegionGate{}
+
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("you have to enable health_check or specify alarms of region_gate: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: empty region gate")
+	}
+	b.Stacks[0].RegionGate.HealthCheck = true
+
+	if err := b.CheckValidation(); err != nil {
+		t.Errorf("validation failed: no error")
+	}
+
+	b.Stacks[0].WarmPool = &schemas.WarmPool{MinSize: 1, PoolState: "Paused"}
+	if err := b.CheckValidation(); err == nil || err.Error() != "pool_state of warm_pool should be one of Stopped, Running, Hibernated: Paused" {
+		t.Errorf("validation failed: warm pool state")
+	}
+	b.Stacks[0].WarmPool.PoolState = "Stopped"
+	b.Stacks[0].WarmPool.MaxPreparedCapacity = -1
+
+	if err := b.CheckValidation(); err == nil || err.Error() != "max_prepared_capacity of warm_pool cannot be negative: -1" {
+		t.Errorf("validation failed: warm pool max prepared capacity")
+	}
+	b.Stacks[0].WarmPool.MaxPreparedCapacity = 4
+	b.Stacks[0].MixedInstancesPolicy.Enabled = true
+
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("warm_pool cannot be used with mixed_instances_policy or spot instances: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: warm pool with mixed instances policy")
+	}
+	b.Stacks[0].MixedInstancesPolicy.Enabled = false
+
+	b.AwsConfig.Hooks = &schemas.Hooks{
+		BeforeDeploy: []schemas.Hook{{Name: "notify"}},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "either command or http is required for before_deploy hook" {
+		t.Errorf("validation failed: hook without command and http")
+	}
+
+	b.AwsConfig.Hooks.BeforeDeploy[0].Command = "./scripts/check.sh"
+	b.AwsConfig.Hooks.AfterDeploy = []schemas.Hook{{HTTP: &schemas.HTTPHook{URL: "https://example.com/deploy", Method: "delete"}}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "method of after_deploy hook is not allowed: delete" {
+		t.Errorf("validation failed: hook http method")
+	}
+	b.AwsConfig.Hooks.AfterDeploy[0].HTTP.Method = "post"
+
+	b.AwsConfig.EventSinks = []schemas.EventSink{{Type: "file"}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "path is required for file event sink" {
+		t.Errorf("validation failed: file event sink without path")
+	}
+
+	b.AwsConfig.EventSinks[0].Path = "events.ndjson"
+	b.AwsConfig.EventSinks = append(b.AwsConfig.EventSinks, schemas.EventSink{Type: "webhook", URL: "example.com/events"})
+	if err := b.CheckValidation(); err == nil || err.Error() != "url of webhook event sink should start with http:// or https://: example.com/events" {
+		t.Errorf("validation failed: webhook event sink url")
+	}
+
+	b.AwsConfig.EventSinks[1].URL = "https://example.com/events"
+	b.AwsConfig.EventSinks = append(b.AwsConfig.EventSinks, schemas.EventSink{Type: "kafka"})
+	if err := b.CheckValidation(); err == nil || err.Error() != "type of event sink is not allowed: kafka" {
+		t.Errorf("validation failed: event sink type")
+	}
+	b.AwsConfig.EventSinks = b.AwsConfig.EventSinks[:2]
+
+	b.AwsConfig.Notifiers = []schemas.Notifier{{Type: "slack"}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "type of notifier is not allowed: slack" {
+		t.Errorf("validation failed: notifier type")
+	}
+
+	b.AwsConfig.Notifiers = []schemas.Notifier{{Type: "teams", URL: "outlook.office.com/webhook"}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "url of teams notifier should start with http:// or https://: outlook.office.com/webhook" {
+		t.Errorf("validation failed: notifier url")
+	}
+	b.AwsConfig.Notifiers[0].URL = "https://outlook.office.com/webhook"
+
+	b.Stacks[0].Notifiers = []schemas.Notifier{{Type: "email", SMTP: &schemas.SMTP{Host: "smtp.example.com"}}}
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("from and to are required for email notifier: %s", b.Stacks[0].Stack) {
+		t.Errorf("validation failed: email notifier without recipients")
+	}
+	b.Stacks[0].Notifiers[0].SMTP.From = "goployer@example.com"
+	b.Stacks[0].Notifiers[0].SMTP.To = []string{"ops@example.com"}
+
 	if err := b.CheckValidation(); err != nil {
 		t.Errorf("validation failed: no error")
 	}
@@ -0,0 +1,131 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// StackDependencies returns names of stacks which each stack waits for.
+// A stack depends on stacks in depends_on and on every stack with a lower wave.
+// Stacks which are not in the list are ignored.
+func StackDependencies(stacks []schemas.Stack) map[string][]string {
+	exists := map[string]bool{}
+	for _, s := range stacks {
+		exists[s.Stack] = true
+	}
+
+	ret := map[string][]string{}
+	for _, s := range stacks {
+		deps := map[string]bool{}
+		for _, d := range s.DependsOn {
+			if exists[d] && d != s.Stack {
+				deps[d] = true
+			}
+		}
+
+		for _, other := range stacks {
+			if other.Wave < s.Wave {
+				deps[other.Stack] = true
+			}
+		}
+
+		var names []string
+		for d := range deps {
+			names = append(names, d)
+		}
+		sort.Strings(names)
+		ret[s.Stack] = names
+	}
+
+	return ret
+}
+
+// StackWaves groups stacks so that every stack only depends on stacks in the previous groups.
+// The order of stacks in each group follows the manifest.
+func StackWaves(stacks []schemas.Stack) ([][]string, error) {
+	deps := StackDependencies(stacks)
+
+	level := map[string]int{}
+	var waves [][]string
+	for len(level) < len(stacks) {
+		var wave []string
+		for _, s := range stacks {
+			if _, ok := level[s.Stack]; ok {
+				continue
+			}
+
+			ready := true
+			for _, d := range deps[s.Stack] {
+				if l, ok := level[d]; !ok || l == len(waves) {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				wave = append(wave, s.Stack)
+				level[s.Stack] = len(waves)
+			}
+		}
+
+		if len(wave) == 0 {
+			var remained []string
+			for _, s := range stacks {
+				if _, ok := level[s.Stack]; !ok {
+					remained = append(remained, s.Stack)
+				}
+			}
+			return nil, fmt.Errorf("circular dependency exists between stacks: %s", strings.Join(remained, ", "))
+		}
+
+		waves = append(waves, wave)
+	}
+
+	return waves, nil
+}
+
+// ValidStackDependencies checks depends_on and wave of stacks
+func ValidStackDependencies(stacks []schemas.Stack) error {
+	exists := map[string]bool{}
+	for _, s := range stacks {
+		exists[s.Stack] = true
+	}
+
+	for _, s := range stacks {
+		if s.Wave < 0 {
+			return fmt.Errorf("wave cannot be negative: %s", s.Stack)
+		}
+
+		for _, d := range s.DependsOn {
+			if d == s.Stack {
+				return fmt.Errorf("stack cannot depend on itself: %s", s.Stack)
+			}
+
+			if !exists[d] {
+				return fmt.Errorf("stack in depends_on does not exist: %s / %s", d, s.Stack)
+			}
+		}
+	}
+
+	_, err := StackWaves(stacks)
+	return err
+}
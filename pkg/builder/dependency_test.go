@@ -0,0 +1,121 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestStackWaves(t *testing.T) {
+	testData := []struct {
+		title    string
+		stacks   []schemas.Stack
+		expected [][]string
+		err      string
+	}{
+		{
+			title:    "no dependency",
+			stacks:   []schemas.Stack{{Stack: "api"}, {Stack: "worker"}},
+			expected: [][]string{{"api", "worker"}},
+		},
+		{
+			title:    "depends_on",
+			stacks:   []schemas.Stack{{Stack: "worker", DependsOn: []string{"api"}}, {Stack: "api"}, {Stack: "batch"}},
+			expected: [][]string{{"api", "batch"}, {"worker"}},
+		},
+		{
+			title:    "wave",
+			stacks:   []schemas.Stack{{Stack: "worker", Wave: 2}, {Stack: "api", Wave: 1}, {Stack: "web", Wave: 1}},
+			expected: [][]string{{"api", "web"}, {"worker"}},
+		},
+		{
+			title:    "depends_on inside wave",
+			stacks:   []schemas.Stack{{Stack: "api"}, {Stack: "worker", Wave: 1}, {Stack: "cron", Wave: 1, DependsOn: []string{"worker"}}},
+			expected: [][]string{{"api"}, {"worker"}, {"cron"}},
+		},
+		{
+			title:  "circular dependency",
+			stacks: []schemas.Stack{{Stack: "api", DependsOn: []string{"worker"}}, {Stack: "worker", DependsOn: []string{"api"}}, {Stack: "batch"}},
+			err:    "circular dependency exists between stacks: api, worker",
+		},
+		{
+			title:  "depends_on stack in later wave",
+			stacks: []schemas.Stack{{Stack: "api", DependsOn: []string{"worker"}}, {Stack: "worker", Wave: 1}},
+			err:    "circular dependency exists between stacks: api, worker",
+		},
+	}
+
+	for _, td := range testData {
+		waves, err := StackWaves(td.stacks)
+		if len(td.err) > 0 {
+			if err == nil || err.Error() != td.err {
+				t.Errorf("%s: expected error %q, got %v", td.title, td.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", td.title, err.Error())
+			continue
+		}
+
+		if diff := deep.Equal(waves, td.expected); diff != nil {
+			t.Errorf("%s: %v", td.title, diff)
+		}
+	}
+}
+
+func TestValidStackDependencies(t *testing.T) {
+	testData := []struct {
+		stacks []schemas.Stack
+		err    string
+	}{
+		{
+			stacks: []schemas.Stack{{Stack: "api"}, {Stack: "worker", DependsOn: []string{"api"}}},
+		},
+		{
+			stacks: []schemas.Stack{{Stack: "api", DependsOn: []string{"api"}}},
+			err:    "stack cannot depend on itself: api",
+		},
+		{
+			stacks: []schemas.Stack{{Stack: "worker", DependsOn: []string{"web"}}},
+			err:    "stack in depends_on does not exist: web / worker",
+		},
+		{
+			stacks: []schemas.Stack{{Stack: "api", Wave: -1}},
+			err:    "wave cannot be negative: api",
+		},
+	}
+
+	for _, td := range testData {
+		err := ValidStackDependencies(td.stacks)
+		if len(td.err) == 0 {
+			if err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != td.err {
+			t.Errorf("expected error %q, got %v", td.err, err)
+		}
+	}
+}
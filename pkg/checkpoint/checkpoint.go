@@ -0,0 +1,192 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// ErrNotFound is returned when there is no checkpoint of the deployment
+var ErrNotFound = errors.New("checkpoint does not exist")
+
+// Deployment is the persisted progress of a whole deployment
+type Deployment struct {
+	ID               string                     `json:"id"`
+	Status           string                     `json:"status"`
+	Config           schemas.Config             `json:"config"`
+	AwsConfig        schemas.AWSConfig          `json:"aws_config"`
+	APITestTemplates []*schemas.APITestTemplate `json:"api_test_templates,omitempty"`
+	Stacks           []Stack                    `json:"stacks"`
+	UpdatedAt        time.Time                  `json:"updated_at"`
+}
+
+// Stack is the persisted progress of a stack deployer
+type Stack struct {
+	Stack                schemas.Stack               `json:"stack"`
+	StepStatus           map[int64]bool              `json:"step_status"`
+	AsgNames             map[string]string           `json:"asg_names"`
+	PrevAsgs             map[string][]string         `json:"prev_asgs"`
+	PrevInstances        map[string][]string         `json:"prev_instances"`
+	PrevVersions         map[string][]int            `json:"prev_versions"`
+	PrevInstanceCount    map[string]schemas.Capacity `json:"prev_instance_count"`
+	PrevCapacity         map[string]schemas.Capacity `json:"prev_capacity"`
+	SecurityGroup        map[string]*string          `json:"security_group,omitempty"`
+	LatestAsg            map[string]string           `json:"latest_asg"`
+	DeploymentFlag       map[string]string           `json:"deployment_flag,omitempty"`
+	AppliedCapacity      *schemas.Capacity           `json:"applied_capacity,omitempty"`
+	RolledBack           bool                        `json:"rolled_back,omitempty"`
+	DetachedTargetGroups map[string][]string         `json:"detached_target_groups,omitempty"`
+}
+
+// Store saves and loads checkpoints of deployments
+type Store interface {
+	Save(deployment Deployment) error
+	Load(id string) (*Deployment, error)
+}
+
+// FileStore keeps checkpoints as JSON files in a local directory
+type FileStore struct {
+	Dir string
+}
+
+// DynamoDBStore keeps checkpoints in the metric table
+type DynamoDBStore struct {
+	Client   aws.DynamoDBAPI
+	Table    string
+	Timezone string
+}
+
+// NewStore returns the metric table store if metrics are stored in DynamoDB, otherwise the local file store
+func NewStore(mc schemas.MetricConfig, client aws.MetricClient) Store {
+	if mc.Enabled && client.DynamoDBService != nil && (len(mc.Storage.Type) == 0 || mc.Storage.Type == constants.DefaultMetricStorageType) {
+		return DynamoDBStore{
+			Client:   client.DynamoDBService,
+			Table:    mc.Storage.Name,
+			Timezone: mc.Metrics.BaseTimezone,
+		}
+	}
+
+	return FileStore{Dir: fileStoreDir()}
+}
+
+// fileStoreDir returns the directory of checkpoint files under the cache directory of the user,
+// so that the deployment can be resumed from any working directory
+func fileStoreDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+
+	return filepath.Join(base, constants.DefaultCheckpointDirectory)
+}
+
+// NewDeploymentID creates an identifier of deployment with application name and start time
+func NewDeploymentID(application string, start time.Time) string {
+	return fmt.Sprintf("%s-%s", application, start.UTC().Format("20060102150405"))
+}
+
+// LastStep returns the last step which is completed in a row
+func (s Stack) LastStep() int64 {
+	var step int64
+	for s.StepStatus[step+1] {
+		step++
+	}
+	return step
+}
+
+// Save writes the checkpoint to the file of deployment id
+func (f FileStore) Save(deployment Deployment) error {
+	if err := os.MkdirAll(f.Dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(deployment, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first not to leave a broken checkpoint
+	tmp := f.path(deployment.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, f.path(deployment.ID))
+}
+
+// Load reads the checkpoint of deployment id
+func (f FileStore) Load(id string) (*Deployment, error) {
+	data, err := os.ReadFile(f.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s in %s", ErrNotFound, id, f.Dir)
+		}
+		return nil, err
+	}
+
+	var deployment Deployment
+	if err := json.Unmarshal(data, &deployment); err != nil {
+		return nil, err
+	}
+
+	return &deployment, nil
+}
+
+// path returns the file path of checkpoint
+func (f FileStore) path(id string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%s.json", id))
+}
+
+// Save stores the checkpoint to the record of deployment id
+func (d DynamoDBStore) Save(deployment Deployment) error {
+	data, err := json.Marshal(deployment)
+	if err != nil {
+		return err
+	}
+
+	return d.Client.UpdateStatistics(deployment.ID, d.Table, d.Timezone, map[string]interface{}{
+		constants.CheckpointKey: string(data),
+	})
+}
+
+// Load retrieves the checkpoint from the record of deployment id
+func (d DynamoDBStore) Load(id string) (*Deployment, error) {
+	item, err := d.Client.GetSingleItem(id, d.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil || item[constants.CheckpointKey] == nil || item[constants.CheckpointKey].S == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	var deployment Deployment
+	if err := json.Unmarshal([]byte(*item[constants.CheckpointKey].S), &deployment); err != nil {
+		return nil, err
+	}
+
+	return &deployment, nil
+}
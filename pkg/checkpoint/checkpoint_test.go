@@ -0,0 +1,96 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package checkpoint
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	if err := backend.MetricClient().DynamoDBService.CreateTable("goployer-metrics"); err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := Deployment{
+		ID:     "hello-20201012093015",
+		Status: constants.CheckpointRunning,
+		Config: schemas.Config{Region: "ap-northeast-2"},
+		Stacks: []Stack{
+			{
+				Stack:           schemas.Stack{Stack: "artd"},
+				StepStatus:      map[int64]bool{constants.StepCheckPrevious: true, constants.StepDeploy: true},
+				AsgNames:        map[string]string{"ap-northeast-2": "hello-dev_apnortheast2-v001"},
+				PrevAsgs:        map[string][]string{"ap-northeast-2": {"hello-dev_apnortheast2-v000"}},
+				PrevCapacity:    map[string]schemas.Capacity{"hello-dev_apnortheast2-v000": {Min: 2, Max: 2, Desired: 2}},
+				AppliedCapacity: &schemas.Capacity{Min: 2, Max: 2, Desired: 2},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	stores := map[string]Store{
+		"file":     FileStore{Dir: dir},
+		"dynamodb": DynamoDBStore{Client: backend.MetricClient().DynamoDBService, Table: "goployer-metrics", Timezone: "UTC"},
+	}
+
+	for name, store := range stores {
+		if _, err := store.Load(deployment.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected not found error, got %v", name, err)
+		} else if name == "file" && !strings.Contains(err.Error(), dir) {
+			t.Errorf("directory of checkpoint is not shown: %v", err)
+		}
+
+		if err := store.Save(deployment); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		loaded, err := store.Load(deployment.ID)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if diff := deep.Equal(*loaded, deployment); diff != nil {
+			t.Errorf("%s: %v", name, diff)
+		}
+	}
+}
+
+func TestStack_LastStep(t *testing.T) {
+	testData := []struct {
+		status   map[int64]bool
+		expected int64
+	}{
+		{status: map[int64]bool{}, expected: 0},
+		{status: map[int64]bool{constants.StepCheckPrevious: true}, expected: constants.StepCheckPrevious},
+		{status: map[int64]bool{constants.StepCheckPrevious: true, constants.StepDeploy: true, constants.StepCleanPreviousVersion: true}, expected: constants.StepDeploy},
+	}
+
+	for _, td := range testData {
+		if step := (Stack{StepStatus: td.status}).LastStep(); step != td.expected {
+			t.Errorf("expected %d, got %d", td.expected, step)
+		}
+	}
+}
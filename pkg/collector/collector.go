@@ -48,6 +48,14 @@ type TargetMetrics struct {
 	MappingFunction func(*HelperStruct, *Logger.Logger, aws.MetricClient, string) (map[string]interface{}, error)
 }
 
+// DeploymentRecord is a deployment stamped on the metric table
+type DeploymentRecord struct {
+	Status   string
+	Stack    schemas.Stack
+	Config   schemas.Config
+	Userdata string
+}
+
 type HelperStruct struct {
 	BaseTimeDuration float64
 	StartDate        time.Time
@@ -160,6 +168,39 @@ func (c Collector) UpdateStatus(asg string, status string, updateFields map[stri
 	return nil
 }
 
+// GetDeploymentRecord retrieves the deployment record of autoscaling group
+func (c Collector) GetDeploymentRecord(asg string) (*DeploymentRecord, error) {
+	item, err := c.MetricClient.DynamoDBService.GetSingleItem(asg, c.MetricConfig.Storage.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil || item["stack"] == nil || item["stack"].S == nil {
+		return nil, nil
+	}
+
+	record := DeploymentRecord{}
+	if err := json.Unmarshal([]byte(*item["stack"].S), &record.Stack); err != nil {
+		return nil, err
+	}
+
+	if item["config"] != nil && item["config"].S != nil {
+		if err := json.Unmarshal([]byte(*item["config"].S), &record.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	if item["deployment_status"] != nil && item["deployment_status"].S != nil {
+		record.Status = *item["deployment_status"].S
+	}
+
+	if item["userdata"] != nil && item["userdata"].S != nil {
+		record.Userdata = *item["userdata"].S
+	}
+
+	return &record, nil
+}
+
 // UpdateStatistics update value of metric table
 func (c Collector) UpdateStatistics(asg string, updateFields map[string]interface{}) error {
 	if err := c.MetricClient.DynamoDBService.UpdateStatistics(asg, c.MetricConfig.Storage.Name, c.MetricConfig.Metrics.BaseTimezone, updateFields); err != nil {
@@ -168,6 +209,18 @@ func (c Collector) UpdateStatistics(asg string, updateFields map[string]interfac
 	return nil
 }
 
+// UpdateCanaryAnalysis stores results of canary analysis to the record of autoscaling group
+func (c Collector) UpdateCanaryAnalysis(asg string, results []schemas.CanaryAnalysisResult) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	return c.UpdateStatistics(asg, map[string]interface{}{
+		"canary_analysis": string(data),
+	})
+}
+
 // GetAdditionalMetric retrieves additional metrics to store
 func (c Collector) GetAdditionalMetric(asg string, tgs []*string, lbs []*string, logger *Logger.Logger) (map[string]interface{}, error) {
 	ret := map[string]interface{}{}
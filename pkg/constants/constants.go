@@ -170,6 +170,155 @@ const (
 	CanaryDeployment        = "canary"
 	RollingUpdateDeployment = "rollingupdate"
 	DeployOnly              = "deployonly"
+	RefreshDeployment       = "refresh"
+
+	// DefaultWarmPoolState is the state of warm pool instances when pool_state is not specified
+	DefaultWarmPoolState = "Stopped"
+
+	// WarmedLifecycleStatePrefix is the prefix of lifecycle states of instances in warm pool
+	WarmedLifecycleStatePrefix = "Warmed:"
+
+	// Region rollout strategies
+	ParallelRollout   = "parallel"
+	SequentialRollout = "sequential"
+
+	// Results of region in sequential region rollout
+	RegionSucceeded  = "succeeded"
+	RegionFailed     = "failed"
+	RegionRolledBack = "rolled back"
+	RegionHalted     = "halted"
+
+	// Output formats of plan
+	TextOutput = "text"
+	JSONOutput = "json"
+
+	// DefaultCanaryMetricNamespace is the namespace of canary analysis metric when it is not specified
+	DefaultCanaryMetricNamespace = "AWS/ApplicationELB"
+
+	// Dimensions of canary analysis metric
+	TargetGroupDimension      = "target_group"
+	AutoScalingGroupDimension = "autoscaling_group"
+
+	// Comparisons of canary analysis metric
+	AbsoluteComparison   = "absolute"
+	RatioComparison      = "ratio"
+	DifferenceComparison = "difference"
+
+	// Methods of manual approval
+	CLIApproval    = "cli"
+	ServerApproval = "server"
+	SlackApproval  = "slack"
+
+	// Decisions of manual approval
+	ApproveAction = "approve"
+	RejectAction  = "reject"
+
+	// DefaultApprovalTimeout is how long goployer waits for approval when timeout is not specified
+	DefaultApprovalTimeout = 30 * time.Minute
+
+	// DefaultApprovalAddress is the address of approval API served while goployer waits for approval of server and slack method
+	DefaultApprovalAddress = "localhost:9037"
+
+	// SlackSigningSecret is environment key for verifying interactive requests from slack
+	SlackSigningSecret = "SLACK_SIGNING_SECRET"
+
+	// ApprovalToken is environment key for the shared token which is required to call approval API
+	ApprovalToken = "GOPLOYER_APPROVAL_TOKEN"
+
+	// DefaultCheckpointDirectory is the directory of checkpoint files under the cache directory of the user when metric table is not used
+	DefaultCheckpointDirectory = "goployer/checkpoints"
+
+	// CheckpointKey is the attribute of metric table which keeps a checkpoint
+	CheckpointKey = "checkpoint"
+
+	// Statuses of deployment checkpoint
+	CheckpointRunning   = "running"
+	CheckpointFailed    = "failed"
+	CheckpointCompleted = "completed"
+
+	// DefaultLockTTL is how long a deployment lock is kept without renewal
+	DefaultLockTTL = 2 * time.Minute
+
+	// DefaultLockDirectory is the directory of lock files under the temporary directory of the host
+	DefaultLockDirectory = "goployer/locks"
+
+	// LockMutexTimeout is how long to wait for another process changing the same lock file
+	LockMutexTimeout = 5 * time.Second
+
+	// LockKeyPrefix is the prefix of lock items in metric table
+	LockKeyPrefix = "lock:"
+
+	// Attributes of lock items in metric table
+	LockHolderKey     = "holder"
+	LockAcquiredAtKey = "acquired_at"
+	LockExpiresAtKey  = "expires_at"
+
+	// DefaultLifecycleHookValidationTimeout is how long goployer waits for validation of launching instances when neither timeout nor heartbeat timeout is specified
+	DefaultLifecycleHookValidationTimeout = 10 * time.Minute
+
+	// DefaultLifecycleCallbackTimeout is how long goployer waits for lifecycle callbacks when timeout is not specified
+	DefaultLifecycleCallbackTimeout = 10 * time.Minute
+
+	// DefaultLifecycleCallbackRetries is the number of retries of failed lifecycle callbacks with retry policy
+	DefaultLifecycleCallbackRetries = int64(2)
+
+	// MaxCommandOutputLength is the maximum length of command output shown in logs and slack
+	MaxCommandOutputLength = 300
+
+	// Failure policies of lifecycle callbacks
+	ContinuePolicy = "continue"
+	AbortPolicy    = "abort"
+	RetryPolicy    = "retry"
+
+	// Steps of deployment where hooks run
+	BeforeDeployHook     = "before_deploy"
+	AfterHealthCheckHook = "after_health_check"
+	BeforeCleanupHook    = "before_cleanup"
+	AfterDeployHook      = "after_deploy"
+	OnFailureHook        = "on_failure"
+
+	// DefaultHookTimeout is how long goployer waits for a hook when timeout is not specified
+	DefaultHookTimeout = 5 * time.Minute
+
+	// HookEnvPrefix is the prefix of environment variables passed to hook commands
+	HookEnvPrefix = "GOPLOYER_"
+
+	// DefaultInstanceHealthCheckTimeout is how long goployer waits for response of instance health check
+	DefaultInstanceHealthCheckTimeout = 5 * time.Second
+
+	// DefaultInstanceHealthCheckStatus is the expected status code of instance health check
+	DefaultInstanceHealthCheckStatus = int64(200)
+
+	// DefaultMaxInstanceReplacements is the number of instances replaced for failed health check before deployment fails
+	DefaultMaxInstanceReplacements = int64(3)
+
+	// Types of event sinks
+	FileEventSink    = "file"
+	WebhookEventSink = "webhook"
+
+	// StdoutEventSinkPath is the path of file event sink which writes events to stdout
+	StdoutEventSinkPath = "-"
+
+	// Types of notifiers
+	TeamsNotifier   = "teams"
+	DiscordNotifier = "discord"
+	WebhookNotifier = "webhook"
+	EmailNotifier   = "email"
+
+	// FailureNotifierEvents is the event filter of notifiers which stands for every failure event
+	FailureNotifierEvents = "failures"
+
+	// DefaultSMTPPort is the port of SMTP server when it is not specified
+	DefaultSMTPPort = int64(587)
+
+	// Names of steps in deployment events
+	StepDeployName               = "deploy"
+	StepHealthCheckName          = "health_check"
+	StepCleanPreviousVersionName = "clean_previous_version"
+
+	// Results of lifecycle action
+	LifecycleActionContinue = "CONTINUE"
+	LifecycleActionAbandon  = "ABANDON"
 
 	DelimiterRegex = "[,/|!@$%^&*_=`~]+"
 )
@@ -185,6 +334,16 @@ var (
 		"error": logrus.ErrorLevel,
 	}
 
+	// FatalScalingActivityErrors are messages of failed scaling activities which new instances never recover from
+	FatalScalingActivityErrors = []string{
+		"InsufficientInstanceCapacity",
+		"InvalidAMIID",
+		"The image id",
+		"InvalidKeyPair",
+		"InvalidGroup",
+		"InvalidParameterValue",
+	}
+
 	// AWSCredentialsPath is the file path of aws credentials
 	AWSCredentialsPath = HomeDir() + "/.aws/credentials"
 
@@ -203,13 +362,35 @@ var (
 	// TimeFields is a list of time.Time field
 	TimeFields = []string{"timeout", "polling-interval"}
 
+	// AllowedWarmPoolStates is a list of states of instances in warm pool
+	AllowedWarmPoolStates = []string{"Stopped", "Running", "Hibernated"}
+
+	// AllowedCPUManufacturers is a list of CPU manufacturers of instance requirements
+	AllowedCPUManufacturers = []string{"intel", "amd", "amazon-web-services"}
+
+	// AllowedBurstablePerformance is a list of options for burstable performance instance types
+	AllowedBurstablePerformance = []string{"included", "excluded", "required"}
+
+	// AllowedInstanceGenerations is a list of generations of instance types
+	AllowedInstanceGenerations = []string{"current", "previous"}
+
+	// AllowedFailurePolicies is a list of failure policies of lifecycle callbacks
+	AllowedFailurePolicies = []string{ContinuePolicy, AbortPolicy, RetryPolicy}
+
+	// AllowedCanaryMetricStatistics is a list of statistics for canary analysis except percentiles
+	AllowedCanaryMetricStatistics = []string{"Sum", "Average", "Minimum", "Maximum", "SampleCount"}
+
+	// CountMetricStatistics is a list of statistics which grow with the amount of traffic
+	CountMetricStatistics = []string{"Sum", "SampleCount"}
+
 	// ProhibitedTags is a list of prohibited tags which are going to be attached by goployer
 	ProhibitedTags = []string{"Name", "stack"}
 
 	// StatusTimeStampKey is a map of timestamp keys with deployment status
 	StatusTimeStampKey = map[string]string{
-		"deployed":   "deployed_date",
-		"terminated": "terminated_date",
+		"deployed":    "deployed_date",
+		"terminated":  "terminated_date",
+		"rolled_back": "rolled_back_date",
 	}
 
 	// AllowedAnswerYes is a list of allowed answers with yes
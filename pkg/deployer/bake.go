@@ -0,0 +1,221 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// ErrBakeAlarm is returned when a watched alarm or metric is breached during bake period
+var ErrBakeAlarm = errors.New("bake alarm triggered")
+
+// StartBake checks watched alarms and metrics once and detaches previous versions from target groups.
+// Previous versions keep their capacity so that they can be attached again if the bake fails.
+func (d *Deployer) StartBake(config schemas.Config) error {
+	if d.Stack.Bake == nil {
+		return nil
+	}
+
+	start := time.Now()
+	if err := d.checkBake(config, start, start); err != nil {
+		return err
+	}
+
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			continue
+		}
+
+		client, err := selectClientFromList(d.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		for _, asg := range d.PrevAsgs[region.Region] {
+			if asg == d.AsgNames[region.Region] {
+				continue
+			}
+
+			tgs, err := client.EC2Service.GetTargetGroups(asg)
+			if err != nil {
+				return err
+			}
+
+			if len(tgs) == 0 {
+				continue
+			}
+
+			d.Logger.Infof("Detach target groups from previous autoscaling group during bake period: %s", asg)
+			if err := client.EC2Service.DetachAsgFromTargetGroups(asg, tgs); err != nil {
+				return err
+			}
+
+			for _, tg := range tgs {
+				d.DetachedTargetGroups[asg] = append(d.DetachedTargetGroups[asg], *tg)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WatchBake watches alarms and metrics of the new version until the bake duration ends
+func (d *Deployer) WatchBake(config schemas.Config) error {
+	if d.Stack.Bake == nil {
+		return nil
+	}
+
+	start := time.Now()
+	end := start.Add(d.Stack.Bake.Duration)
+	d.Logger.Infof("Bake period starts until %s: %s", end.Format(time.RFC3339), d.Stack.Stack)
+	d.emit(event.Event{Type: event.BakeStarted, Message: fmt.Sprintf(":hourglass: Bake period starts for %s : %s", d.Stack.Bake.Duration, d.Stack.Stack)})
+
+	for {
+		now := time.Now()
+		if err := d.checkBake(config, start, now); err != nil {
+			return err
+		}
+
+		if !now.Before(end) {
+			break
+		}
+
+		wait := config.PollingInterval
+		if remained := end.Sub(now); remained < wait {
+			wait = remained
+		}
+		time.Sleep(wait)
+	}
+
+	d.Logger.Infof("Bake period is finished without any alarm: %s", d.Stack.Stack)
+	d.emit(event.Event{Type: event.BakeFinished, Message: fmt.Sprintf(":white_check_mark: Bake period is finished without any alarm : %s", d.Stack.Stack)})
+
+	return nil
+}
+
+// checkBake returns ErrBakeAlarm if any alarm is in ALARM state or any metric exceeds its threshold
+func (d *Deployer) checkBake(config schemas.Config, startTime, endTime time.Time) error {
+	var breached []string
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			continue
+		}
+
+		ret, err := d.checkBakeRegion(region, startTime, endTime)
+		if err != nil {
+			return err
+		}
+		breached = append(breached, ret...)
+	}
+
+	if len(breached) > 0 {
+		msg := fmt.Sprintf(":x: Bake alarm is triggered : %s\n- %s", d.Stack.Stack, strings.Join(breached, "\n- "))
+		d.Logger.Warn(msg)
+		d.emit(event.Event{Type: event.BakeFailed, Message: msg})
+		return fmt.Errorf("%w: %s", ErrBakeAlarm, strings.Join(breached, ", "))
+	}
+
+	return nil
+}
+
+// checkBakeRegion returns alarms and metrics which are breached in the region
+func (d *Deployer) checkBakeRegion(region schemas.RegionConfig, startTime, endTime time.Time) ([]string, error) {
+	var breached []string
+
+	client, err := selectClientFromList(d.AWSClients, region.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Stack.Bake.Alarms) > 0 {
+		states, err := client.CloudWatchService.GetAlarmStates(d.Stack.Bake.Alarms)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range d.Stack.Bake.Alarms {
+			state, ok := states[name]
+			if !ok {
+				return nil, fmt.Errorf("alarm does not exist: %s / %s", name, region.Region)
+			}
+
+			if state == cloudwatch.StateValueAlarm {
+				breached = append(breached, fmt.Sprintf("[%s] %s is in ALARM state", region.Region, name))
+			}
+		}
+	}
+
+	for _, m := range d.Stack.Bake.Metrics {
+		namespace := m.Namespace
+		if len(namespace) == 0 {
+			namespace = constants.DefaultCanaryMetricNamespace
+		}
+
+		dimensions, err := d.bakeMetricDimensions(region, m.Dimension)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := client.CloudWatchService.GetMetricValue(namespace, m.Metric, m.Statistic, dimensions, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+
+		name := m.Name
+		if len(name) == 0 {
+			name = m.Metric
+		}
+		d.Logger.Debugf("[%s] bake metric %s: %.2f (threshold %.2f)", region.Region, name, value, m.Threshold)
+
+		if value > m.Threshold {
+			breached = append(breached, fmt.Sprintf("[%s] %s is %.2f, which exceeds %.2f", region.Region, name, value, m.Threshold))
+		}
+	}
+
+	return breached, nil
+}
+
+// bakeMetricDimensions returns dimensions of the new version for bake metric
+func (d *Deployer) bakeMetricDimensions(region schemas.RegionConfig, dimension string) (map[string]string, error) {
+	if dimension == constants.AutoScalingGroupDimension {
+		return map[string]string{"AutoScalingGroupName": d.AsgNames[region.Region]}, nil
+	}
+
+	tg, err := d.DescribeTargetGroup(region.HealthcheckTargetGroup, region.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tg.LoadBalancerArns) == 0 {
+		return nil, fmt.Errorf("target group is not used by any load balancer: %s", region.HealthcheckTargetGroup)
+	}
+
+	return map[string]string{
+		"TargetGroup":  gaws.TargetGroupDimension(*tg.TargetGroupArn),
+		"LoadBalancer": gaws.LoadBalancerDimension(*tg.LoadBalancerArns[0]),
+	}, nil
+}
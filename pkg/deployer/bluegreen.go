@@ -21,10 +21,10 @@ import (
 	"fmt"
 	"time"
 
-	Logger "github.com/sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/service/elbv2"
 
-	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/checkpoint"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
@@ -32,24 +32,25 @@ import (
 )
 
 type BlueGreen struct {
+	SwitchListeners   map[string][]*elbv2.Listener
+	SwitchRules       map[string][]*elbv2.Rule
+	ActiveTargetGroup map[string]*elbv2.TargetGroup
+	IdleTargetGroup   map[string]*elbv2.TargetGroup
+	Switched          map[string]bool
 	*Deployer
 }
 
 // NewBlueGreen creates new BlueGreen deployment deployer
 func NewBlueGreen(h *helper.DeployerHelper) *BlueGreen {
-	awsClients := []aws.Client{}
-	for _, region := range h.Stack.Regions {
-		if len(h.Region) > 0 && h.Region != region.Region {
-			Logger.Debugf("skip creating aws clients in %s region", region.Region)
-			continue
-		}
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, h.Stack.AssumeRole))
-	}
-
-	d := InitDeploymentConfiguration(h, awsClients)
+	d := InitDeploymentConfiguration(h, bootstrapClients(h))
 
 	return &BlueGreen{
-		Deployer: &d,
+		SwitchListeners:   map[string][]*elbv2.Listener{},
+		SwitchRules:       map[string][]*elbv2.Rule{},
+		ActiveTargetGroup: map[string]*elbv2.TargetGroup{},
+		IdleTargetGroup:   map[string]*elbv2.TargetGroup{},
+		Switched:          map[string]bool{},
+		Deployer:          &d,
 	}
 }
 
@@ -79,7 +80,7 @@ func (b *BlueGreen) Deploy(config schemas.Config) error {
 	// Get LocalFileProvider
 	b.LocalProvider = builder.SetUserdataProvider(b.Stack.Userdata, b.AwsConfig.Userdata)
 
-	for _, region := range b.Stack.Regions {
+	for i, region := range b.Stack.Regions {
 		// Region check
 		// If region id is passed from command line, then deployer will deploy in that region only.
 		if config.Region != "" && config.Region != region.Region {
@@ -87,6 +88,15 @@ func (b *BlueGreen) Deploy(config schemas.Config) error {
 			continue
 		}
 
+		if len(region.BlueGreenTargetGroups) > 0 {
+			changedRegionConfig, err := b.PrepareTargetGroupSwitch(region)
+			if err != nil {
+				return err
+			}
+			region = changedRegionConfig
+			b.Stack.Regions[i] = changedRegionConfig
+		}
+
 		err := b.Deployer.Deploy(config, region)
 		if err != nil {
 			return err
@@ -110,7 +120,7 @@ func (b *BlueGreen) HealthChecking(config schemas.Config) error {
 
 		isDone, err := b.Deployer.HealthChecking(config)
 		if err != nil {
-			return errors.New("error happened while health checking")
+			return fmt.Errorf("error happened while health checking: %s", err.Error())
 		}
 
 		if isDone {
@@ -132,6 +142,10 @@ func (b *BlueGreen) FinishAdditionalWork(config schemas.Config) error {
 	skipped := len(config.Region) > 0 && !CheckRegionExist(config.Region, b.Stack.Regions)
 
 	if !skipped {
+		if err := b.SwitchTargetGroups(config); err != nil {
+			return err
+		}
+
 		if err := b.DoCommonAdditionalWork(config); err != nil {
 			return err
 		}
@@ -226,3 +240,37 @@ func (b *BlueGreen) GatherMetrics(config schemas.Config) error {
 func (b *BlueGreen) RunAPITest(config schemas.Config) error {
 	return b.Deployer.RunAPITest(config)
 }
+
+// Rollback switches listeners back to the previous target group, deletes the new autoscaling group and restores previous versions
+func (b *BlueGreen) Rollback(config schemas.Config) error {
+	for _, region := range b.Stack.Regions {
+		if err := b.SwitchBack(region.Region); err != nil {
+			return err
+		}
+	}
+
+	return b.Deployer.Rollback(config)
+}
+
+// RestoreCheckpoint restores the progress of deployer and target groups to switch
+func (b *BlueGreen) RestoreCheckpoint(cp checkpoint.Stack) error {
+	if err := b.Deployer.RestoreCheckpoint(cp); err != nil {
+		return err
+	}
+
+	if !b.StepStatus[constants.StepDeploy] || b.StepStatus[constants.StepAdditionalWork] {
+		return nil
+	}
+
+	for _, region := range b.Stack.Regions {
+		if len(region.BlueGreenTargetGroups) == 0 || len(b.AsgNames[region.Region]) == 0 {
+			continue
+		}
+
+		if err := b.RestoreTargetGroupSwitch(region); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
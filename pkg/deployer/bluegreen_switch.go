@@ -0,0 +1,215 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// PrepareTargetGroupSwitch finds the active target group of blue_green_target_groups and
+// changes region configuration so that the new version is registered to the idle one
+func (b *BlueGreen) PrepareTargetGroupSwitch(region schemas.RegionConfig) (schemas.RegionConfig, error) {
+	client, err := selectClientFromList(b.AWSClients, region.Region)
+	if err != nil {
+		return region, err
+	}
+
+	var active, idle *elbv2.TargetGroup
+	for _, name := range region.BlueGreenTargetGroups {
+		tg, err := b.DescribeTargetGroup(name, region.Region)
+		if err != nil {
+			return region, err
+		}
+
+		listeners, err := client.ELBV2Service.GetListenersForTargetGroup(tg.TargetGroupArn)
+		if err != nil {
+			return region, err
+		}
+
+		rules, err := client.ELBV2Service.GetRulesForTargetGroup(tg.TargetGroupArn)
+		if err != nil {
+			return region, err
+		}
+
+		if len(listeners) == 0 && len(rules) == 0 {
+			idle = tg
+			continue
+		}
+
+		if active != nil {
+			return region, fmt.Errorf("both of blue_green_target_groups receive traffic: %s", region.Region)
+		}
+		active = tg
+
+		b.SwitchListeners[region.Region] = listeners
+		b.SwitchRules[region.Region] = rules
+	}
+
+	if active == nil {
+		return region, fmt.Errorf("no listener or rule forwards to blue_green_target_groups: %s", region.Region)
+	}
+
+	b.ActiveTargetGroup[region.Region] = active
+	b.IdleTargetGroup[region.Region] = idle
+	b.Logger.Infof("[%s] New version is registered to the idle target group: %s (active: %s)", region.Region, *idle.TargetGroupName, *active.TargetGroupName)
+
+	region.HealthcheckTargetGroup = *idle.TargetGroupName
+	region.TargetGroups = append(append([]string{}, region.TargetGroups...), *idle.TargetGroupName)
+
+	return region, nil
+}
+
+// RestoreTargetGroupSwitch finds the target groups to switch again from the region configuration changed by PrepareTargetGroupSwitch.
+// Listeners and rules which already forward to the idle target group are left as they are.
+func (b *BlueGreen) RestoreTargetGroupSwitch(region schemas.RegionConfig) error {
+	client, err := selectClientFromList(b.AWSClients, region.Region)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range region.BlueGreenTargetGroups {
+		tg, err := b.DescribeTargetGroup(name, region.Region)
+		if err != nil {
+			return err
+		}
+
+		if name == region.HealthcheckTargetGroup {
+			b.IdleTargetGroup[region.Region] = tg
+			continue
+		}
+		b.ActiveTargetGroup[region.Region] = tg
+
+		listeners, err := client.ELBV2Service.GetListenersForTargetGroup(tg.TargetGroupArn)
+		if err != nil {
+			return err
+		}
+
+		rules, err := client.ELBV2Service.GetRulesForTargetGroup(tg.TargetGroupArn)
+		if err != nil {
+			return err
+		}
+
+		b.SwitchListeners[region.Region] = listeners
+		b.SwitchRules[region.Region] = rules
+	}
+
+	if b.ActiveTargetGroup[region.Region] == nil || b.IdleTargetGroup[region.Region] == nil {
+		return fmt.Errorf("target groups to switch cannot be restored: %s", region.Region)
+	}
+
+	return nil
+}
+
+// SwitchTargetGroups makes listeners and rules forward to the target group of the new version
+func (b *BlueGreen) SwitchTargetGroups(config schemas.Config) error {
+	for _, region := range b.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			continue
+		}
+
+		idle, ok := b.IdleTargetGroup[region.Region]
+		if !ok {
+			continue
+		}
+		active := b.ActiveTargetGroup[region.Region]
+
+		client, err := selectClientFromList(b.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		// listeners which are not switched yet are also restored by switching back
+		b.Switched[region.Region] = true
+		for _, l := range b.SwitchListeners[region.Region] {
+			actions := ReplaceForwardTargetGroup(l.DefaultActions, *active.TargetGroupArn, *idle.TargetGroupArn)
+			if err := client.ELBV2Service.ModifyListenerActions(l.ListenerArn, actions); err != nil {
+				return err
+			}
+		}
+
+		for _, r := range b.SwitchRules[region.Region] {
+			actions := ReplaceForwardTargetGroup(r.Actions, *active.TargetGroupArn, *idle.TargetGroupArn)
+			if err := client.ELBV2Service.ModifyRule(r.RuleArn, actions); err != nil {
+				return err
+			}
+		}
+
+		b.Logger.Infof("[%s] Traffic is switched from %s to %s", region.Region, *active.TargetGroupName, *idle.TargetGroupName)
+		b.emit(event.Event{Type: event.TrafficShifted, Region: region.Region, Message: fmt.Sprintf(":twisted_rightwards_arrows: Traffic is switched from %s to %s in %s", *active.TargetGroupName, *idle.TargetGroupName, region.Region)})
+	}
+
+	return nil
+}
+
+// SwitchBack makes listeners and rules in the region forward to the previous target group again
+func (b *BlueGreen) SwitchBack(region string) error {
+	if !b.Switched[region] {
+		return nil
+	}
+
+	client, err := selectClientFromList(b.AWSClients, region)
+	if err != nil {
+		return err
+	}
+
+	active := b.ActiveTargetGroup[region]
+	for _, l := range b.SwitchListeners[region] {
+		if err := client.ELBV2Service.ModifyListenerActions(l.ListenerArn, l.DefaultActions); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range b.SwitchRules[region] {
+		if err := client.ELBV2Service.ModifyRule(r.RuleArn, r.Actions); err != nil {
+			return err
+		}
+	}
+	b.Switched[region] = false
+
+	b.Logger.Warnf("[%s] Traffic is switched back to %s", region, *active.TargetGroupName)
+	b.emit(event.Event{Type: event.TrafficShifted, Region: region, Message: fmt.Sprintf(":rewind: Traffic is switched back to %s in %s", *active.TargetGroupName, region)})
+
+	return nil
+}
+
+// ReplaceForwardTargetGroup returns a copy of actions which forward to the target group instead of the previous one
+func ReplaceForwardTargetGroup(actions []*elbv2.Action, from, to string) []*elbv2.Action {
+	var ret []*elbv2.Action
+	for _, a := range actions {
+		action := awsutil.CopyOf(a).(*elbv2.Action)
+		if action.TargetGroupArn != nil && *action.TargetGroupArn == from {
+			action.TargetGroupArn = &to
+		}
+
+		if action.ForwardConfig != nil {
+			for _, tg := range action.ForwardConfig.TargetGroups {
+				if tg.TargetGroupArn != nil && *tg.TargetGroupArn == from {
+					tg.TargetGroupArn = &to
+				}
+			}
+		}
+		ret = append(ret, action)
+	}
+
+	return ret
+}
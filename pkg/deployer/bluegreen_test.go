@@ -17,13 +17,20 @@ limitations under the license.
 package deployer
 
 import (
+	"io/ioutil"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 )
 
 func TestGetStackName(t *testing.T) {
-	b := BlueGreen{&Deployer{Stack: schemas.Stack{Stack: "Test"}}}
+	b := BlueGreen{Deployer: &Deployer{Stack: schemas.Stack{Stack: "Test"}}}
 
 	input := b.GetStackName()
 	expected := "Test"
@@ -58,3 +65,60 @@ func TestCheckRegionExist(t *testing.T) {
 		t.Error(regionList, target)
 	}
 }
+
+func TestBlueGreen_SwitchBack(t *testing.T) {
+	backend := fake.NewBackend(constants.DefaultRegion)
+	backend.AddTargetGroup("hello-dev-blue", 8080)
+	backend.AddTargetGroup("hello-dev-green", 8080)
+	backend.AddLoadBalancer("hello-dev-alb", "hello-dev-blue")
+	backend.AddListenerRule("hello-dev-alb", "hello-dev-blue")
+
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+
+	region := schemas.RegionConfig{
+		Region:                constants.DefaultRegion,
+		BlueGreenTargetGroups: []string{"hello-dev-green", "hello-dev-blue"},
+	}
+	b := BlueGreen{
+		SwitchListeners:   map[string][]*elbv2.Listener{},
+		SwitchRules:       map[string][]*elbv2.Rule{},
+		ActiveTargetGroup: map[string]*elbv2.TargetGroup{},
+		IdleTargetGroup:   map[string]*elbv2.TargetGroup{},
+		Switched:          map[string]bool{},
+		Deployer: &Deployer{
+			Logger:     logger,
+			Stack:      schemas.Stack{Regions: []schemas.RegionConfig{region}},
+			AWSClients: []aws.Client{backend.Client()},
+		},
+	}
+
+	changed, err := b.PrepareTargetGroupSwitch(region)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed.HealthcheckTargetGroup != "hello-dev-green" {
+		t.Errorf("new version should be checked with the idle target group: %s", changed.HealthcheckTargetGroup)
+	}
+
+	if err := b.SwitchTargetGroups(schemas.Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tgs := backend.ListenerTargetGroups("hello-dev-alb"); len(tgs) != 1 || tgs[0] != "hello-dev-green" {
+		t.Errorf("listener is not switched: %v", tgs)
+	}
+
+	if err := b.SwitchBack(constants.DefaultRegion); err != nil {
+		t.Fatal(err)
+	}
+
+	if tgs := backend.ListenerTargetGroups("hello-dev-alb"); len(tgs) != 1 || tgs[0] != "hello-dev-blue" {
+		t.Errorf("listener is not switched back: %v", tgs)
+	}
+
+	if tgs := backend.RuleTargetGroups("hello-dev-alb"); len(tgs) != 1 || tgs[0] != "hello-dev-blue" {
+		t.Errorf("rule is not switched back: %v", tgs)
+	}
+}
@@ -27,9 +27,10 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 
-	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/checkpoint"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
@@ -41,21 +42,18 @@ type Canary struct {
 	PrevHealthCheckTargetGroups map[string]string
 	LoadBalancer                map[string]string
 	LBSecurityGroup             map[string]*string
+	Listeners                   map[string][]*elbv2.Listener
+	ListenerRules               map[string][]*elbv2.Rule
+	OriginalTargetGroup         map[string]*string
+	CanaryTargetGroup           map[string]*string
+	ListenerLoadBalancer        map[string]*string
+	AnalysisResults             map[string][]schemas.CanaryAnalysisResult
 	*Deployer
 }
 
 // NewCanary creates new canary deployment deployer
 func NewCanary(h *helper.DeployerHelper) *Canary {
-	var awsClients []aws.Client
-	for _, region := range h.Stack.Regions {
-		if len(h.Region) > 0 && h.Region != region.Region {
-			h.Logger.Debugf("skip creating aws clients in %s region", region.Region)
-			continue
-		}
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, h.Stack.AssumeRole))
-	}
-
-	d := InitDeploymentConfiguration(h, awsClients)
+	d := InitDeploymentConfiguration(h, bootstrapClients(h))
 
 	return &Canary{
 		PrevHealthCheckTargetGroups: map[string]string{},
@@ -63,6 +61,12 @@ func NewCanary(h *helper.DeployerHelper) *Canary {
 		TargetGroups:                map[string][]*string{},
 		LoadBalancer:                map[string]string{},
 		LBSecurityGroup:             map[string]*string{},
+		Listeners:                   map[string][]*elbv2.Listener{},
+		ListenerRules:               map[string][]*elbv2.Rule{},
+		OriginalTargetGroup:         map[string]*string{},
+		CanaryTargetGroup:           map[string]*string{},
+		ListenerLoadBalancer:        map[string]*string{},
+		AnalysisResults:             map[string][]schemas.CanaryAnalysisResult{},
 		Deployer:                    &d,
 	}
 }
@@ -103,6 +107,15 @@ func (c *Canary) Deploy(config schemas.Config) error {
 			return err
 		}
 
+		if c.Stack.CanaryTrafficShifting != nil {
+			changedRegionConfig, err := c.RunTrafficShiftingDeployment(config, region)
+			if err != nil {
+				return err
+			}
+			c.Stack.Regions[i] = changedRegionConfig
+			continue
+		}
+
 		latestASG := c.LatestAsg[region.Region]
 		targetGroups, err := c.GetAsgTargetGroups(latestASG, region.Region)
 		if err != nil {
@@ -163,7 +176,7 @@ func (c *Canary) HealthChecking(config schemas.Config) error {
 
 		isDone, err := c.Deployer.HealthChecking(config)
 		if err != nil {
-			return errors.New("error happened while health checking")
+			return fmt.Errorf("error happened while health checking: %s", err.Error())
 		}
 
 		if isDone {
@@ -189,6 +202,26 @@ func (c *Canary) FinishAdditionalWork(config schemas.Config) error {
 
 	skipped := len(config.Region) > 0 && !CheckRegionExist(config.Region, c.Stack.Regions)
 
+	if c.Stack.CanaryTrafficShifting != nil {
+		if !skipped {
+			if err := c.ShiftTraffic(config); err != nil {
+				return err
+			}
+
+			if err := c.CompleteTrafficShifting(config); err != nil {
+				return err
+			}
+
+			if err := c.DoCommonAdditionalWork(config); err != nil {
+				return err
+			}
+		}
+
+		c.Logger.Debug("Finish additional works.")
+		c.StepStatus[constants.StepAdditionalWork] = true
+		return nil
+	}
+
 	if !skipped {
 		// attach to the previous target group
 		if len(c.PrevTargetGroups) > 0 {
@@ -276,7 +309,7 @@ func (c *Canary) GatherMetrics(config schemas.Config) error {
 		}
 	}
 
-	if !config.CompleteCanary {
+	if !config.CompleteCanary && c.Stack.CanaryTrafficShifting == nil {
 		c.Logger.Debug("Skip gathering metrics because canary is now applied")
 		return nil
 	}
@@ -295,12 +328,12 @@ func (c *Canary) RunAPITest(config schemas.Config) error {
 		return nil
 	}
 
-	if !config.CompleteCanary {
+	if !config.CompleteCanary && c.Stack.CanaryTrafficShifting == nil {
 		c.Logger.Debug("Skip API test because canary is now applied")
 		return nil
 	}
 
-	err := c.RunAPITest(config)
+	err := c.Deployer.RunAPITest(config)
 	if err != nil {
 		return err
 	}
@@ -309,12 +342,88 @@ func (c *Canary) RunAPITest(config schemas.Config) error {
 	return nil
 }
 
+// Rollback deletes the canary autoscaling group and its canary target groups
+func (c *Canary) Rollback(config schemas.Config) error {
+	for _, region := range c.Stack.Regions {
+		if err := c.RestoreListeners(region.Region); err != nil {
+			return err
+		}
+	}
+
+	canaryTgs := map[string][]*string{}
+	for _, region := range c.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			continue
+		}
+
+		if len(c.AsgNames[region.Region]) == 0 {
+			continue
+		}
+
+		client, err := selectClientFromList(c.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		tgs, err := client.EC2Service.GetTargetGroups(c.AsgNames[region.Region])
+		if err != nil {
+			return err
+		}
+
+		for _, tg := range tgs {
+			if tool.IsCanaryTargetGroupArn(*tg, region.Region) {
+				canaryTgs[region.Region] = append(canaryTgs[region.Region], tg)
+			}
+		}
+	}
+
+	if err := c.Deployer.Rollback(config); err != nil {
+		return err
+	}
+
+	for region, tgs := range canaryTgs {
+		client, err := selectClientFromList(c.AWSClients, region)
+		if err != nil {
+			return err
+		}
+
+		for _, tg := range tgs {
+			c.Logger.Debugf("Try to delete canary target group: %s", *tg)
+			if err := client.ELBV2Service.DeleteTargetGroup(tg); err != nil {
+				c.Logger.Warnf("canary target group cannot be deleted and will be cleaned with the next canary deployment: %s", err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// RestoreCheckpoint restores the progress of deployer.
+// Canary resources created in the deploy step are not kept in the checkpoint, so canary can be resumed only before it.
+func (c *Canary) RestoreCheckpoint(cp checkpoint.Stack) error {
+	if cp.StepStatus[constants.StepDeploy] {
+		return fmt.Errorf("canary deployment cannot be resumed after the deploy step, please roll back the stack instead: %s", cp.Stack.Stack)
+	}
+
+	return c.Deployer.RestoreCheckpoint(cp)
+}
+
 // ValidateCanaryDeployment validates if configuration is right for canary deployment
 func (c *Canary) ValidateCanaryDeployment(config schemas.Config, region string) error {
 	if c.DeploymentFlag[region] != constants.CanaryDeployment && config.CompleteCanary {
 		return errors.New("you cannot complete canary deployment before start canary before")
 	}
 
+	if c.Stack.CanaryTrafficShifting != nil {
+		if config.CompleteCanary {
+			return errors.New("you do not need to complete canary deployment with canary_traffic_shifting")
+		}
+
+		if c.DeploymentFlag[region] == constants.CanaryDeployment {
+			return fmt.Errorf("canary deployment is already in progress: %s", c.LatestAsg[region])
+		}
+	}
+
 	return nil
 }
 
@@ -704,7 +813,7 @@ func (c *Canary) ReduceOriginalAutoscalingGroupCount(region schemas.RegionConfig
 	}
 
 	c.Logger.Debugf("Reduce size of autoscaling group by one instance: %s / %s", c.LatestAsg[region.Region], region.Region)
-	c.Slack.SendSimpleMessage(fmt.Sprintf("Reducing the size of autoscaling group by 1 : %s / %s", c.LatestAsg[region.Region], region.Region))
+	c.emit(event.Event{Type: event.CapacityChanged, Region: region.Region, AutoScalingGroup: c.LatestAsg[region.Region], Message: fmt.Sprintf("Reducing the size of autoscaling group by 1 : %s / %s", c.LatestAsg[region.Region], region.Region)})
 	changedCapacity.Desired--
 	if changedCapacity.Desired < changedCapacity.Min {
 		changedCapacity.Min--
@@ -0,0 +1,186 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// ErrCanaryAnalysisFailed is returned when metrics of canary exceed thresholds of canary analysis
+var ErrCanaryAnalysisFailed = errors.New("canary analysis failed")
+
+// AnalyzeCanary compares metrics of canary with baseline in regions where traffic is shifted
+func (c *Canary) AnalyzeCanary(weight int64, startTime, endTime time.Time) error {
+	var failed []string
+	for _, region := range c.Stack.Regions {
+		if !c.isTrafficShifting(region.Region) {
+			continue
+		}
+
+		result, err := c.analyzeRegion(region.Region, weight, startTime, endTime)
+		if err != nil {
+			return err
+		}
+
+		if err := c.reportCanaryAnalysis(result); err != nil {
+			return err
+		}
+
+		if !result.Passed {
+			failed = append(failed, region.Region)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w with %d%% of traffic: %s", ErrCanaryAnalysisFailed, weight, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// analyzeRegion queries metrics of canary and baseline in the region and compares them
+func (c *Canary) analyzeRegion(region string, weight int64, startTime, endTime time.Time) (schemas.CanaryAnalysisResult, error) {
+	result := schemas.CanaryAnalysisResult{
+		Region: region,
+		Weight: weight,
+		Passed: true,
+	}
+
+	client, err := selectClientFromList(c.AWSClients, region)
+	if err != nil {
+		return result, err
+	}
+
+	for _, m := range c.Stack.CanaryTrafficShifting.Analysis.Metrics {
+		namespace := m.Namespace
+		if len(namespace) == 0 {
+			namespace = constants.DefaultCanaryMetricNamespace
+		}
+
+		canaryDimensions, baselineDimensions := c.canaryMetricDimensions(region, m.Dimension)
+
+		canary, err := client.CloudWatchService.GetMetricValue(namespace, m.Metric, m.Statistic, canaryDimensions, startTime, endTime)
+		if err != nil {
+			return result, err
+		}
+
+		baseline, err := client.CloudWatchService.GetMetricValue(namespace, m.Metric, m.Statistic, baselineDimensions, startTime, endTime)
+		if err != nil {
+			return result, err
+		}
+
+		name := m.Name
+		if len(name) == 0 {
+			name = m.Metric
+		}
+
+		canary, baseline = NormalizeCanaryMetric(m, canary, baseline, weight)
+		passed := CompareCanaryMetric(m, canary, baseline)
+		result.Metrics = append(result.Metrics, schemas.CanaryMetricAnalysisResult{
+			Name:       name,
+			Canary:     canary,
+			Baseline:   baseline,
+			Comparison: m.Comparison,
+			Threshold:  m.Threshold,
+			Passed:     passed,
+		})
+
+		if !passed {
+			result.Passed = false
+		}
+	}
+
+	return result, nil
+}
+
+// canaryMetricDimensions returns dimensions of canary and baseline
+func (c *Canary) canaryMetricDimensions(region, dimension string) (map[string]string, map[string]string) {
+	if dimension == constants.AutoScalingGroupDimension {
+		return map[string]string{"AutoScalingGroupName": c.AsgNames[region]}, map[string]string{"AutoScalingGroupName": c.LatestAsg[region]}
+	}
+
+	lb := gaws.LoadBalancerDimension(*c.ListenerLoadBalancer[region])
+	return map[string]string{"TargetGroup": gaws.TargetGroupDimension(*c.CanaryTargetGroup[region]), "LoadBalancer": lb},
+		map[string]string{"TargetGroup": gaws.TargetGroupDimension(*c.OriginalTargetGroup[region]), "LoadBalancer": lb}
+}
+
+// NormalizeCanaryMetric scales count metrics of canary and baseline to the full traffic so that they can be compared with each other.
+// Canary receives weight% of traffic while baseline receives the rest, so raw counts of canary are always smaller.
+func NormalizeCanaryMetric(m schemas.CanaryMetric, canary, baseline float64, weight int64) (float64, float64) {
+	if m.Comparison == constants.AbsoluteComparison || len(m.Comparison) == 0 || !tool.IsStringInArray(m.Statistic, constants.CountMetricStatistics) {
+		return canary, baseline
+	}
+
+	if weight <= 0 || weight >= 100 {
+		return canary, baseline
+	}
+
+	return canary * 100 / float64(weight), baseline * 100 / float64(100-weight)
+}
+
+// CompareCanaryMetric checks if canary value is within the threshold of metric
+func CompareCanaryMetric(m schemas.CanaryMetric, canary, baseline float64) bool {
+	switch m.Comparison {
+	case constants.RatioComparison:
+		return canary <= baseline*m.Threshold
+	case constants.DifferenceComparison:
+		return canary-baseline <= m.Threshold
+	default:
+		return canary <= m.Threshold
+	}
+}
+
+// reportCanaryAnalysis sends the result of canary analysis to slack and metric table
+func (c *Canary) reportCanaryAnalysis(result schemas.CanaryAnalysisResult) error {
+	asg := c.AsgNames[result.Region]
+
+	verdict := ":white_check_mark: Canary analysis passed"
+	if !result.Passed {
+		verdict = ":x: Canary analysis failed"
+	}
+
+	lines := []string{fmt.Sprintf("%s with %d%% of traffic: %s / %s", verdict, result.Weight, asg, result.Region)}
+	for _, m := range result.Metrics {
+		line := fmt.Sprintf("- %s: canary %.2f, baseline %.2f (%s, threshold %.2f)", m.Name, m.Canary, m.Baseline, m.Comparison, m.Threshold)
+		if !m.Passed {
+			line += " - exceeded"
+		}
+		lines = append(lines, line)
+	}
+
+	msg := strings.Join(lines, "\n")
+	c.Logger.Info(msg)
+	c.emit(event.Event{Type: event.CanaryAnalysis, Region: result.Region, Message: msg})
+
+	c.AnalysisResults[result.Region] = append(c.AnalysisResults[result.Region], result)
+	if c.Collector.MetricConfig.Enabled {
+		if err := c.Collector.UpdateCanaryAnalysis(asg, c.AnalysisResults[result.Region]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
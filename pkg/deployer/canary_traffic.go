@@ -0,0 +1,281 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	gaws "github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// RunTrafficShiftingDeployment deploys a new version behind a canary target group which shares listeners with the original one
+func (c *Canary) RunTrafficShiftingDeployment(config schemas.Config, region schemas.RegionConfig) (schemas.RegionConfig, error) {
+	latestASG, ok := c.LatestAsg[region.Region]
+	if !ok {
+		c.Logger.Infof("No previous version exists, so traffic shifting is skipped: %s", region.Region)
+		return region, c.Deployer.Deploy(config, region)
+	}
+
+	client, err := selectClientFromList(c.AWSClients, region.Region)
+	if err != nil {
+		return region, err
+	}
+
+	targetGroups, err := c.GetAsgTargetGroups(latestASG, region.Region)
+	if err != nil {
+		return region, err
+	}
+	canaryVersion := CheckCanaryVersion(targetGroups, region.Region)
+
+	original, err := c.DescribeTargetGroup(region.HealthcheckTargetGroup, region.Region)
+	if err != nil {
+		return region, err
+	}
+
+	listeners, err := client.ELBV2Service.GetListenersForTargetGroup(original.TargetGroupArn)
+	if err != nil {
+		return region, err
+	}
+
+	rules, err := client.ELBV2Service.GetRulesForTargetGroup(original.TargetGroupArn)
+	if err != nil {
+		return region, err
+	}
+
+	if len(listeners) == 0 && len(rules) == 0 {
+		return region, fmt.Errorf("no listener forwards to the target group: %s", *original.TargetGroupName)
+	}
+
+	newTgName := c.GenerateCanaryTargetGroupName(canaryVersion)
+	tg, err := c.CopyTargetGroups(original, newTgName, region.Region)
+	if err != nil {
+		return region, err
+	}
+	c.Logger.Debugf("New target group is created for traffic shifting: %s", *tg.TargetGroupName)
+
+	// actions are kept to restore listeners and rules after traffic shifting
+	c.Listeners[region.Region] = listeners
+	c.ListenerRules[region.Region] = rules
+	c.ListenerLoadBalancer[region.Region] = original.LoadBalancerArns[0]
+	c.OriginalTargetGroup[region.Region] = original.TargetGroupArn
+	c.CanaryTargetGroup[region.Region] = tg.TargetGroupArn
+
+	// every version which exists now is replaced after traffic shifting is completed
+	prefix := tool.BuildPrefixName(c.AwsConfig.Name, c.Stack.Env, region.Region)
+	var prevAsgs []string
+	for _, v := range c.PrevVersions[region.Region] {
+		prevAsgs = append(prevAsgs, tool.GenerateAsgName(prefix, v))
+	}
+	c.PrevAsgs[region.Region] = prevAsgs
+
+	region = c.ChangeTargetGroupInfo(newTgName, region)
+	if err := c.Deployer.Deploy(config, region); err != nil {
+		return region, err
+	}
+
+	return region, nil
+}
+
+// ShiftTraffic applies weights of canary steps to listeners in order
+func (c *Canary) ShiftTraffic(config schemas.Config) error {
+	steps := append([]schemas.CanaryStep{}, c.Stack.CanaryTrafficShifting.Steps...)
+	if steps[len(steps)-1].Weight < 100 {
+		steps = append(steps, schemas.CanaryStep{Weight: 100})
+	}
+
+	for _, step := range steps {
+		startTime := time.Now()
+		for _, region := range c.Stack.Regions {
+			if !c.isTrafficShifting(region.Region) {
+				continue
+			}
+
+			if err := c.SetCanaryWeight(region.Region, step.Weight); err != nil {
+				return err
+			}
+			c.Logger.Infof("[%s] %d%% of traffic is sent to the new version: %s", region.Region, step.Weight, c.AsgNames[region.Region])
+			c.emit(event.Event{Type: event.TrafficShifted, Region: region.Region, AutoScalingGroup: c.AsgNames[region.Region], Message: fmt.Sprintf(":arrows_counterclockwise: %d%% of traffic is sent to %s in %s", step.Weight, c.AsgNames[region.Region], region.Region)})
+		}
+
+		if step.Pause == 0 {
+			continue
+		}
+
+		c.Logger.Infof("Wait %s before the next step of traffic shifting", step.Pause)
+		time.Sleep(step.Pause)
+
+		healthy, err := c.Deployer.HealthChecking(config)
+		if err != nil {
+			return err
+		}
+
+		if !healthy {
+			return fmt.Errorf("new version became unhealthy while %d%% of traffic is sent", step.Weight)
+		}
+
+		// baseline does not receive any traffic with full weight
+		if c.Stack.CanaryTrafficShifting.Analysis != nil && step.Weight < 100 {
+			if err := c.AnalyzeCanary(step.Weight, startTime, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetCanaryWeight changes weights between the original and canary target groups
+func (c *Canary) SetCanaryWeight(region string, weight int64) error {
+	client, err := selectClientFromList(c.AWSClients, region)
+	if err != nil {
+		return err
+	}
+
+	original, canary := *c.OriginalTargetGroup[region], *c.CanaryTargetGroup[region]
+	for _, l := range c.Listeners[region] {
+		if err := client.ELBV2Service.ModifyListenerActions(l.ListenerArn, gaws.WeightForwardActions(l.DefaultActions, original, canary, weight)); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range c.ListenerRules[region] {
+		if err := client.ELBV2Service.ModifyRule(r.RuleArn, gaws.WeightForwardActions(r.Actions, original, canary, weight)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isTrafficShifting checks if traffic of the region is shifted between the original and canary target groups
+func (c *Canary) isTrafficShifting(region string) bool {
+	return len(c.Listeners[region]) > 0 || len(c.ListenerRules[region]) > 0
+}
+
+// CompleteTrafficShifting moves the new version to the original target groups and removes the canary target group
+func (c *Canary) CompleteTrafficShifting(config schemas.Config) error {
+	for i, region := range c.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			continue
+		}
+
+		asg := c.AsgNames[region.Region]
+		if c.isTrafficShifting(region.Region) {
+			client, err := selectClientFromList(c.AWSClients, region.Region)
+			if err != nil {
+				return err
+			}
+
+			targetGroupARNs, err := client.ELBV2Service.GetTargetGroupARNs(c.PrevTargetGroups[region.Region])
+			if err != nil {
+				return err
+			}
+
+			c.Logger.Debugf("Attach autoscaling group to original target groups: %s", asg)
+			if err := client.EC2Service.AttachAsgToTargetGroups(asg, targetGroupARNs); err != nil {
+				return err
+			}
+
+			region.HealthcheckTargetGroup = c.PrevHealthCheckTargetGroups[region.Region]
+			region.TargetGroups = c.PrevTargetGroups[region.Region]
+			if err := c.waitForOriginalTargetGroup(config, region, asg); err != nil {
+				return err
+			}
+
+			if err := c.RestoreListeners(region.Region); err != nil {
+				return err
+			}
+
+			if err := c.DetachCanaryTargetGroup(asg, region, []*string{c.CanaryTargetGroup[region.Region]}); err != nil {
+				return err
+			}
+
+			if err := client.ELBV2Service.DeleteTargetGroup(c.CanaryTargetGroup[region.Region]); err != nil {
+				c.Logger.Warnf("canary target group cannot be deleted and will be cleaned with the next canary deployment: %s", err.Error())
+			}
+			c.Stack.Regions[i] = region
+		}
+
+		if err := c.RemoveCanaryTag(asg, region); err != nil {
+			return err
+		}
+		c.emit(event.Event{Type: event.TrafficShifted, Region: region.Region, AutoScalingGroup: asg, Message: fmt.Sprintf(":white_check_mark: Traffic shifting is completed: %s", asg)})
+	}
+
+	return nil
+}
+
+// RestoreListeners puts back actions of listeners and rules in the region which were taken before traffic shifting
+func (c *Canary) RestoreListeners(region string) error {
+	if !c.isTrafficShifting(region) {
+		return nil
+	}
+
+	client, err := selectClientFromList(c.AWSClients, region)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range c.Listeners[region] {
+		if err := client.ELBV2Service.ModifyListenerActions(l.ListenerArn, l.DefaultActions); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range c.ListenerRules[region] {
+		if err := client.ELBV2Service.ModifyRule(r.RuleArn, r.Actions); err != nil {
+			return err
+		}
+	}
+	delete(c.Listeners, region)
+	delete(c.ListenerRules, region)
+
+	return nil
+}
+
+// waitForOriginalTargetGroup waits until instances of autoscaling group are healthy in the original target group
+func (c *Canary) waitForOriginalTargetGroup(config schemas.Config, region schemas.RegionConfig, asg string) error {
+	client, err := selectClientFromList(c.AWSClients, region.Region)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if isTimeout, _ := tool.CheckTimeout(config.StartTimestamp, config.Timeout); isTimeout {
+			return fmt.Errorf("timeout has been exceeded : %.0f minutes", config.Timeout.Minutes())
+		}
+
+		group, err := client.EC2Service.GetMatchingAutoscalingGroup(asg)
+		if err != nil {
+			return err
+		}
+
+		healthy, err := c.Polling(region, group, client, config.ForceManifestCapacity, false, false)
+		if err != nil {
+			return err
+		}
+
+		if healthy {
+			return nil
+		}
+		time.Sleep(config.PollingInterval)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"github.com/DevopsArtFactory/goployer/pkg/checkpoint"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Checkpoint returns a snapshot of the progress of deployer
+func (d *Deployer) Checkpoint() checkpoint.Stack {
+	stack := d.Stack
+	stack.Regions = append([]schemas.RegionConfig{}, d.Stack.Regions...)
+
+	cp := checkpoint.Stack{
+		Stack:                stack,
+		StepStatus:           map[int64]bool{},
+		AsgNames:             copyStringMap(d.AsgNames),
+		PrevAsgs:             copyStringSliceMap(d.PrevAsgs),
+		PrevInstances:        copyStringSliceMap(d.PrevInstances),
+		PrevVersions:         map[string][]int{},
+		PrevInstanceCount:    map[string]schemas.Capacity{},
+		PrevCapacity:         map[string]schemas.Capacity{},
+		SecurityGroup:        map[string]*string{},
+		LatestAsg:            copyStringMap(d.LatestAsg),
+		DeploymentFlag:       copyStringMap(d.DeploymentFlag),
+		DetachedTargetGroups: copyStringSliceMap(d.DetachedTargetGroups),
+	}
+
+	for k, v := range d.StepStatus {
+		cp.StepStatus[k] = v
+	}
+
+	for k, v := range d.PrevVersions {
+		cp.PrevVersions[k] = append([]int{}, v...)
+	}
+
+	for k, v := range d.PrevInstanceCount {
+		cp.PrevInstanceCount[k] = v
+	}
+
+	for k, v := range d.PrevCapacity {
+		cp.PrevCapacity[k] = v
+	}
+
+	for k, v := range d.SecurityGroup {
+		cp.SecurityGroup[k] = v
+	}
+
+	if d.AppliedCapacity != nil {
+		applied := *d.AppliedCapacity
+		cp.AppliedCapacity = &applied
+	}
+
+	return cp
+}
+
+// RestoreCheckpoint restores the progress of deployer from the checkpoint
+func (d *Deployer) RestoreCheckpoint(cp checkpoint.Stack) error {
+	d.Stack = cp.Stack
+	d.AppliedCapacity = cp.AppliedCapacity
+
+	for k, v := range cp.StepStatus {
+		d.StepStatus[k] = v
+	}
+
+	for k, v := range cp.AsgNames {
+		d.AsgNames[k] = v
+	}
+
+	for k, v := range cp.PrevAsgs {
+		d.PrevAsgs[k] = v
+	}
+
+	for k, v := range cp.PrevInstances {
+		d.PrevInstances[k] = v
+	}
+
+	for k, v := range cp.PrevVersions {
+		d.PrevVersions[k] = v
+	}
+
+	for k, v := range cp.PrevInstanceCount {
+		d.PrevInstanceCount[k] = v
+	}
+
+	for k, v := range cp.PrevCapacity {
+		d.PrevCapacity[k] = v
+	}
+
+	for k, v := range cp.SecurityGroup {
+		d.SecurityGroup[k] = v
+	}
+
+	for k, v := range cp.LatestAsg {
+		d.LatestAsg[k] = v
+	}
+
+	for k, v := range cp.DeploymentFlag {
+		d.DeploymentFlag[k] = v
+	}
+
+	for k, v := range cp.DetachedTargetGroups {
+		d.DetachedTargetGroups[k] = v
+	}
+
+	return nil
+}
+
+// copyStringMap returns a copy of map
+func copyStringMap(m map[string]string) map[string]string {
+	ret := map[string]string{}
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
+
+// copyStringSliceMap returns a copy of map with copies of slices
+func copyStringSliceMap(m map[string][]string) map[string][]string {
+	ret := map[string][]string{}
+	for k, v := range m {
+		ret[k] = append([]string{}, v...)
+	}
+	return ret
+}
@@ -17,6 +17,7 @@ limitations under the license.
 package deployer
 
 import (
+	"github.com/DevopsArtFactory/goployer/pkg/checkpoint"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 )
 
@@ -31,4 +32,7 @@ type DeployManager interface {
 	CleanChecking(config schemas.Config) error
 	GatherMetrics(config schemas.Config) error
 	RunAPITest(config schemas.Config) error
+	Rollback(config schemas.Config) error
+	Checkpoint() checkpoint.Stack
+	RestoreCheckpoint(cp checkpoint.Stack) error
 }
@@ -23,6 +23,7 @@ import (
 	"html/template"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"text/tabwriter"
@@ -40,35 +41,40 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/DevopsArtFactory/goployer/pkg/collector"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
-	"github.com/DevopsArtFactory/goployer/pkg/slack"
 	"github.com/DevopsArtFactory/goployer/pkg/templates"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
 // Deployer for each stack
 type Deployer struct {
-	Mode              string
-	AsgNames          map[string]string
-	PrevAsgs          map[string][]string
-	PrevInstances     map[string][]string
-	PrevVersions      map[string][]int
-	PrevInstanceCount map[string]schemas.Capacity
-	SecurityGroup     map[string]*string
-	LatestAsg         map[string]string
-	Logger            *Logger.Logger
-	Stack             schemas.Stack
-	AwsConfig         schemas.AWSConfig
-	APITestTemplate   *schemas.APITestTemplate
-	AWSClients        []aws.Client
-	LocalProvider     builder.UserdataProvider
-	Slack             slack.Slack
-	AppliedCapacity   *schemas.Capacity
-	Collector         collector.Collector
-	StepStatus        map[int64]bool
-	DeploymentFlag    map[string]string
-	HealthCheckStatus map[string]bool
+	Mode                 string
+	AsgNames             map[string]string
+	PrevAsgs             map[string][]string
+	PrevInstances        map[string][]string
+	PrevVersions         map[string][]int
+	PrevInstanceCount    map[string]schemas.Capacity
+	PrevCapacity         map[string]schemas.Capacity
+	SecurityGroup        map[string]*string
+	LatestAsg            map[string]string
+	Logger               *Logger.Logger
+	Stack                schemas.Stack
+	AwsConfig            schemas.AWSConfig
+	APITestTemplate      *schemas.APITestTemplate
+	AWSClients           []aws.Client
+	LocalProvider        builder.UserdataProvider
+	Events               *event.Emitter
+	AppliedCapacity      *schemas.Capacity
+	Collector            collector.Collector
+	StepStatus           map[int64]bool
+	DeploymentFlag       map[string]string
+	HealthCheckStatus    map[string]bool
+	DetachedTargetGroups map[string][]string
+	LaunchValidations    map[string]*LaunchValidation
+	HealthySince         map[string]time.Time
+	Watcher              *Watcher
 }
 
 type APIAttacker struct {
@@ -79,28 +85,52 @@ type APIAttacker struct {
 	Targets  []vegeta.Target
 }
 
+// bootstrapClients creates aws clients for regions to deploy
+func bootstrapClients(h *helper.DeployerHelper) []aws.Client {
+	factory := h.ClientFactory
+	if factory == nil {
+		factory = aws.BootstrapServices
+	}
+
+	var awsClients []aws.Client
+	for _, region := range h.Stack.Regions {
+		if len(h.Region) > 0 && h.Region != region.Region {
+			h.Logger.Debugf("skip creating aws clients in %s region", region.Region)
+			continue
+		}
+		awsClients = append(awsClients, factory(region.Region, h.Stack.AssumeRole))
+	}
+
+	return awsClients
+}
+
 // InitDeploymentConfiguration initializes and returns configurations for the Deployer.
 func InitDeploymentConfiguration(h *helper.DeployerHelper, awsClients []aws.Client) Deployer {
 	return Deployer{
-		Mode:              h.Stack.ReplacementType,
-		Logger:            h.Logger,
-		AwsConfig:         h.AwsConfig,
-		AWSClients:        awsClients,
-		APITestTemplate:   h.APITestTemplates,
-		AsgNames:          map[string]string{},
-		PrevAsgs:          map[string][]string{},
-		PrevInstances:     map[string][]string{},
-		PrevInstanceCount: map[string]schemas.Capacity{},
-		PrevVersions:      map[string][]int{},
-		SecurityGroup:     map[string]*string{},
-		DeploymentFlag:    map[string]string{},
-		LatestAsg:         map[string]string{},
-		Stack:             h.Stack,
-		Slack:             h.Slack,
-		Collector:         h.Collector,
-		AppliedCapacity:   nil,
-		StepStatus:        helper.InitStartStatus(),
-		HealthCheckStatus: map[string]bool{},
+		Mode:                 h.Stack.ReplacementType,
+		Logger:               h.Logger,
+		AwsConfig:            h.AwsConfig,
+		AWSClients:           awsClients,
+		APITestTemplate:      h.APITestTemplates,
+		AsgNames:             map[string]string{},
+		PrevAsgs:             map[string][]string{},
+		PrevInstances:        map[string][]string{},
+		PrevInstanceCount:    map[string]schemas.Capacity{},
+		PrevCapacity:         map[string]schemas.Capacity{},
+		PrevVersions:         map[string][]int{},
+		SecurityGroup:        map[string]*string{},
+		DeploymentFlag:       map[string]string{},
+		LatestAsg:            map[string]string{},
+		Stack:                h.Stack,
+		Events:               h.Events,
+		Collector:            h.Collector,
+		AppliedCapacity:      nil,
+		StepStatus:           helper.InitStartStatus(),
+		HealthCheckStatus:    map[string]bool{},
+		DetachedTargetGroups: map[string][]string{},
+		LaunchValidations:    map[string]*LaunchValidation{},
+		HealthySince:         map[string]time.Time{},
+		Watcher:              NewWatcher(),
 	}
 }
 
@@ -110,22 +140,33 @@ func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group,
 		return false, fmt.Errorf("no autoscaling found for %s", d.AsgNames[region.Region])
 	}
 
-	threshold := d.AppliedCapacity.Desired
+	// instances in warm pool are not serving yet
+	asg = withoutWarmPoolInstances(asg)
+
+	// update does not create a new autoscaling group, so capacity is not applied
+	threshold := d.Stack.Capacity.Desired
+	if d.AppliedCapacity != nil {
+		threshold = d.AppliedCapacity.Desired
+	}
+
+	// instances waiting in launch lifecycle hooks are validated by goployer
+	validating, err := d.ValidateLaunchingInstances(client, asg)
+	if err != nil {
+		return false, err
+	}
 
-	if region.HealthcheckTargetGroup == "" && region.HealthcheckLB == "" {
+	if region.HealthcheckTargetGroup == "" && region.HealthcheckLB == "" && d.Stack.InstanceHealthCheck == nil && d.Stack.HealthyFor == 0 {
+		if validating > 0 {
+			d.Logger.Infof("Waiting for validation of launching instances(%s) : %d", *asg.AutoScalingGroupName, validating)
+			return false, nil
+		}
 		d.Logger.Info("health check skipped because of neither target group nor classic load balancer specified")
 		return true, nil
 	}
 
 	var targetHosts []aws.HealthcheckHost
-	var err error
 	validHostCount := int64(0)
 
-	if len(region.HealthcheckLB) == 0 && len(region.HealthcheckTargetGroup) == 0 {
-		Logger.Infof("No health check target for this autoscaling group: %s / %s", *asg.AutoScalingGroupName, region.Region)
-		return true, nil
-	}
-
 	d.Logger.Debugf("[Checking healthy host count] Autoscaling Group: %s", *asg.AutoScalingGroupName)
 	if len(region.HealthcheckTargetGroup) > 0 {
 		var healthCheckTargetGroupArn *string
@@ -145,16 +186,37 @@ func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group,
 		if err != nil {
 			return false, err
 		}
+		d.reportWatchEvents(d.watcher().Targets(*asg.AutoScalingGroupName, targetHosts))
 	} else if len(region.HealthcheckLB) > 0 {
 		d.Logger.Debugf("[Checking healthy host count] Load Balancer : %s", region.HealthcheckLB)
 		targetHosts, err = client.ELBService.GetHealthyHostInELB(asg, region.HealthcheckLB)
 		if err != nil {
 			return false, err
 		}
+		d.reportWatchEvents(d.watcher().Targets(*asg.AutoScalingGroupName, targetHosts))
+	} else {
+		targetHosts = instanceHosts(asg)
+	}
+
+	// instances are probed directly on top of the state of load balancer
+	if d.Stack.InstanceHealthCheck != nil {
+		targetHosts, err = d.ProbeInstances(client, targetHosts)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if d.Stack.HealthyFor > 0 {
+		d.requireStableHealth(targetHosts)
 	}
 
 	validHostCount = d.GetValidHostCount(targetHosts)
 
+	// capacity is counted in units of weight when instance types have weights
+	if weighted, ok := weightedHostCount(asg, targetHosts); ok {
+		validHostCount = weighted
+	}
+
 	if isUpdate {
 		if validHostCount == threshold {
 			d.Logger.Infof("[Update completed] current / desired : %d/%d", validHostCount, threshold)
@@ -164,12 +226,26 @@ func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group,
 	} else {
 		if validHostCount >= threshold {
 			d.Logger.Infof("Healthy Count for %s : %d/%d", d.AsgNames[region.Region], validHostCount, threshold)
-			d.Slack.SendSimpleMessage(fmt.Sprintf("All instances are healthy in %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold))
+			d.emit(event.Event{
+				Type:             event.HealthProgress,
+				Region:           region.Region,
+				AutoScalingGroup: *asg.AutoScalingGroupName,
+				Healthy:          validHostCount,
+				Desired:          threshold,
+				Message:          fmt.Sprintf("All instances are healthy in %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold),
+			})
 			return true, nil
 		}
 
 		d.Logger.Infof("Healthy count does not meet the requirement(%s) : %d/%d", d.AsgNames[region.Region], validHostCount, threshold)
-		d.Slack.SendSimpleMessage(fmt.Sprintf("Waiting for healthy instances %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold))
+		d.emit(event.Event{
+			Type:             event.HealthProgress,
+			Region:           region.Region,
+			AutoScalingGroup: *asg.AutoScalingGroupName,
+			Healthy:          validHostCount,
+			Desired:          threshold,
+			Message:          fmt.Sprintf("Waiting for healthy instances %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold),
+		})
 	}
 	return false, nil
 }
@@ -183,7 +259,7 @@ func (d *Deployer) CheckTerminating(client aws.Client, target string, disableMet
 	}
 
 	if done {
-		d.Slack.SendSimpleMessage(fmt.Sprintf(":+1: All instances are deleted : %s", target))
+		d.emit(event.Event{Type: event.CleanupFinished, AutoScalingGroup: target, Message: fmt.Sprintf(":+1: All instances are deleted : %s", target)})
 	} else {
 		return false
 	}
@@ -204,7 +280,7 @@ func (d *Deployer) CheckAutoscalingInstanceCount(client aws.Client, asg string,
 
 	if len(asgInfo.Instances) > desired {
 		d.Logger.Infof("still terminating<< desired: %d, current: %d: %s", desired, len(asgInfo.Instances), asg)
-		d.Slack.SendSimpleMessage(fmt.Sprintf("Still found %d instance to delete : %s", len(asgInfo.Instances)-desired, asg))
+		d.emit(event.Event{Type: event.CleanupProgress, AutoScalingGroup: asg, Message: fmt.Sprintf("Still found %d instance to delete : %s", len(asgInfo.Instances)-desired, asg)})
 
 		return false, nil
 	}
@@ -214,6 +290,10 @@ func (d *Deployer) CheckAutoscalingInstanceCount(client aws.Client, asg string,
 
 // CleanAutoscalingSet cleans autoscaling group itself
 func (d *Deployer) CleanAutoscalingSet(client aws.Client, target string) error {
+	if err := d.DeleteWarmPool(client, target); err != nil {
+		return err
+	}
+
 	d.Logger.Debugf("Start deleting autoscaling group : %s", target)
 	if err := client.EC2Service.DeleteAutoscalingSet(target); err != nil {
 		return err
@@ -226,7 +306,7 @@ func (d *Deployer) CleanAutoscalingSet(client aws.Client, target string) error {
 // ResizingAutoScalingGroupCount set autoscaling group instance count to 0
 func (d *Deployer) ResizingAutoScalingGroupCount(client aws.Client, asg string, count int64) error {
 	d.Logger.Info(fmt.Sprintf("Modifying the size of autoscaling group to %d : %s(%s)", count, asg, d.Stack.Stack))
-	d.Slack.SendSimpleMessage(fmt.Sprintf("Modifying the size of autoscaling group to %d : %s/%s", count, asg, d.Stack.Stack))
+	d.emit(event.Event{Type: event.CapacityChanged, AutoScalingGroup: asg, Desired: count, Message: fmt.Sprintf("Modifying the size of autoscaling group to %d : %s/%s", count, asg, d.Stack.Stack)})
 
 	retry := int64(3)
 	var err error
@@ -249,23 +329,6 @@ func (d *Deployer) ResizingAutoScalingGroupCount(client aws.Client, asg string,
 	return nil
 }
 
-// RunLifecycleCallbacks executes commands before termination.
-func (d *Deployer) RunLifecycleCallbacks(client aws.Client, region string) bool {
-	targets := d.PrevInstances[region]
-	if len(targets) == 0 {
-		d.Logger.Debugf("no target instance exists\n")
-		return false
-	}
-
-	commands := d.Stack.LifecycleCallbacks.PreTerminatePastClusters
-
-	d.Logger.Debugf("run lifecycle callbacks before termination : %s", targets)
-	return client.SSMService.SendCommand(
-		eaws.StringSlice(targets),
-		eaws.StringSlice(commands),
-	)
-}
-
 // selectClientFromList get aws client.
 func selectClientFromList(awsClients []aws.Client, region string) (aws.Client, error) {
 	for _, c := range awsClients {
@@ -311,21 +374,92 @@ func (d *Deployer) GatherMetrics(client aws.Client, asg string) error {
 // GetValidHostCount return the number of health host
 func (d *Deployer) GetValidHostCount(targetHosts []aws.HealthcheckHost) int64 {
 	ret := 0
+	probed := d.Stack.InstanceHealthCheck != nil
 	var data [][]string
 	for _, host := range targetHosts {
-		data = append(data, []string{host.InstanceID, host.LifecycleState, host.TargetStatus, host.HealthStatus, fmt.Sprintf("%t", host.Valid)})
+		row := []string{host.InstanceID, host.LifecycleState, host.TargetStatus, host.HealthStatus}
+		if probed {
+			probeStatus := host.ProbeStatus
+			if len(probeStatus) == 0 {
+				probeStatus = "-"
+			}
+			row = append(row, probeStatus)
+		}
+		data = append(data, append(row, fmt.Sprintf("%t", host.Valid)))
 		if host.Valid {
 			ret++
 		}
 	}
 
 	if len(data) > 0 {
-		printCurrentHostStatus(data)
+		printCurrentHostStatus(data, probed)
 	}
 
 	return int64(ret)
 }
 
+// requireStableHealth counts hosts as valid only after they stay valid for healthy_for across consecutive polls
+func (d *Deployer) requireStableHealth(targetHosts []aws.HealthcheckHost) {
+	if d.HealthySince == nil {
+		d.HealthySince = map[string]time.Time{}
+	}
+
+	now := time.Now()
+	for i := range targetHosts {
+		id := targetHosts[i].InstanceID
+		if !targetHosts[i].Valid {
+			delete(d.HealthySince, id)
+			continue
+		}
+
+		since, ok := d.HealthySince[id]
+		if !ok {
+			since = now
+			d.HealthySince[id] = since
+		}
+
+		if healthy := now.Sub(since); healthy < d.Stack.HealthyFor {
+			d.Logger.Debugf("Instance has been healthy for %s out of %s: %s", healthy.Round(time.Second), d.Stack.HealthyFor, id)
+			targetHosts[i].Valid = false
+		}
+	}
+}
+
+// weightedHostCount returns the sum of weights of valid hosts if instances in the autoscaling group have weights
+func weightedHostCount(asg *autoscaling.Group, targetHosts []aws.HealthcheckHost) (int64, bool) {
+	weights := map[string]int64{}
+	for _, instance := range asg.Instances {
+		if instance.WeightedCapacity == nil {
+			continue
+		}
+
+		weight, err := strconv.ParseInt(*instance.WeightedCapacity, 10, 64)
+		if err != nil {
+			continue
+		}
+		weights[*instance.InstanceId] = weight
+	}
+
+	if len(weights) == 0 {
+		return 0, false
+	}
+
+	ret := int64(0)
+	for _, host := range targetHosts {
+		if !host.Valid {
+			continue
+		}
+
+		if weight, ok := weights[host.InstanceID]; ok {
+			ret += weight
+		} else {
+			ret++
+		}
+	}
+
+	return ret, true
+}
+
 // GenerateAPIAttacker create API Attacker
 func (d *Deployer) GenerateAPIAttacker(template schemas.APITestTemplate) (*APIAttacker, error) {
 	attacker := APIAttacker{
@@ -429,9 +563,14 @@ func (a APIAttacker) Print(metrics []schemas.MetricResult) (string, error) {
 }
 
 // printCurrentHostStatus shows current instance status
-func printCurrentHostStatus(data [][]string) {
+func printCurrentHostStatus(data [][]string, probed bool) {
+	header := []string{"Instance ID", "Lifecycle State", "Target Status", "Health Status"}
+	if probed {
+		header = append(header, "Probe Status")
+	}
+
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Instance ID", "Lifecycle State", "Target Status", "Health Status", "Valid"})
+	table.SetHeader(append(header, "Valid"))
 	table.SetCenterSeparator("|")
 	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -497,6 +636,7 @@ func (d *Deployer) CheckPrevious(config schemas.Config) error {
 			prevInstanceCount.Desired = *asgGroup.DesiredCapacity
 			prevInstanceCount.Max = *asgGroup.MaxSize
 			prevInstanceCount.Min = *asgGroup.MinSize
+			d.PrevCapacity[*asgGroup.AutoScalingGroupName] = prevInstanceCount
 
 			isBeingCanaryDeployed := false
 			for _, tag := range asgGroup.Tags {
@@ -547,6 +687,112 @@ func (d *Deployer) CheckPrevious(config schemas.Config) error {
 
 // Deploy is a basic deployment process for any deployment method
 func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) error {
+	plan, err := d.MakeDeploymentPlan(config, region)
+	if err != nil {
+		return err
+	}
+
+	if err := d.createResources(plan, region); err != nil {
+		return err
+	}
+
+	d.stampDeployment(config, plan)
+
+	appliedCapacity := plan.Capacity
+	d.AsgNames[region.Region] = plan.AutoscalingGroupName
+	d.AppliedCapacity = &appliedCapacity
+
+	return nil
+}
+
+// stampDeployment records the deployment of the plan in metric table
+func (d *Deployer) stampDeployment(config schemas.Config, plan *DeploymentPlan) {
+	if !d.Collector.MetricConfig.Enabled {
+		return
+	}
+
+	additionalFields := map[string]string{}
+	if len(config.ReleaseNotes) > 0 {
+		additionalFields["release-notes"] = config.ReleaseNotes
+	}
+
+	if len(config.ReleaseNotesBase64) > 0 {
+		additionalFields["release-notes-base64"] = config.ReleaseNotesBase64
+	}
+
+	if len(plan.userdata) > 0 {
+		additionalFields["userdata"] = plan.userdata
+	}
+
+	if err := d.Collector.StampDeployment(d.Stack, config, plan.tags, plan.AutoscalingGroupName, "creating", additionalFields); err != nil {
+		d.Logger.Error(err.Error())
+	}
+}
+
+// createResources creates launch template and autoscaling group with the plan
+func (d *Deployer) createResources(plan *DeploymentPlan, region schemas.RegionConfig) error {
+	// select client
+	client, err := selectClientFromList(d.AWSClients, region.Region)
+	if err != nil {
+		return err
+	}
+
+	lt := plan.LaunchTemplate
+	d.Logger.Debugf("Block Device Len %d", len(plan.blockDevices))
+	err = client.EC2Service.CreateNewLaunchTemplate(
+		lt.Name,
+		lt.AMI,
+		lt.InstanceType,
+		lt.KeyName,
+		lt.IamInstanceProfile,
+		plan.userdata,
+		lt.EbsOptimized,
+		plan.mixedInstancesPolicy.Enabled,
+		plan.securityGroups,
+		plan.blockDevices,
+		d.Stack.InstanceMarketOptions,
+		lt.DetailedMonitoringEnabled,
+		region.PrimaryENI,
+		region.SecondaryENIs,
+		lt.Tags,
+		region.HttpPutResponseHopLimit,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	d.Stack.MixedInstancesPolicy = plan.mixedInstancesPolicy
+
+	return client.EC2Service.CreateAutoScalingGroup(
+		plan.AutoscalingGroupName,
+		lt.Name,
+		plan.HealthcheckType,
+		plan.HealthcheckGracePeriod,
+		plan.Capacity,
+		plan.LoadBalancers,
+		plan.AvailabilityZones,
+		plan.targetGroupARNs,
+		plan.terminationPolicies,
+		plan.tags,
+		plan.Subnets,
+		d.Stack.MixedInstancesPolicy,
+		plan.lifecycleHooks,
+		plan.WarmPool,
+	)
+}
+
+// MakeDeploymentPlan assembles every parameter of launch template and autoscaling group for a new version without creating them
+func (d *Deployer) MakeDeploymentPlan(config schemas.Config, region schemas.RegionConfig) (*DeploymentPlan, error) {
+	// Get Current Version
+	curVersion := getCurrentVersion(d.PrevVersions[region.Region])
+	d.Logger.Infof("Current Version: %d", curVersion)
+
+	return d.makeDeploymentPlan(config, region, curVersion)
+}
+
+// makeDeploymentPlan assembles deployment plan for the given version
+func (d *Deployer) makeDeploymentPlan(config schemas.Config, region schemas.RegionConfig, curVersion int) (*DeploymentPlan, error) {
 	var terminationPolicies []*string
 	var lifecycleHooksSpecificationList []*autoscaling.LifecycleHookSpecification
 
@@ -556,16 +802,12 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 	// select client
 	client, err := selectClientFromList(d.AWSClients, region.Region)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Setup frigga with prefix
 	frigga.Prefix = tool.BuildPrefixName(d.AwsConfig.Name, d.Stack.Env, region.Region)
 
-	// Get Current Version
-	curVersion := getCurrentVersion(d.PrevVersions[region.Region])
-	d.Logger.Infof("Current Version: %d", curVersion)
-
 	// Get AMI
 	var ami string
 	if len(config.Ami) > 0 {
@@ -583,13 +825,13 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 
 	userdata, err := d.LocalProvider.Provide()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Stack check
 	securityGroups, err := client.EC2Service.GetSecurityGroupList(region.VPC, region.SecurityGroups)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if d.SecurityGroup[region.Region] != nil {
 		securityGroups = append(securityGroups, d.SecurityGroup[region.Region])
@@ -601,8 +843,6 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 
 	d.Logger.Debugf("additional blokcDevice information %s", blockDevices)
 
-	ebsOptimized := d.Stack.EbsOptimized
-
 	// Instance Type Override
 	instanceType := region.InstanceType
 	if len(config.OverrideInstanceType) > 0 {
@@ -616,30 +856,7 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 	// LaunchTemplate
 	// Validate security group assignment before creating launch template
 	if len(securityGroups) > 0 && (region.PrimaryENI != nil || len(region.SecondaryENIs) > 0) {
-		return fmt.Errorf("cannot use both launch template security groups and ENI security groups at the same time")
-	}
-	d.Logger.Debugf("Block Device Len %d", len(blockDevices))
-	err = client.EC2Service.CreateNewLaunchTemplate(
-		launchTemplateName,
-		ami,
-		instanceType,
-		region.SSHKey,
-		d.Stack.IamInstanceProfile,
-		userdata,
-		ebsOptimized,
-		d.Stack.MixedInstancesPolicy.Enabled,
-		securityGroups,
-		blockDevices,
-		d.Stack.InstanceMarketOptions,
-		region.DetailedMonitoringEnabled,
-		region.PrimaryENI,
-		region.SecondaryENIs,
-		launchTemplateTags,
-		region.HttpPutResponseHopLimit,
-	)
-
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("cannot use both launch template security groups and ENI security groups at the same time")
 	}
 
 	healthElb := region.HealthcheckLB
@@ -650,13 +867,11 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 
 	targetGroups := d.GetTargetGroupNames(region)
 
-	healthCheckType := constants.DefaultHealthcheckType
-	healthCheckGracePeriod := int64(constants.DefaultHealthcheckGracePeriod)
 	tags := d.GenerateTags(newAsgName, d.Stack.Stack, config.ExtraTags, config.AnsibleExtraVars, region.Region)
 
 	availabilityZones, err := client.EC2Service.GetAvailabilityZones(region.VPC, region.AvailabilityZones)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	subnets := make([]string, 0)
@@ -667,7 +882,7 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 		Logger.Info("Not Subnet ID Specific")
 		subnets, err = client.EC2Service.GetSubnets(region.VPC, region.UsePublicSubnets, availabilityZones)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		subnetIds := strings.Join(subnets, " ")
@@ -676,16 +891,18 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 
 	targetGroupARNs, err := client.ELBV2Service.GetTargetGroupARNs(targetGroups)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if targetGroupARNs == nil {
 		Logger.Debugf("target group does not exist: %s", newAsgName)
 	}
 
-	appliedCapacity, err := d.DecideCapacity(config.ForceManifestCapacity, config.CompleteCanary, region.Region, len(d.PrevAsgs[region.Region]), d.Stack.RollingUpdateInstanceCount)
+	// traffic shifting moves the whole traffic to the new version, so it starts with the full capacity
+	completeCanary := config.CompleteCanary || d.Stack.CanaryTrafficShifting != nil
+	appliedCapacity, err := d.DecideCapacity(config.ForceManifestCapacity, completeCanary, region.Region, len(d.PrevAsgs[region.Region]), d.Stack.RollingUpdateInstanceCount)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	d.Logger.Infof("Applied instance capacity - Min: %d, Desired: %d, Max: %d", appliedCapacity.Min, appliedCapacity.Desired, appliedCapacity.Max)
@@ -698,67 +915,71 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 		terminationPolicies = eaws.StringSlice(region.TerminationPolicies)
 	}
 
-	if d.Stack.MixedInstancesPolicy.Enabled {
+	mixedInstancesPolicy := d.Stack.MixedInstancesPolicy
+	if mixedInstancesPolicy.Enabled {
 		if len(config.OverrideSpotType) > 0 {
+			if mixedInstancesPolicy.InstanceRequirements != nil {
+				return nil, errors.New("override-spot-types cannot be used with instance_requirements")
+			}
+
 			overRideSpotInstanceType := config.OverrideSpotType
 			instanceTypeList, instanceTypeErr := client.EC2Service.DescribeInstanceTypes()
 			amiImgArchitecture, amiImageErr := client.EC2Service.DescribeAMIArchitecture(region.AmiID)
 			if instanceTypeErr == nil && amiImageErr == nil {
 				validErr := checkSpotInstanceOption(overRideSpotInstanceType, instanceTypeList, amiImgArchitecture)
 				if validErr == nil {
-					d.Stack.MixedInstancesPolicy.Override = strings.Split(config.OverrideSpotType, "|")
+					mixedInstancesPolicy.Override = strings.Split(config.OverrideSpotType, "|")
+					if err := builder.ValidWeightedCapacity(mixedInstancesPolicy, appliedCapacity); err != nil {
+						return nil, err
+					}
 				} else {
-					return validErr
+					return nil, validErr
 				}
 			} else {
-				return instanceTypeErr
+				return nil, instanceTypeErr
 			}
 		}
 	}
 
-	err = client.EC2Service.CreateAutoScalingGroup(
-		newAsgName,
-		launchTemplateName,
-		healthCheckType,
-		healthCheckGracePeriod,
-		appliedCapacity,
-		loadBalancers,
-		availabilityZones,
-		targetGroupARNs,
-		terminationPolicies,
-		tags,
-		subnets,
-		d.Stack.MixedInstancesPolicy,
-		lifecycleHooksSpecificationList,
-	)
-
-	if err != nil {
-		return err
-	}
-
-	if d.Collector.MetricConfig.Enabled {
-		additionalFields := map[string]string{}
-		if len(config.ReleaseNotes) > 0 {
-			additionalFields["release-notes"] = config.ReleaseNotes
-		}
-
-		if len(config.ReleaseNotesBase64) > 0 {
-			additionalFields["release-notes-base64"] = config.ReleaseNotesBase64
-		}
-
-		if len(userdata) > 0 {
-			additionalFields["userdata"] = userdata
-		}
-
-		if err := d.Collector.StampDeployment(d.Stack, config, tags, newAsgName, "creating", additionalFields); err != nil {
-			d.Logger.Error(err.Error())
-		}
-	}
-
-	d.AsgNames[region.Region] = newAsgName
-	d.AppliedCapacity = &appliedCapacity
-
-	return nil
+	return &DeploymentPlan{
+		Stack:                  d.Stack.Stack,
+		Region:                 region.Region,
+		ReplacementType:        d.Mode,
+		Version:                curVersion,
+		AutoscalingGroupName:   newAsgName,
+		PreviousVersions:       d.PrevAsgs[region.Region],
+		Capacity:               appliedCapacity,
+		HealthcheckType:        constants.DefaultHealthcheckType,
+		HealthcheckGracePeriod: int64(constants.DefaultHealthcheckGracePeriod),
+		AvailabilityZones:      availabilityZones,
+		Subnets:                subnets,
+		LoadBalancers:          loadBalancers,
+		TargetGroupARNs:        eaws.StringValueSlice(targetGroupARNs),
+		TerminationPolicies:    region.TerminationPolicies,
+		Tags:                   makeTagPlans(tags),
+		InstanceOverrides:      mixedInstancesPolicy.Override,
+		WarmPool:               d.Stack.WarmPool,
+		LaunchTemplate: LaunchTemplatePlan{
+			Name:                      launchTemplateName,
+			AMI:                       ami,
+			InstanceType:              instanceType,
+			KeyName:                   region.SSHKey,
+			IamInstanceProfile:        d.Stack.IamInstanceProfile,
+			EbsOptimized:              d.Stack.EbsOptimized,
+			DetailedMonitoringEnabled: region.DetailedMonitoringEnabled,
+			SecurityGroups:            eaws.StringValueSlice(securityGroups),
+			BlockDevices:              makeBlockDevicePlans(d.Stack.BlockDevices),
+			Tags:                      launchTemplateTags,
+		},
+		userdata:             userdata,
+		securityGroups:       securityGroups,
+		blockDevices:         blockDevices,
+		tags:                 tags,
+		targetGroupARNs:      targetGroupARNs,
+		terminationPolicies:  terminationPolicies,
+		lifecycleHooks:       lifecycleHooksSpecificationList,
+		mixedInstancesPolicy: mixedInstancesPolicy,
+	}, nil
 }
 
 // DecideCapacity returns Applied Capacity for deployment
@@ -767,6 +988,13 @@ func (d *Deployer) DecideCapacity(forceManifestCapacity, completeCanary bool, re
 		instanceCnt := int64(1)
 		if d.Mode == constants.RollingUpdateDeployment {
 			instanceCnt = rollingUpdateInstanceCount
+			if HasSurgeSettings(d.Stack) {
+				batch, err := d.FirstRollingBatch(forceManifestCapacity, region)
+				if err != nil {
+					return schemas.Capacity{}, err
+				}
+				instanceCnt = batch.Next
+			}
 		}
 
 		return schemas.Capacity{
@@ -1018,6 +1246,18 @@ func (d *Deployer) HealthChecking(config schemas.Config) (bool, error) {
 		}
 		d.Logger.Debugf("Health check target autoscaling group: %s / %s", region.Region, *asg.AutoScalingGroupName)
 
+		activities, err := client.EC2Service.DescribeScalingActivities(*asg.AutoScalingGroupName)
+		if err != nil {
+			return false, err
+		}
+
+		since := time.Unix(config.StartTimestamp, 0)
+		d.reportWatchEvents(append(d.watcher().Instances(asg), d.watcher().Activities(*asg.AutoScalingGroupName, activities, since)...))
+
+		if err := d.CheckScalingActivities(*asg.AutoScalingGroupName, activities, since); err != nil {
+			return false, err
+		}
+
 		isHealthy, err := d.Polling(region, asg, client, config.ForceManifestCapacity, isUpdate, config.DownSizingUpdate)
 		if err != nil {
 			return false, err
@@ -1134,10 +1374,12 @@ func (d *Deployer) TriggerLifecycleCallbacks(config schemas.Config) error {
 
 			if len(d.PrevInstances[region.Region]) > 0 {
 				d.Logger.Debugf("Run lifecycle callbacks: %s", d.PrevInstances[region.Region])
-				d.RunLifecycleCallbacks(client, region.Region)
+				if err := d.RunLifecycleCallbacks(client, region.Region, config.PollingInterval); err != nil {
+					return err
+				}
 			} else {
 				d.Logger.Debugf("No previous versions to be deleted : %s\n", region.Region)
-				d.Slack.SendSimpleMessage(fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region))
+				d.emit(event.Event{Type: event.CleanupSkipped, Region: region.Region, Message: fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region)})
 			}
 		}
 	}
@@ -1156,7 +1398,7 @@ func (d *Deployer) CleanPreviousAutoScalingGroup(config schemas.Config) error {
 		// Check if health check passed for this region
 		if healthStatus, exists := d.HealthCheckStatus[region.Region]; exists && !healthStatus {
 			d.Logger.Warnf("[%s] Skipping cleanup of previous ASGs due to failed health check", region.Region)
-			d.Slack.SendSimpleMessage(fmt.Sprintf(":warning: Skipping cleanup of previous ASGs in %s due to failed health check", region.Region))
+			d.emit(event.Event{Type: event.CleanupSkipped, Region: region.Region, Message: fmt.Sprintf(":warning: Skipping cleanup of previous ASGs in %s due to failed health check", region.Region)})
 			continue
 		}
 
@@ -1212,7 +1454,7 @@ func (d *Deployer) CleanPreviousAutoScalingGroup(config schemas.Config) error {
 			}
 		} else {
 			d.Logger.Infof("No previous versions to be deleted : %s", region.Region)
-			d.Slack.SendSimpleMessage(fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region))
+			d.emit(event.Event{Type: event.CleanupSkipped, Region: region.Region, Message: fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region)})
 		}
 	}
 
@@ -1286,6 +1528,7 @@ func (d *Deployer) CleanChecking(config schemas.Config) (bool, error) {
 			finished = append(finished, region.Region)
 			continue
 		}
+		d.watchDraining(client, targets, time.Unix(config.StartTimestamp, 0))
 
 		okCount := 0
 		for _, target := range targets {
@@ -1334,6 +1577,136 @@ func (d *Deployer) ClearResources(client aws.Client, target string, disableMetri
 	return true
 }
 
+// Rollback deletes the new version which failed health checking and restores capacities of previous versions
+func (d *Deployer) Rollback(config schemas.Config) error {
+	for _, region := range d.Stack.Regions {
+		if config.Region != constants.EmptyString && config.Region != region.Region {
+			d.Logger.Debugf("This region is skipped by user: %s", region.Region)
+			continue
+		}
+
+		target, ok := d.AsgNames[region.Region]
+		if !ok || len(target) == 0 {
+			d.Logger.Debugf("No new autoscaling group to roll back: %s", region.Region)
+			continue
+		}
+
+		client, err := selectClientFromList(d.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		d.Logger.Warnf("[%s] Rolling back the new autoscaling group: %s", region.Region, target)
+		d.emit(event.Event{Type: event.RollbackStarted, Region: region.Region, AutoScalingGroup: target, Message: fmt.Sprintf(":rewind: Rolling back the new autoscaling group : %s/%s", target, region.Region)})
+
+		// previous versions should serve traffic again before the new version is removed
+		for _, asg := range d.PrevAsgs[region.Region] {
+			capacity, ok := d.PrevCapacity[asg]
+			if !ok || asg == target {
+				continue
+			}
+
+			d.Logger.Infof("Restore capacity of previous autoscaling group - Min: %d, Desired: %d, Max: %d: %s", capacity.Min, capacity.Desired, capacity.Max, asg)
+			if err := d.ResizingAutoScalingGroup(asg, region.Region, capacity); err != nil {
+				return err
+			}
+
+			if err := d.waitForInServiceInstances(client, asg, capacity.Desired, config); err != nil {
+				return err
+			}
+
+			if tgs, ok := d.DetachedTargetGroups[asg]; ok {
+				d.Logger.Infof("Attach target groups to previous autoscaling group again: %s", asg)
+				if err := client.EC2Service.AttachAsgToTargetGroups(asg, eaws.StringSlice(tgs)); err != nil {
+					return err
+				}
+				delete(d.DetachedTargetGroups, asg)
+			}
+		}
+
+		tgs, err := client.EC2Service.GetTargetGroups(target)
+		if err != nil {
+			return err
+		}
+
+		if len(tgs) > 0 {
+			d.Logger.Debugf("Detach target groups from autoscaling group: %s", target)
+			if err := client.EC2Service.DetachAsgFromTargetGroups(target, tgs); err != nil {
+				return err
+			}
+		}
+
+		if err := d.ResizingAutoScalingGroupCount(client, target, 0); err != nil {
+			return err
+		}
+
+		startTime := time.Now()
+		for {
+			done, err := d.CheckAutoscalingInstanceCount(client, target, 0)
+			if err != nil {
+				return err
+			}
+
+			if done {
+				break
+			}
+
+			if time.Since(startTime) > config.Timeout {
+				return fmt.Errorf("timeout has been exceeded while terminating instances : %s", target)
+			}
+			time.Sleep(config.PollingInterval)
+		}
+
+		if err := d.CleanAutoscalingSet(client, target); err != nil {
+			return err
+		}
+
+		if err := client.EC2Service.DeleteLaunchTemplates(target); err != nil {
+			return err
+		}
+
+		if d.Collector.MetricConfig.Enabled {
+			if err := d.Collector.UpdateStatus(target, "rolled_back", nil); err != nil {
+				d.Logger.Errorf("Update status Error, %s : %s", err.Error(), target)
+			}
+		}
+
+		delete(d.AsgNames, region.Region)
+		d.Logger.Infof("[%s] Rollback is finished: %s", region.Region, target)
+		d.emit(event.Event{Type: event.RollbackFinished, Region: region.Region, AutoScalingGroup: target, Message: fmt.Sprintf(":rewind: Rollback is finished : %s/%s", target, region.Region)})
+	}
+
+	return nil
+}
+
+// waitForInServiceInstances waits until the autoscaling group has enough instances in service
+func (d *Deployer) waitForInServiceInstances(client aws.Client, asg string, desired int64, config schemas.Config) error {
+	startTime := time.Now()
+	for {
+		group, err := client.EC2Service.GetMatchingAutoscalingGroup(asg)
+		if err != nil {
+			return err
+		}
+
+		inService := int64(0)
+		for _, instance := range group.Instances {
+			if *instance.LifecycleState == constants.InServiceStatus {
+				inService++
+			}
+		}
+
+		if inService >= desired {
+			return nil
+		}
+		d.Logger.Infof("Waiting for instances of previous version to be in service: %d/%d: %s", inService, desired, asg)
+
+		if time.Since(startTime) > config.Timeout {
+			return fmt.Errorf("timeout has been exceeded while restoring capacity : %s", asg)
+		}
+		time.Sleep(config.PollingInterval)
+	}
+}
+
 // StartGatheringMetrics starts to gather the whole metrics from deployer
 func (d *Deployer) StartGatheringMetrics(config schemas.Config) error {
 	for _, region := range d.Stack.Regions {
@@ -1404,9 +1777,7 @@ func (d *Deployer) RunAPITest(config schemas.Config) error {
 		return err
 	}
 
-	if err := d.Slack.SendAPITestResultMessage(result); err != nil {
-		return err
-	}
+	d.emit(event.Event{Type: event.APITestFinished, Metrics: result})
 	d.Logger.Debugf("API test is done")
 
 	d.StepStatus[constants.StepRunAPI] = true
@@ -1465,7 +1836,7 @@ func (d *Deployer) ResizingAutoScalingGroup(asg, region string, capacity schemas
 	}
 
 	d.Logger.Infof("Modifying the size of autoscaling group: %s(%s)", asg, d.Stack.Stack)
-	d.Slack.SendSimpleMessage(fmt.Sprintf("Modifying the size of autoscaling group: %s/%s", asg, d.Stack.Stack))
+	d.emit(event.Event{Type: event.CapacityChanged, Region: region, AutoScalingGroup: asg, Desired: capacity.Desired, Message: fmt.Sprintf("Modifying the size of autoscaling group: %s/%s", asg, d.Stack.Stack)})
 
 	retry := int64(3)
 	for {
@@ -1573,3 +1944,14 @@ func checkSpotInstanceOption(overRideSpotInstanceType string, instanceTypeList [
 	}
 	return nil
 }
+
+// emit sends events of the stack to sinks of deployer
+func (d *Deployer) emit(events ...event.Event) {
+	for i := range events {
+		if len(events[i].Stack) == 0 {
+			events[i].Stack = d.Stack.Stack
+		}
+	}
+
+	d.Events.Emit(events...)
+}
@@ -17,7 +17,12 @@ limitations under the license.
 package deployer
 
 import (
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
@@ -113,3 +118,281 @@ func TestDeployer_HealthCheckingStatus(t *testing.T) {
 		t.Error("HealthCheckStatus should be true after successful health check")
 	}
 }
+
+func TestParseVersion(t *testing.T) {
+	testData := []struct {
+		input  string
+		output int
+		err    bool
+	}{
+		{input: "v012", output: 12},
+		{input: "V999", output: 999},
+		{input: "3", output: 3},
+		{input: "v1000", err: true},
+		{input: "latest", err: true},
+	}
+
+	for _, td := range testData {
+		v, err := ParseVersion(td.input)
+		if td.err != (err != nil) {
+			t.Errorf("unexpected error for %s: %v", td.input, err)
+		}
+
+		if v != td.output {
+			t.Errorf("expected: %d, got: %d", td.output, v)
+		}
+	}
+}
+
+func TestCompareCanaryMetric(t *testing.T) {
+	testData := []struct {
+		comparison string
+		threshold  float64
+		canary     float64
+		baseline   float64
+		output     bool
+	}{
+		{comparison: constants.AbsoluteComparison, threshold: 10, canary: 10, baseline: 0, output: true},
+		{comparison: constants.AbsoluteComparison, threshold: 10, canary: 11, baseline: 0, output: false},
+		{comparison: constants.RatioComparison, threshold: 1.5, canary: 0.15, baseline: 0.1, output: true},
+		{comparison: constants.RatioComparison, threshold: 1.5, canary: 0.2, baseline: 0.1, output: false},
+		{comparison: constants.RatioComparison, threshold: 1.5, canary: 1, baseline: 0, output: false},
+		{comparison: constants.DifferenceComparison, threshold: 5, canary: 7, baseline: 3, output: true},
+		{comparison: constants.DifferenceComparison, threshold: 5, canary: 9, baseline: 3, output: false},
+	}
+
+	for _, td := range testData {
+		m := schemas.CanaryMetric{Comparison: td.comparison, Threshold: td.threshold}
+		if got := CompareCanaryMetric(m, td.canary, td.baseline); got != td.output {
+			t.Errorf("%s comparison of %f and %f: expected %t, got %t", td.comparison, td.canary, td.baseline, td.output, got)
+		}
+	}
+}
+
+func TestNormalizeCanaryMetric(t *testing.T) {
+	testData := []struct {
+		comparison       string
+		statistic        string
+		canary           float64
+		baseline         float64
+		weight           int64
+		expectedCanary   float64
+		expectedBaseline float64
+	}{
+		{comparison: constants.RatioComparison, statistic: "Sum", canary: 5, baseline: 19, weight: 5, expectedCanary: 100, expectedBaseline: 20},
+		{comparison: constants.DifferenceComparison, statistic: "SampleCount", canary: 25, baseline: 75, weight: 25, expectedCanary: 100, expectedBaseline: 100},
+		{comparison: constants.RatioComparison, statistic: "Average", canary: 0.2, baseline: 0.1, weight: 5, expectedCanary: 0.2, expectedBaseline: 0.1},
+		{comparison: constants.AbsoluteComparison, statistic: "Sum", canary: 5, baseline: 19, weight: 5, expectedCanary: 5, expectedBaseline: 19},
+	}
+
+	for _, td := range testData {
+		m := schemas.CanaryMetric{Comparison: td.comparison, Statistic: td.statistic}
+		canary, baseline := NormalizeCanaryMetric(m, td.canary, td.baseline, td.weight)
+		if canary != td.expectedCanary || baseline != td.expectedBaseline {
+			t.Errorf("%s of %s with %d%%: expected %f/%f, got %f/%f", td.comparison, td.statistic, td.weight, td.expectedCanary, td.expectedBaseline, canary, baseline)
+		}
+	}
+
+	// canary with 5 times higher error rate does not pass after normalization
+	m := schemas.CanaryMetric{Comparison: constants.RatioComparison, Statistic: "Sum", Threshold: 1.5}
+	canary, baseline := NormalizeCanaryMetric(m, 5, 19, 5)
+	if CompareCanaryMetric(m, canary, baseline) {
+		t.Error("canary with higher error rate should fail")
+	}
+}
+
+func TestNextRollingBatch(t *testing.T) {
+	testData := []struct {
+		name        string
+		previous    int64
+		target      int64
+		surge       int64
+		unavailable int64
+		expected    []RollingBatch
+	}{
+		{
+			name:     "surge only",
+			previous: 4,
+			target:   4,
+			surge:    1,
+			expected: []RollingBatch{{4, 1}, {3, 2}, {2, 3}, {1, 4}, {0, 4}},
+		},
+		{
+			name:        "unavailable only",
+			previous:    4,
+			target:      4,
+			unavailable: 2,
+			expected:    []RollingBatch{{2, 2}, {0, 4}},
+		},
+		{
+			name:        "surge and unavailable",
+			previous:    4,
+			target:      4,
+			surge:       1,
+			unavailable: 1,
+			expected:    []RollingBatch{{3, 2}, {1, 4}, {0, 4}},
+		},
+		{
+			name:        "scale out while rolling",
+			previous:    2,
+			target:      4,
+			surge:       1,
+			unavailable: 1,
+			expected:    []RollingBatch{{2, 3}, {0, 4}},
+		},
+	}
+
+	for _, td := range testData {
+		batch := RollingBatch{Previous: td.previous}
+		var got []RollingBatch
+		for batch.Previous > 0 || batch.Next < td.target {
+			next := NextRollingBatch(batch, td.target, td.surge, td.unavailable)
+			if next == batch {
+				t.Fatalf("%s: no progress at %v", td.name, batch)
+			}
+
+			if next.Previous+next.Next > td.target+td.surge {
+				t.Errorf("%s: instances exceed max surge: %v", td.name, next)
+			}
+
+			if next.Previous+batch.Next < td.target-td.unavailable && next.Previous < batch.Previous {
+				t.Errorf("%s: available instances go below max unavailable: %v", td.name, next)
+			}
+
+			got = append(got, next)
+			batch = next
+		}
+
+		if len(got) != len(td.expected) {
+			t.Errorf("%s: expected %v, got %v", td.name, td.expected, got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != td.expected[i] {
+				t.Errorf("%s: expected %v, got %v", td.name, td.expected, got)
+				break
+			}
+		}
+	}
+}
+
+func TestRollingUpdateLimits(t *testing.T) {
+	surge, unavailable, err := RollingUpdateLimits(schemas.Stack{MaxSurge: "25%", MaxUnavailable: "25%"}, 6)
+	if err != nil || surge != 2 || unavailable != 1 {
+		t.Errorf("expected surge 2 and unavailable 1, got %d, %d, %v", surge, unavailable, err)
+	}
+
+	// rolling update needs at least one of them
+	surge, unavailable, err = RollingUpdateLimits(schemas.Stack{MaxSurge: "0", MaxUnavailable: "10%"}, 4)
+	if err != nil || surge != 0 || unavailable != 1 {
+		t.Errorf("expected surge 0 and unavailable 1, got %d, %d, %v", surge, unavailable, err)
+	}
+}
+
+func TestWithoutWarmPoolInstances(t *testing.T) {
+	asg := &autoscaling.Group{
+		AutoScalingGroupName:  aws.String("hello-dev_apnortheast2-v001"),
+		WarmPoolConfiguration: &autoscaling.WarmPoolConfiguration{MinSize: aws.Int64(1)},
+		Instances: []*autoscaling.Instance{
+			{InstanceId: aws.String("i-1"), LifecycleState: aws.String(autoscaling.LifecycleStateInService)},
+			{InstanceId: aws.String("i-2"), LifecycleState: aws.String(autoscaling.LifecycleStateWarmedStopped)},
+			{InstanceId: aws.String("i-3"), LifecycleState: aws.String(autoscaling.LifecycleStatePending)},
+		},
+	}
+
+	ret := withoutWarmPoolInstances(asg)
+	if len(ret.Instances) != 2 || *ret.Instances[0].InstanceId != "i-1" || *ret.Instances[1].InstanceId != "i-3" {
+		t.Errorf("warm pool instances are not excluded: %v", ret.Instances)
+	}
+
+	if len(asg.Instances) != 3 {
+		t.Errorf("original autoscaling group is changed: %v", asg.Instances)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	w := NewWatcher()
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	activities := []*autoscaling.Activity{
+		{
+			ActivityId:  aws.String("a-2"),
+			Description: aws.String("Terminating EC2 instance: i-1"),
+			Cause:       aws.String("an instance was taken out of service in response to an EC2 health check"),
+			StartTime:   aws.Time(since.Add(2 * time.Minute)),
+			StatusCode:  aws.String(autoscaling.ScalingActivityStatusCodeInProgress),
+		},
+		{
+			ActivityId:  aws.String("a-1"),
+			Description: aws.String("Launching a new EC2 instance: i-1"),
+			StartTime:   aws.Time(since.Add(time.Minute)),
+			StatusCode:  aws.String(autoscaling.ScalingActivityStatusCodeSuccessful),
+		},
+		{
+			ActivityId:  aws.String("a-0"),
+			Description: aws.String("Launching a new EC2 instance: i-0"),
+			StartTime:   aws.Time(since.Add(-time.Minute)),
+			StatusCode:  aws.String(autoscaling.ScalingActivityStatusCodeSuccessful),
+		},
+	}
+
+	// activities before the deployment are not reported, and the oldest comes first
+	events := w.Activities("hello-v001", activities, since)
+	if len(events) != 2 || !strings.Contains(events[0].Message, "i-1") || !strings.Contains(events[1].Message, "cause: an instance was taken out of service") {
+		t.Errorf("unexpected activity events: %v", events)
+	}
+
+	// activities are reported again only when their status changes
+	activities[0].StatusCode = aws.String(autoscaling.ScalingActivityStatusCodeSuccessful)
+	if events := w.Activities("hello-v001", activities, since); len(events) != 1 || !strings.HasPrefix(events[0].Message, "activity Successful") {
+		t.Errorf("unexpected activity events: %v", events)
+	}
+
+	group := &autoscaling.Group{
+		AutoScalingGroupName: aws.String("hello-v001"),
+		Instances: []*autoscaling.Instance{
+			{InstanceId: aws.String("i-1"), LifecycleState: aws.String(autoscaling.LifecycleStatePending), HealthStatus: aws.String("Healthy")},
+		},
+	}
+
+	if events := w.Instances(group); len(events) != 1 || events[0].Message != "instance i-1 joined as Pending/Healthy" {
+		t.Errorf("unexpected instance events: %v", events)
+	}
+
+	if events := w.Instances(group); len(events) != 0 {
+		t.Errorf("unchanged instances should not be reported: %v", events)
+	}
+
+	group.Instances[0].LifecycleState = aws.String(autoscaling.LifecycleStateInService)
+	if events := w.Instances(group); len(events) != 1 || events[0].Message != "instance i-1 changed Pending/Healthy -> InService/Healthy" {
+		t.Errorf("unexpected instance events: %v", events)
+	}
+
+	group.Instances = nil
+	if events := w.Instances(group); len(events) != 1 || events[0].Message != "instance i-1 left the group" {
+		t.Errorf("unexpected instance events: %v", events)
+	}
+}
+
+func TestDeployer_ValidationTimeout(t *testing.T) {
+	d := Deployer{Stack: schemas.Stack{PollingInterval: 30 * time.Second}}
+
+	testData := []struct {
+		heartbeat int64
+		timeout   time.Duration
+		expected  time.Duration
+	}{
+		{heartbeat: 300, timeout: time.Minute, expected: time.Minute},
+		{heartbeat: 300, expected: 270 * time.Second},
+		{heartbeat: 20, expected: 10 * time.Second},
+		{expected: constants.DefaultLifecycleHookValidationTimeout},
+	}
+
+	for _, td := range testData {
+		hook := schemas.LifecycleHookSpecification{HeartbeatTimeout: td.heartbeat, Validation: &schemas.LifecycleHookValidation{Timeout: td.timeout}}
+		if got := d.validationTimeout(hook); got != td.expected {
+			t.Errorf("heartbeat %d, timeout %s: expected %s, got %s", td.heartbeat, td.timeout, td.expected, got)
+		}
+	}
+}
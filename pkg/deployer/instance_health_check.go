@@ -0,0 +1,153 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// instanceHosts makes health check hosts from autoscaling group when there is no load balancer to check
+func instanceHosts(asg *autoscaling.Group) []aws.HealthcheckHost {
+	ret := []aws.HealthcheckHost{}
+	for _, instance := range asg.Instances {
+		ret = append(ret, aws.HealthcheckHost{
+			InstanceID:     *instance.InstanceId,
+			LifecycleState: *instance.LifecycleState,
+			TargetStatus:   "-",
+			HealthStatus:   *instance.HealthStatus,
+			Valid:          *instance.LifecycleState == constants.InServiceStatus && *instance.HealthStatus == "Healthy",
+		})
+	}
+	return ret
+}
+
+// ProbeInstances sends instance health check requests to private IPs of valid hosts
+// and marks hosts which do not pass the check as invalid.
+func (d *Deployer) ProbeInstances(client aws.Client, targetHosts []aws.HealthcheckHost) ([]aws.HealthcheckHost, error) {
+	check := d.Stack.InstanceHealthCheck
+
+	var bodyRegex *regexp.Regexp
+	if len(check.BodyRegex) > 0 {
+		r, err := regexp.Compile(check.BodyRegex)
+		if err != nil {
+			return nil, err
+		}
+		bodyRegex = r
+	}
+
+	var ids []*string
+	for _, host := range targetHosts {
+		if host.Valid {
+			ids = append(ids, eaws.String(host.InstanceID))
+		}
+	}
+
+	if len(ids) == 0 {
+		return targetHosts, nil
+	}
+
+	instances, err := client.EC2Service.DescribeInstances(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := map[string]string{}
+	for _, instance := range instances {
+		if instance.PrivateIpAddress != nil {
+			addresses[*instance.InstanceId] = *instance.PrivateIpAddress
+		}
+	}
+
+	httpClient := &http.Client{Timeout: instanceHealthCheckTimeout(check.Timeout)}
+	expected := check.ExpectedStatus
+	if expected == 0 {
+		expected = constants.DefaultInstanceHealthCheckStatus
+	}
+
+	var wg sync.WaitGroup
+	for i := range targetHosts {
+		if !targetHosts[i].Valid {
+			continue
+		}
+
+		address, ok := addresses[targetHosts[i].InstanceID]
+		if !ok {
+			targetHosts[i].Valid = false
+			targetHosts[i].ProbeStatus = "no private ip"
+			continue
+		}
+
+		wg.Add(1)
+		go func(host *aws.HealthcheckHost, url string) {
+			defer wg.Done()
+			if err := probe(httpClient, url, expected, bodyRegex); err != nil {
+				d.Logger.Debugf("Instance health check failed: %s / %s", host.InstanceID, err.Error())
+				host.Valid = false
+				host.ProbeStatus = "unhealthy"
+				return
+			}
+			host.ProbeStatus = "healthy"
+		}(&targetHosts[i], fmt.Sprintf("http://%s:%d%s", address, check.Port, check.Path))
+	}
+	wg.Wait()
+
+	return targetHosts, nil
+}
+
+// probe sends a request to the instance and checks the response
+func probe(httpClient *http.Client, url string, expected int64, bodyRegex *regexp.Regexp) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if int64(resp.StatusCode) != expected {
+		return fmt.Errorf("status code %d is returned from %s, expected %d: %s", resp.StatusCode, url, expected, tool.Excerpt(string(body), constants.MaxCommandOutputLength))
+	}
+
+	if bodyRegex != nil && !bodyRegex.Match(body) {
+		return fmt.Errorf("response body from %s does not match %s: %s", url, bodyRegex.String(), tool.Excerpt(string(body), constants.MaxCommandOutputLength))
+	}
+
+	return nil
+}
+
+// instanceHealthCheckTimeout returns how long to wait for response of instance health check
+func instanceHealthCheckTimeout(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return constants.DefaultInstanceHealthCheckTimeout
+}
@@ -0,0 +1,171 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// ErrLifecycleCallbacksFailed is returned when lifecycle callbacks fail with abort or retry policy
+var ErrLifecycleCallbacksFailed = errors.New("lifecycle callbacks failed")
+
+// RunLifecycleCallbacks executes commands on previous instances before termination and waits for the results.
+// Failures are handled with the failure policy of lifecycle callbacks.
+func (d *Deployer) RunLifecycleCallbacks(client aws.Client, region string, pollingInterval time.Duration) error {
+	targets := d.PrevInstances[region]
+	if len(targets) == 0 {
+		d.Logger.Debugf("no target instance exists\n")
+		return nil
+	}
+
+	callbacks := d.Stack.LifecycleCallbacks
+	policy := callbacks.FailurePolicy
+	if len(policy) == 0 {
+		policy = constants.ContinuePolicy
+	}
+
+	timeout := callbacks.Timeout
+	if timeout <= 0 {
+		timeout = constants.DefaultLifecycleCallbackTimeout
+	}
+
+	attempts := int64(1)
+	if policy == constants.RetryPolicy {
+		retries := callbacks.Retries
+		if retries <= 0 {
+			retries = constants.DefaultLifecycleCallbackRetries
+		}
+		attempts += retries
+	}
+
+	for attempt := int64(1); ; attempt++ {
+		d.Logger.Debugf("run lifecycle callbacks before termination : %s", targets)
+		invocations, err := d.runCommands(client, targets, callbacks.PreTerminatePastClusters, timeout, pollingInterval)
+		if err != nil {
+			if policy == constants.ContinuePolicy {
+				d.Logger.Warnf("Lifecycle callbacks cannot be run in %s, but termination proceeds: %s", region, err.Error())
+				return nil
+			}
+			return fmt.Errorf("%w in %s: %s", ErrLifecycleCallbacksFailed, region, err.Error())
+		}
+		d.reportLifecycleCallbacks(region, invocations)
+
+		var failed []string
+		for _, invocation := range invocations {
+			if invocation.Status != ssm.CommandInvocationStatusSuccess {
+				failed = append(failed, invocation.InstanceID)
+			}
+		}
+
+		if len(failed) == 0 {
+			return nil
+		}
+
+		if attempt < attempts {
+			d.Logger.Warnf("Retry lifecycle callbacks(%d/%d) on failed instances : %s", attempt, attempts-1, failed)
+			targets = failed
+			continue
+		}
+
+		if policy == constants.ContinuePolicy {
+			d.Logger.Warnf("Lifecycle callbacks failed on %d instance(s) in %s, but termination proceeds", len(failed), region)
+			return nil
+		}
+
+		return fmt.Errorf("%w on %d instance(s) in %s: %s", ErrLifecycleCallbacksFailed, len(failed), region, strings.Join(failed, ", "))
+	}
+}
+
+// runCommands sends commands to instances and waits until they finish on every instance or timeout is exceeded
+func (d *Deployer) runCommands(client aws.Client, targets, commands []string, timeout, pollingInterval time.Duration) ([]aws.CommandInvocation, error) {
+	commandID, err := client.SSMService.StartCommand(targets, commands, "goployer lifecycle callbacks", timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]aws.CommandInvocation{}
+	deadline := time.Now().Add(timeout)
+	for {
+		for _, target := range targets {
+			if _, ok := results[target]; ok {
+				continue
+			}
+
+			invocation, err := client.SSMService.GetCommandInvocation(commandID, target)
+			if err != nil {
+				return nil, err
+			}
+
+			if invocation.Done() {
+				results[target] = invocation
+			}
+		}
+
+		if len(results) == len(targets) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(pollingInterval)
+	}
+
+	var ret []aws.CommandInvocation
+	for _, target := range targets {
+		invocation, ok := results[target]
+		if !ok {
+			invocation = aws.CommandInvocation{
+				CommandID:  commandID,
+				InstanceID: target,
+				Status:     ssm.CommandInvocationStatusTimedOut,
+			}
+		}
+		ret = append(ret, invocation)
+	}
+
+	return ret, nil
+}
+
+// reportLifecycleCallbacks prints results of lifecycle callbacks on each instance and sends them to slack
+func (d *Deployer) reportLifecycleCallbacks(region string, invocations []aws.CommandInvocation) {
+	var lines []string
+	for _, invocation := range invocations {
+		line := fmt.Sprintf("%s: %s (exit code %d)", invocation.InstanceID, invocation.Status, invocation.ResponseCode)
+		if stdout := tool.Excerpt(invocation.StandardOutput, constants.MaxCommandOutputLength); len(stdout) > 0 {
+			line += fmt.Sprintf("\n  stdout: %s", stdout)
+		}
+		if stderr := tool.Excerpt(invocation.StandardError, constants.MaxCommandOutputLength); len(stderr) > 0 {
+			line += fmt.Sprintf("\n  stderr: %s", stderr)
+		}
+
+		if invocation.Status == ssm.CommandInvocationStatusSuccess {
+			d.Logger.Infof("[Lifecycle callbacks] %s", line)
+		} else {
+			d.Logger.Warnf("[Lifecycle callbacks] %s", line)
+		}
+		lines = append(lines, line)
+	}
+
+	d.emit(event.Event{Type: event.LifecycleCallbacksFinished, Region: region, Message: fmt.Sprintf("Lifecycle callbacks before termination in %s\n```%s```", region, strings.Join(lines, "\n"))})
+}
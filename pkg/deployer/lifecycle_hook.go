@@ -0,0 +1,188 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ssm"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// LaunchValidation is a validation command running on an instance waiting in launch lifecycle hook
+type LaunchValidation struct {
+	CommandID string
+	StartedAt time.Time
+	Result    string
+}
+
+// validationHooks returns launch lifecycle hooks which goployer completes after validation
+func (d *Deployer) validationHooks() []schemas.LifecycleHookSpecification {
+	if d.Stack.LifecycleHooks == nil {
+		return nil
+	}
+
+	var ret []schemas.LifecycleHookSpecification
+	for _, hook := range d.Stack.LifecycleHooks.LaunchTransition {
+		if hook.Validation != nil {
+			ret = append(ret, hook)
+		}
+	}
+
+	return ret
+}
+
+// ValidateLaunchingInstances runs validation commands on instances waiting in launch lifecycle hooks
+// and completes their lifecycle actions with the results. It returns the number of instances still being validated.
+func (d *Deployer) ValidateLaunchingInstances(client aws.Client, asg *autoscaling.Group) (int, error) {
+	hooks := d.validationHooks()
+	if len(hooks) == 0 {
+		return 0, nil
+	}
+
+	if d.LaunchValidations == nil {
+		d.LaunchValidations = map[string]*LaunchValidation{}
+	}
+
+	waiting := 0
+	for _, instance := range asg.Instances {
+		if instance.LifecycleState == nil || *instance.LifecycleState != autoscaling.LifecycleStatePendingWait {
+			continue
+		}
+
+		done := true
+		for _, hook := range hooks {
+			completed, err := d.validateLaunchingInstance(client, *asg.AutoScalingGroupName, *instance.InstanceId, hook)
+			if err != nil {
+				return 0, err
+			}
+			done = done && completed
+		}
+
+		if !done {
+			waiting++
+		}
+	}
+
+	return waiting, nil
+}
+
+// validateLaunchingInstance starts validation of instance or completes its lifecycle action when the result comes out
+func (d *Deployer) validateLaunchingInstance(client aws.Client, asg, instanceID string, hook schemas.LifecycleHookSpecification) (bool, error) {
+	key := fmt.Sprintf("%s/%s", hook.LifecycleHookName, instanceID)
+	v, ok := d.LaunchValidations[key]
+	if !ok {
+		v = &LaunchValidation{StartedAt: time.Now()}
+		d.LaunchValidations[key] = v
+	}
+
+	// the instance is still waiting for other lifecycle hooks
+	if len(v.Result) > 0 {
+		return true, nil
+	}
+
+	if len(v.CommandID) == 0 {
+		commandID, err := client.SSMService.StartCommand([]string{instanceID}, hook.Validation.Commands, "goployer lifecycle hook validation", d.validationTimeout(hook))
+		switch {
+		case err == nil:
+			// timeout is counted from the start of lifecycle action like heartbeat timeout, including the wait for SSM agent
+			v.CommandID = commandID
+			d.Logger.Infof("Validation of launching instance started: %s / %s", hook.LifecycleHookName, instanceID)
+			return false, nil
+		case !aws.IsInstanceNotManaged(err):
+			return false, err
+		case time.Since(v.StartedAt) <= d.validationTimeout(hook):
+			// SSM agent is usually registered a while after the instance starts
+			d.Logger.Debugf("Validation waits for SSM agent of launching instance: %s / %s", hook.LifecycleHookName, instanceID)
+			return false, nil
+		}
+
+		d.Logger.Warnf("Validation of launching instance cannot start because SSM agent is not registered in %s: %s / %s", d.validationTimeout(hook), hook.LifecycleHookName, instanceID)
+		return d.completeLaunchValidation(client, asg, instanceID, hook, v, constants.LifecycleActionAbandon)
+	}
+
+	invocation, err := client.SSMService.GetCommandInvocation(v.CommandID, instanceID)
+	if err != nil {
+		return false, err
+	}
+
+	var result string
+	switch {
+	case invocation.Status == ssm.CommandInvocationStatusSuccess:
+		result = constants.LifecycleActionContinue
+	case invocation.Done():
+		result = constants.LifecycleActionAbandon
+		d.Logger.Warnf("Validation of launching instance failed with %s(exit code %d): %s / %s", invocation.Status, invocation.ResponseCode, hook.LifecycleHookName, instanceID)
+		if output := strings.TrimSpace(invocation.StandardError); len(output) > 0 {
+			d.Logger.Warnf("[%s] %s", instanceID, output)
+		}
+	case time.Since(v.StartedAt) > d.validationTimeout(hook):
+		result = constants.LifecycleActionAbandon
+		d.Logger.Warnf("Validation of launching instance timed out after %s: %s / %s", d.validationTimeout(hook), hook.LifecycleHookName, instanceID)
+	default:
+		return false, nil
+	}
+
+	return d.completeLaunchValidation(client, asg, instanceID, hook, v, result)
+}
+
+// completeLaunchValidation completes the lifecycle action of instance with the result of validation.
+// The result is kept only after the lifecycle action is completed, so that it is tried again in the next polling if it fails.
+func (d *Deployer) completeLaunchValidation(client aws.Client, asg, instanceID string, hook schemas.LifecycleHookSpecification, v *LaunchValidation, result string) (bool, error) {
+	if err := client.EC2Service.CompleteLifecycleAction(asg, hook.LifecycleHookName, instanceID, result); err != nil {
+		d.Logger.Warnf("Lifecycle action cannot be completed with %s, so it is tried again in the next polling: %s / %s: %s", result, hook.LifecycleHookName, instanceID, err.Error())
+		return false, nil
+	}
+	v.Result = result
+	d.Logger.Infof("Lifecycle action is completed with %s: %s / %s", v.Result, hook.LifecycleHookName, instanceID)
+
+	if v.Result == constants.LifecycleActionAbandon {
+		d.emit(event.Event{Type: event.InstanceAbandoned, AutoScalingGroup: asg, InstanceID: instanceID, Message: fmt.Sprintf("Launching instance is abandoned by validation of %s : %s / %s", hook.LifecycleHookName, asg, instanceID)})
+	}
+
+	return true, nil
+}
+
+// validationTimeout returns how long to wait for the result of validation.
+// Without timeout, validation ends a polling interval before heartbeat timeout so that autoscaling group does not apply the default result first.
+func (d *Deployer) validationTimeout(hook schemas.LifecycleHookSpecification) time.Duration {
+	if hook.Validation.Timeout > 0 {
+		return hook.Validation.Timeout
+	}
+
+	if hook.HeartbeatTimeout > 0 {
+		heartbeat := time.Duration(hook.HeartbeatTimeout) * time.Second
+		margin := d.Stack.PollingInterval
+		if margin < constants.MinPollingInterval {
+			margin = constants.MinPollingInterval
+		}
+
+		if heartbeat > margin {
+			return heartbeat - margin
+		}
+		return heartbeat / 2
+	}
+
+	return constants.DefaultLifecycleHookValidationTimeout
+}
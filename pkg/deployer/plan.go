@@ -0,0 +1,146 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// DeploymentPlan describes resources which will be created for a new version in a region
+type DeploymentPlan struct {
+	Stack                  string             `json:"stack"`
+	Region                 string             `json:"region"`
+	ReplacementType        string             `json:"replacement_type"`
+	Version                int                `json:"version"`
+	AutoscalingGroupName   string             `json:"autoscaling_group_name"`
+	PreviousVersions       []string           `json:"previous_versions"`
+	Capacity               schemas.Capacity   `json:"capacity"`
+	HealthcheckType        string             `json:"healthcheck_type"`
+	HealthcheckGracePeriod int64              `json:"healthcheck_grace_period"`
+	AvailabilityZones      []string           `json:"availability_zones"`
+	Subnets                []string           `json:"subnets"`
+	LoadBalancers          []string           `json:"load_balancers"`
+	TargetGroupARNs        []string           `json:"target_group_arns"`
+	TerminationPolicies    []string           `json:"termination_policies"`
+	InstanceOverrides      []string           `json:"instance_overrides,omitempty"`
+	WarmPool               *schemas.WarmPool  `json:"warm_pool,omitempty"`
+	Tags                   []TagPlan          `json:"tags"`
+	LaunchTemplate         LaunchTemplatePlan `json:"launch_template"`
+
+	// raw inputs kept for creating resources
+	userdata             string
+	securityGroups       []*string
+	blockDevices         []*ec2.LaunchTemplateBlockDeviceMappingRequest
+	tags                 []*autoscaling.Tag
+	targetGroupARNs      []*string
+	terminationPolicies  []*string
+	lifecycleHooks       []*autoscaling.LifecycleHookSpecification
+	mixedInstancesPolicy schemas.MixedInstancesPolicy
+}
+
+// LaunchTemplatePlan describes a launch template which will be created
+type LaunchTemplatePlan struct {
+	Name                      string            `json:"name"`
+	AMI                       string            `json:"ami"`
+	InstanceType              string            `json:"instance_type"`
+	KeyName                   string            `json:"key_name"`
+	IamInstanceProfile        string            `json:"iam_instance_profile"`
+	EbsOptimized              bool              `json:"ebs_optimized"`
+	DetailedMonitoringEnabled bool              `json:"detailed_monitoring_enabled"`
+	SecurityGroups            []string          `json:"security_groups"`
+	BlockDevices              []BlockDevicePlan `json:"block_devices"`
+	Tags                      []string          `json:"tags"`
+}
+
+// BlockDevicePlan describes an EBS volume of launch template
+type BlockDevicePlan struct {
+	DeviceName string `json:"device_name"`
+	VolumeType string `json:"volume_type"`
+	VolumeSize int64  `json:"volume_size"`
+	Iops       int64  `json:"iops,omitempty"`
+	Encrypted  bool   `json:"encrypted"`
+}
+
+// TagPlan is a tag of autoscaling group
+type TagPlan struct {
+	Key               string `json:"key"`
+	Value             string `json:"value"`
+	PropagateAtLaunch bool   `json:"propagate_at_launch"`
+}
+
+// Plan makes deployment plans of all target regions without changing any resource
+func (d *Deployer) Plan(config schemas.Config) ([]DeploymentPlan, error) {
+	if err := d.CheckPrevious(config); err != nil {
+		return nil, err
+	}
+
+	d.LocalProvider = builder.SetUserdataProvider(d.Stack.Userdata, d.AwsConfig.Userdata)
+
+	var plans []DeploymentPlan
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			d.Logger.Debug("This region is skipped by user : " + region.Region)
+			continue
+		}
+
+		plan, err := d.MakeDeploymentPlan(config, region)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+
+	return plans, nil
+}
+
+// makeTagPlans converts autoscaling tags to plan
+func makeTagPlans(tags []*autoscaling.Tag) []TagPlan {
+	var ret []TagPlan
+	for _, tag := range tags {
+		t := TagPlan{}
+		if tag.Key != nil {
+			t.Key = *tag.Key
+		}
+		if tag.Value != nil {
+			t.Value = *tag.Value
+		}
+		if tag.PropagateAtLaunch != nil {
+			t.PropagateAtLaunch = *tag.PropagateAtLaunch
+		}
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+// makeBlockDevicePlans converts block devices of stack to plan
+func makeBlockDevicePlans(blockDevices []schemas.BlockDevice) []BlockDevicePlan {
+	var ret []BlockDevicePlan
+	for _, bd := range blockDevices {
+		ret = append(ret, BlockDevicePlan{
+			DeviceName: bd.DeviceName,
+			VolumeType: bd.VolumeType,
+			VolumeSize: bd.VolumeSize,
+			Iops:       bd.Iops,
+			Encrypted:  bd.Encrypted,
+		})
+	}
+	return ret
+}
@@ -0,0 +1,525 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/checkpoint"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/helper"
+	"github.com/DevopsArtFactory/goployer/pkg/refresh"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// Refresh replaces instances of a single long-lived autoscaling group with instance refresh
+type Refresh struct {
+	RefreshIDs          map[string]*string
+	RefreshStatus       map[string]string
+	PrevLaunchTemplates map[string]*autoscaling.LaunchTemplateSpecification
+	*Deployer
+}
+
+// NewRefresh creates new Refresh deployment deployer
+func NewRefresh(h *helper.DeployerHelper) *Refresh {
+	d := InitDeploymentConfiguration(h, bootstrapClients(h))
+
+	return &Refresh{
+		RefreshIDs:          map[string]*string{},
+		RefreshStatus:       map[string]string{},
+		PrevLaunchTemplates: map[string]*autoscaling.LaunchTemplateSpecification{},
+		Deployer:            &d,
+	}
+}
+
+// GetDeployer returns Deployer struct
+func (r *Refresh) GetDeployer() *Deployer {
+	return r.Deployer
+}
+
+// CheckPreviousResources checks previous autoscaling groups and excludes the one which is going to be refreshed
+func (r *Refresh) CheckPreviousResources(config schemas.Config) error {
+	if err := r.CheckPrevious(config); err != nil {
+		return err
+	}
+
+	for _, region := range r.Stack.Regions {
+		target, ok := r.LatestAsg[region.Region]
+		if !ok {
+			continue
+		}
+
+		group, err := r.DescribeAutoScalingGroup(target, region.Region)
+		if err != nil {
+			return err
+		}
+
+		// the latest autoscaling group keeps serving, so only stale ones are cleaned after refresh
+		var prevAsgs []string
+		for _, asg := range r.PrevAsgs[region.Region] {
+			if asg != target {
+				prevAsgs = append(prevAsgs, asg)
+			}
+		}
+
+		var prevInstances []string
+		for _, id := range r.PrevInstances[region.Region] {
+			if !isInstanceOfGroup(id, group) {
+				prevInstances = append(prevInstances, id)
+			}
+		}
+
+		r.PrevAsgs[region.Region] = prevAsgs
+		r.PrevInstances[region.Region] = prevInstances
+		r.Logger.Infof("Autoscaling group to refresh : %s", target)
+	}
+
+	return nil
+}
+
+// Deploy starts instance refresh of the latest autoscaling group or creates a new one if nothing exists
+func (r *Refresh) Deploy(config schemas.Config) error {
+	if !r.StepStatus[constants.StepCheckPrevious] {
+		return nil
+	}
+
+	r.Logger.Info("Deploy Mode is " + r.Mode)
+
+	// Get LocalFileProvider
+	r.LocalProvider = builder.SetUserdataProvider(r.Stack.Userdata, r.AwsConfig.Userdata)
+
+	for _, region := range r.Stack.Regions {
+		// Region check
+		// If region id is passed from command line, then deployer will deploy in that region only.
+		if config.Region != "" && config.Region != region.Region {
+			r.Logger.Debug("This region is skipped by user : " + region.Region)
+			continue
+		}
+
+		if len(r.LatestAsg[region.Region]) == 0 {
+			r.Logger.Infof("No autoscaling group to refresh, so a new one is created : %s", region.Region)
+			if err := r.Deployer.Deploy(config, region); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := r.StartRefresh(config, region); err != nil {
+			return err
+		}
+	}
+
+	r.StepStatus[constants.StepDeploy] = true
+	return nil
+}
+
+// StartRefresh creates a new launch template version and starts instance refresh with it
+func (r *Refresh) StartRefresh(config schemas.Config, region schemas.RegionConfig) error {
+	target := r.LatestAsg[region.Region]
+
+	client, err := selectClientFromList(r.AWSClients, region.Region)
+	if err != nil {
+		return err
+	}
+
+	group, err := client.EC2Service.GetMatchingAutoscalingGroup(target)
+	if err != nil {
+		return err
+	}
+
+	if group.LaunchTemplate == nil || group.LaunchTemplate.LaunchTemplateId == nil {
+		return fmt.Errorf("autoscaling group does not use launch template: %s", target)
+	}
+
+	plan, err := r.makeDeploymentPlan(config, region, tool.ParseAutoScalingVersion(target))
+	if err != nil {
+		return err
+	}
+	plan.AutoscalingGroupName = target
+
+	sourceVersion := aws.StringValue(group.LaunchTemplate.Version)
+	if len(sourceVersion) == 0 {
+		sourceVersion = "$Default"
+	}
+
+	lt, err := client.EC2Service.CreateLaunchTemplateVersionWithImage(*group.LaunchTemplate.LaunchTemplateId, sourceVersion, plan.LaunchTemplate.AMI, plan.LaunchTemplate.InstanceType, plan.userdata, plan.securityGroups)
+	if err != nil {
+		return err
+	}
+	r.Logger.Infof("New launch template version is created : %s / %d", *lt.LaunchTemplateId, *lt.VersionNumber)
+
+	current := schemas.Capacity{Min: *group.MinSize, Max: *group.MaxSize, Desired: *group.DesiredCapacity}
+	if current != plan.Capacity {
+		if err := r.ResizingAutoScalingGroup(target, region.Region, plan.Capacity); err != nil {
+			return err
+		}
+	}
+
+	desired := &autoscaling.LaunchTemplateSpecification{
+		LaunchTemplateId: lt.LaunchTemplateId,
+		Version:          aws.String(strconv.FormatInt(*lt.VersionNumber, 10)),
+	}
+
+	id, err := client.EC2Service.StartLaunchTemplateRefresh(group.AutoScalingGroupName, desired, MakeRefreshPreferences(r.Stack.InstanceRefresh))
+	if err != nil {
+		return err
+	}
+	r.Logger.Infof("Instance refresh is started : %s / %s", target, *id)
+	r.emit(event.Event{Type: event.InstanceRefreshStarted, Region: region.Region, AutoScalingGroup: target, Message: fmt.Sprintf("Instance refresh is started : %s/%s", target, region.Region)})
+
+	r.stampDeployment(config, plan)
+
+	appliedCapacity := plan.Capacity
+	r.PrevLaunchTemplates[region.Region] = group.LaunchTemplate
+	r.RefreshIDs[region.Region] = id
+	r.AsgNames[region.Region] = target
+	r.AppliedCapacity = &appliedCapacity
+
+	return nil
+}
+
+// HealthChecking waits for instance refreshes to finish and checks health of the refreshed instances
+func (r *Refresh) HealthChecking(config schemas.Config) error {
+	for _, region := range r.Stack.Regions {
+		id, ok := r.RefreshIDs[region.Region]
+		if !ok {
+			continue
+		}
+
+		timeout := config.Timeout - time.Since(time.Unix(config.StartTimestamp, 0))
+		info, err := r.waitForRefresh(region.Region, id, config.PollingInterval, timeout)
+		if err != nil {
+			return err
+		}
+
+		if *info.Status != autoscaling.InstanceRefreshStatusSuccessful {
+			return fmt.Errorf("instance refresh is finished with %s status : %s", *info.Status, r.AsgNames[region.Region])
+		}
+	}
+
+	healthy := false
+	for !healthy {
+		isTimeout, _ := tool.CheckTimeout(config.StartTimestamp, config.Timeout)
+		if isTimeout {
+			return fmt.Errorf("timeout has been exceeded : %.0f minutes", config.Timeout.Minutes())
+		}
+
+		isDone, err := r.Deployer.HealthChecking(config)
+		if err != nil {
+			return fmt.Errorf("error happened while health checking: %s", err.Error())
+		}
+
+		if isDone {
+			healthy = true
+		} else {
+			time.Sleep(config.PollingInterval)
+		}
+	}
+
+	return nil
+}
+
+// waitForRefresh polls instance refresh until it is finished and prints the result
+func (r *Refresh) waitForRefresh(region string, id *string, pollingInterval, timeout time.Duration) (*autoscaling.InstanceRefresh, error) {
+	client, err := selectClientFromList(r.AWSClients, region)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := client.EC2Service.GetMatchingAutoscalingGroup(r.AsgNames[region])
+	if err != nil {
+		return nil, err
+	}
+
+	refresher := refresh.NewWithClient(client)
+	refresher.SetTarget(group)
+	refresher.RefreshID = id
+
+	if err := refresher.StatusCheck(pollingInterval, timeout); err != nil {
+		return nil, err
+	}
+
+	if err := refresher.PrintResult(); err != nil {
+		return nil, err
+	}
+
+	r.RefreshStatus[region] = *refresher.Info.Status
+	r.emit(event.Event{Type: event.InstanceRefreshEnded, Region: region, AutoScalingGroup: r.AsgNames[region], Message: fmt.Sprintf("Instance refresh is finished with %s status : %s/%s", *refresher.Info.Status, r.AsgNames[region], region)})
+
+	return refresher.Info, nil
+}
+
+// FinishAdditionalWork processes additional work for the refreshed autoscaling group
+func (r *Refresh) FinishAdditionalWork(config schemas.Config) error {
+	if !r.StepStatus[constants.StepDeploy] {
+		return nil
+	}
+
+	skipped := len(config.Region) > 0 && !CheckRegionExist(config.Region, r.Stack.Regions)
+
+	if !skipped {
+		if err := r.DoCommonAdditionalWork(config); err != nil {
+			return err
+		}
+	}
+
+	r.Logger.Debug("Finish additional works.")
+	r.StepStatus[constants.StepAdditionalWork] = true
+	return nil
+}
+
+// TriggerLifecycleCallbacks runs lifecycle callbacks before cleaning.
+func (r *Refresh) TriggerLifecycleCallbacks(config schemas.Config) error {
+	if !r.StepStatus[constants.StepAdditionalWork] {
+		return nil
+	}
+	return r.Deployer.TriggerLifecycleCallbacks(config)
+}
+
+// CleanPreviousVersion cleans stale autoscaling groups except the refreshed one
+func (r *Refresh) CleanPreviousVersion(config schemas.Config) error {
+	if !r.StepStatus[constants.StepTriggerLifecycleCallback] {
+		return nil
+	}
+	r.Logger.Debug("Delete Mode is " + r.Mode)
+
+	skipped := len(config.Region) > 0 && !CheckRegionExist(config.Region, r.Stack.Regions)
+
+	if !skipped {
+		if err := r.CleanPreviousAutoScalingGroup(config); err != nil {
+			return err
+		}
+	}
+	r.StepStatus[constants.StepCleanPreviousVersion] = true
+	return nil
+}
+
+// CleanChecking checks Termination status
+func (r *Refresh) CleanChecking(config schemas.Config) error {
+	if !r.StepStatus[constants.StepCleanPreviousVersion] {
+		return nil
+	}
+	done := false
+
+	for !done {
+		isTimeout, _ := tool.CheckTimeout(config.StartTimestamp, config.Timeout)
+		if isTimeout {
+			return fmt.Errorf("timeout has been exceeded : %.0f minutes", config.Timeout.Minutes())
+		}
+
+		isDone, err := r.Deployer.CleanChecking(config)
+		if err != nil {
+			return errors.New("error happened while health checking")
+		}
+
+		if isDone {
+			done = true
+		} else {
+			r.Logger.Info("All stacks are not ready to be terminated... Please waiting...")
+			time.Sleep(config.PollingInterval)
+		}
+	}
+
+	r.StepStatus[constants.StepCleanChecking] = true
+	return nil
+}
+
+// GatherMetrics gathers the whole metrics from deployer
+func (r *Refresh) GatherMetrics(config schemas.Config) error {
+	if config.DisableMetrics {
+		return nil
+	}
+
+	if len(config.Region) > 0 && !CheckRegionExist(config.Region, r.Stack.Regions) {
+		return nil
+	}
+
+	return r.StartGatheringMetrics(config)
+}
+
+// RunAPITest tries to run API Test
+func (r *Refresh) RunAPITest(config schemas.Config) error {
+	return r.Deployer.RunAPITest(config)
+}
+
+// Rollback brings the previous launch template back to refreshed autoscaling groups and deletes newly created ones
+func (r *Refresh) Rollback(config schemas.Config) error {
+	for _, region := range r.Stack.Regions {
+		if config.Region != constants.EmptyString && config.Region != region.Region {
+			continue
+		}
+
+		target := r.AsgNames[region.Region]
+		if len(target) == 0 || len(r.LatestAsg[region.Region]) == 0 {
+			continue
+		}
+
+		if err := r.rollbackRefresh(config, region.Region, target); err != nil {
+			return err
+		}
+		delete(r.AsgNames, region.Region)
+	}
+
+	// autoscaling groups created because nothing existed are deleted like the other replacement types
+	return r.Deployer.Rollback(config)
+}
+
+// rollbackRefresh rolls back the in-progress refresh or refreshes instances again with the previous launch template
+func (r *Refresh) rollbackRefresh(config schemas.Config, region, target string) error {
+	r.Logger.Warnf("[%s] Rolling back instance refresh: %s", region, target)
+	r.emit(event.Event{Type: event.RollbackStarted, Region: region, AutoScalingGroup: target, Message: fmt.Sprintf(":rewind: Rolling back instance refresh : %s/%s", target, region)})
+
+	if r.RefreshStatus[region] == autoscaling.InstanceRefreshStatusRollbackSuccessful {
+		r.Logger.Infof("[%s] Instance refresh has already been rolled back: %s", region, target)
+	} else {
+		client, err := selectClientFromList(r.AWSClients, region)
+		if err != nil {
+			return err
+		}
+
+		id, err := client.EC2Service.RollbackInstanceRefresh(aws.String(target))
+		if err != nil {
+			aerr, ok := err.(awserr.Error)
+			if !ok || aerr.Code() != autoscaling.ErrCodeActiveInstanceRefreshNotFoundFault {
+				return err
+			}
+
+			prev, ok := r.PrevLaunchTemplates[region]
+			if !ok {
+				return fmt.Errorf("previous launch template is unknown, so instance refresh cannot be rolled back: %s", target)
+			}
+
+			preferences := MakeRefreshPreferences(r.Stack.InstanceRefresh)
+			preferences.SkipMatching = aws.Bool(true)
+			preferences.AutoRollback = nil
+			preferences.CheckpointPercentages = nil
+			preferences.CheckpointDelay = nil
+
+			id, err = client.EC2Service.StartLaunchTemplateRefresh(aws.String(target), prev, preferences)
+			if err != nil {
+				return err
+			}
+		}
+
+		info, err := r.waitForRefresh(region, id, config.PollingInterval, config.Timeout)
+		if err != nil {
+			return err
+		}
+
+		if !tool.IsStringInArray(*info.Status, []string{autoscaling.InstanceRefreshStatusSuccessful, autoscaling.InstanceRefreshStatusRollbackSuccessful}) {
+			return fmt.Errorf("rollback of instance refresh is finished with %s status : %s", *info.Status, target)
+		}
+	}
+
+	if r.Collector.MetricConfig.Enabled {
+		if err := r.Collector.UpdateStatus(target, "rolled_back", nil); err != nil {
+			r.Logger.Errorf("Update status Error, %s : %s", err.Error(), target)
+		}
+	}
+
+	r.Logger.Infof("[%s] Rollback is finished: %s", region, target)
+	r.emit(event.Event{Type: event.RollbackFinished, Region: region, AutoScalingGroup: target, Message: fmt.Sprintf(":rewind: Rollback is finished : %s/%s", target, region)})
+
+	return nil
+}
+
+// RestoreCheckpoint restores the progress of deployer and finds the instance refresh which is still in progress
+func (r *Refresh) RestoreCheckpoint(cp checkpoint.Stack) error {
+	if err := r.Deployer.RestoreCheckpoint(cp); err != nil {
+		return err
+	}
+
+	if !r.StepStatus[constants.StepDeploy] || r.StepStatus[constants.StepAdditionalWork] {
+		return nil
+	}
+
+	for _, region := range r.Stack.Regions {
+		target := r.AsgNames[region.Region]
+		if len(target) == 0 || len(r.LatestAsg[region.Region]) == 0 {
+			continue
+		}
+
+		client, err := selectClientFromList(r.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		// finished refresh is not returned, then only health check of target group is done
+		info, err := client.EC2Service.DescribeInstanceRefreshes(aws.String(target), nil)
+		if err != nil {
+			r.Logger.Debugf("No instance refresh in progress: %s", target)
+			continue
+		}
+		r.RefreshIDs[region.Region] = info.InstanceRefreshId
+	}
+
+	return nil
+}
+
+// MakeRefreshPreferences makes preferences of instance refresh with defaults
+func MakeRefreshPreferences(ir *schemas.InstanceRefresh) *autoscaling.RefreshPreferences {
+	preferences := &autoscaling.RefreshPreferences{
+		InstanceWarmup:       aws.Int64(constants.DefaultInstanceWarmup),
+		MinHealthyPercentage: aws.Int64(constants.DefaultMinHealthyPercentage),
+	}
+
+	if ir == nil {
+		return preferences
+	}
+
+	if ir.InstanceWarmup > 0 {
+		preferences.InstanceWarmup = aws.Int64(ir.InstanceWarmup)
+	}
+
+	if ir.MinHealthyPercentage > 0 {
+		preferences.MinHealthyPercentage = aws.Int64(ir.MinHealthyPercentage)
+	}
+
+	if len(ir.CheckpointPercentages) > 0 {
+		preferences.CheckpointPercentages = aws.Int64Slice(ir.CheckpointPercentages)
+	}
+
+	if ir.CheckpointDelay > 0 {
+		preferences.CheckpointDelay = aws.Int64(int64(ir.CheckpointDelay / time.Second))
+	}
+
+	preferences.SkipMatching = aws.Bool(ir.SkipMatching)
+	preferences.AutoRollback = aws.Bool(ir.AutoRollback)
+
+	return preferences
+}
+
+// isInstanceOfGroup checks if the instance belongs to the autoscaling group
+func isInstanceOfGroup(id string, group *autoscaling.Group) bool {
+	for _, instance := range group.Instances {
+		if *instance.InstanceId == id {
+			return true
+		}
+	}
+
+	return false
+}
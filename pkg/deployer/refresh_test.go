@@ -0,0 +1,67 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestMakeRefreshPreferences(t *testing.T) {
+	testData := []struct {
+		Input    *schemas.InstanceRefresh
+		Expected *autoscaling.RefreshPreferences
+	}{
+		{
+			Input: nil,
+			Expected: &autoscaling.RefreshPreferences{
+				InstanceWarmup:       aws.Int64(300),
+				MinHealthyPercentage: aws.Int64(90),
+			},
+		},
+		{
+			Input: &schemas.InstanceRefresh{
+				InstanceWarmup:        60,
+				MinHealthyPercentage:  50,
+				CheckpointPercentages: []int64{20, 100},
+				CheckpointDelay:       5 * time.Minute,
+				SkipMatching:          true,
+				AutoRollback:          true,
+			},
+			Expected: &autoscaling.RefreshPreferences{
+				InstanceWarmup:        aws.Int64(60),
+				MinHealthyPercentage:  aws.Int64(50),
+				CheckpointPercentages: aws.Int64Slice([]int64{20, 100}),
+				CheckpointDelay:       aws.Int64(300),
+				SkipMatching:          aws.Bool(true),
+				AutoRollback:          aws.Bool(true),
+			},
+		},
+	}
+
+	for _, td := range testData {
+		if diff := deep.Equal(MakeRefreshPreferences(td.Input), td.Expected); diff != nil {
+			t.Error(diff)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// ErrRegionGate is returned when the deployed region does not pass the gate of sequential region rollout
+var ErrRegionGate = errors.New("region gate is not passed")
+
+// CheckRegionGate checks the new version of deployed regions before the rollout goes on to the next region
+func (d *Deployer) CheckRegionGate(config schemas.Config) error {
+	gate := d.Stack.RegionGate
+	if gate == nil {
+		return nil
+	}
+
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			continue
+		}
+
+		client, err := selectClientFromList(d.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		if gate.HealthCheck {
+			asg, err := client.EC2Service.GetMatchingAutoscalingGroup(d.AsgNames[region.Region])
+			if err != nil {
+				return err
+			}
+
+			healthy, err := d.Polling(region, asg, client, false, false, false)
+			if err != nil {
+				return err
+			}
+
+			if !healthy {
+				return fmt.Errorf("%w: new version is not healthy: %s", ErrRegionGate, d.AsgNames[region.Region])
+			}
+		}
+
+		if len(gate.Alarms) == 0 {
+			continue
+		}
+
+		states, err := client.CloudWatchService.GetAlarmStates(gate.Alarms)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range gate.Alarms {
+			state, ok := states[name]
+			if !ok {
+				return fmt.Errorf("alarm does not exist: %s / %s", name, region.Region)
+			}
+
+			if state == cloudwatch.StateValueAlarm {
+				return fmt.Errorf("%w: %s is in ALARM state in %s", ErrRegionGate, name, region.Region)
+			}
+		}
+	}
+
+	d.Logger.Infof("Region gate is passed: %s", d.Stack.Stack)
+	return nil
+}
@@ -0,0 +1,271 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// recordedUserdata provides userdata stamped on the deployment record
+type recordedUserdata struct {
+	userdata string
+}
+
+// Provide returns userdata which is already encoded
+func (r recordedUserdata) Provide() (string, error) {
+	return r.userdata, nil
+}
+
+// Restore brings back a previous version of autoscaling group in all target regions.
+// The version which is currently serving becomes the previous version, so it will be drained with the normal cleaning steps.
+func (d *Deployer) Restore(config schemas.Config) error {
+	for _, region := range d.Stack.Regions {
+		if config.Region != constants.EmptyString && config.Region != region.Region {
+			d.Logger.Debugf("This region is skipped by user: %s", region.Region)
+			continue
+		}
+
+		if err := d.restoreVersion(config, region); err != nil {
+			return err
+		}
+	}
+
+	d.StepStatus[constants.StepDeploy] = true
+	return nil
+}
+
+// restoreVersion rescales the target version if it still exists, or recreates it from the deployment record
+func (d *Deployer) restoreVersion(config schemas.Config, region schemas.RegionConfig) error {
+	current, ok := d.LatestAsg[region.Region]
+	if !ok {
+		return fmt.Errorf("no autoscaling group is deployed in %s", region.Region)
+	}
+	currentVersion := tool.ParseAutoScalingVersion(current)
+
+	prefix := tool.BuildPrefixName(d.AwsConfig.Name, d.Stack.Env, region.Region)
+	var existing []string
+	for _, v := range d.PrevVersions[region.Region] {
+		existing = append(existing, tool.GenerateAsgName(prefix, v))
+	}
+
+	targetVersion, err := d.selectRestoreVersion(config.RollbackVersion, prefix, currentVersion, d.PrevVersions[region.Region])
+	if err != nil {
+		return err
+	}
+	target := tool.GenerateAsgName(prefix, targetVersion)
+	d.Logger.Infof("[%s] Rollback from %s to %s", region.Region, current, target)
+	d.emit(event.Event{Type: event.RollbackStarted, Region: region.Region, AutoScalingGroup: current, Message: fmt.Sprintf(":rewind: Rollback from %s to %s in %s", current, target, region.Region)})
+
+	// the restored version takes over the capacity which the current version is serving with
+	capacity := d.PrevCapacity[current]
+	if capacity.Desired == 0 {
+		capacity = d.Stack.Capacity
+	}
+
+	if tool.IsStringInArray(target, existing) {
+		if prev := d.PrevCapacity[target]; prev.Desired > 0 {
+			capacity = prev
+		}
+
+		if err := d.ResizingAutoScalingGroup(target, region.Region, capacity); err != nil {
+			return err
+		}
+	} else {
+		if err := d.recreateVersion(config, region, targetVersion, capacity); err != nil {
+			return err
+		}
+	}
+
+	if d.Collector.MetricConfig.Enabled {
+		if err := d.Collector.UpdateStatus(target, "creating", nil); err != nil {
+			d.Logger.Errorf("Update status Error, %s : %s", err.Error(), target)
+		}
+	}
+
+	var others []string
+	for _, asg := range existing {
+		if asg != target {
+			others = append(others, asg)
+		}
+	}
+
+	d.AsgNames[region.Region] = target
+	d.LatestAsg[region.Region] = target
+	d.PrevAsgs[region.Region] = others
+	d.AppliedCapacity = &capacity
+
+	return nil
+}
+
+// WaitForRestoredVersion waits until the restored version passes health checks
+func (d *Deployer) WaitForRestoredVersion(config schemas.Config) error {
+	for {
+		if isTimeout, _ := tool.CheckTimeout(config.StartTimestamp, config.Timeout); isTimeout {
+			return fmt.Errorf("timeout has been exceeded : %.0f minutes", config.Timeout.Minutes())
+		}
+
+		isDone, err := d.HealthChecking(config)
+		if err != nil {
+			return err
+		}
+
+		if isDone {
+			return nil
+		}
+		time.Sleep(config.PollingInterval)
+	}
+}
+
+// DrainPreviousVersions removes the versions other than the restored one
+func (d *Deployer) DrainPreviousVersions(config schemas.Config) error {
+	if err := d.CleanPreviousAutoScalingGroup(config); err != nil {
+		return err
+	}
+	d.StepStatus[constants.StepCleanPreviousVersion] = true
+
+	for {
+		if isTimeout, _ := tool.CheckTimeout(config.StartTimestamp, config.Timeout); isTimeout {
+			return fmt.Errorf("timeout has been exceeded : %.0f minutes", config.Timeout.Minutes())
+		}
+
+		isDone, err := d.CleanChecking(config)
+		if err != nil {
+			return err
+		}
+
+		if isDone {
+			break
+		}
+		d.Logger.Info("Previous versions are not terminated yet... Please waiting...")
+		time.Sleep(config.PollingInterval)
+	}
+
+	d.StepStatus[constants.StepCleanChecking] = true
+	return nil
+}
+
+// recreateVersion creates autoscaling group of the version again with stack and config in the deployment record
+func (d *Deployer) recreateVersion(config schemas.Config, region schemas.RegionConfig, version int, capacity schemas.Capacity) error {
+	target := tool.GenerateAsgName(tool.BuildPrefixName(d.AwsConfig.Name, d.Stack.Env, region.Region), version)
+	if !d.Collector.MetricConfig.Enabled {
+		return fmt.Errorf("autoscaling group does not exist and cannot be recreated without metrics: %s", target)
+	}
+
+	record, err := d.Collector.GetDeploymentRecord(target)
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		return fmt.Errorf("no deployment record exists for %s", target)
+	}
+
+	var recordedRegion *schemas.RegionConfig
+	for i, r := range record.Stack.Regions {
+		if r.Region == region.Region {
+			recordedRegion = &record.Stack.Regions[i]
+			break
+		}
+	}
+
+	if recordedRegion == nil {
+		return fmt.Errorf("region %s does not exist in the deployment record of %s", region.Region, target)
+	}
+
+	restorer := *d
+	restorer.Stack = record.Stack
+	restorer.LocalProvider = recordedUserdata{userdata: record.Userdata}
+	if len(record.Userdata) == 0 {
+		restorer.LocalProvider = builder.SetUserdataProvider(record.Stack.Userdata, d.AwsConfig.Userdata)
+	}
+
+	recordedConfig := record.Config
+	recordedConfig.ExtraTags = config.ExtraTags
+	if len(recordedConfig.ExtraTags) == 0 {
+		recordedConfig.ExtraTags = record.Config.ExtraTags
+	}
+
+	plan, err := restorer.makeDeploymentPlan(recordedConfig, *recordedRegion, version)
+	if err != nil {
+		return err
+	}
+	plan.Capacity = capacity
+
+	d.Logger.Infof("Recreate autoscaling group with ami %s: %s", plan.LaunchTemplate.AMI, target)
+	return restorer.createResources(plan, *recordedRegion)
+}
+
+// selectRestoreVersion decides the version to roll back to
+func (d *Deployer) selectRestoreVersion(requested, prefix string, current int, versions []int) (int, error) {
+	if len(requested) > 0 {
+		v, err := ParseVersion(requested)
+		if err != nil {
+			return 0, err
+		}
+
+		if v == current {
+			return 0, fmt.Errorf("version %s is already the current version", requested)
+		}
+		return v, nil
+	}
+
+	// the latest one among remaining autoscaling groups
+	selected := -1
+	for _, v := range versions {
+		if v < current && v > selected {
+			selected = v
+		}
+	}
+
+	if selected >= 0 {
+		return selected, nil
+	}
+
+	// look up deployment records of older versions
+	if d.Collector.MetricConfig.Enabled {
+		for v := current - 1; v >= 0; v-- {
+			record, err := d.Collector.GetDeploymentRecord(tool.GenerateAsgName(prefix, v))
+			if err != nil {
+				return 0, err
+			}
+
+			if record != nil && record.Status != "rolled_back" {
+				return v, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("no previous version exists to roll back: %s", tool.GenerateAsgName(prefix, current))
+}
+
+// ParseVersion parses version string like v012 or 12
+func ParseVersion(version string) (int, error) {
+	v, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(version), "v"))
+	if err != nil || v < 0 || v > 999 {
+		return 0, fmt.Errorf("version should be between v000 and v999: %s", version)
+	}
+	return v, nil
+}
@@ -21,9 +21,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/sirupsen/logrus"
 
-	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
@@ -42,16 +42,7 @@ type RollingUpdate struct {
 
 // NewRollingUpdate creates new rolling-update deployment deployer
 func NewRollingUpdate(h *helper.DeployerHelper) *RollingUpdate {
-	var awsClients []aws.Client
-	for _, region := range h.Stack.Regions {
-		if len(h.Region) > 0 && h.Region != region.Region {
-			h.Logger.Debugf("skip creating aws clients in %s region", region.Region)
-			continue
-		}
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, h.Stack.AssumeRole))
-	}
-
-	d := InitDeploymentConfiguration(h, awsClients)
+	d := InitDeploymentConfiguration(h, bootstrapClients(h))
 
 	return &RollingUpdate{
 		PrevHealthCheckTargetGroups: map[string]string{},
@@ -92,6 +83,18 @@ func (r *RollingUpdate) Deploy(config schemas.Config) error {
 			continue
 		}
 
+		// previous versions are reduced first so that the new version does not exceed max_surge
+		if HasSurgeSettings(r.Stack) && len(r.PrevAsgs[region.Region]) > 0 {
+			batch, err := r.FirstRollingBatch(config.ForceManifestCapacity, region.Region)
+			if err != nil {
+				return err
+			}
+
+			if err := r.ResizePreviousVersions(region.Region, batch.Previous); err != nil {
+				return err
+			}
+		}
+
 		err := r.Deployer.Deploy(config, region)
 		if err != nil {
 			return err
@@ -115,7 +118,7 @@ func (r *RollingUpdate) HealthChecking(config schemas.Config) error {
 
 		isDone, err := r.Deployer.HealthChecking(config)
 		if err != nil {
-			return errors.New("error happened while health checking")
+			return fmt.Errorf("error happened while health checking: %s", err.Error())
 		}
 
 		if isDone {
@@ -259,6 +262,11 @@ func (r *RollingUpdate) CleanChecking(config schemas.Config) error {
 	return nil
 }
 
+// Rollback deletes the new autoscaling group and restores capacities which were reduced during rolling update
+func (r *RollingUpdate) Rollback(config schemas.Config) error {
+	return r.Deployer.Rollback(config)
+}
+
 // CompleteRollingUpdate processes the whole process of rolling update
 func (r *RollingUpdate) CompleteRollingUpdate(config schemas.Config, region schemas.RegionConfig) error {
 	latestASG, ok := r.LatestAsg[region.Region]
@@ -276,6 +284,10 @@ func (r *RollingUpdate) CompleteRollingUpdate(config schemas.Config, region sche
 		return fmt.Errorf("no autoscaling group information retrieved. Please check autoscaling group resource: %s", latestASG)
 	}
 
+	if HasSurgeSettings(r.Stack) {
+		return r.completeRollingUpdateWithSurge(config, region)
+	}
+
 	appliedCapacity, err := r.DecideCapacity(config.ForceManifestCapacity, false, region.Region, len(r.PrevAsgs[region.Region]), r.Stack.RollingUpdateInstanceCount)
 	if err != nil {
 		return err
@@ -340,3 +352,176 @@ func RetrieveNextCapacity(capacity *schemas.Capacity, targetCapacity schemas.Cap
 func IsFinishedRollingUpdate(current schemas.Capacity, target schemas.Capacity) bool {
 	return current.Min == target.Min && current.Desired == target.Desired && current.Max == target.Max
 }
+
+// RollingBatch is the desired capacity of previous versions and the new version in a round of rolling update
+type RollingBatch struct {
+	Previous int64
+	Next     int64
+}
+
+// HasSurgeSettings checks if rolling update is controlled by max_surge and max_unavailable
+func HasSurgeSettings(stack schemas.Stack) bool {
+	return len(stack.MaxSurge) > 0 || len(stack.MaxUnavailable) > 0
+}
+
+// RollingUpdateLimits resolves max_surge and max_unavailable of stack with the target capacity
+func RollingUpdateLimits(stack schemas.Stack, target int64) (int64, int64, error) {
+	surge, err := tool.ParseCountOrPercentage(stack.MaxSurge, target, true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	unavailable, err := tool.ParseCountOrPercentage(stack.MaxUnavailable, target, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// rolling update cannot make any progress if both of them are zero
+	if surge == 0 && unavailable == 0 {
+		unavailable = 1
+	}
+
+	return surge, unavailable, nil
+}
+
+// NextRollingBatch decides the next round of rolling update.
+// Instances of both versions never exceed target+surge and healthy instances never go below target-unavailable.
+func NextRollingBatch(current RollingBatch, target, surge, unavailable int64) RollingBatch {
+	previous := target - unavailable - current.Next
+	if previous < 0 {
+		previous = 0
+	}
+	if previous > current.Previous {
+		previous = current.Previous
+	}
+
+	next := target + surge - previous
+	if next > target {
+		next = target
+	}
+	if next < current.Next {
+		next = current.Next
+	}
+
+	return RollingBatch{
+		Previous: previous,
+		Next:     next,
+	}
+}
+
+// FirstRollingBatch returns the round of rolling update in which the new autoscaling group is created
+func (d *Deployer) FirstRollingBatch(forceManifestCapacity bool, region string) (RollingBatch, error) {
+	target := d.CompareWithCurrentCapacity(forceManifestCapacity, region)
+	surge, unavailable, err := RollingUpdateLimits(d.Stack, target.Desired)
+	if err != nil {
+		return RollingBatch{}, err
+	}
+
+	var previous int64
+	for _, asg := range d.PrevAsgs[region] {
+		previous += d.PrevCapacity[asg].Desired
+	}
+
+	return NextRollingBatch(RollingBatch{Previous: previous}, target.Desired, surge, unavailable), nil
+}
+
+// ResizePreviousVersions reduces previous autoscaling groups in order until they have total instances
+func (d *Deployer) ResizePreviousVersions(region string, total int64) error {
+	groups := map[string]*autoscaling.Group{}
+	var current int64
+	for _, asg := range d.PrevAsgs[region] {
+		asgDetail, err := d.DescribeAutoScalingGroup(asg, region)
+		if err != nil {
+			return err
+		}
+		groups[asg] = asgDetail
+		current += *asgDetail.DesiredCapacity
+	}
+
+	reduction := current - total
+	for _, asg := range d.PrevAsgs[region] {
+		if reduction <= 0 {
+			break
+		}
+
+		desired := *groups[asg].DesiredCapacity
+		if desired == 0 {
+			continue
+		}
+
+		cut := reduction
+		if cut > desired {
+			cut = desired
+		}
+		reduction -= cut
+
+		capacity := schemas.Capacity{
+			Min:     *groups[asg].MinSize,
+			Desired: desired - cut,
+			Max:     desired - cut,
+		}
+		if capacity.Min > capacity.Desired {
+			capacity.Min = capacity.Desired
+		}
+
+		d.Logger.Infof("[%s]Previous version: %s, desired capacity: %d", region, asg, capacity.Desired)
+		if err := d.ResizingAutoScalingGroup(asg, region, capacity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// completeRollingUpdateWithSurge moves instances from previous versions to the new version in batches
+// decided by max_surge and max_unavailable, and waits until each batch is healthy in target groups
+func (r *RollingUpdate) completeRollingUpdateWithSurge(config schemas.Config, region schemas.RegionConfig) error {
+	batch, err := r.FirstRollingBatch(config.ForceManifestCapacity, region.Region)
+	if err != nil {
+		return err
+	}
+
+	targetCapacity := r.CompareWithCurrentCapacity(config.ForceManifestCapacity, region.Region)
+	surge, unavailable, err := RollingUpdateLimits(r.Stack, targetCapacity.Desired)
+	if err != nil {
+		return err
+	}
+	r.Logger.Debugf("Rolling update with max surge %d and max unavailable %d: %s", surge, unavailable, r.AsgNames[region.Region])
+
+	for batch.Previous > 0 || batch.Next < targetCapacity.Desired {
+		next := NextRollingBatch(batch, targetCapacity.Desired, surge, unavailable)
+		if next == batch {
+			return fmt.Errorf("rolling update cannot make progress with max_surge %d and max_unavailable %d", surge, unavailable)
+		}
+
+		if next.Previous < batch.Previous {
+			if err := r.ResizePreviousVersions(region.Region, next.Previous); err != nil {
+				return err
+			}
+		}
+
+		appliedCapacity := targetCapacity
+		if next.Next < targetCapacity.Desired {
+			appliedCapacity = schemas.Capacity{
+				Min:     next.Next,
+				Desired: next.Next,
+				Max:     next.Next,
+			}
+		}
+
+		r.Logger.Debugf("Rolling update of autoscaling group: min - %d, desired - %d, max - %d", appliedCapacity.Min, appliedCapacity.Desired, appliedCapacity.Max)
+		if err := r.ResizingAutoScalingGroup(r.AsgNames[region.Region], region.Region, appliedCapacity); err != nil {
+			return err
+		}
+
+		// settings for health checking
+		r.AppliedCapacity = &appliedCapacity
+
+		if err := r.HealthChecking(config); err != nil {
+			return err
+		}
+		batch = next
+	}
+
+	return nil
+}
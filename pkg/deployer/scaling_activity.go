@@ -0,0 +1,88 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// ErrScalingActivityFailed is returned when scaling activities show that new instances will never become healthy
+var ErrScalingActivityFailed = errors.New("scaling activity failed")
+
+// CheckScalingActivities inspects scaling activities of autoscaling group since the deployment started.
+// It fails fast on fatal launch errors or when too many instances are replaced for failed health check.
+func (d *Deployer) CheckScalingActivities(asg string, activities []*autoscaling.Activity, since time.Time) error {
+	var replacements []*autoscaling.Activity
+	for _, activity := range activities {
+		if activity.StartTime != nil && activity.StartTime.Before(since) {
+			continue
+		}
+
+		if isFatalActivity(activity) {
+			return fmt.Errorf("%w in %s: %s", ErrScalingActivityFailed, asg, eaws.StringValue(activity.StatusMessage))
+		}
+
+		if isHealthCheckReplacement(activity) {
+			replacements = append(replacements, activity)
+		}
+	}
+
+	limit := d.Stack.MaxInstanceReplacements
+	if limit == 0 {
+		limit = constants.DefaultMaxInstanceReplacements
+	}
+
+	if int64(len(replacements)) > limit {
+		return fmt.Errorf("%w in %s: %d instances are replaced for failed health check: %s", ErrScalingActivityFailed, asg, len(replacements), eaws.StringValue(replacements[0].Cause))
+	}
+
+	if len(replacements) > 0 {
+		d.Logger.Warnf("Instances replaced for failed health check in %s : %d/%d", asg, len(replacements), limit)
+	}
+
+	return nil
+}
+
+// isFatalActivity checks if launch of instances failed with an error which retries do not fix
+func isFatalActivity(activity *autoscaling.Activity) bool {
+	if eaws.StringValue(activity.StatusCode) != autoscaling.ScalingActivityStatusCodeFailed {
+		return false
+	}
+
+	message := eaws.StringValue(activity.StatusMessage)
+	for _, e := range constants.FatalScalingActivityErrors {
+		if strings.Contains(message, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHealthCheckReplacement checks if the activity terminated an instance for failed health check
+func isHealthCheckReplacement(activity *autoscaling.Activity) bool {
+	return strings.HasPrefix(eaws.StringValue(activity.Description), "Terminating EC2 instance") &&
+		strings.Contains(strings.ToLower(eaws.StringValue(activity.Cause)), "health check")
+}
@@ -0,0 +1,69 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// withoutWarmPoolInstances returns the autoscaling group without instances which are still in the warm pool
+func withoutWarmPoolInstances(asg *autoscaling.Group) *autoscaling.Group {
+	if asg.WarmPoolConfiguration == nil {
+		return asg
+	}
+
+	ret := *asg
+	ret.Instances = nil
+	for _, instance := range asg.Instances {
+		if instance.LifecycleState != nil && strings.HasPrefix(*instance.LifecycleState, constants.WarmedLifecycleStatePrefix) {
+			continue
+		}
+		ret.Instances = append(ret.Instances, instance)
+	}
+
+	return &ret
+}
+
+// DeleteWarmPool deletes the warm pool of autoscaling group before the group itself is deleted
+func (d *Deployer) DeleteWarmPool(client aws.Client, target string) error {
+	asg, err := client.EC2Service.GetMatchingAutoscalingGroup(target)
+	if err != nil {
+		return err
+	}
+
+	if asg == nil || asg.WarmPoolConfiguration == nil {
+		return nil
+	}
+
+	// deletion was already requested in the previous polling
+	if asg.WarmPoolConfiguration.Status != nil && *asg.WarmPoolConfiguration.Status == autoscaling.WarmPoolStatusPendingDelete {
+		return nil
+	}
+
+	d.Logger.Debugf("Start deleting warm pool : %s", target)
+	if err := client.EC2Service.DeleteWarmPool(target); err != nil {
+		return err
+	}
+	d.Logger.Debugf("Warm pool is deleted : %s", target)
+
+	return nil
+}
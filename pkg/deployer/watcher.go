@@ -0,0 +1,205 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+)
+
+// Watcher remembers what has been reported, so that only new scaling activities,
+// instance state changes and target health transitions are reported on every poll
+type Watcher struct {
+	activities map[string]bool
+	instances  map[string]map[string]string
+	targets    map[string]map[string]string
+}
+
+// NewWatcher creates a new watcher
+func NewWatcher() *Watcher {
+	return &Watcher{
+		activities: map[string]bool{},
+		instances:  map[string]map[string]string{},
+		targets:    map[string]map[string]string{},
+	}
+}
+
+// Activities returns events of scaling activities which started after since and have not been reported.
+// Activities in progress are reported again when they finish.
+func (w *Watcher) Activities(asg string, activities []*autoscaling.Activity, since time.Time) []event.Event {
+	var ret []event.Event
+
+	// activities are returned newest first
+	for i := len(activities) - 1; i >= 0; i-- {
+		activity := activities[i]
+		startTime := eaws.TimeValue(activity.StartTime)
+		if startTime.Before(since) {
+			continue
+		}
+
+		status := eaws.StringValue(activity.StatusCode)
+		key := fmt.Sprintf("%s/%s", eaws.StringValue(activity.ActivityId), status)
+		if w.activities[key] {
+			continue
+		}
+		w.activities[key] = true
+
+		eventTime := startTime
+		if activity.EndTime != nil {
+			eventTime = *activity.EndTime
+		}
+
+		message := fmt.Sprintf("activity %s - %s", status, eaws.StringValue(activity.Description))
+		if activity.StatusMessage != nil {
+			message = fmt.Sprintf("%s (%s)", message, *activity.StatusMessage)
+		}
+
+		eventType := event.ScalingActivity
+		if isHealthCheckReplacement(activity) {
+			eventType = event.InstanceReplaced
+		}
+
+		if status == autoscaling.ScalingActivityStatusCodeFailed || strings.Contains(strings.ToLower(eaws.StringValue(activity.Cause)), "health check") {
+			message = fmt.Sprintf("%s, cause: %s", message, eaws.StringValue(activity.Cause))
+		}
+
+		ret = append(ret, event.Event{Type: eventType, Time: eventTime, AutoScalingGroup: asg, Message: message})
+	}
+
+	return ret
+}
+
+// Instances returns events of instances whose lifecycle state or health status has changed, or which left the group
+func (w *Watcher) Instances(group *autoscaling.Group) []event.Event {
+	asg := eaws.StringValue(group.AutoScalingGroupName)
+	now := time.Now()
+
+	prev, ok := w.instances[asg]
+	if !ok {
+		prev = map[string]string{}
+	}
+
+	current := map[string]string{}
+	var ret []event.Event
+	for _, instance := range group.Instances {
+		id := eaws.StringValue(instance.InstanceId)
+		state := fmt.Sprintf("%s/%s", eaws.StringValue(instance.LifecycleState), eaws.StringValue(instance.HealthStatus))
+		current[id] = state
+
+		if before, ok := prev[id]; !ok {
+			ret = append(ret, event.Event{Type: event.InstanceStateChanged, Time: now, AutoScalingGroup: asg, InstanceID: id, Message: fmt.Sprintf("instance %s joined as %s", id, state)})
+		} else if before != state {
+			ret = append(ret, event.Event{Type: event.InstanceStateChanged, Time: now, AutoScalingGroup: asg, InstanceID: id, Message: fmt.Sprintf("instance %s changed %s -> %s", id, before, state)})
+		}
+	}
+
+	for _, id := range sortedKeys(prev) {
+		if _, ok := current[id]; !ok {
+			ret = append(ret, event.Event{Type: event.InstanceStateChanged, Time: now, AutoScalingGroup: asg, InstanceID: id, Message: fmt.Sprintf("instance %s left the group", id)})
+		}
+	}
+	w.instances[asg] = current
+
+	return ret
+}
+
+// Targets returns events of instances whose target health has changed
+func (w *Watcher) Targets(asg string, hosts []aws.HealthcheckHost) []event.Event {
+	now := time.Now()
+
+	prev, ok := w.targets[asg]
+	if !ok {
+		prev = map[string]string{}
+	}
+
+	current := map[string]string{}
+	var ret []event.Event
+	for _, host := range hosts {
+		current[host.InstanceID] = host.TargetStatus
+
+		before, ok := prev[host.InstanceID]
+		if !ok || before != host.TargetStatus {
+			if !ok {
+				before = "-"
+			}
+			ret = append(ret, event.Event{Type: event.TargetHealthChanged, Time: now, AutoScalingGroup: asg, InstanceID: host.InstanceID, Message: fmt.Sprintf("target %s %s -> %s", host.InstanceID, before, host.TargetStatus)})
+		}
+	}
+	w.targets[asg] = current
+
+	return ret
+}
+
+// sortedKeys returns keys of map in order
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// watcher returns the watcher of deployer
+func (d *Deployer) watcher() *Watcher {
+	if d.Watcher == nil {
+		d.Watcher = NewWatcher()
+	}
+	return d.Watcher
+}
+
+// reportWatchEvents writes events to the log and emits them at once
+func (d *Deployer) reportWatchEvents(events []event.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	for _, e := range events {
+		d.Logger.Info(e.Text())
+	}
+
+	d.emit(events...)
+}
+
+// watchDraining reports changes of previous autoscaling groups which are being drained
+func (d *Deployer) watchDraining(client aws.Client, asgs []string, since time.Time) {
+	var events []event.Event
+	for _, name := range asgs {
+		group, err := client.EC2Service.GetMatchingAutoscalingGroup(name)
+		if err != nil || group == nil {
+			continue
+		}
+		events = append(events, d.watcher().Instances(group)...)
+
+		activities, err := client.EC2Service.DescribeScalingActivities(name)
+		if err != nil {
+			d.Logger.Debugf("Failed to describe scaling activities of %s: %s", name, err.Error())
+			continue
+		}
+		events = append(events, d.watcher().Activities(name, activities, since)...)
+	}
+
+	d.reportWatchEvents(events)
+}
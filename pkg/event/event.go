@@ -0,0 +1,168 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+// Package event provides typed deployment events and sinks which receive them.
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Type of deployment event
+type Type string
+
+const (
+	DeploymentStarted  Type = "DeploymentStarted"
+	DeploymentFinished Type = "DeploymentFinished"
+	DeploymentFailed   Type = "DeploymentFailed"
+	StepStarted        Type = "StepStarted"
+	StepFinished       Type = "StepFinished"
+
+	HealthProgress       Type = "HealthProgress"
+	CapacityChanged      Type = "CapacityChanged"
+	InstanceReplaced     Type = "InstanceReplaced"
+	InstanceAbandoned    Type = "InstanceAbandoned"
+	InstanceStateChanged Type = "InstanceStateChanged"
+	TargetHealthChanged  Type = "TargetHealthChanged"
+	ScalingActivity      Type = "ScalingActivity"
+
+	TrafficShifted         Type = "TrafficShifted"
+	CanaryAnalysis         Type = "CanaryAnalysis"
+	BakeStarted            Type = "BakeStarted"
+	BakeFinished           Type = "BakeFinished"
+	BakeFailed             Type = "BakeFailed"
+	ApprovalResolved       Type = "ApprovalResolved"
+	InstanceRefreshStarted Type = "InstanceRefreshStarted"
+	InstanceRefreshEnded   Type = "InstanceRefreshEnded"
+
+	LifecycleCallbacksFinished Type = "LifecycleCallbacksFinished"
+	CleanupStarted             Type = "CleanupStarted"
+	CleanupProgress            Type = "CleanupProgress"
+	CleanupSkipped             Type = "CleanupSkipped"
+	CleanupFinished            Type = "CleanupFinished"
+
+	RollbackStarted  Type = "RollbackStarted"
+	RollbackFinished Type = "RollbackFinished"
+	APITestFinished  Type = "APITestFinished"
+)
+
+// allTypes are every type of deployment event
+var allTypes = []Type{
+	DeploymentStarted, DeploymentFinished, DeploymentFailed, StepStarted, StepFinished,
+	HealthProgress, CapacityChanged, InstanceReplaced, InstanceAbandoned, InstanceStateChanged, TargetHealthChanged, ScalingActivity,
+	TrafficShifted, CanaryAnalysis, BakeStarted, BakeFinished, BakeFailed, ApprovalResolved, InstanceRefreshStarted, InstanceRefreshEnded,
+	LifecycleCallbacksFinished, CleanupStarted, CleanupProgress, CleanupSkipped, CleanupFinished,
+	RollbackStarted, RollbackFinished, APITestFinished,
+}
+
+// isType returns true if t is a type of deployment event
+func isType(t Type) bool {
+	for _, known := range allTypes {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a change in deployment with the context where it happens
+type Event struct {
+	Type             Type                   `json:"type"`
+	Time             time.Time              `json:"time"`
+	Application      string                 `json:"application,omitempty"`
+	Stack            string                 `json:"stack,omitempty"`
+	Region           string                 `json:"region,omitempty"`
+	AutoScalingGroup string                 `json:"autoscaling_group,omitempty"`
+	Step             string                 `json:"step,omitempty"`
+	InstanceID       string                 `json:"instance_id,omitempty"`
+	Healthy          int64                  `json:"healthy,omitempty"`
+	Desired          int64                  `json:"desired,omitempty"`
+	Message          string                 `json:"message,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+	Metrics          []schemas.MetricResult `json:"metrics,omitempty"`
+}
+
+// Text returns human readable message of event. Events without message are not meant to be read by people.
+func (e Event) Text() string {
+	if len(e.Message) == 0 {
+		return ""
+	}
+
+	switch e.Type {
+	case ScalingActivity, InstanceStateChanged, TargetHealthChanged, InstanceReplaced:
+		return fmt.Sprintf("[%s] %s: %s", e.Time.Format(time.RFC3339), e.AutoScalingGroup, e.Message)
+	}
+
+	return e.Message
+}
+
+// Sink receives deployment events
+type Sink interface {
+	Send(events ...Event) error
+}
+
+// Emitter stamps events with time and application, and sends them to every sink
+type Emitter struct {
+	Application string
+	Logger      *logrus.Logger
+	Sinks       []Sink
+}
+
+// NewEmitter creates a new emitter
+func NewEmitter(logger *logrus.Logger, application string, sinks ...Sink) *Emitter {
+	return &Emitter{
+		Application: application,
+		Logger:      logger,
+		Sinks:       sinks,
+	}
+}
+
+// Emit sends events to sinks. Failures of sinks do not stop deployment, so they are only logged
+func (e *Emitter) Emit(events ...Event) {
+	if e == nil || len(events) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for i := range events {
+		if events[i].Time.IsZero() {
+			events[i].Time = now
+		}
+
+		if len(events[i].Application) == 0 {
+			events[i].Application = e.Application
+		}
+	}
+
+	for _, sink := range e.Sinks {
+		if err := sink.Send(events...); err != nil {
+			e.logger().Warnf("failed to send deployment events to %T: %s", sink, err.Error())
+		}
+	}
+}
+
+// logger returns logger of emitter or the standard logger
+func (e *Emitter) logger() *logrus.Logger {
+	if e.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return e.Logger
+}
@@ -0,0 +1,304 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// recordingSink keeps events it received
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) Send(events ...Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestEmitter_Emit(t *testing.T) {
+	sink := &recordingSink{}
+	emitter := NewEmitter(nil, "hello", sink)
+
+	at := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	emitter.Emit(
+		Event{Type: StepStarted, Stack: "artd", Step: "deploy"},
+		Event{Type: ScalingActivity, Time: at, Application: "other", AutoScalingGroup: "hello-artd_apnortheast2-v002", Message: "activity Successful"},
+	)
+
+	if len(sink.events) != 2 {
+		t.Fatalf("two events should be sent: %v", sink.events)
+	}
+	if sink.events[0].Time.IsZero() || sink.events[0].Application != "hello" {
+		t.Errorf("event should be stamped with time and application: %v", sink.events[0])
+	}
+	if diff := deep.Equal(sink.events[1].Time, at); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(sink.events[1].Application, "other"); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(sink.events[0].Text(), ""); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(sink.events[1].Text(), "[2020-10-01T00:00:00Z] hello-artd_apnortheast2-v002: activity Successful"); diff != nil {
+		t.Error(diff)
+	}
+
+	// nil emitter does nothing
+	var e *Emitter
+	e.Emit(Event{Type: DeploymentStarted})
+}
+
+func TestFileSink_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := NewSink(schemas.EventSink{Type: "file", Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Send(Event{Type: DeploymentStarted, Application: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(Event{Type: HealthProgress, Stack: "artd", Healthy: 1, Desired: 2}, Event{Type: DeploymentFinished}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var types []Type
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("each line should be an event: %s", scanner.Text())
+		}
+		types = append(types, e.Type)
+
+		if e.Type == HealthProgress && (e.Healthy != 1 || e.Desired != 2) {
+			t.Errorf("counts are not written: %s", scanner.Text())
+		}
+	}
+
+	if diff := deep.Equal(types, []Type{DeploymentStarted, HealthProgress, DeploymentFinished}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestWebhookSink_Send(t *testing.T) {
+	var received []Event
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, e)
+		if e.Type == DeploymentFailed {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("broken"))
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv("GOPLOYER_TEST_EVENT_TOKEN", "secret")
+	defer os.Unsetenv("GOPLOYER_TEST_EVENT_TOKEN")
+
+	sink, err := NewSink(schemas.EventSink{
+		Type:    "webhook",
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer ${GOPLOYER_TEST_EVENT_TOKEN}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Send(Event{Type: DeploymentStarted}, Event{Type: StepStarted, Step: "deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(len(received), 2); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(received[1].Step, "deploy"); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(token, "Bearer secret"); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := sink.Send(Event{Type: DeploymentFailed}); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failed response should return error: %v", err)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	if _, err := NewSink(schemas.EventSink{Type: "kafka"}); err == nil {
+		t.Error("unknown type of event sink should fail")
+	}
+}
+
+// recordingNotifier keeps messages it sent
+type recordingNotifier struct {
+	messages  []string
+	summaries [][]schemas.Stack
+	metrics   int
+}
+
+func (n *recordingNotifier) SendSimpleMessage(message string) error {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func (n *recordingNotifier) SendSummaryMessage(config schemas.Config, stacks []schemas.Stack, app string) error {
+	n.summaries = append(n.summaries, stacks)
+	return nil
+}
+
+func (n *recordingNotifier) SendAPITestResultMessage(metrics []schemas.MetricResult) error {
+	n.metrics++
+	return nil
+}
+
+func TestNotifierSink(t *testing.T) {
+	events := []Event{
+		{Type: StepStarted, Stack: "artd"},
+		{Type: HealthProgress, Stack: "artd", Message: "Waiting for healthy instances"},
+		{Type: RollbackStarted, Stack: "artd", Message: ":rewind: Rolling back"},
+		{Type: DeploymentFailed, Stack: "hello", Message: ":x: Deployment of hello failed"},
+		{Type: APITestFinished, Stack: "artd", Metrics: []schemas.MetricResult{{URL: "https://example.com"}}},
+		{Type: DeploymentFailed, Message: ":x: Deployment failed"},
+	}
+	stacks := []schemas.Stack{{Stack: "artd"}, {Stack: "hello"}}
+
+	// every event with message except progress of each polling
+	all := &recordingNotifier{}
+	sink, err := NewNotifierSink(all, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(events...); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.SendSummaryMessage(schemas.Config{}, stacks, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(all.messages, []string{":rewind: Rolling back\n:x: Deployment of hello failed\n:x: Deployment failed"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(all.summaries, [][]schemas.Stack{stacks}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(all.metrics, 1); diff != nil {
+		t.Error(diff)
+	}
+
+	// failures of a stack and the whole deployment
+	failures := &recordingNotifier{}
+	sink, err = NewNotifierSink(failures, "artd", []string{"failures"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(events...); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.SendSummaryMessage(schemas.Config{}, stacks, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(failures.messages, []string{":rewind: Rolling back\n:x: Deployment failed"}); diff != nil {
+		t.Error(diff)
+	}
+	if len(failures.summaries) > 0 || failures.metrics > 0 {
+		t.Errorf("summary and API test result should not be sent: %v", failures)
+	}
+
+	// summary of the stack
+	summary := &recordingNotifier{}
+	sink, err = NewNotifierSink(summary, "hello", []string{"DeploymentStarted", "APITestFinished"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(events...); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.SendSummaryMessage(schemas.Config{}, stacks, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(summary.summaries, [][]schemas.Stack{{{Stack: "hello"}}}); diff != nil {
+		t.Error(diff)
+	}
+	if summary.metrics > 0 || len(summary.messages) > 0 {
+		t.Errorf("events of other stacks should not be sent: %v", summary)
+	}
+
+	// progress is sent only when it is given
+	progress := &recordingNotifier{}
+	sink, err = NewNotifierSink(progress, "", []string{"HealthProgress"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(events...); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(progress.messages, []string{"Waiting for healthy instances"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, err := NewNotifierSink(all, "", []string{"Everything"}); err == nil || err.Error() != "event type of notifier is not allowed: Everything" {
+		t.Errorf("unknown event type should fail: %v", err)
+	}
+}
+
+func TestNotifierEvents(t *testing.T) {
+	testData := []struct {
+		config   schemas.Notifier
+		expected []string
+	}{
+		{config: schemas.Notifier{Type: constants.EmailNotifier}, expected: []string{constants.FailureNotifierEvents}},
+		{config: schemas.Notifier{Type: constants.EmailNotifier, Events: []string{"DeploymentFinished"}}, expected: []string{"DeploymentFinished"}},
+		{config: schemas.Notifier{Type: constants.TeamsNotifier}, expected: nil},
+	}
+
+	for _, td := range testData {
+		if diff := deep.Equal(NotifierEvents(td.config), td.expected); diff != nil {
+			t.Errorf("%s: %v", td.config.Type, diff)
+		}
+	}
+}
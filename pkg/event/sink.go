@@ -0,0 +1,232 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/notifier"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// fileLock serializes writes of file sinks, as several emitters can write to the same file
+var fileLock sync.Mutex
+
+// NewSink creates a sink from the configuration in manifest
+func NewSink(config schemas.EventSink) (Sink, error) {
+	switch config.Type {
+	case constants.FileEventSink:
+		return FileSink{Path: config.Path}, nil
+	case constants.WebhookEventSink:
+		return WebhookSink{URL: config.URL, Headers: config.Headers}, nil
+	}
+
+	return nil, fmt.Errorf("type of event sink is not allowed: %s", config.Type)
+}
+
+// failureTypes are types of events notified by the failures filter
+var failureTypes = []Type{DeploymentFailed, BakeFailed, InstanceAbandoned, RollbackStarted, RollbackFinished}
+
+// progressTypes are types of events emitted on every polling, which are not notified unless they are given
+var progressTypes = []Type{HealthProgress, InstanceStateChanged, TargetHealthChanged}
+
+// NotifierSink sends messages of events to a notifier like slack.
+// With a stack, only events of the stack and the whole deployment are sent.
+type NotifierSink struct {
+	Notifier notifier.Notifier
+	Stack    string
+	Types    map[Type]bool
+}
+
+// NotifierEvents returns types of events which the notifier receives.
+// An email is sent for each message, so email notifiers only receive failures unless events are given.
+func NotifierEvents(config schemas.Notifier) []string {
+	if len(config.Events) == 0 && config.Type == constants.EmailNotifier {
+		return []string{constants.FailureNotifierEvents}
+	}
+
+	return config.Events
+}
+
+// NewNotifierSink creates a notifier sink which sends events of the types.
+// Every event except progress of each polling is sent if no type is given.
+func NewNotifierSink(n notifier.Notifier, stack string, types []string) (NotifierSink, error) {
+	sink := NotifierSink{Notifier: n, Stack: stack, Types: map[Type]bool{}}
+	if len(types) == 0 {
+		for _, t := range allTypes {
+			sink.Types[t] = true
+		}
+
+		for _, t := range progressTypes {
+			delete(sink.Types, t)
+		}
+		return sink, nil
+	}
+
+	for _, t := range types {
+		if t == constants.FailureNotifierEvents {
+			for _, ft := range failureTypes {
+				sink.Types[ft] = true
+			}
+			continue
+		}
+
+		if !isType(Type(t)) {
+			return sink, fmt.Errorf("event type of notifier is not allowed: %s", t)
+		}
+		sink.Types[Type(t)] = true
+	}
+
+	return sink, nil
+}
+
+// Accepts returns true if the event should be sent to the notifier
+func (s NotifierSink) Accepts(e Event) bool {
+	if len(s.Stack) > 0 && len(e.Stack) > 0 && e.Stack != s.Stack {
+		return false
+	}
+
+	return s.Types == nil || s.Types[e.Type]
+}
+
+// Send sends messages of events as one message, and the result of API test as its own message
+func (s NotifierSink) Send(events ...Event) error {
+	var lines []string
+	for _, e := range events {
+		if !s.Accepts(e) {
+			continue
+		}
+
+		if len(e.Metrics) > 0 {
+			if err := s.Notifier.SendAPITestResultMessage(e.Metrics); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if text := e.Text(); len(text) > 0 {
+			lines = append(lines, text)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return s.Notifier.SendSimpleMessage(strings.Join(lines, "\n"))
+}
+
+// SendSummaryMessage sends summary of deployment when the notifier accepts start of deployment
+func (s NotifierSink) SendSummaryMessage(config schemas.Config, stacks []schemas.Stack, app string) error {
+	if s.Types != nil && !s.Types[DeploymentStarted] {
+		return nil
+	}
+
+	var targets []schemas.Stack
+	for _, st := range stacks {
+		if len(s.Stack) == 0 || st.Stack == s.Stack {
+			targets = append(targets, st)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	return s.Notifier.SendSummaryMessage(config, targets, app)
+}
+
+// FileSink appends events to a file as NDJSON
+type FileSink struct {
+	Path string
+}
+
+// Send writes each event as a line of JSON
+func (s FileSink) Send(events ...Event) error {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+
+	var w io.Writer = os.Stdout
+	if s.Path != constants.StdoutEventSinkPath {
+		f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, e := range events {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WebhookSink posts each event as JSON
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+}
+
+// Send posts events one by one
+func (s WebhookSink) Send(events ...Event) error {
+	client := http.Client{Timeout: 10 * time.Second}
+	for _, e := range events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewBuffer(body))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Add("Content-Type", "application/json")
+		for key, value := range s.Headers {
+			req.Header.Set(key, os.ExpandEnv(value))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, tool.Excerpt(string(respBody), constants.MaxCommandOutputLength))
+		}
+	}
+
+	return nil
+}
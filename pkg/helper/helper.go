@@ -19,10 +19,11 @@ package helper
 import (
 	"github.com/sirupsen/logrus"
 
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/collector"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
-	"github.com/DevopsArtFactory/goployer/pkg/slack"
 )
 
 // DeployerHelper is a struct for passing parameters when creating new deployer
@@ -32,8 +33,9 @@ type DeployerHelper struct {
 	AwsConfig        schemas.AWSConfig
 	APITestTemplates *schemas.APITestTemplate
 	Region           string
-	Slack            slack.Slack
+	Events           *event.Emitter
 	Collector        collector.Collector
+	ClientFactory    aws.ClientFactory
 }
 
 // InitStartStatus set start status for deployment
@@ -0,0 +1,176 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// Context is the deployment context passed to hooks
+type Context struct {
+	Step             string `json:"step"`
+	Application      string `json:"application"`
+	Stack            string `json:"stack"`
+	Region           string `json:"region"`
+	AutoScalingGroup string `json:"autoscaling_group,omitempty"`
+	Version          int    `json:"version"`
+	AMI              string `json:"ami,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Env returns the context as environment variables like GOPLOYER_STACK
+func (c Context) Env() []string {
+	values := map[string]string{
+		"STEP":              c.Step,
+		"APPLICATION":       c.Application,
+		"STACK":             c.Stack,
+		"REGION":            c.Region,
+		"AUTOSCALING_GROUP": c.AutoScalingGroup,
+		"VERSION":           fmt.Sprintf("%d", c.Version),
+		"AMI":               c.AMI,
+		"ERROR":             c.Error,
+	}
+
+	var ret []string
+	for _, key := range []string{"STEP", "APPLICATION", "STACK", "REGION", "AUTOSCALING_GROUP", "VERSION", "AMI", "ERROR"} {
+		ret = append(ret, fmt.Sprintf("%s%s=%s", constants.HookEnvPrefix, key, values[key]))
+	}
+
+	return ret
+}
+
+// Hooks returns hooks of the step
+func Hooks(hooks *schemas.Hooks, step string) []schemas.Hook {
+	if hooks == nil {
+		return nil
+	}
+
+	switch step {
+	case constants.BeforeDeployHook:
+		return hooks.BeforeDeploy
+	case constants.AfterHealthCheckHook:
+		return hooks.AfterHealthCheck
+	case constants.BeforeCleanupHook:
+		return hooks.BeforeCleanup
+	case constants.AfterDeployHook:
+		return hooks.AfterDeploy
+	case constants.OnFailureHook:
+		return hooks.OnFailure
+	}
+
+	return nil
+}
+
+// Run runs a local command or sends an HTTP request of hook.
+// It returns an error when the command exits with non-zero code or the response status is not 2xx.
+func Run(h schemas.Hook, c Context) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = constants.DefaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if h.HTTP != nil {
+		return sendRequest(ctx, *h.HTTP, c)
+	}
+
+	return runCommand(ctx, h.Command, c)
+}
+
+// Name returns the name of hook, or the command or URL when it is not specified
+func Name(h schemas.Hook) string {
+	if len(h.Name) > 0 {
+		return h.Name
+	}
+
+	if h.HTTP != nil {
+		return h.HTTP.URL
+	}
+
+	return h.Command
+}
+
+// runCommand runs the command with shell and the context in environment variables
+func runCommand(ctx context.Context, command string, c Context) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), c.Env()...)
+
+	// child processes of the shell may keep output open after the shell is killed
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("command timed out: %s", command)
+	}
+
+	if err != nil {
+		return fmt.Errorf("command failed with %s: %s", err.Error(), tool.Excerpt(string(output), constants.MaxCommandOutputLength))
+	}
+
+	return nil
+}
+
+// sendRequest sends the context as JSON body of the request
+func sendRequest(ctx context.Context, h schemas.HTTPHook, c Context) error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	method := strings.ToUpper(h.Method)
+	if len(method) == 0 {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	for key, value := range h.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, tool.Excerpt(string(respBody), constants.MaxCommandOutputLength))
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package hook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+var testContext = Context{
+	Step:             constants.AfterHealthCheckHook,
+	Application:      "hello",
+	Stack:            "artd",
+	Region:           "ap-northeast-2",
+	AutoScalingGroup: "hello-artd_apnortheast2-v002",
+	Version:          2,
+	AMI:              "ami-0000000000000001",
+}
+
+func TestRun_Command(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output")
+
+	h := schemas.Hook{Command: "echo $GOPLOYER_STEP $GOPLOYER_AUTOSCALING_GROUP $GOPLOYER_VERSION > " + output}
+	if err := Run(h, testContext); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(strings.TrimSpace(string(b)), "after_health_check hello-artd_apnortheast2-v002 2"); diff != nil {
+		t.Error(diff)
+	}
+
+	h = schemas.Hook{Command: "echo not ready && exit 3"}
+	if err := Run(h, testContext); err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Errorf("non-zero exit should fail with output: %v", err)
+	}
+
+	h = schemas.Hook{Command: "sleep 5", Timeout: 50 * time.Millisecond}
+	if err := Run(h, testContext); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("command should time out: %v", err)
+	}
+}
+
+func TestRun_HTTP(t *testing.T) {
+	var received Context
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("deployment freeze"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	t.Setenv("HOOK_TOKEN", "secret")
+	h := schemas.Hook{HTTP: &schemas.HTTPHook{URL: server.URL + "/deploy", Headers: map[string]string{"Authorization": "Bearer ${HOOK_TOKEN}"}}}
+	if err := Run(h, testContext); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(received, testContext); diff != nil {
+		t.Error(diff)
+	}
+
+	if token != "Bearer secret" {
+		t.Errorf("environment variables in headers should be expanded: %s", token)
+	}
+
+	h.HTTP.URL = server.URL + "/fail"
+	if err := Run(h, testContext); err == nil || !strings.Contains(err.Error(), "409") || !strings.Contains(err.Error(), "deployment freeze") {
+		t.Errorf("non-2xx response should fail with body: %v", err)
+	}
+}
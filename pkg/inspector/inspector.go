@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	eaws "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
 
@@ -49,6 +50,21 @@ type StatusSummary struct {
 	Tags         []string
 	IngressRules []SecurityGroup
 	EgressRules  []SecurityGroup
+	WarmPool     *WarmPoolSummary
+}
+
+type WarmPoolSummary struct {
+	MinSize             int64
+	MaxPreparedCapacity int64
+	PoolState           string
+	Status              string
+	Instances           []WarmPoolInstance
+}
+
+type WarmPoolInstance struct {
+	ID             string
+	LifecycleState string
+	HealthStatus   string
 }
 
 type SecurityGroup struct {
@@ -68,8 +84,13 @@ type UpdateFields struct {
 
 // New creates new Inspector
 func New(region string) Inspector {
+	return NewWithClient(aws.BootstrapServices(region, constants.EmptyString))
+}
+
+// NewWithClient creates new Inspector with the given aws client
+func NewWithClient(client aws.Client) Inspector {
 	return Inspector{
-		AWSClient: aws.BootstrapServices(region, constants.EmptyString),
+		AWSClient: client,
 	}
 }
 
@@ -126,6 +147,11 @@ func (i Inspector) GetStackInformation(asgName string) (*autoscaling.Group, erro
 	return asg, nil
 }
 
+// GetWarmPoolInformation retrieves the warm pool of autoscaling group
+func (i Inspector) GetWarmPoolInformation(asgName string) (*autoscaling.WarmPoolConfiguration, []*autoscaling.Instance, error) {
+	return i.AWSClient.EC2Service.DescribeWarmPool(asgName)
+}
+
 // GetLaunchTemplateInformation retrieves single launch template information
 func (i Inspector) GetLaunchTemplateInformation(ltID string) (*ec2.LaunchTemplateVersion, error) {
 	lt, err := i.AWSClient.EC2Service.GetMatchingLaunchTemplate(ltID)
@@ -268,6 +294,30 @@ func (i Inspector) SetStatusSummary(asg *autoscaling.Group, sgs []*ec2.SecurityG
 	return summary
 }
 
+// SetWarmPoolSummary creates summary of warm pool. It returns nil if there is no warm pool.
+func (i Inspector) SetWarmPoolSummary(config *autoscaling.WarmPoolConfiguration, instances []*autoscaling.Instance) *WarmPoolSummary {
+	if config == nil {
+		return nil
+	}
+
+	summary := &WarmPoolSummary{
+		MinSize:             eaws.Int64Value(config.MinSize),
+		MaxPreparedCapacity: eaws.Int64Value(config.MaxGroupPreparedCapacity),
+		PoolState:           eaws.StringValue(config.PoolState),
+		Status:              eaws.StringValue(config.Status),
+	}
+
+	for _, instance := range instances {
+		summary.Instances = append(summary.Instances, WarmPoolInstance{
+			ID:             eaws.StringValue(instance.InstanceId),
+			LifecycleState: eaws.StringValue(instance.LifecycleState),
+			HealthStatus:   eaws.StringValue(instance.HealthStatus),
+		})
+	}
+
+	return summary
+}
+
 // Print prints the current status of deployment
 func (i Inspector) Print() error {
 	var data = struct {
@@ -301,8 +351,9 @@ func (i Inspector) Update() error {
 // GenerateStack generates stack configuration for update
 func (i Inspector) GenerateStack(region string, group *autoscaling.Group) schemas.Stack {
 	s := schemas.Stack{
-		Stack:    "update-stack",
-		Capacity: i.UpdateFields.Capacity,
+		Stack:           "update-stack",
+		ReplacementType: constants.BlueGreenDeployment,
+		Capacity:        i.UpdateFields.Capacity,
 		Regions: []schemas.RegionConfig{
 			{
 				Region: region,
@@ -0,0 +1,410 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// ErrLocked is returned when another deployment holds the lock
+var ErrLocked = errors.New("deployment is locked")
+
+// Lock is a lease of deployment for autoscaling groups with the same prefix
+type Lock struct {
+	Key        string    `json:"key"`
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Store keeps locks of deployments
+type Store interface {
+	// Acquire takes the lock or renews it if the holder already has it
+	Acquire(l Lock) error
+
+	// Release removes the lock of the holder, or the lock of anyone if holder is empty
+	Release(key, holder string) error
+
+	// Get returns the current lock or nil if nobody holds it
+	Get(key string) (*Lock, error)
+}
+
+// FileStore keeps locks as JSON files in a directory of the host
+type FileStore struct {
+	Dir string
+}
+
+// DynamoDBStore keeps locks in the metric table with conditional writes
+type DynamoDBStore struct {
+	Client aws.DynamoDBAPI
+	Table  string
+}
+
+// NewStore returns the metric table store if metrics are stored in DynamoDB, otherwise the local file store
+func NewStore(mc schemas.MetricConfig, client aws.MetricClient) Store {
+	if mc.Enabled && client.DynamoDBService != nil && (len(mc.Storage.Type) == 0 || mc.Storage.Type == constants.DefaultMetricStorageType) {
+		return DynamoDBStore{
+			Client: client.DynamoDBService,
+			Table:  mc.Storage.Name,
+		}
+	}
+
+	return FileStore{Dir: filepath.Join(os.TempDir(), constants.DefaultLockDirectory)}
+}
+
+// NewHolder returns an identifier of this process for the deployment
+func NewHolder(deploymentID string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	user := os.Getenv("USER")
+	if len(user) == 0 {
+		user = os.Getenv("USERNAME")
+	}
+
+	return fmt.Sprintf("%s@%s(pid %d) %s", user, host, os.Getpid(), deploymentID)
+}
+
+// Expired checks whether the lock is not renewed until the time
+func (l Lock) Expired(now time.Time) bool {
+	return now.After(l.ExpiresAt)
+}
+
+// lockedError returns an error with the current holder of lock
+func lockedError(l Lock) error {
+	return fmt.Errorf("%w: %s is held by %s since %s until %s. run `goployer unlock %s --force` if the holder is not running",
+		ErrLocked, l.Key, l.Holder, l.AcquiredAt.Format(time.RFC3339), l.ExpiresAt.Format(time.RFC3339), l.Key)
+}
+
+// Acquire takes the lock if it does not exist or is expired
+func (f FileStore) Acquire(l Lock) error {
+	return f.withMutex(l.Key, func() error {
+		current, err := f.read(l.Key)
+		if err != nil {
+			return err
+		}
+
+		if current != nil && current.Holder != l.Holder && !current.Expired(time.Now()) {
+			return lockedError(*current)
+		}
+
+		data, err := json.MarshalIndent(l, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		tmp := f.path(l.Key) + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+
+		return os.Rename(tmp, f.path(l.Key))
+	})
+}
+
+// Release removes the lock file
+func (f FileStore) Release(key, holder string) error {
+	return f.withMutex(key, func() error {
+		current, err := f.read(key)
+		if err != nil || current == nil {
+			return err
+		}
+
+		if len(holder) > 0 && current.Holder != holder {
+			return lockedError(*current)
+		}
+
+		return os.Remove(f.path(key))
+	})
+}
+
+// Get reads the lock file
+func (f FileStore) Get(key string) (*Lock, error) {
+	return f.read(key)
+}
+
+// read returns the lock in the file or nil if the file does not exist
+func (f FileStore) read(key string) (*Lock, error) {
+	data, err := os.ReadFile(f.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var l Lock
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// withMutex runs fn while no other process changes the lock file
+func (f FileStore) withMutex(key string, fn func() error) error {
+	if err := os.MkdirAll(f.Dir, 0755); err != nil {
+		return err
+	}
+
+	mutex := f.path(key) + ".mutex"
+	deadline := time.Now().Add(constants.LockMutexTimeout)
+	for {
+		file, err := os.OpenFile(mutex, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			break
+		}
+
+		if !os.IsExist(err) {
+			return err
+		}
+
+		// the process which created the mutex might be killed
+		if info, err := os.Stat(mutex); err == nil && time.Since(info.ModTime()) > constants.LockMutexTimeout {
+			os.Remove(mutex)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cannot get the mutex of lock file: %s", mutex)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer os.Remove(mutex)
+
+	return fn()
+}
+
+// path returns the file path of lock
+func (f FileStore) path(key string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%s.json", key))
+}
+
+// Acquire puts the lock item unless another holder keeps it
+func (d DynamoDBStore) Acquire(l Lock) error {
+	err := d.Client.PutLock(d.Table, constants.LockKeyPrefix+l.Key, l.Holder, l.AcquiredAt, l.ExpiresAt)
+	if !isConditionalCheckFailed(err) {
+		return err
+	}
+
+	current, err := d.Get(l.Key)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return fmt.Errorf("%w: %s", ErrLocked, l.Key)
+	}
+
+	return lockedError(*current)
+}
+
+// Release deletes the lock item
+func (d DynamoDBStore) Release(key, holder string) error {
+	err := d.Client.DeleteLock(d.Table, constants.LockKeyPrefix+key, holder)
+	if !isConditionalCheckFailed(err) {
+		return err
+	}
+
+	current, err := d.Get(key)
+	if err != nil || current == nil {
+		return err
+	}
+
+	return lockedError(*current)
+}
+
+// Get retrieves the lock item
+func (d DynamoDBStore) Get(key string) (*Lock, error) {
+	item, err := d.Client.GetSingleItem(constants.LockKeyPrefix+key, d.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil || item[constants.LockHolderKey] == nil || item[constants.LockHolderKey].S == nil {
+		return nil, nil
+	}
+
+	l := Lock{
+		Key:    key,
+		Holder: *item[constants.LockHolderKey].S,
+	}
+
+	if v := item[constants.LockAcquiredAtKey]; v != nil && v.S != nil {
+		l.AcquiredAt, _ = time.Parse(time.RFC3339, *v.S)
+	}
+
+	if v := item[constants.LockExpiresAtKey]; v != nil && v.N != nil {
+		expires, _ := strconv.ParseInt(*v.N, 10, 64)
+		l.ExpiresAt = time.Unix(expires, 0)
+	}
+
+	return &l, nil
+}
+
+// isConditionalCheckFailed checks whether the conditional write is rejected
+func isConditionalCheckFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// Lease keeps locks of a deployment and renews them until it is released
+type Lease struct {
+	store  Store
+	locks  []Lock
+	ttl    time.Duration
+	stop   chan struct{}
+	lost   chan struct{}
+	err    error
+	wg     sync.WaitGroup
+	logger *Logger.Logger
+}
+
+// Acquire takes locks of all keys and starts renewing them.
+// If any key is locked by another holder, locks which are already taken are released.
+func Acquire(store Store, keys []string, holder string, ttl time.Duration, logger *Logger.Logger) (*Lease, error) {
+	lease := &Lease{
+		store:  store,
+		ttl:    ttl,
+		stop:   make(chan struct{}),
+		lost:   make(chan struct{}),
+		logger: logger,
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		l := Lock{
+			Key:        key,
+			Holder:     holder,
+			AcquiredAt: now,
+			ExpiresAt:  now.Add(ttl),
+		}
+
+		if err := store.Acquire(l); err != nil {
+			lease.release()
+			return nil, err
+		}
+		lease.locks = append(lease.locks, l)
+		logger.Debugf("Lock is acquired: %s", key)
+	}
+
+	lease.wg.Add(1)
+	go lease.renew()
+
+	return lease, nil
+}
+
+// renew extends locks periodically before they expire
+func (l *Lease) renew() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			for i := range l.locks {
+				renewed := l.locks[i]
+				renewed.ExpiresAt = now.Add(l.ttl)
+
+				err := l.store.Acquire(renewed)
+				if err == nil {
+					l.locks[i] = renewed
+					continue
+				}
+				l.logger.Errorf("cannot renew the lock of deployment: %s", err.Error())
+
+				// another deployment can take the lock once it expires
+				if errors.Is(err, ErrLocked) || l.locks[i].Expired(now) {
+					l.err = fmt.Errorf("lock of deployment is lost: %w", err)
+					close(l.lost)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Lost returns a channel which is closed when the lease cannot be renewed anymore
+func (l *Lease) Lost() <-chan struct{} {
+	if l == nil {
+		return nil
+	}
+
+	return l.lost
+}
+
+// Err returns the reason why the lease is lost, or nil if locks are still held
+func (l *Lease) Err() error {
+	if l == nil {
+		return nil
+	}
+
+	select {
+	case <-l.lost:
+		return l.err
+	default:
+		return nil
+	}
+}
+
+// Release stops renewal and releases all locks
+func (l *Lease) Release() error {
+	if l == nil {
+		return nil
+	}
+
+	close(l.stop)
+	l.wg.Wait()
+
+	return l.release()
+}
+
+// release removes locks which are taken
+func (l *Lease) release() error {
+	var errs []error
+	for _, lock := range l.locks {
+		if err := l.store.Release(lock.Key, lock.Holder); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		l.logger.Debugf("Lock is released: %s", lock.Key)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package lock
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+)
+
+func TestStore_AcquireAndRelease(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	if err := backend.MetricClient().DynamoDBService.CreateTable("goployer-metrics"); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]Store{
+		"file":     FileStore{Dir: t.TempDir()},
+		"dynamodb": DynamoDBStore{Client: backend.MetricClient().DynamoDBService, Table: "goployer-metrics"},
+	}
+
+	now := time.Now().Truncate(time.Second)
+	first := Lock{Key: "hello-dev_apnortheast2", Holder: "ci-1", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}
+	second := Lock{Key: "hello-dev_apnortheast2", Holder: "ci-2", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}
+
+	for name, store := range stores {
+		if current, err := store.Get(first.Key); err != nil || current != nil {
+			t.Fatalf("%s: expected no lock, got %v, %v", name, current, err)
+		}
+
+		if err := store.Acquire(first); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		current, err := store.Get(first.Key)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if diff := deep.Equal(current.Holder, first.Holder); diff != nil {
+			t.Errorf("%s: %v", name, diff)
+		}
+
+		err = store.Acquire(second)
+		if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), "ci-1") {
+			t.Errorf("%s: expected locked error naming the holder, got %v", name, err)
+		}
+
+		// the holder can renew its own lock
+		renewed := first
+		renewed.ExpiresAt = now.Add(2 * time.Minute)
+		if err := store.Acquire(renewed); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+
+		if err := store.Release(first.Key, second.Holder); !errors.Is(err, ErrLocked) {
+			t.Errorf("%s: expected locked error, got %v", name, err)
+		}
+
+		if err := store.Release(first.Key, first.Holder); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// an expired lock can be taken by another holder
+		expired := first
+		expired.ExpiresAt = now.Add(-time.Minute)
+		if err := store.Acquire(expired); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := store.Acquire(second); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+
+		// force release
+		if err := store.Release(second.Key, ""); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if current, err := store.Get(second.Key); err != nil || current != nil {
+			t.Errorf("%s: expected no lock, got %v, %v", name, current, err)
+		}
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	store := FileStore{Dir: t.TempDir()}
+	logger := Logger.New()
+
+	now := time.Now()
+	if err := store.Acquire(Lock{Key: "hello-dev_useast1", Holder: "ci-1", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// locks which are already taken are released when another key is locked
+	if _, err := Acquire(store, []string{"hello-dev_apnortheast2", "hello-dev_useast1"}, "ci-2", time.Minute, logger); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected locked error, got %v", err)
+	}
+	if current, _ := store.Get("hello-dev_apnortheast2"); current != nil {
+		t.Errorf("expected the lock to be released, got %v", current)
+	}
+
+	lease, err := Acquire(store, []string{"hello-dev_apnortheast2"}, "ci-2", 30*time.Millisecond, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, _ := store.Get("hello-dev_apnortheast2")
+	time.Sleep(50 * time.Millisecond)
+	after, _ := store.Get("hello-dev_apnortheast2")
+	if !after.ExpiresAt.After(before.ExpiresAt) {
+		t.Errorf("expected the lock to be renewed, expires at %s and %s", before.ExpiresAt, after.ExpiresAt)
+	}
+
+	if err := lease.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := store.Get("hello-dev_apnortheast2"); current != nil {
+		t.Errorf("expected the lock to be released, got %v", current)
+	}
+}
+
+func TestLease_Lost(t *testing.T) {
+	store := FileStore{Dir: t.TempDir()}
+	logger := Logger.New()
+
+	lease, err := Acquire(store, []string{"hello-dev_apnortheast2"}, "ci-1", 30*time.Millisecond, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Release()
+
+	if err := lease.Err(); err != nil {
+		t.Fatalf("lease should be held: %v", err)
+	}
+
+	// another deployment takes the lock after it is released by force
+	if err := store.Release("hello-dev_apnortheast2", ""); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := store.Acquire(Lock{Key: "hello-dev_apnortheast2", Holder: "ci-2", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-lease.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lease should be lost")
+	}
+
+	if err := lease.Err(); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected locked error, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"encoding/json"
+)
+
+// Limits of discord embeds
+const (
+	discordMaxEmbeds      = 10
+	discordMaxFields      = 25
+	discordMaxFieldName   = 256
+	discordMaxFieldValue  = 1024
+	discordMaxDescription = 4096
+	discordColor          = 0x2eb67d
+)
+
+// Discord sends messages as embeds to webhook of Discord
+type Discord struct {
+	URL string
+}
+
+type discordBody struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// Send posts message as embeds. Sections with many fields are split, and
+// embeds over the limit of discord are posted in following requests
+func (d Discord) Send(m Message) error {
+	embeds := []discordEmbed{{
+		Title:       m.Title,
+		Description: truncate(m.Text, discordMaxDescription),
+		Color:       discordColor,
+	}}
+
+	for _, section := range m.Sections {
+		embed := discordEmbed{Title: section.Title, Color: discordColor}
+		for _, f := range section.Fields {
+			if len(embed.Fields) == discordMaxFields {
+				embeds = append(embeds, embed)
+				embed = discordEmbed{Title: section.Title, Color: discordColor}
+			}
+			embed.Fields = append(embed.Fields, discordField{
+				Name:   truncate(discordValue(f.Title), discordMaxFieldName),
+				Value:  truncate(discordValue(f.Value), discordMaxFieldValue),
+				Inline: true,
+			})
+		}
+		embeds = append(embeds, embed)
+	}
+
+	// the first embed is empty when message only has sections
+	if len(embeds[0].Title) == 0 && len(embeds[0].Description) == 0 {
+		embeds = embeds[1:]
+	}
+
+	for len(embeds) > 0 {
+		n := discordMaxEmbeds
+		if len(embeds) < n {
+			n = len(embeds)
+		}
+
+		body, err := json.Marshal(discordBody{Embeds: embeds[:n]})
+		if err != nil {
+			return err
+		}
+
+		if err := post(d.URL, nil, body); err != nil {
+			return err
+		}
+		embeds = embeds[n:]
+	}
+
+	return nil
+}
+
+// discordValue returns a placeholder for empty value, which discord does not accept
+func discordValue(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
+
+// truncate cuts text to the length of characters, keeping the beginning
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-3]) + "..."
+}
@@ -0,0 +1,112 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// maxSubjectLength is the length of subject taken from text of message without title
+const maxSubjectLength = 80
+
+// Email sends messages as plain text email through SMTP server
+type Email struct {
+	Address  string
+	Host     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// NewEmail creates an email notifier
+func NewEmail(config schemas.SMTP) Email {
+	port := config.Port
+	if port == 0 {
+		port = constants.DefaultSMTPPort
+	}
+
+	return Email{
+		Address:  net.JoinHostPort(config.Host, strconv.FormatInt(port, 10)),
+		Host:     config.Host,
+		Username: config.Username,
+		Password: os.ExpandEnv(config.Password),
+		From:     config.From,
+		To:       config.To,
+	}
+}
+
+// Send sends message as an email
+func (e Email) Send(m Message) error {
+	var auth smtp.Auth
+	if len(e.Username) > 0 {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+
+	return smtp.SendMail(e.Address, auth, e.From, e.To, e.render(m))
+}
+
+// render returns message in the format of email with headers
+func (e Email) render(m Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject(m))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+
+	var lines []string
+	if len(m.Title) > 0 {
+		lines = append(lines, m.Title, "")
+	}
+
+	if len(m.Text) > 0 {
+		lines = append(lines, m.Text, "")
+	}
+
+	for _, section := range m.Sections {
+		lines = append(lines, section.Title)
+		for _, f := range section.Fields {
+			lines = append(lines, fmt.Sprintf("  %s: %s", f.Title, strings.ReplaceAll(f.Value, "\n", "\n    ")))
+		}
+		lines = append(lines, "")
+	}
+
+	b.WriteString(strings.ReplaceAll(strings.Join(lines, "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// subject returns title of message, or the first line of text
+func subject(m Message) string {
+	s := m.Title
+	if len(s) == 0 {
+		s = strings.SplitN(strings.TrimSpace(m.Text), "\n", 2)[0]
+	}
+	return mime.QEncoding.Encode("utf-8", "[goployer] "+truncate(s, maxSubjectLength))
+}
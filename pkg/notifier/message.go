@@ -0,0 +1,164 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// SummaryMessage creates the message of configurations applied to the deployment,
+// with the same content as the summary sent to slack
+func SummaryMessage(config schemas.Config, stacks []schemas.Stack, app string) Message {
+	var fields []Field
+	for _, d := range builder.ExtractAppliedConfig(config) {
+		fields = append(fields, Field{Title: d[0], Value: d[1]})
+	}
+
+	m := Message{
+		Title: fmt.Sprintf("[ %s ] Deployment has been started", app),
+		Sections: []Section{
+			{Title: "These are configurations that are applied to this deployment.", Fields: fields},
+		},
+	}
+
+	for _, st := range stacks {
+		m.Sections = append(m.Sections, Section{
+			Title:  fmt.Sprintf("Stack configurations: %s", st.Stack),
+			Fields: stackFields(config, st),
+		})
+	}
+
+	return m
+}
+
+// APITestResultMessage creates the message of API test result
+func APITestResultMessage(metrics []schemas.MetricResult) Message {
+	m := Message{Title: "API test result"}
+	for _, metric := range metrics {
+		m.Sections = append(m.Sections, Section{
+			Title: metric.URL,
+			Fields: []Field{
+				{Title: "Duration", Value: tool.RoundTime(metric.Data.Duration)},
+				{Title: "Wait", Value: tool.RoundTime(metric.Data.Wait)},
+				{Title: "Requests", Value: fmt.Sprintf("%d", metric.Data.Requests)},
+				{Title: "Rate", Value: tool.RoundNum(metric.Data.Rate)},
+				{Title: "Throughput", Value: tool.RoundNum(metric.Data.Throughput)},
+				{Title: "Success", Value: tool.RoundNum(metric.Data.Success)},
+				{Title: "Latency P99", Value: tool.RoundTime(metric.Data.Latencies.P99)},
+			},
+		})
+	}
+
+	return m
+}
+
+// stackFields returns configurations of stack which are set
+func stackFields(config schemas.Config, st schemas.Stack) []Field {
+	var fields []Field
+	val := reflect.ValueOf(&st).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		typeField := val.Type().Field(i)
+		key := yamlKey(typeField)
+		t := val.Field(i)
+
+		switch t.Kind() {
+		case reflect.String:
+			if len(t.String()) > 0 && typeField.Name != "Stack" {
+				fields = append(fields, Field{Title: key, Value: t.String()})
+			}
+		case reflect.Int, reflect.Int64:
+			if t.Int() > 0 {
+				value := fmt.Sprintf("%d", t.Int())
+				if tool.IsStringInArray(key, []string{"polling-interval", "timeout"}) {
+					value = fmt.Sprintf("%.0fs", time.Duration(t.Int()).Seconds())
+				}
+				fields = append(fields, Field{Title: key, Value: value})
+			}
+		case reflect.Bool:
+			fields = append(fields, Field{Title: key, Value: fmt.Sprintf("%t", t.Bool())})
+		default:
+			switch key {
+			case "capacity":
+				fields = append(fields, Field{Title: key, Value: fmt.Sprintf("min: %d, desired: %d, max: %d", st.Capacity.Min, st.Capacity.Desired, st.Capacity.Max)})
+			case "tags":
+				fields = append(fields, Field{Title: key, Value: strings.Join(st.Tags, ",")})
+			case "block-devices":
+				if len(st.BlockDevices) > 0 {
+					var lines []string
+					for _, bd := range st.BlockDevices {
+						lines = append(lines, fmt.Sprintf("%s | %s | %d | %d", bd.DeviceName, bd.VolumeType, bd.VolumeSize, bd.Iops))
+					}
+					fields = append(fields, Field{Title: fmt.Sprintf("%s(name|type|size|iops)", key), Value: strings.Join(lines, "\n")})
+				}
+			case "regions":
+				for _, region := range st.Regions {
+					if len(config.Region) == 0 || config.Region == region.Region {
+						fields = append(fields, regionFields(region)...)
+					}
+				}
+			}
+		}
+	}
+
+	return fields
+}
+
+// regionFields returns configurations of region which are set
+func regionFields(region schemas.RegionConfig) []Field {
+	var fields []Field
+	rv := reflect.ValueOf(&region).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		title := fmt.Sprintf("[%s] %s", region.Region, yamlKey(rv.Type().Field(i)))
+		rt := rv.Field(i)
+
+		switch rt.Kind() {
+		case reflect.String:
+			if len(rt.String()) > 0 && rv.Type().Field(i).Name != "Region" {
+				fields = append(fields, Field{Title: title, Value: rt.String()})
+			}
+		case reflect.Int, reflect.Int64:
+			if rt.Int() > 0 {
+				fields = append(fields, Field{Title: title, Value: fmt.Sprintf("%d", rt.Int())})
+			}
+		case reflect.Bool:
+			fields = append(fields, Field{Title: title, Value: fmt.Sprintf("%t", rt.Bool())})
+		case reflect.Slice:
+			if rt.Len() > 0 && rt.Index(0).Kind() == reflect.String {
+				var values []string
+				for j := 0; j < rt.Len(); j++ {
+					values = append(values, rt.Index(j).String())
+				}
+				fields = append(fields, Field{Title: title, Value: strings.Join(values, "\n")})
+			}
+		}
+	}
+
+	return fields
+}
+
+// yamlKey returns the key of field in manifest as it is shown in slack summary
+func yamlKey(field reflect.StructField) string {
+	key := strings.ReplaceAll(field.Tag.Get("yaml"), "_", "-")
+	return strings.ReplaceAll(key, ",omitempty", "")
+}
@@ -0,0 +1,163 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+// Package notifier sends deployment messages to channels other than slack.
+// Each notifier renders the same message in its own format.
+package notifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// Notifier sends messages of deployment. Slack client is also a notifier.
+type Notifier interface {
+	SendSimpleMessage(message string) error
+	SendSummaryMessage(config schemas.Config, stacks []schemas.Stack, app string) error
+	SendAPITestResultMessage(metrics []schemas.MetricResult) error
+}
+
+// Sender delivers a message in the format of its channel
+type Sender interface {
+	Send(m Message) error
+}
+
+// Message is the content of notification regardless of channel
+type Message struct {
+	Title    string    `json:"title,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Sections []Section `json:"sections,omitempty"`
+}
+
+// Section is a group of fields in message
+type Section struct {
+	Title  string  `json:"title"`
+	Fields []Field `json:"fields"`
+}
+
+// Field is a pair of title and value
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// New creates a notifier from the configuration in manifest
+func New(config schemas.Notifier) (Notifier, error) {
+	var sender Sender
+	switch config.Type {
+	case constants.TeamsNotifier:
+		sender = Teams{URL: os.ExpandEnv(config.URL)}
+	case constants.DiscordNotifier:
+		sender = Discord{URL: os.ExpandEnv(config.URL)}
+	case constants.WebhookNotifier:
+		webhook, err := NewWebhook(os.ExpandEnv(config.URL), config.Headers, config.Template)
+		if err != nil {
+			return nil, err
+		}
+		sender = webhook
+	case constants.EmailNotifier:
+		if config.SMTP == nil {
+			return nil, errors.New("smtp is required for email notifier")
+		}
+		sender = NewEmail(*config.SMTP)
+	default:
+		return nil, fmt.Errorf("type of notifier is not allowed: %s", config.Type)
+	}
+
+	return FromSender(sender), nil
+}
+
+// FromSender creates a notifier which renders messages for the sender
+func FromSender(sender Sender) Notifier {
+	return messenger{sender: sender}
+}
+
+// messenger builds messages of deployment and delivers them with its sender
+type messenger struct {
+	sender Sender
+}
+
+// SendSimpleMessage sends a text message
+func (m messenger) SendSimpleMessage(message string) error {
+	return m.sender.Send(Message{Text: Emojify(message)})
+}
+
+// SendSummaryMessage sends configurations applied to the deployment
+func (m messenger) SendSummaryMessage(config schemas.Config, stacks []schemas.Stack, app string) error {
+	return m.sender.Send(SummaryMessage(config, stacks, app))
+}
+
+// SendAPITestResultMessage sends result of API test
+func (m messenger) SendAPITestResultMessage(metrics []schemas.MetricResult) error {
+	return m.sender.Send(APITestResultMessage(metrics))
+}
+
+// emojis are unicode characters of slack emoji codes used in messages
+var emojis = strings.NewReplacer(
+	":+1:", "\U0001F44D",
+	":100:", "\U0001F4AF",
+	":arrows_counterclockwise:", "\U0001F504",
+	":hourglass:", "⌛",
+	":no_entry:", "⛔",
+	":raising_hand:", "\U0001F64B",
+	":rewind:", "⏪",
+	":twisted_rightwards_arrows:", "\U0001F500",
+	":warning:", "⚠️",
+	":white_check_mark:", "✅",
+	":x:", "❌",
+)
+
+// Emojify replaces slack emoji codes with unicode characters, because other channels do not know them
+func Emojify(text string) string {
+	return emojis.Replace(text)
+}
+
+// post sends JSON body to url and checks status of response
+func post(url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, tool.Excerpt(string(respBody), constants.MaxCommandOutputLength))
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+var testMessage = Message{
+	Title: "[ hello ] Deployment has been started",
+	Sections: []Section{
+		{Title: "Stack configurations: artd", Fields: []Field{{Title: "env", Value: "dev"}, {Title: "tags", Value: ""}}},
+	},
+}
+
+// recordServer records bodies of requests
+func recordServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies
+	}
+}
+
+func TestSummaryMessage(t *testing.T) {
+	stack := schemas.Stack{
+		Stack:    "artd",
+		Env:      "dev",
+		Capacity: schemas.Capacity{Min: 1, Max: 2, Desired: 2},
+		Regions:  []schemas.RegionConfig{{Region: "ap-northeast-2", InstanceType: "t3.medium"}, {Region: "us-east-1", InstanceType: "t3.large"}},
+	}
+
+	m := SummaryMessage(schemas.Config{Region: "ap-northeast-2"}, []schemas.Stack{stack}, "hello")
+	if diff := deep.Equal(m.Title, "[ hello ] Deployment has been started"); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(m.Sections[1].Title, "Stack configurations: artd"); diff != nil {
+		t.Error(diff)
+	}
+
+	fields := map[string]string{}
+	for _, f := range m.Sections[1].Fields {
+		fields[f.Title] = f.Value
+	}
+
+	if diff := deep.Equal(fields["capacity"], "min: 1, desired: 2, max: 2"); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(fields["[ap-northeast-2] instance-type"], "t3.medium"); diff != nil {
+		t.Error(diff)
+	}
+	if _, ok := fields["[us-east-1] instance-type"]; ok {
+		t.Error("regions which are not deployed should not be shown")
+	}
+	if _, ok := fields["stack"]; ok {
+		t.Error("name of stack should not be a field")
+	}
+}
+
+func TestTeams_Send(t *testing.T) {
+	server, bodies := recordServer(t)
+	if err := (Teams{URL: server.URL}).Send(testMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	var body teamsBody
+	if err := json.Unmarshal([]byte(bodies()[0]), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	card := body.Attachments[0].Content
+	if diff := deep.Equal(card.Type, "AdaptiveCard"); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(card.Body[0].Text, testMessage.Title); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(card.Body[2].Facts, []teamsFact{{Title: "env", Value: "dev"}, {Title: "tags", Value: ""}}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestDiscord_Send(t *testing.T) {
+	server, bodies := recordServer(t)
+
+	// 12 sections with 30 fields become 24 embeds, which are sent in 3 requests
+	var m Message
+	for i := 0; i < 12; i++ {
+		s := Section{Title: fmt.Sprintf("section %d", i)}
+		for j := 0; j < 30; j++ {
+			s.Fields = append(s.Fields, Field{Title: fmt.Sprintf("field %d", j)})
+		}
+		m.Sections = append(m.Sections, s)
+	}
+
+	if err := (Discord{URL: server.URL}).Send(m); err != nil {
+		t.Fatal(err)
+	}
+
+	var embeds []discordEmbed
+	for _, b := range bodies() {
+		var body discordBody
+		if err := json.Unmarshal([]byte(b), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Embeds) > discordMaxEmbeds {
+			t.Errorf("too many embeds in a request: %d", len(body.Embeds))
+		}
+		embeds = append(embeds, body.Embeds...)
+	}
+
+	if diff := deep.Equal(len(bodies()), 3); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(len(embeds), 24); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(len(embeds[0].Fields), discordMaxFields); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(embeds[0].Fields[0].Value, "-"); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestWebhook_Send(t *testing.T) {
+	server, bodies := recordServer(t)
+
+	// message itself is sent without template
+	w, err := NewWebhook(server.URL, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(Message{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(bodies()[0], `{"text":"hello"}`); diff != nil {
+		t.Error(diff)
+	}
+
+	w, err = NewWebhook(server.URL, nil, `{"summary": {{ json .Title }}, "fields": {{ len (index .Sections 0).Fields }}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(bodies()[1], `{"summary": "[ hello ] Deployment has been started", "fields": 2}`); diff != nil {
+		t.Error(diff)
+	}
+
+	w, err = NewWebhook(server.URL, nil, `{"text": "{{ .Text }}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(Message{Text: `say "hi"`}); err == nil || !strings.Contains(err.Error(), "does not render JSON") {
+		t.Errorf("template which does not render JSON should fail: %v", err)
+	}
+
+	if _, err := NewWebhook(server.URL, nil, `{{ .Text `); err == nil {
+		t.Error("invalid template should fail")
+	}
+}
+
+func TestEmail_Send(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// minimal SMTP server which accepts one mail
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		var data []string
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- strings.Join(data, "")
+					fmt.Fprint(conn, "250 OK\r\n")
+					continue
+				}
+				data = append(data, line)
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case cmd == "DATA":
+				inData = true
+				fmt.Fprint(conn, "354 go ahead\r\n")
+			case cmd == "QUIT":
+				fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	var p int64
+	fmt.Sscanf(port, "%d", &p)
+
+	n, err := New(schemas.Notifier{
+		Type: "email",
+		SMTP: &schemas.SMTP{Host: host, Port: p, From: "goployer@example.com", To: []string{"ops@example.com"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.SendSimpleMessage(":rewind: Rollback is finished : hello-artd_apnortheast2-v002/ap-northeast-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	mail := <-received
+	if !strings.Contains(mail, "To: ops@example.com\r\n") {
+		t.Errorf("recipient is not in mail: %s", mail)
+	}
+	if !strings.Contains(mail, "Subject: =?utf-8?q?") {
+		t.Errorf("subject should be encoded: %s", mail)
+	}
+	if !strings.Contains(mail, "⏪ Rollback is finished : hello-artd_apnortheast2-v002/ap-northeast-2") {
+		t.Errorf("text is not in mail: %s", mail)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(schemas.Notifier{Type: "pager"}); err == nil {
+		t.Error("unknown type of notifier should fail")
+	}
+
+	if _, err := New(schemas.Notifier{Type: "email"}); err == nil {
+		t.Error("email notifier without smtp should fail")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"encoding/json"
+)
+
+// Teams sends messages as adaptive cards to incoming webhook of Microsoft Teams
+type Teams struct {
+	URL string
+}
+
+type teamsBody struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string           `json:"$schema"`
+	Type    string           `json:"type"`
+	Version string           `json:"version"`
+	Body    []teamsCardBlock `json:"body"`
+}
+
+type teamsCardBlock struct {
+	Type   string      `json:"type"`
+	Text   string      `json:"text,omitempty"`
+	Weight string      `json:"weight,omitempty"`
+	Size   string      `json:"size,omitempty"`
+	Wrap   bool        `json:"wrap,omitempty"`
+	Facts  []teamsFact `json:"facts,omitempty"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Send posts message as an adaptive card. Each section becomes a fact set
+func (t Teams) Send(m Message) error {
+	var blocks []teamsCardBlock
+	if len(m.Title) > 0 {
+		blocks = append(blocks, teamsCardBlock{Type: "TextBlock", Text: m.Title, Weight: "Bolder", Size: "Medium", Wrap: true})
+	}
+
+	if len(m.Text) > 0 {
+		blocks = append(blocks, teamsCardBlock{Type: "TextBlock", Text: m.Text, Wrap: true})
+	}
+
+	for _, section := range m.Sections {
+		blocks = append(blocks, teamsCardBlock{Type: "TextBlock", Text: section.Title, Weight: "Bolder", Wrap: true})
+
+		var facts []teamsFact
+		for _, f := range section.Fields {
+			facts = append(facts, teamsFact{Title: f.Title, Value: f.Value})
+		}
+		if len(facts) > 0 {
+			blocks = append(blocks, teamsCardBlock{Type: "FactSet", Facts: facts})
+		}
+	}
+
+	body, err := json.Marshal(teamsBody{
+		Type: "message",
+		Attachments: []teamsAttachment{
+			{
+				ContentType: "application/vnd.microsoft.card.adaptive",
+				Content: teamsCard{
+					Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+					Type:    "AdaptiveCard",
+					Version: "1.4",
+					Body:    blocks,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return post(t.URL, nil, body)
+}
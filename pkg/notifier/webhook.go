@@ -0,0 +1,85 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+// templateFuncs are functions available in template of webhook notifier
+var templateFuncs = template.FuncMap{
+	// json encodes value, so that text can be put in JSON body safely
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Webhook posts messages as JSON to any endpoint.
+// Body is the message itself unless template is given.
+type Webhook struct {
+	URL      string
+	Headers  map[string]string
+	Template *template.Template
+}
+
+// NewWebhook creates a webhook notifier with body template
+func NewWebhook(url string, headers map[string]string, body string) (Webhook, error) {
+	w := Webhook{URL: url, Headers: headers}
+	if len(body) == 0 {
+		return w, nil
+	}
+
+	tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return w, fmt.Errorf("template of webhook notifier is not valid: %s", err.Error())
+	}
+	w.Template = tmpl
+
+	return w, nil
+}
+
+// Send renders message with template and posts it
+func (w Webhook) Send(m Message) error {
+	body, err := w.render(m)
+	if err != nil {
+		return err
+	}
+
+	return post(w.URL, w.Headers, body)
+}
+
+// render returns request body of message
+func (w Webhook) render(m Message) ([]byte, error) {
+	if w.Template == nil {
+		return json.Marshal(m)
+	}
+
+	var buf bytes.Buffer
+	if err := w.Template.Execute(&buf, m); err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("template of webhook notifier does not render JSON: %s", buf.String())
+	}
+
+	return buf.Bytes(), nil
+}
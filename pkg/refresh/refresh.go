@@ -39,8 +39,13 @@ type Refresher struct {
 
 // New creates new Refresher
 func New(region string) Refresher {
+	return NewWithClient(aws.BootstrapServices(region, constants.EmptyString))
+}
+
+// NewWithClient creates new Refresher with the given aws client
+func NewWithClient(client aws.Client) Refresher {
 	return Refresher{
-		AWSClient: aws.BootstrapServices(region, constants.EmptyString),
+		AWSClient: client,
 	}
 }
 
@@ -95,7 +100,7 @@ func (r *Refresher) StatusCheck(pollingInterval, timeout time.Duration) error {
 			return err
 		}
 
-		if tool.IsStringInArray(*r.Info.Status, []string{"Successful", "Cancelled", "Failed"}) {
+		if tool.IsStringInArray(*r.Info.Status, []string{"Successful", "Cancelled", "Failed", "RollbackSuccessful", "RollbackFailed"}) {
 			logrus.Debugf("Instance refresh is finished because the status is %s", *r.Info.Status)
 			break
 		}
@@ -17,11 +17,16 @@ limitations under the license.
 package runner
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"text/tabwriter"
+	"text/template"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
@@ -29,46 +34,65 @@ import (
 	Logger "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"github.com/DevopsArtFactory/goployer/pkg/approval"
 	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/checkpoint"
 	"github.com/DevopsArtFactory/goployer/pkg/collector"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
+	"github.com/DevopsArtFactory/goployer/pkg/hook"
 	"github.com/DevopsArtFactory/goployer/pkg/initializer"
 	"github.com/DevopsArtFactory/goployer/pkg/inspector"
+	"github.com/DevopsArtFactory/goployer/pkg/lock"
+	"github.com/DevopsArtFactory/goployer/pkg/notifier"
 	"github.com/DevopsArtFactory/goployer/pkg/refresh"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 	"github.com/DevopsArtFactory/goployer/pkg/slack"
+	"github.com/DevopsArtFactory/goployer/pkg/templates"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
 type Runner struct {
-	Logger     *Logger.Logger
-	Builder    builder.Builder
-	Collector  collector.Collector
-	Slacker    slack.Slack
-	FuncMapper map[string]func() error
+	Logger          *Logger.Logger
+	Builder         builder.Builder
+	Collector       collector.Collector
+	Slacker         slack.Slack
+	Events          *event.Emitter
+	FuncMapper      map[string]func() error
+	ClientFactory   aws.ClientFactory
+	CheckpointStore checkpoint.Store
+	LockStore       lock.Store
+	Lease           *lock.Lease
 }
 
 // NewRunner creates a new runner
 func NewRunner(newBuilder builder.Builder, mode string) (Runner, error) {
 	newRunner := Runner{
-		Logger:  Logger.New(),
-		Builder: newBuilder,
-		Slacker: slack.NewSlackClient(newBuilder.Config.SlackOff),
+		Logger:        Logger.New(),
+		Builder:       newBuilder,
+		Slacker:       slack.NewSlackClient(newBuilder.Config.SlackOff),
+		ClientFactory: aws.BootstrapServices,
 	}
 
-	if checkBuilderConfigurationNeeded(mode) {
+	if checkBuilderConfigurationNeeded(mode) || tool.IsStringInArray(mode, []string{"resume", "unlock"}) {
 		newRunner.Collector = collector.NewCollector(newBuilder.MetricConfig, newBuilder.Config.AssumeRole)
+		newRunner.CheckpointStore = checkpoint.NewStore(newBuilder.MetricConfig, newRunner.Collector.MetricClient)
+		newRunner.LockStore = lock.NewStore(newBuilder.MetricConfig, newRunner.Collector.MetricClient)
 	}
 
 	newRunner.FuncMapper = map[string]func() error{
-		"deploy":  newRunner.Deploy,
-		"delete":  newRunner.Delete,
-		"status":  newRunner.Status,
-		"update":  newRunner.Update,
-		"refresh": newRunner.Refresh,
+		"deploy":   newRunner.Deploy,
+		"delete":   newRunner.Delete,
+		"status":   newRunner.Status,
+		"update":   newRunner.Update,
+		"refresh":  newRunner.Refresh,
+		"plan":     newRunner.Plan,
+		"rollback": newRunner.Rollback,
+		"resume":   newRunner.Resume,
+		"unlock":   newRunner.Unlock,
 	}
 
 	return newRunner, nil
@@ -82,6 +106,17 @@ func SetupBuilder(mode string) (builder.Builder, error) {
 		return builder.Builder{}, err
 	}
 
+	// resume and unlock do not need manifest, but checkpoints and locks can be stored in the metric table
+	if tool.IsStringInArray(mode, []string{"resume", "unlock"}) {
+		m, err := builder.ParseMetricConfig(builderSt.Config.DisableMetrics, constants.MetricYamlPath)
+		if err != nil {
+			return builder.Builder{}, err
+		}
+		builderSt.MetricConfig = m
+
+		return builderSt, nil
+	}
+
 	if !checkBuilderConfigurationNeeded(mode) {
 		return builderSt, nil
 	}
@@ -215,13 +250,13 @@ func Start(builderSt builder.Builder, mode string) error {
 	return withRunner(builderSt, mode, func(slacker slack.Slack) error {
 		// These are post actions after deployment
 		if !builderSt.Config.SlackOff {
-			if mode == "deploy" {
-				slacker.SendSimpleMessage(fmt.Sprintf(":100: Deployment is done: %s", builderSt.AwsConfig.Name))
-			}
-
 			if mode == "delete" {
 				slacker.SendSimpleMessage(fmt.Sprintf(":100: Delete process is done: %s", builderSt.AwsConfig.Name))
 			}
+
+			if mode == "rollback" {
+				slacker.SendSimpleMessage(fmt.Sprintf(":100: Rollback is done: %s", builderSt.AwsConfig.Name))
+			}
 		}
 
 		return nil
@@ -272,6 +307,19 @@ func (r Runner) Deploy() error {
 		return err
 	}
 
+	notifiers, err := r.notifierSinks(r.Builder.Stacks)
+	if err != nil {
+		return err
+	}
+
+	deploymentID := checkpoint.NewDeploymentID(r.Builder.AwsConfig.Name, time.Now())
+	lease, err := r.acquireLocks(r.Builder.Stacks, deploymentID)
+	if err != nil {
+		return err
+	}
+	defer r.releaseLocks(lease)
+	r.Lease = lease
+
 	// Send Beginning Message
 	r.Logger.Infof("Beginning deployment: %s", r.Builder.AwsConfig.Name)
 
@@ -279,14 +327,15 @@ func (r Runner) Deploy() error {
 		return err
 	}
 
+	var stacks []schemas.Stack
+	for _, s := range r.Builder.Stacks {
+		if len(r.Builder.Config.Stack) == 0 || r.Builder.Config.Stack == s.Stack {
+			stacks = append(stacks, s)
+		}
+	}
+
 	if r.Slacker.ValidClient() {
 		r.Logger.Debug("Slack configuration is valid")
-		var stacks []schemas.Stack
-		for _, s := range r.Builder.Stacks {
-			if len(r.Builder.Config.Stack) == 0 || r.Builder.Config.Stack == s.Stack {
-				stacks = append(stacks, s)
-			}
-		}
 		if err := r.Slacker.SendSummaryMessage(r.Builder.Config, stacks, r.Builder.AwsConfig.Name); err != nil {
 			r.Logger.Warn(err.Error())
 			r.Slacker.SlackOff = true
@@ -296,15 +345,20 @@ func (r Runner) Deploy() error {
 		r.Logger.Warn("no slack variables exists. [ SLACK_TOKEN, SLACK_CHANNEL or SLACK_WEBHOOK_URL ]")
 	}
 
+	for _, n := range notifiers {
+		if err := n.SendSummaryMessage(r.Builder.Config, stacks, r.Builder.AwsConfig.Name); err != nil {
+			r.Logger.Warnf("failed to send summary to notifier: %s", err.Error())
+		}
+	}
+	r.Events = r.emitter(notifiers...)
+	r.Events.Emit(event.Event{Type: event.DeploymentStarted, Stack: r.Builder.Config.Stack, Region: r.Builder.Config.Region})
+
 	if r.Builder.MetricConfig.Enabled {
 		if err := r.CheckEnabledMetrics(); err != nil {
 			return err
 		}
 	}
 
-	r.Logger.Debugf("create wait group for deployer setup")
-	wg := sync.WaitGroup{}
-
 	// Prepare deployers
 	r.Logger.Debug("create deployers for stacks")
 	var deployers []deployer.DeployManager
@@ -315,76 +369,218 @@ func (r Runner) Deploy() error {
 		}
 
 		r.Logger.Debugf("add deployer setup function : %s", stack.Stack)
-		deployers = append(deployers, getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Slacker, r.Collector))
+		deployers = append(deployers, r.newStackDeployers(stack)...)
 	}
 	r.Logger.Debugf("successfully assign deployer to stacks")
 
-	errs := make(chan error)
-	// Check Previous Version
+	checkpoints := newCheckpointRecorder(r.CheckpointStore, r.Logger, checkpoint.Deployment{
+		ID:               deploymentID,
+		Config:           r.Builder.Config,
+		AwsConfig:        r.Builder.AwsConfig,
+		APITestTemplates: r.Builder.APITestTemplates,
+	})
+	if r.CheckpointStore != nil {
+		r.Logger.Infof("Deployment ID: %s", checkpoints.id())
+	}
+
+	return r.runDeployers(deployers, checkpoints)
+}
+
+// runDeployers runs the steps of deployers which are not completed yet and records checkpoints of them
+func (r Runner) runDeployers(deployers []deployer.DeployManager, checkpoints *checkpointRecorder) error {
 	for _, d := range deployers {
-		wg.Add(1)
-		go func(deployer deployer.DeployManager) {
-			defer wg.Done()
-			if err := deployer.CheckPreviousResources(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepCheckPrevious] check previous deployer error occurred: %s", err.Error())
-				errs <- err
-			}
+		checkpoints.record(d)
+	}
 
-			if err := deployer.Deploy(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepDeploy] deploy step error occurred: %s", err.Error())
-				errs <- err
+	rollback := newRollbackRecorder()
+	dependencies := newDependencyRecorder(deployers)
+	results := newRegionRecorder()
+
+	// Regions of a stack with sequential region rollout are deployed in separate passes
+	passes := groupDeployersByRegion(deployers)
+	for i, pass := range passes {
+		if i > 0 {
+			pass = r.proceedToNextRegions(passes[i-1], pass, rollback, dependencies, results)
+
+			// timeout is counted again for each region
+			r.Builder.Config.StartTimestamp = time.Now().Unix()
+		}
+
+		if err := r.runPass(pass, checkpoints, rollback, dependencies); err != nil {
+			results.abort(append([][]deployer.DeployManager{pass}, passes[i+1:]...), err)
+			r.printRegionResults(results)
+			checkpoints.finish(constants.CheckpointFailed)
+			r.Events.Emit(event.Event{Type: event.DeploymentFailed, Error: err.Error(), Message: fmt.Sprintf(":x: Deployment failed: %s\n%s", r.Builder.AwsConfig.Name, err.Error())})
+			return err
+		}
+		results.record(pass, rollback, dependencies)
+	}
+	r.printRegionResults(results)
+
+	if err := errors.Join(rollback.err(), dependencies.err(), results.err()); err != nil {
+		checkpoints.finish(constants.CheckpointFailed)
+		r.Events.Emit(event.Event{Type: event.DeploymentFailed, Error: err.Error(), Message: fmt.Sprintf(":x: Deployment failed: %s\n%s", r.Builder.AwsConfig.Name, err.Error())})
+		return err
+	}
+
+	checkpoints.finish(constants.CheckpointCompleted)
+	r.Events.Emit(event.Event{Type: event.DeploymentFinished, Message: fmt.Sprintf(":100: Deployment is done: %s", r.Builder.AwsConfig.Name)})
+	return nil
+}
+
+// runPass runs the whole steps of deployers at once.
+// An error is returned only when the deployment cannot go on.
+func (r Runner) runPass(deployers []deployer.DeployManager, checkpoints *checkpointRecorder, rollback *rollbackRecorder, dependencies *dependencyRecorder) error {
+	r.Logger.Debugf("create wait group for deployer setup")
+	wg := sync.WaitGroup{}
+
+	waves, err := r.groupDeployersByWave(deployers)
+	if err != nil {
+		return err
+	}
+
+	// Stacks in a wave start after all stacks of previous waves finish health checking
+	var started []deployer.DeployManager
+	for i, wave := range waves {
+		var targets []deployer.DeployManager
+		for _, d := range wave {
+			stack := d.GetDeployer().Stack.Stack
+			if failed := dependencies.failedDependency(stack); len(failed) > 0 {
+				r.Logger.Errorf("[StepDeploy] %s is not deployed because its dependency failed: %s", stack, failed)
+				dependencies.skip(stack, failed)
+				continue
 			}
-		}(d)
+			targets = append(targets, d)
+		}
+
+		if len(waves) > 1 {
+			r.Logger.Infof("Wave %d/%d starts: %s", i+1, len(waves), strings.Join(stackNames(targets), ", "))
+		}
+
+		if err := r.checkLease(); err != nil {
+			return err
+		}
+
+		deployed, err := r.deployWave(targets, checkpoints, rollback, dependencies)
+		if err != nil {
+			return err
+		}
+		started = append(started, deployed...)
 	}
-	go func() {
-		wg.Wait()
-		close(errs)
-	}()
-	errFlag := checkError(errs)
-	if errFlag != nil {
-		return errFlag
+	deployers = rollback.filter(started)
+
+	if err := r.checkLease(); err != nil {
+		return err
 	}
 
-	// Health checking step
+	// Manual approval while both versions are up
 	for _, d := range deployers {
+		if d.GetDeployer().Stack.Approval == nil || d.GetDeployer().StepStatus[constants.StepAdditionalWork] {
+			continue
+		}
+
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.HealthChecking(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepHealthCheck] check new deployment error occurred: %s", err.Error())
+			if err := r.waitForApproval(deployer); err != nil {
+				r.Logger.Errorf("[StepApproval] approval error occurred: %s", err.Error())
+				r.rollbackOnFailure(deployer, err, rollback)
 			}
 		}(d)
 	}
 	wg.Wait()
+	checkpoints.rolledBack(rollback.rolledBack())
+	deployers = rollback.filter(deployers)
 
+	// previous versions are not touched without locks
+	if err := r.checkLease(); err != nil {
+		return err
+	}
+
+	hookErrs := make(chan error, len(deployers))
 	for _, d := range deployers {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
+			status := deployer.GetDeployer().StepStatus
+
 			// Attach scaling policy
-			if err := deployer.FinishAdditionalWork(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepFinishAdditionalWork] finish additional work error occurred: %s", err.Error())
+			if !status[constants.StepAdditionalWork] {
+				err := deployer.FinishAdditionalWork(r.Builder.Config)
+				checkpoints.record(deployer)
+				if err != nil {
+					r.Logger.Errorf("[StepFinishAdditionalWork] finish additional work error occurred: %s", err.Error())
+					if r.rollbackOnFailure(deployer, err, rollback) {
+						return
+					}
+				}
 			}
 
-			if err := deployer.TriggerLifecycleCallbacks(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepTriggerLifecycleCallbacks] trigger lifecycle callbacks error occurred: %s", err.Error())
+			// Keep previous versions until the bake period ends without any alarm
+			if deployer.GetDeployer().Stack.Bake != nil && !status[constants.StepTriggerLifecycleCallback] {
+				if err := r.bake(deployer, checkpoints); err != nil {
+					r.Logger.Errorf("[StepBake] bake error occurred: %s", err.Error())
+					r.rollbackOnFailure(deployer, err, rollback)
+					return
+				}
 			}
 
-			if err := deployer.CleanPreviousVersion(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepCleanPreviousVersion] clean previous verson error occurred: %s", err.Error())
+			if err := r.checkLease(); err != nil {
+				hookErrs <- err
+				return
+			}
+
+			if !status[constants.StepTriggerLifecycleCallback] {
+				if err := r.runHooks(constants.BeforeCleanupHook, deployer, nil); err != nil {
+					r.Logger.Errorf("[StepBeforeCleanup] hook error occurred: %s", err.Error())
+					dependencies.fail(deployer.GetDeployer().Stack.Stack, err.Error())
+					r.rollbackOnFailure(deployer, err, rollback)
+					hookErrs <- err
+					return
+				}
+
+				err := deployer.TriggerLifecycleCallbacks(r.Builder.Config)
+				checkpoints.record(deployer)
+
+				// previous versions are not terminated when lifecycle callbacks fail with abort or retry policy
+				if err != nil {
+					r.Logger.Errorf("[StepTriggerLifecycleCallbacks] trigger lifecycle callbacks error occurred: %s", err.Error())
+					dependencies.fail(deployer.GetDeployer().Stack.Stack, err.Error())
+					r.rollbackOnFailure(deployer, err, rollback)
+					return
+				}
+			}
+
+			if !status[constants.StepCleanPreviousVersion] {
+				r.emitStep(event.CleanupStarted, constants.StepCleanPreviousVersionName, deployer, nil)
+				if err := deployer.CleanPreviousVersion(r.Builder.Config); err != nil {
+					r.Logger.Errorf("[StepCleanPreviousVersion] clean previous verson error occurred: %s", err.Error())
+				}
+				checkpoints.record(deployer)
 			}
 		}(d)
 	}
 	wg.Wait()
+	close(hookErrs)
+	checkpoints.rolledBack(rollback.rolledBack())
+	if err := checkError(hookErrs); err != nil {
+		return err
+	}
+	deployers = rollback.filter(deployers)
 
 	// CleanChecking
 	for _, d := range deployers {
+		if d.GetDeployer().StepStatus[constants.StepCleanChecking] {
+			continue
+		}
+
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
 			if err := deployer.CleanChecking(r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepCleanChecking] clean checking error occurred: %s", err.Error())
 			}
+			checkpoints.record(deployer)
 		}(d)
 	}
 	wg.Wait()
@@ -413,294 +609,1088 @@ func (r Runner) Deploy() error {
 	}
 	wg.Wait()
 
-	return nil
-}
-
-// Delete is the main function for `goployer delete`
-func (r Runner) Delete() error {
-	defer func() {
-		if err := recover(); err != nil {
-			Logger.Error(err)
-			os.Exit(1)
+	// Hooks after deployment of stacks which did not fail
+	hookErrs = make(chan error, len(deployers))
+	for _, d := range deployers {
+		if _, failed := dependencies.failure(d.GetDeployer().Stack.Stack); failed {
+			continue
 		}
-	}()
 
-	if err := tool.LocalCheck("Do you really want to delete applications? ", r.Builder.Config.AutoApply); err != nil {
-		return err
+		wg.Add(1)
+		go func(deployer deployer.DeployManager) {
+			defer wg.Done()
+			if err := r.runHooks(constants.AfterDeployHook, deployer, nil); err != nil {
+				r.Logger.Errorf("[StepAfterDeploy] hook error occurred: %s", err.Error())
+				r.runFailureHooks(deployer, err)
+				hookErrs <- err
+			}
+		}(d)
 	}
+	wg.Wait()
+	close(hookErrs)
 
-	// Send Beginning Message
-	r.Logger.Info("Beginning delete process: ", r.Builder.AwsConfig.Name)
-	r.Builder.Config.SlackOff = true
-
-	if r.Builder.MetricConfig.Enabled {
-		if err := r.CheckEnabledMetrics(); err != nil {
-			return err
-		}
-	}
+	return checkError(hookErrs)
+}
 
+// deployWave deploys new versions of stacks in the same wave and checks their health.
+// Stacks which fail to deploy are recorded as failures so that only their dependents stop, and the rest are returned.
+func (r Runner) deployWave(deployers []deployer.DeployManager, checkpoints *checkpointRecorder, rollback *rollbackRecorder, dependencies *dependencyRecorder) ([]deployer.DeployManager, error) {
 	wg := sync.WaitGroup{}
-
-	// Prepare deployers
-	r.Logger.Debug("create deployers for stacks to delete")
-	var deployers []deployer.DeployManager
-	for _, stack := range r.Builder.Stacks {
-		// If target stack is passed from command, then
-		// Skip other stacks
-		if r.Builder.Config.Stack != "" && stack.Stack != r.Builder.Config.Stack {
-			r.Logger.Debugf("Skipping this stack, stack=%s", stack.Stack)
+	errs := make(chan error, len(deployers))
+	// Check Previous Version
+	for _, d := range deployers {
+		if d.GetDeployer().StepStatus[constants.StepDeploy] {
 			continue
 		}
 
-		r.Logger.Debugf("add deployer setup function : %s", stack.Stack)
-		d := getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Slacker, r.Collector)
-		deployers = append(deployers, d)
-	}
-
-	r.Logger.Debugf("successfully assign deployer to stacks")
-
-	// Check Previous Version
-	errs := make(chan error)
-	for _, d := range deployers {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.GetDeployer().CheckPrevious(r.Builder.Config); err != nil {
+			defer checkpoints.record(deployer)
+			if err := deployer.CheckPreviousResources(r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepCheckPrevious] check previous deployer error occurred: %s", err.Error())
-				errs <- err
+				dependencies.abort(deployer.GetDeployer().Stack.Stack, err)
+				r.runFailureHooks(deployer, err)
+				return
 			}
 
-			deployer.GetDeployer().SkipDeployStep()
-
-			// Trigger Lifecycle Callbacks
-			if err := deployer.TriggerLifecycleCallbacks(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepTriggerLifecycleCallbacks] trigger lifecycle callbacks error occurred: %s", err.Error())
+			if err := r.runBeforeDeployHooks(deployer); err != nil {
+				r.Logger.Errorf("[StepBeforeDeploy] hook error occurred: %s", err.Error())
+				r.runFailureHooks(deployer, err)
 				errs <- err
+				return
 			}
 
-			// Clear previous Version
-			if err := deployer.CleanPreviousVersion(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepCleanPreviousVersion] clean previous version error occurred: %s", err.Error())
-				errs <- err
+			r.emitStep(event.StepStarted, constants.StepDeployName, deployer, nil)
+			if err := deployer.Deploy(r.Builder.Config); err != nil {
+				r.Logger.Errorf("[StepDeploy] deploy step error occurred: %s", err.Error())
+				r.emitStep(event.StepFinished, constants.StepDeployName, deployer, err)
+				dependencies.abort(deployer.GetDeployer().Stack.Stack, err)
+				r.runFailureHooks(deployer, err)
+				return
 			}
+			r.emitStep(event.StepFinished, constants.StepDeployName, deployer, nil)
 		}(d)
 	}
-	go func() {
-		wg.Wait()
-		close(errs)
-	}()
-	errFlag := checkError(errs)
-	if errFlag != nil {
-		return errFlag
+	wg.Wait()
+	close(errs)
+	if err := checkError(errs); err != nil {
+		return nil, err
 	}
 
-	errs = make(chan error)
+	var deployed []deployer.DeployManager
 	for _, d := range deployers {
-		wg.Add(1)
-		go func(deployer deployer.DeployManager) {
-			defer wg.Done()
-			if err := deployer.CleanChecking(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepCleanChecking] clean checking error occurred: %s", err.Error())
-				errs <- err
-			}
-		}(d)
-	}
-	go func() {
-		wg.Wait()
-		close(errs)
-	}()
-	errFlag = checkError(errs)
-	if errFlag != nil {
-		return errFlag
+		if _, failed := dependencies.failure(d.GetDeployer().Stack.Stack); !failed {
+			deployed = append(deployed, d)
+		}
 	}
-	// gather metrics of previous version
-	errs = make(chan error)
+	deployers = deployed
+
+	// Health checking step
+	hookErrs := make(chan error, len(deployers))
 	for _, d := range deployers {
+		if d.GetDeployer().StepStatus[constants.StepAdditionalWork] {
+			continue
+		}
+
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.GatherMetrics(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepGatherMetrics] gather metrics error occurred: %s", err.Error())
-				errs <- err
+			r.emitStep(event.StepStarted, constants.StepHealthCheckName, deployer, nil)
+			err := deployer.HealthChecking(r.Builder.Config)
+			r.emitStep(event.StepFinished, constants.StepHealthCheckName, deployer, err)
+			if err != nil {
+				r.Logger.Errorf("[StepHealthCheck] check new deployment error occurred: %s", err.Error())
+				dependencies.fail(deployer.GetDeployer().Stack.Stack, err.Error())
+				r.rollbackOnFailure(deployer, err, rollback)
+				return
+			}
+
+			if err := r.runHooks(constants.AfterHealthCheckHook, deployer, nil); err != nil {
+				r.Logger.Errorf("[StepAfterHealthCheck] hook error occurred: %s", err.Error())
+				dependencies.fail(deployer.GetDeployer().Stack.Stack, err.Error())
+				r.rollbackOnFailure(deployer, err, rollback)
+				hookErrs <- err
 			}
 		}(d)
 	}
-	go func() {
-		wg.Wait()
-		close(errs)
-	}()
-	errFlag = checkError(errs)
-	if errFlag != nil {
-		return errFlag
-	}
+	wg.Wait()
+	close(hookErrs)
+	checkpoints.rolledBack(rollback.rolledBack())
 
-	return nil
+	return deployers, checkError(hookErrs)
 }
 
-// Status shows the detailed information about autoscaling deployment
-func (r Runner) Status() error {
-	inspector := inspector.New(r.Builder.Config.Region)
-
-	asg, err := inspector.SelectStack(r.Builder.Config.Application)
-	if err != nil {
-		return err
+// groupDeployersByWave groups deployers with depends_on and wave of stacks
+func (r Runner) groupDeployersByWave(deployers []deployer.DeployManager) ([][]deployer.DeployManager, error) {
+	var stacks []schemas.Stack
+	m := map[string]deployer.DeployManager{}
+	for _, d := range deployers {
+		stack := d.GetDeployer().Stack
+		stacks = append(stacks, stack)
+		m[stack.Stack] = d
 	}
 
-	group, err := inspector.GetStackInformation(asg)
+	waves, err := builder.StackWaves(stacks)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	launchTemplateInfo, err := inspector.GetLaunchTemplateInformation(*group.LaunchTemplate.LaunchTemplateId)
-	if err != nil {
-		return err
+	var ret [][]deployer.DeployManager
+	for _, wave := range waves {
+		var group []deployer.DeployManager
+		for _, name := range wave {
+			group = append(group, m[name])
+		}
+		ret = append(ret, group)
 	}
 
-	securityGroups, err := inspector.GetSecurityGroupsInformation(launchTemplateInfo.LaunchTemplateData.SecurityGroupIds)
-	if err != nil {
-		return err
-	}
+	return ret, nil
+}
 
-	inspector.StatusSummary = inspector.SetStatusSummary(group, securityGroups)
+// groupDeployersByRegion groups deployers into passes.
+// Deployers of the same stack, which are created per region for sequential region rollout, go to consecutive passes.
+func groupDeployersByRegion(deployers []deployer.DeployManager) [][]deployer.DeployManager {
+	count := map[string]int{}
+	var passes [][]deployer.DeployManager
+	for _, d := range deployers {
+		stack := d.GetDeployer().Stack.Stack
+		i := count[stack]
+		count[stack]++
 
-	if err := inspector.Print(); err != nil {
-		return err
+		if i == len(passes) {
+			passes = append(passes, nil)
+		}
+		passes[i] = append(passes[i], d)
 	}
 
-	return nil
+	return passes
 }
 
-// Update will changes configuration of current deployment on live
-func (r Runner) Update() error {
-	var wg sync.WaitGroup
-	i := inspector.New(r.Builder.Config.Region)
+// proceedToNextRegions waits between regions and checks the gate of deployed regions.
+// Remaining regions of a stack are halted if any previous region of the stack failed.
+func (r Runner) proceedToNextRegions(prev, next []deployer.DeployManager, rollback *rollbackRecorder, dependencies *dependencyRecorder, results *regionRecorder) []deployer.DeployManager {
+	failed := func(stack string) bool {
+		_, ok := dependencies.failure(stack)
+		return ok || rollback.cause(stack) != nil
+	}
 
-	asg, err := i.SelectStack(r.Builder.Config.Application)
-	if err != nil {
+	var pause time.Duration
+	for _, d := range next {
+		stack := d.GetDeployer().Stack
+		if !failed(stack.Stack) && stack.PauseBetweenRegions > pause {
+			pause = stack.PauseBetweenRegions
+		}
+	}
+
+	if pause > 0 {
+		r.Logger.Infof("Waiting for %s before the next region", pause)
+		time.Sleep(pause)
+	}
+
+	prevRegions := map[string]string{}
+	for _, d := range prev {
+		stack := d.GetDeployer().Stack.Stack
+		prevRegions[stack] = strings.Join(regionNames(d), ", ")
+		if failed(stack) {
+			continue
+		}
+
+		if err := d.GetDeployer().CheckRegionGate(r.Builder.Config); err != nil {
+			r.Logger.Errorf("[StepRegionGate] region gate error occurred: %s", err.Error())
+			dependencies.fail(stack, err.Error())
+			results.fail(d, err)
+		}
+	}
+
+	var ret []deployer.DeployManager
+	for _, d := range next {
+		stack := d.GetDeployer().Stack.Stack
+		if failed(stack) {
+			r.Logger.Errorf("[StepDeploy] %s is not deployed in %s because previous region failed", stack, strings.Join(regionNames(d), ", "))
+			results.halt(d, fmt.Sprintf("previous region failed: %s", prevRegions[stack]))
+			continue
+		}
+
+		r.Logger.Infof("Rollout goes on to the next region: %s / %s", stack, strings.Join(regionNames(d), ", "))
+		ret = append(ret, d)
+	}
+
+	return ret
+}
+
+// regionNames returns regions of the deployer
+func regionNames(d deployer.DeployManager) []string {
+	var ret []string
+	for _, region := range d.GetDeployer().Stack.Regions {
+		ret = append(ret, region.Region)
+	}
+	return ret
+}
+
+// stackNames returns names of stacks of deployers
+func stackNames(deployers []deployer.DeployManager) []string {
+	var ret []string
+	for _, d := range deployers {
+		ret = append(ret, d.GetDeployer().Stack.Stack)
+	}
+	return ret
+}
+
+// waitForApproval asks an operator to approve the new version of stack.
+// If approval cannot be requested, the default action is applied.
+func (r Runner) waitForApproval(d deployer.DeployManager) error {
+	dep := d.GetDeployer()
+
+	var regions []string
+	for region := range dep.AsgNames {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var lines []string
+	for _, region := range regions {
+		lines = append(lines, fmt.Sprintf("[%s] new version: %s, previous versions: %s", region, dep.AsgNames[region], strings.Join(dep.PrevAsgs[region], ", ")))
+	}
+
+	req := approval.NewRequest(r.Builder.AwsConfig.Name, dep.Stack.Stack, strings.Join(lines, "\n"), *dep.Stack.Approval)
+	approver := approval.NewApprover(*dep.Stack.Approval, r.Slacker, r.Builder.Config.AutoApply, r.Logger)
+
+	r.Logger.Infof("Waiting for approval of %s until %s", req.ID, req.Timeout)
+	decision, err := approval.Wait(approver, req)
+	if err != nil {
+		r.Logger.Warnf("approval cannot be requested, so default action is applied: %s", err.Error())
+		decision = approval.Decision{Action: req.DefaultAction}
+	}
+
+	if decision.TimedOut {
+		r.Logger.Warnf("Approval of %s is timed out, so default action is applied: %s", req.ID, req.DefaultAction)
+	}
+
+	if decision.Action != constants.ApproveAction {
+		r.Events.Emit(event.Event{Type: event.ApprovalResolved, Stack: dep.Stack.Stack, Message: fmt.Sprintf(":no_entry: New version is rejected: %s", req.ID)})
+		return fmt.Errorf("%w: %s", approval.ErrRejected, req.ID)
+	}
+
+	r.Logger.Infof("New version is approved: %s", req.ID)
+	r.Events.Emit(event.Event{Type: event.ApprovalResolved, Stack: dep.Stack.Stack, Message: fmt.Sprintf(":white_check_mark: New version is approved: %s", req.ID)})
+
+	return nil
+}
+
+// bake detaches previous versions from traffic and watches alarms of the new version during bake period.
+// Previous versions are not cleaned if bake does not finish cleanly.
+func (r Runner) bake(d deployer.DeployManager, checkpoints *checkpointRecorder) error {
+	dep := d.GetDeployer()
+	err := dep.StartBake(r.Builder.Config)
+	checkpoints.record(d)
+	if err != nil {
+		return err
+	}
+
+	return dep.WatchBake(r.Builder.Config)
+}
+
+// runHooks runs hooks of the step in every region where the stack is deployed
+func (r Runner) runHooks(step string, d deployer.DeployManager, cause error) error {
+	return r.runHooksWithAsgNames(step, d, cause, d.GetDeployer().AsgNames)
+}
+
+// runBeforeDeployHooks runs before_deploy hooks with autoscaling groups in deployment plans, which are not created yet
+func (r Runner) runBeforeDeployHooks(d deployer.DeployManager) error {
+	if len(hook.Hooks(r.Builder.AwsConfig.Hooks, constants.BeforeDeployHook)) == 0 {
+		return nil
+	}
+
+	dep := d.GetDeployer()
+	dep.LocalProvider = builder.SetUserdataProvider(dep.Stack.Userdata, dep.AwsConfig.Userdata)
+
+	asgNames := map[string]string{}
+	for _, region := range dep.Stack.Regions {
+		if len(r.Builder.Config.Region) > 0 && r.Builder.Config.Region != region.Region {
+			continue
+		}
+
+		// instance refresh replaces instances of the latest autoscaling group
+		if dep.Mode == constants.RefreshDeployment {
+			asgNames[region.Region] = dep.LatestAsg[region.Region]
+			continue
+		}
+
+		plan, err := dep.MakeDeploymentPlan(r.Builder.Config, region)
+		if err != nil {
+			return err
+		}
+		asgNames[region.Region] = plan.AutoscalingGroupName
+	}
+
+	return r.runHooksWithAsgNames(constants.BeforeDeployHook, d, nil, asgNames)
+}
+
+// runHooksWithAsgNames runs hooks of the step with autoscaling groups of regions
+func (r Runner) runHooksWithAsgNames(step string, d deployer.DeployManager, cause error, asgNames map[string]string) error {
+	hooks := hook.Hooks(r.Builder.AwsConfig.Hooks, step)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	dep := d.GetDeployer()
+	for _, region := range dep.Stack.Regions {
+		if len(r.Builder.Config.Region) > 0 && r.Builder.Config.Region != region.Region {
+			continue
+		}
+
+		c := hook.Context{
+			Step:             step,
+			Application:      r.Builder.AwsConfig.Name,
+			Stack:            dep.Stack.Stack,
+			Region:           region.Region,
+			AutoScalingGroup: asgNames[region.Region],
+			Version:          tool.ParseAutoScalingVersion(asgNames[region.Region]),
+			AMI:              region.AmiID,
+		}
+
+		if len(r.Builder.Config.Ami) > 0 {
+			c.AMI = r.Builder.Config.Ami
+		}
+
+		if cause != nil {
+			c.Error = cause.Error()
+		}
+
+		for _, h := range hooks {
+			r.Logger.Infof("Run %s hook %s: %s / %s", step, hook.Name(h), dep.Stack.Stack, region.Region)
+			if err := hook.Run(h, c); err != nil {
+				return fmt.Errorf("%s hook %s failed in %s: %s", step, hook.Name(h), region.Region, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// runFailureHooks runs on_failure hooks of the stack. Their failures are only logged because the deployment already failed
+func (r Runner) runFailureHooks(d deployer.DeployManager, cause error) {
+	stack := d.GetDeployer().Stack.Stack
+	r.Events.Emit(event.Event{Type: event.DeploymentFailed, Stack: stack, Error: cause.Error(), Message: fmt.Sprintf(":x: Deployment of %s failed: %s", stack, cause.Error())})
+	if err := r.runHooks(constants.OnFailureHook, d, cause); err != nil {
+		r.Logger.Errorf("[StepOnFailure] hook error occurred: %s", err.Error())
+	}
+}
+
+// rollbackOnFailure rolls back the new version of stack if rollback_on_failure is enabled.
+// A canary which failed analysis, a rejected version and a version which triggered bake alarms are always rolled back.
+func (r Runner) rollbackOnFailure(d deployer.DeployManager, cause error, recorder *rollbackRecorder) bool {
+	r.runFailureHooks(d, cause)
+
+	stack := d.GetDeployer().Stack
+	if !stack.RollbackOnFailure && !errors.Is(cause, deployer.ErrCanaryAnalysisFailed) && !errors.Is(cause, approval.ErrRejected) && !errors.Is(cause, deployer.ErrBakeAlarm) {
+		return false
+	}
+
+	r.Logger.Warnf("[StepRollback] rollback starts because of failure: %s", stack.Stack)
+	if err := d.Rollback(r.Builder.Config); err != nil {
+		r.Logger.Errorf("[StepRollback] rollback error occurred: %s", err.Error())
+		recorder.add(stack.Stack, fmt.Errorf("%s, and rollback failed: %s", cause.Error(), err.Error()))
+		return true
+	}
+
+	recorder.add(stack.Stack, fmt.Errorf("%s, and the new version is rolled back", cause.Error()))
+	return true
+}
+
+// Delete is the main function for `goployer delete`
+func (r Runner) Delete() error {
+	defer func() {
+		if err := recover(); err != nil {
+			Logger.Error(err)
+			os.Exit(1)
+		}
+	}()
+
+	if err := tool.LocalCheck("Do you really want to delete applications? ", r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	// Send Beginning Message
+	r.Logger.Info("Beginning delete process: ", r.Builder.AwsConfig.Name)
+	r.Builder.Config.SlackOff = true
+
+	if r.Builder.MetricConfig.Enabled {
+		if err := r.CheckEnabledMetrics(); err != nil {
+			return err
+		}
+	}
+
+	wg := sync.WaitGroup{}
+
+	// Prepare deployers
+	r.Logger.Debug("create deployers for stacks to delete")
+	var deployers []deployer.DeployManager
+	for _, stack := range r.Builder.Stacks {
+		// If target stack is passed from command, then
+		// Skip other stacks
+		if r.Builder.Config.Stack != "" && stack.Stack != r.Builder.Config.Stack {
+			r.Logger.Debugf("Skipping this stack, stack=%s", stack.Stack)
+			continue
+		}
+
+		r.Logger.Debugf("add deployer setup function : %s", stack.Stack)
+		d := getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.emitter(), r.Collector, r.ClientFactory)
+		deployers = append(deployers, d)
+	}
+
+	r.Logger.Debugf("successfully assign deployer to stacks")
+
+	// Check Previous Version
+	errs := make(chan error)
+	for _, d := range deployers {
+		wg.Add(1)
+		go func(deployer deployer.DeployManager) {
+			defer wg.Done()
+			if err := deployer.GetDeployer().CheckPrevious(r.Builder.Config); err != nil {
+				r.Logger.Errorf("[StepCheckPrevious] check previous deployer error occurred: %s", err.Error())
+				errs <- err
+			}
+
+			deployer.GetDeployer().SkipDeployStep()
+
+			// Trigger Lifecycle Callbacks
+			if err := deployer.TriggerLifecycleCallbacks(r.Builder.Config); err != nil {
+				r.Logger.Errorf("[StepTriggerLifecycleCallbacks] trigger lifecycle callbacks error occurred: %s", err.Error())
+				errs <- err
+			}
+
+			// Clear previous Version
+			if err := deployer.CleanPreviousVersion(r.Builder.Config); err != nil {
+				r.Logger.Errorf("[StepCleanPreviousVersion] clean previous version error occurred: %s", err.Error())
+				errs <- err
+			}
+		}(d)
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	errFlag := checkError(errs)
+	if errFlag != nil {
+		return errFlag
+	}
+
+	errs = make(chan error)
+	for _, d := range deployers {
+		wg.Add(1)
+		go func(deployer deployer.DeployManager) {
+			defer wg.Done()
+			if err := deployer.CleanChecking(r.Builder.Config); err != nil {
+				r.Logger.Errorf("[StepCleanChecking] clean checking error occurred: %s", err.Error())
+				errs <- err
+			}
+		}(d)
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	errFlag = checkError(errs)
+	if errFlag != nil {
+		return errFlag
+	}
+	// gather metrics of previous version
+	errs = make(chan error)
+	for _, d := range deployers {
+		wg.Add(1)
+		go func(deployer deployer.DeployManager) {
+			defer wg.Done()
+			if err := deployer.GatherMetrics(r.Builder.Config); err != nil {
+				r.Logger.Errorf("[StepGatherMetrics] gather metrics error occurred: %s", err.Error())
+				errs <- err
+			}
+		}(d)
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	errFlag = checkError(errs)
+	if errFlag != nil {
+		return errFlag
+	}
+
+	return nil
+}
+
+// Status shows the detailed information about autoscaling deployment
+func (r Runner) Status() error {
+	inspector := inspector.NewWithClient(r.ClientFactory(r.Builder.Config.Region, constants.EmptyString))
+
+	asg, err := inspector.SelectStack(r.Builder.Config.Application)
+	if err != nil {
+		return err
+	}
+
+	group, err := inspector.GetStackInformation(asg)
+	if err != nil {
+		return err
+	}
+
+	launchTemplateInfo, err := inspector.GetLaunchTemplateInformation(*group.LaunchTemplate.LaunchTemplateId)
+	if err != nil {
+		return err
+	}
+
+	securityGroups, err := inspector.GetSecurityGroupsInformation(launchTemplateInfo.LaunchTemplateData.SecurityGroupIds)
+	if err != nil {
+		return err
+	}
+
+	inspector.StatusSummary = inspector.SetStatusSummary(group, securityGroups)
+
+	warmPool, warmPoolInstances, err := inspector.GetWarmPoolInformation(asg)
+	if err != nil {
+		return err
+	}
+	inspector.StatusSummary.WarmPool = inspector.SetWarmPoolSummary(warmPool, warmPoolInstances)
+
+	if err := inspector.Print(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Update will changes configuration of current deployment on live
+func (r Runner) Update() error {
+	var wg sync.WaitGroup
+	i := inspector.NewWithClient(r.ClientFactory(r.Builder.Config.Region, constants.EmptyString))
+
+	asg, err := i.SelectStack(r.Builder.Config.Application)
+	if err != nil {
+		return err
+	}
+
+	group, err := i.GetStackInformation(asg)
+	if err != nil {
+		return err
+	}
+
+	oldCapacity := makeCapacityStruct(*group.MinSize, *group.MaxSize, *group.DesiredCapacity)
+	newCapacity := makeCapacityStruct(nullCheck(r.Builder.Config.Min, oldCapacity.Min), nullCheck(r.Builder.Config.Max, oldCapacity.Max), nullCheck(r.Builder.Config.Desired, oldCapacity.Desired))
+	if err := CheckUpdateInformation(oldCapacity, newCapacity); err != nil {
+		return err
+	}
+	color.Cyan.Fprintln(os.Stdout, "[ AS IS ]")
+	color.Cyan.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", oldCapacity.Min, oldCapacity.Desired, oldCapacity.Max)
+	color.Green.Fprintln(os.Stdout, "[ TO BE ]")
+	color.Green.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", newCapacity.Min, newCapacity.Desired, newCapacity.Max)
+
+	if err := tool.LocalCheck("Do you really want to update? ", r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	if oldCapacity.Desired > newCapacity.Desired {
+		r.Logger.Debugf("downsizing operation is triggered")
+	}
+
+	i.UpdateFields = inspector.UpdateFields{
+		AutoscalingName: *group.AutoScalingGroupName,
+		Capacity:        newCapacity,
+	}
+
+	r.Logger.Debugf("start updating configuration")
+	if err := i.Update(); err != nil {
+		return err
+	}
+	r.Logger.Debugf("update configuration is triggered")
+
+	stack := i.GenerateStack(r.Builder.Config.Region, group)
+	r.Builder.Config.DownSizingUpdate = oldCapacity.Desired > newCapacity.Desired
+	r.Builder.Config.TargetAutoscalingGroup = i.UpdateFields.AutoscalingName
+	r.Builder.Config.ForceManifestCapacity = false
+
+	r.Logger.Debugf("create deployer for update")
+	deployers := []deployer.DeployManager{
+		getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.emitter(), r.Collector, r.ClientFactory),
+	}
+
+	// Health checking step
+	errs := make(chan error)
+	r.Logger.Debugf("Start health checking")
+	for _, d := range deployers {
+		wg.Add(1)
+		go func(deployer deployer.DeployManager) {
+			defer wg.Done()
+			if err := deployer.HealthChecking(r.Builder.Config); err != nil {
+				r.Logger.Errorf("[StepHealthCheck] check previous deployer error occurred: %s", err.Error())
+				errs <- err
+			}
+		}(d)
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	errFlag := checkError(errs)
+	if errFlag != nil {
+		return errFlag
+	}
+
+	r.Logger.Debugf("Health check process is done")
+	r.Logger.Infof("update operation is finished")
+	return nil
+}
+
+// Refresh will refresh autoscaling group instances
+func (r Runner) Refresh() error {
+	i := inspector.NewWithClient(r.ClientFactory(r.Builder.Config.Region, constants.EmptyString))
+
+	asg, err := i.SelectStack(r.Builder.Config.Application)
+	if err != nil {
+		return err
+	}
+	r.Logger.Debugf("Selected target autoscaling group: %s", asg)
+
+	group, err := i.GetStackInformation(asg)
+	if err != nil {
+		return err
+	}
+
+	r.Logger.Debugf("Autoscaling group found: %s", *group.AutoScalingGroupARN)
+
+	if err := tool.LocalCheck("Do you really want to refresh? ", r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	r.Logger.Debug("Create a new refresher")
+	refresher := refresh.NewWithClient(r.ClientFactory(r.Builder.Config.Region, constants.EmptyString))
+	refresher.SetTarget(group)
+
+	input := make(chan error)
+
+	go func(ch chan error) {
+		ch <- refresher.StartRefresh(r.Builder.Config.InstanceWarmup, r.Builder.Config.MinHealthyPercentage)
+	}(input)
+	time.Sleep(3 * time.Second)
+
+	if err := refresher.StatusCheck(r.Builder.Config.PollingInterval, r.Builder.Config.Timeout); err != nil {
+		return err
+	}
+
+	if err := refresher.PrintResult(); err != nil {
+		return err
+	}
+
+	if err := <-input; err != nil {
+		r.Logger.Warn(err.Error())
+	}
+
+	r.Logger.Infof("Refresh operation is finished")
+	return nil
+}
+
+// Rollback is the main function of `goployer rollback`
+func (r Runner) Rollback() error {
+	if len(r.Builder.Config.Stack) == 0 {
+		return errors.New("you have to specify the stack to roll back with --stack")
+	}
+
+	var target *schemas.Stack
+	for i, stack := range r.Builder.Stacks {
+		if stack.Stack == r.Builder.Config.Stack {
+			target = &r.Builder.Stacks[i]
+			break
+		}
+	}
+
+	if target == nil {
+		return fmt.Errorf("stack does not exist in the manifest: %s", r.Builder.Config.Stack)
+	}
+
+	if err := tool.LocalCheck("Do you really want to roll back this application? ", r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	// rollback changes the same autoscaling groups as deployment, so it waits for nobody else to deploy them
+	lease, err := r.acquireLocks([]schemas.Stack{*target}, checkpoint.NewDeploymentID(r.Builder.AwsConfig.Name, time.Now()))
+	if err != nil {
+		return err
+	}
+	defer r.releaseLocks(lease)
+	r.Lease = lease
+
+	r.Logger.Infof("Beginning rollback: %s", r.Builder.AwsConfig.Name)
+
+	if r.Builder.MetricConfig.Enabled {
+		if err := r.CheckEnabledMetrics(); err != nil {
+			return err
+		}
+	}
+
+	d := getDeployer(r.Logger, *target, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.emitter(), r.Collector, r.ClientFactory).GetDeployer()
+
+	steps := []func(schemas.Config) error{
+		d.CheckPrevious,
+		d.Restore,
+		d.WaitForRestoredVersion,
+		d.DoCommonAdditionalWork,
+		d.DrainPreviousVersions,
+	}
+
+	for _, step := range steps {
+		if err := r.checkLease(); err != nil {
+			return err
+		}
+
+		if err := step(r.Builder.Config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resume is the main function of `goployer resume`
+func (r Runner) Resume() error {
+	if r.CheckpointStore == nil {
+		return errors.New("no checkpoint storage is configured")
+	}
+
+	cp, err := r.CheckpointStore.Load(r.Builder.Config.DeploymentID)
+	if err != nil {
+		return err
+	}
+
+	if cp.Status == constants.CheckpointCompleted {
+		return fmt.Errorf("deployment is already completed: %s", cp.ID)
+	}
+
+	// timeout is counted again from now
+	config := cp.Config
+	config.StartTimestamp = time.Now().Unix()
+	config.AutoApply = r.Builder.Config.AutoApply
+	config.SlackOff = config.SlackOff || r.Builder.Config.SlackOff
+	config.DisableMetrics = config.DisableMetrics || r.Builder.Config.DisableMetrics
+
+	r.Builder.Config = config
+	r.Builder.AwsConfig = cp.AwsConfig
+	r.Builder.APITestTemplates = cp.APITestTemplates
+
+	if err := tool.LocalCheck(fmt.Sprintf("Do you really want to resume the deployment %s? ", cp.ID), config.AutoApply); err != nil {
+		return err
+	}
+
+	var stacks []schemas.Stack
+	for _, s := range cp.Stacks {
+		stacks = append(stacks, s.Stack)
+	}
+
+	lease, err := r.acquireLocks(stacks, cp.ID)
+	if err != nil {
+		return err
+	}
+	defer r.releaseLocks(lease)
+	r.Lease = lease
+
+	r.Logger.Infof("Resuming deployment: %s", cp.ID)
+	notifiers, err := r.notifierSinks(stacks)
+	if err != nil {
+		return err
+	}
+	r.Events = r.emitter(notifiers...)
+	r.Events.Emit(event.Event{Type: event.DeploymentStarted, Stack: config.Stack, Region: config.Region, Message: fmt.Sprintf("Resuming deployment: %s", cp.ID)})
+
+	if r.Builder.MetricConfig.Enabled && !config.DisableMetrics {
+		if err := r.CheckEnabledMetrics(); err != nil {
+			return err
+		}
+	}
+
+	var deployers []deployer.DeployManager
+	for _, s := range cp.Stacks {
+		if s.RolledBack {
+			r.Logger.Infof("Skipping the stack which is already rolled back, stack=%s", s.Stack.Stack)
+			continue
+		}
+
+		r.Logger.Infof("Resume the stack after the step %d, stack=%s", s.LastStep(), s.Stack.Stack)
+		d := getDeployer(r.Logger, s.Stack, cp.AwsConfig, cp.APITestTemplates, config.Region, r.emitter(), r.Collector, r.ClientFactory)
+		if err := d.RestoreCheckpoint(s); err != nil {
+			return err
+		}
+		deployers = append(deployers, d)
+	}
+
+	cp.Status = constants.CheckpointRunning
+	return r.runDeployers(deployers, newCheckpointRecorder(r.CheckpointStore, r.Logger, *cp))
+}
+
+// Unlock is the main function of `goployer unlock`
+func (r Runner) Unlock() error {
+	if r.LockStore == nil {
+		return errors.New("no lock storage is configured")
+	}
+
+	key := r.Builder.Config.LockKey
+	current, err := r.LockStore.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return fmt.Errorf("lock does not exist: %s", key)
+	}
+
+	if !current.Expired(time.Now()) && !r.Builder.Config.Force {
+		return fmt.Errorf("lock is held by %s until %s. use --force to release it anyway: %s", current.Holder, current.ExpiresAt.Format(time.RFC3339), key)
+	}
+
+	if err := tool.LocalCheck(fmt.Sprintf("Do you really want to release the lock held by %s? ", current.Holder), r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	if err := r.LockStore.Release(key, constants.EmptyString); err != nil {
+		return err
+	}
+
+	r.Logger.Infof("Lock is released: %s", key)
+	return nil
+}
+
+// acquireLocks takes locks of autoscaling group prefixes which the deployment is going to change
+func (r Runner) acquireLocks(stacks []schemas.Stack, deploymentID string) (*lock.Lease, error) {
+	if r.LockStore == nil {
+		return nil, nil
+	}
+
+	keys := lockKeys(r.Builder.AwsConfig.Name, stacks, r.Builder.Config.Stack, r.Builder.Config.Region)
+	return lock.Acquire(r.LockStore, keys, lock.NewHolder(deploymentID), constants.DefaultLockTTL, r.Logger)
+}
+
+// checkLease returns an error if locks of deployment are lost, so that the deployment stops before changing anything else
+func (r Runner) checkLease() error {
+	if err := r.Lease.Err(); err != nil {
+		r.Logger.Errorf("deployment stops because another deployment can change the same autoscaling groups: %s", err.Error())
 		return err
 	}
 
-	group, err := i.GetStackInformation(asg)
+	return nil
+}
+
+// releaseLocks releases locks of deployment
+func (r Runner) releaseLocks(lease *lock.Lease) {
+	if err := lease.Release(); err != nil {
+		r.Logger.Errorf("cannot release the lock of deployment: %s", err.Error())
+	}
+}
+
+// lockKeys returns sorted prefixes of autoscaling groups in stacks and regions to deploy
+func lockKeys(name string, stacks []schemas.Stack, selectedStack, selectedRegion string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, stack := range stacks {
+		if len(selectedStack) > 0 && stack.Stack != selectedStack {
+			continue
+		}
+
+		for _, region := range stack.Regions {
+			if len(selectedRegion) > 0 && region.Region != selectedRegion {
+				continue
+			}
+
+			key := tool.BuildPrefixName(name, stack.Env, region.Region)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Plan is the main function of `goployer plan`
+func (r Runner) Plan() error {
+	format := r.Builder.Config.Output
+	if len(format) == 0 {
+		format = constants.TextOutput
+	}
+
+	if !tool.IsStringInArray(format, []string{constants.TextOutput, constants.JSONOutput}) {
+		return fmt.Errorf("output format is not supported: %s", format)
+	}
+
+	plans, err := r.MakePlans()
 	if err != nil {
 		return err
 	}
 
-	oldCapacity := makeCapacityStruct(*group.MinSize, *group.MaxSize, *group.DesiredCapacity)
-	newCapacity := makeCapacityStruct(nullCheck(r.Builder.Config.Min, oldCapacity.Min), nullCheck(r.Builder.Config.Max, oldCapacity.Max), nullCheck(r.Builder.Config.Desired, oldCapacity.Desired))
-	if err := CheckUpdateInformation(oldCapacity, newCapacity); err != nil {
-		return err
+	return printPlans(os.Stdout, format, plans)
+}
+
+// MakePlans makes deployment plans of selected stacks without creating any resources
+func (r Runner) MakePlans() ([]deployer.DeploymentPlan, error) {
+	var plans []deployer.DeploymentPlan
+	for _, stack := range r.Builder.Stacks {
+		if r.Builder.Config.Stack != "" && stack.Stack != r.Builder.Config.Stack {
+			r.Logger.Debugf("Skipping this stack, stack=%s", stack.Stack)
+			continue
+		}
+
+		d := getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.emitter(), r.Collector, r.ClientFactory)
+		stackPlans, err := d.GetDeployer().Plan(r.Builder.Config)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, stackPlans...)
 	}
-	color.Cyan.Fprintln(os.Stdout, "[ AS IS ]")
-	color.Cyan.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", oldCapacity.Min, oldCapacity.Desired, oldCapacity.Max)
-	color.Green.Fprintln(os.Stdout, "[ TO BE ]")
-	color.Green.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", newCapacity.Min, newCapacity.Desired, newCapacity.Max)
 
-	if err := tool.LocalCheck("Do you really want to update? ", r.Builder.Config.AutoApply); err != nil {
+	return plans, nil
+}
+
+// printPlans writes deployment plans with the given format
+func printPlans(out io.Writer, format string, plans []deployer.DeploymentPlan) error {
+	if format == constants.JSONOutput {
+		if plans == nil {
+			plans = []deployer.DeploymentPlan{}
+		}
+		b, err := json.MarshalIndent(plans, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(b))
 		return err
 	}
 
-	if oldCapacity.Desired > newCapacity.Desired {
-		r.Logger.Debugf("downsizing operation is triggered")
+	var data = struct {
+		Plans []deployer.DeploymentPlan
+	}{
+		Plans: plans,
 	}
 
-	i.UpdateFields = inspector.UpdateFields{
-		AutoscalingName: *group.AutoScalingGroupName,
-		Capacity:        newCapacity,
+	funcMap := template.FuncMap{
+		"decorate":   tool.DecorateAttr,
+		"joinString": tool.JoinString,
 	}
 
-	r.Logger.Debugf("start updating configuration")
-	if err := i.Update(); err != nil {
+	w := tabwriter.NewWriter(out, 0, 5, 3, ' ', tabwriter.TabIndent)
+	t := template.Must(template.New("Deployment plan").Funcs(funcMap).Parse(templates.DeploymentPlanTemplate))
+
+	if err := t.Execute(w, data); err != nil {
 		return err
 	}
-	r.Logger.Debugf("update configuration is triggered")
-
-	stack := i.GenerateStack(r.Builder.Config.Region, group)
-	r.Builder.Config.DownSizingUpdate = oldCapacity.Desired > newCapacity.Desired
-	r.Builder.Config.TargetAutoscalingGroup = i.UpdateFields.AutoscalingName
-	r.Builder.Config.ForceManifestCapacity = false
+	return w.Flush()
+}
 
-	r.Logger.Debugf("create deployer for update")
-	deployers := []deployer.DeployManager{
-		getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Slacker, r.Collector),
+// printRegionResults writes results of regions in sequential region rollout
+func (r Runner) printRegionResults(results *regionRecorder) {
+	if len(results.results) == 0 {
+		return
 	}
 
-	// Health checking step
-	errs := make(chan error)
-	r.Logger.Debugf("Start health checking")
-	for _, d := range deployers {
-		wg.Add(1)
-		go func(deployer deployer.DeployManager) {
-			defer wg.Done()
-			if err := deployer.HealthChecking(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepHealthCheck] check previous deployer error occurred: %s", err.Error())
-				errs <- err
-			}
-		}(d)
+	var data = struct {
+		Results []regionResult
+	}{
+		Results: results.results,
 	}
-	go func() {
-		wg.Wait()
-		close(errs)
-	}()
-	errFlag := checkError(errs)
-	if errFlag != nil {
-		return errFlag
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 5, 3, ' ', tabwriter.TabIndent)
+	t := template.Must(template.New("Region rollout result").Parse(templates.RegionRolloutResultTemplate))
+
+	if err := t.Execute(w, data); err != nil {
+		r.Logger.Errorf("cannot print results of region rollout: %s", err.Error())
+		return
 	}
 
-	r.Logger.Debugf("Health check process is done")
-	r.Logger.Infof("update operation is finished")
-	return nil
+	if err := w.Flush(); err != nil {
+		r.Logger.Errorf("cannot print results of region rollout: %s", err.Error())
+	}
 }
 
-// Refresh will refresh autoscaling group instances
-func (r Runner) Refresh() error {
-	i := inspector.New(r.Builder.Config.Region)
-
-	asg, err := i.SelectStack(r.Builder.Config.Application)
-	if err != nil {
-		return err
+// newStackDeployers creates deployers of the stack.
+// A stack with sequential region rollout has a deployer per region.
+func (r Runner) newStackDeployers(stack schemas.Stack) []deployer.DeployManager {
+	if stack.RegionRollout != constants.SequentialRollout || len(r.Builder.Config.Region) > 0 || len(stack.Regions) < 2 {
+		return []deployer.DeployManager{getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.emitter(), r.Collector, r.ClientFactory)}
 	}
-	r.Logger.Debugf("Selected target autoscaling group: %s", asg)
 
-	group, err := i.GetStackInformation(asg)
-	if err != nil {
-		return err
+	var ret []deployer.DeployManager
+	for _, region := range stack.Regions {
+		s := stack
+		s.Regions = []schemas.RegionConfig{region}
+		ret = append(ret, getDeployer(r.Logger, s, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.emitter(), r.Collector, r.ClientFactory))
 	}
+	return ret
+}
 
-	r.Logger.Debugf("Autoscaling group found: %s", *group.AutoScalingGroupARN)
+// emitter returns the emitter of runner, or creates one which sends events to slack, sinks in manifest and notifiers
+func (r Runner) emitter(notifiers ...event.NotifierSink) *event.Emitter {
+	if r.Events != nil {
+		return r.Events
+	}
 
-	if err := tool.LocalCheck("Do you really want to refresh? ", r.Builder.Config.AutoApply); err != nil {
-		return err
+	sinks := []event.Sink{event.NotifierSink{Notifier: r.Slacker}}
+	for _, config := range r.Builder.AwsConfig.EventSinks {
+		sink, err := event.NewSink(config)
+		if err != nil {
+			r.Logger.Warn(err.Error())
+			continue
+		}
+		sinks = append(sinks, sink)
 	}
 
-	r.Logger.Debug("Create a new refresher")
-	refresher := refresh.New(r.Builder.Config.Region)
-	refresher.SetTarget(group)
+	for _, n := range notifiers {
+		sinks = append(sinks, n)
+	}
 
-	input := make(chan error)
+	return event.NewEmitter(r.Logger, r.Builder.AwsConfig.Name, sinks...)
+}
 
-	go func(ch chan error) {
-		ch <- refresher.StartRefresh(r.Builder.Config.InstanceWarmup, r.Builder.Config.MinHealthyPercentage)
-	}(input)
-	time.Sleep(3 * time.Second)
+// notifierSinks creates sinks of notifiers in manifest and stacks
+func (r Runner) notifierSinks(stacks []schemas.Stack) ([]event.NotifierSink, error) {
+	var sinks []event.NotifierSink
+	add := func(config schemas.Notifier, stack string) error {
+		n, err := notifier.New(config)
+		if err != nil {
+			return err
+		}
 
-	if err := refresher.StatusCheck(r.Builder.Config.PollingInterval, r.Builder.Config.Timeout); err != nil {
-		return err
+		sink, err := event.NewNotifierSink(n, stack, event.NotifierEvents(config))
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+		return nil
 	}
 
-	if err := refresher.PrintResult(); err != nil {
-		return err
+	for _, config := range r.Builder.AwsConfig.Notifiers {
+		if err := add(config, ""); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := <-input; err != nil {
-		r.Logger.Warn(err.Error())
+	for _, s := range stacks {
+		for _, config := range s.Notifiers {
+			if err := add(config, s.Stack); err != nil {
+				return nil, fmt.Errorf("%s: %s", err.Error(), s.Stack)
+			}
+		}
 	}
 
-	r.Logger.Infof("Refresh operation is finished")
-	return nil
+	return sinks, nil
+}
+
+// emitStep emits an event of the step of stack
+func (r Runner) emitStep(t event.Type, step string, d deployer.DeployManager, err error) {
+	e := event.Event{Type: t, Stack: d.GetDeployer().Stack.Stack, Step: step}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.Events.Emit(e)
 }
 
 // Generate new deployer
-func getDeployer(logger *Logger.Logger, stack schemas.Stack, awsConfig schemas.AWSConfig, apiTestTemplates []*schemas.APITestTemplate, region string, slack slack.Slack, c collector.Collector) deployer.DeployManager {
+func getDeployer(logger *Logger.Logger, stack schemas.Stack, awsConfig schemas.AWSConfig, apiTestTemplates []*schemas.APITestTemplate, region string, events *event.Emitter, c collector.Collector, factory aws.ClientFactory) deployer.DeployManager {
 	var att *schemas.APITestTemplate
 	if stack.APITestEnabled {
 		for _, at := range apiTestTemplates {
@@ -717,8 +1707,9 @@ func getDeployer(logger *Logger.Logger, stack schemas.Stack, awsConfig schemas.A
 		AwsConfig:        awsConfig,
 		APITestTemplates: att,
 		Region:           region,
-		Slack:            slack,
+		Events:           events,
 		Collector:        c,
+		ClientFactory:    factory,
 	}
 
 	var d deployer.DeployManager
@@ -731,6 +1722,8 @@ func getDeployer(logger *Logger.Logger, stack schemas.Stack, awsConfig schemas.A
 		d = deployer.NewRollingUpdate(&h)
 	case constants.DeployOnly:
 		d = deployer.NewDeployOnly(&h)
+	case constants.RefreshDeployment:
+		d = deployer.NewRefresh(&h)
 	}
 
 	return d
@@ -770,7 +1763,7 @@ func askApplicationName() (string, error) {
 
 // checkBuilderConfigurationNeeded checks if mode needs configuration settings like builder, metrics etc
 func checkBuilderConfigurationNeeded(mode string) bool {
-	return tool.IsStringInArray(mode, []string{"deploy", "delete"})
+	return tool.IsStringInArray(mode, []string{"deploy", "delete", "plan", "rollback"})
 }
 
 // CheckUpdateInformation checks if updated information is valid or not
@@ -821,3 +1814,333 @@ func checkError(errs chan error) error {
 	}
 	return nil
 }
+
+// rollbackRecorder keeps stacks which are rolled back during deployment
+type rollbackRecorder struct {
+	mu     sync.Mutex
+	stacks []string
+	errs   []error
+}
+
+func newRollbackRecorder() *rollbackRecorder {
+	return &rollbackRecorder{}
+}
+
+// add records a rolled back stack with the reason
+func (rr *rollbackRecorder) add(stack string, err error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.stacks = append(rr.stacks, stack)
+	rr.errs = append(rr.errs, fmt.Errorf("[%s] %s", stack, err.Error()))
+}
+
+// filter removes rolled back stacks from deployers for the next steps
+func (rr *rollbackRecorder) filter(deployers []deployer.DeployManager) []deployer.DeployManager {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	var ret []deployer.DeployManager
+	for _, d := range deployers {
+		if !tool.IsStringInArray(d.GetDeployer().Stack.Stack, rr.stacks) {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// rolledBack returns stacks which are rolled back
+func (rr *rollbackRecorder) rolledBack() []string {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	return append([]string{}, rr.stacks...)
+}
+
+// cause returns the reason why the stack is rolled back
+func (rr *rollbackRecorder) cause(stack string) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	for i, s := range rr.stacks {
+		if s == stack {
+			return rr.errs[i]
+		}
+	}
+	return nil
+}
+
+// err returns an error if any stack is rolled back
+func (rr *rollbackRecorder) err() error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	return errors.Join(rr.errs...)
+}
+
+// dependencyRecorder keeps stacks which failed so that stacks depending on them are not deployed
+type dependencyRecorder struct {
+	mu           sync.Mutex
+	dependencies map[string][]string
+	failed       map[string]string
+	errs         []error
+}
+
+func newDependencyRecorder(deployers []deployer.DeployManager) *dependencyRecorder {
+	var stacks []schemas.Stack
+	for _, d := range deployers {
+		stacks = append(stacks, d.GetDeployer().Stack)
+	}
+
+	return &dependencyRecorder{
+		dependencies: builder.StackDependencies(stacks),
+		failed:       map[string]string{},
+	}
+}
+
+// fail records a stack which failed with the reason
+func (dr *dependencyRecorder) fail(stack, reason string) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	dr.failed[stack] = reason
+}
+
+// failure returns the reason why the stack failed
+func (dr *dependencyRecorder) failure(stack string) (string, bool) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	reason, ok := dr.failed[stack]
+	return reason, ok
+}
+
+// abort records a stack whose new version could not be deployed
+func (dr *dependencyRecorder) abort(stack string, err error) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	dr.failed[stack] = err.Error()
+	dr.errs = append(dr.errs, fmt.Errorf("[%s] not deployed: %s", stack, err.Error()))
+}
+
+// skip records a stack which is not deployed because of the failed dependency
+func (dr *dependencyRecorder) skip(stack, dependency string) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	dr.failed[stack] = fmt.Sprintf("dependency failed: %s", dependency)
+	dr.errs = append(dr.errs, fmt.Errorf("[%s] not deployed because dependency failed: %s", stack, dependency))
+}
+
+// failedDependency returns the first dependency of stack which failed
+func (dr *dependencyRecorder) failedDependency(stack string) string {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	for _, d := range dr.dependencies[stack] {
+		if _, ok := dr.failed[d]; ok {
+			return d
+		}
+	}
+	return ""
+}
+
+// err returns an error if any stack is not deployed
+func (dr *dependencyRecorder) err() error {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	return errors.Join(dr.errs...)
+}
+
+// regionResult is the result of a region in sequential region rollout
+type regionResult struct {
+	Stack  string
+	Region string
+	Status string
+	Reason string
+}
+
+// regionRecorder keeps results of regions in sequential region rollout
+type regionRecorder struct {
+	results []regionResult
+	errs    []error
+}
+
+func newRegionRecorder() *regionRecorder {
+	return &regionRecorder{}
+}
+
+// record keeps results of the pass
+func (rr *regionRecorder) record(deployers []deployer.DeployManager, rollback *rollbackRecorder, dependencies *dependencyRecorder) {
+	for _, d := range deployers {
+		stack := d.GetDeployer().Stack.Stack
+		if cause := rollback.cause(stack); cause != nil {
+			rr.add(d, constants.RegionRolledBack, cause.Error())
+		} else if reason, failed := dependencies.failure(stack); failed {
+			rr.add(d, constants.RegionFailed, reason)
+		} else {
+			rr.add(d, constants.RegionSucceeded, "")
+		}
+	}
+}
+
+// fail changes the result of deployed region to failure
+func (rr *regionRecorder) fail(d deployer.DeployManager, err error) {
+	stack := d.GetDeployer().Stack
+	region := strings.Join(regionNames(d), ", ")
+	for i, result := range rr.results {
+		if result.Stack == stack.Stack && result.Region == region {
+			rr.results[i].Status = constants.RegionFailed
+			rr.results[i].Reason = err.Error()
+		}
+	}
+	rr.errs = append(rr.errs, fmt.Errorf("[%s] %s: %s", stack.Stack, region, err.Error()))
+}
+
+// halt records a region which is not deployed
+func (rr *regionRecorder) halt(d deployer.DeployManager, reason string) {
+	rr.add(d, constants.RegionHalted, reason)
+	rr.errs = append(rr.errs, fmt.Errorf("[%s] %s is not deployed because %s", d.GetDeployer().Stack.Stack, strings.Join(regionNames(d), ", "), reason))
+}
+
+// abort records the regions of passes which are not finished because the deployment is stopped
+func (rr *regionRecorder) abort(passes [][]deployer.DeployManager, err error) {
+	for i, pass := range passes {
+		for _, d := range pass {
+			if i == 0 {
+				rr.add(d, constants.RegionFailed, err.Error())
+			} else {
+				rr.add(d, constants.RegionHalted, "deployment is stopped")
+			}
+		}
+	}
+}
+
+// add appends the result of the region if its stack is deployed sequentially
+func (rr *regionRecorder) add(d deployer.DeployManager, status, reason string) {
+	stack := d.GetDeployer().Stack
+	if stack.RegionRollout != constants.SequentialRollout {
+		return
+	}
+
+	rr.results = append(rr.results, regionResult{
+		Stack:  stack.Stack,
+		Region: strings.Join(regionNames(d), ", "),
+		Status: status,
+		Reason: reason,
+	})
+}
+
+// err returns an error if any region failed the gate or is not deployed
+func (rr *regionRecorder) err() error {
+	return errors.Join(rr.errs...)
+}
+
+// checkpointRecorder persists the progress of deployers so that the deployment can be resumed
+type checkpointRecorder struct {
+	mu         sync.Mutex
+	store      checkpoint.Store
+	logger     *Logger.Logger
+	deployment checkpoint.Deployment
+}
+
+func newCheckpointRecorder(store checkpoint.Store, logger *Logger.Logger, deployment checkpoint.Deployment) *checkpointRecorder {
+	if len(deployment.Status) == 0 {
+		deployment.Status = constants.CheckpointRunning
+	}
+
+	return &checkpointRecorder{
+		store:      store,
+		logger:     logger,
+		deployment: deployment,
+	}
+}
+
+// id returns the deployment id of checkpoint
+func (cr *checkpointRecorder) id() string {
+	return cr.deployment.ID
+}
+
+// record saves the current progress of the deployer
+func (cr *checkpointRecorder) record(d deployer.DeployManager) {
+	s := d.Checkpoint()
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	replaced := false
+	for i, old := range cr.deployment.Stacks {
+		if sameCheckpointStack(old, s) {
+			s.RolledBack = old.RolledBack
+			cr.deployment.Stacks[i] = s
+			replaced = true
+			break
+		}
+	}
+
+	if !replaced {
+		cr.deployment.Stacks = append(cr.deployment.Stacks, s)
+	}
+
+	cr.save()
+}
+
+// sameCheckpointStack checks whether both checkpoints are of the same deployer.
+// A stack with sequential region rollout has a deployer per region.
+func sameCheckpointStack(a, b checkpoint.Stack) bool {
+	if a.Stack.Stack != b.Stack.Stack || len(a.Stack.Regions) != len(b.Stack.Regions) {
+		return false
+	}
+
+	for i := range a.Stack.Regions {
+		if a.Stack.Regions[i].Region != b.Stack.Regions[i].Region {
+			return false
+		}
+	}
+	return true
+}
+
+// rolledBack marks stacks as rolled back so that they are not resumed
+func (cr *checkpointRecorder) rolledBack(stacks []string) {
+	if len(stacks) == 0 {
+		return
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	for i, s := range cr.deployment.Stacks {
+		if tool.IsStringInArray(s.Stack.Stack, stacks) {
+			cr.deployment.Stacks[i].RolledBack = true
+		}
+	}
+
+	cr.save()
+}
+
+// finish saves the final status of the deployment
+func (cr *checkpointRecorder) finish(status string) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.deployment.Status = status
+	cr.save()
+
+	if status == constants.CheckpointFailed && cr.store != nil {
+		cr.logger.Warnf("You can resume the deployment with `goployer resume %s`", cr.deployment.ID)
+	}
+}
+
+// save writes the checkpoint to the store. Failure of saving does not stop the deployment.
+func (cr *checkpointRecorder) save() {
+	if cr.store == nil {
+		return
+	}
+
+	cr.deployment.UpdatedAt = time.Now()
+	if err := cr.store.Save(cr.deployment); err != nil {
+		cr.logger.Warnf("checkpoint cannot be saved: %s", err.Error())
+	}
+}
@@ -17,15 +17,33 @@ limitations under the license.
 package runner
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-test/deep"
+	Logger "github.com/sirupsen/logrus"
 
+	"github.com/DevopsArtFactory/goployer/pkg/approval"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/checkpoint"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/event"
+	"github.com/DevopsArtFactory/goployer/pkg/lock"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 )
 
@@ -118,3 +136,1486 @@ func TestGoroutineError(t *testing.T) {
 		t.Errorf("validation error")
 	}
 }
+
+// newFakeRunner creates a runner whose aws clients are backed by the in-memory backend
+func newFakeRunner(t *testing.T, backend *fake.Backend, replacementType string) Runner {
+	dir, err := ioutil.TempDir("", "goployer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	userdata := filepath.Join(dir, "userdata.sh")
+	if err := ioutil.WriteFile(userdata, []byte("#!/bin/bash"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+
+	return Runner{
+		Logger: logger,
+		Builder: builder.Builder{
+			Config: schemas.Config{
+				Application:     "hello",
+				AutoApply:       true,
+				SlackOff:        true,
+				DisableMetrics:  true,
+				StartTimestamp:  time.Now().Unix(),
+				Timeout:         time.Minute,
+				PollingInterval: 10 * time.Millisecond,
+			},
+			AwsConfig: schemas.AWSConfig{
+				Name:     "hello",
+				Userdata: schemas.Userdata{Type: "local", Path: userdata},
+			},
+			Stacks: []schemas.Stack{
+				{
+					Stack:           "artd",
+					Env:             "dev",
+					ReplacementType: replacementType,
+					Capacity:        schemas.Capacity{Min: 2, Max: 2, Desired: 2},
+					Regions: []schemas.RegionConfig{
+						{
+							Region:                 backend.Region,
+							InstanceType:           "t3.medium",
+							AmiID:                  "ami-0000000000000001",
+							VPC:                    "dev",
+							SecurityGroups:         []string{"hello-dev"},
+							HealthcheckTargetGroup: "hello-dev-tg",
+							TargetGroups:           []string{"hello-dev-tg"},
+						},
+					},
+				},
+			},
+		},
+		ClientFactory: fake.ClientFactory(backend),
+	}
+}
+
+func TestRunner_DeployWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.PendingPolls = 2
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+
+	for _, expected := range []string{"hello-dev_apnortheast2-v000", "hello-dev_apnortheast2-v001"} {
+		if err := r.Deploy(); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{expected}); diff != nil {
+			t.Error(diff)
+		}
+
+		states := backend.TargetHealth("hello-dev-tg")
+		if len(states) != 2 {
+			t.Errorf("expected 2 targets in target group, got %d", len(states))
+		}
+		for id, state := range states {
+			if state != "healthy" {
+				t.Errorf("target is not healthy: %s(%s)", id, state)
+			}
+		}
+	}
+
+	lts := backend.LaunchTemplateNames()
+	if len(lts) != 1 || !strings.HasPrefix(lts[0], "hello-dev_apnortheast2-v001") {
+		t.Errorf("previous launch templates are not deleted: %v", lts)
+	}
+
+	if err := r.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := backend.AutoScalingGroupNames(); len(names) != 0 {
+		t.Errorf("autoscaling groups are not deleted: %v", names)
+	}
+}
+
+func TestRunner_RollbackOnFailureWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.UnhealthyImages = []string{"ami-0000000000000002"}
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Ami = "ami-0000000000000002"
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Config.Timeout = 2 * time.Second
+	r.Builder.Stacks[0].RollbackOnFailure = true
+
+	err := r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("deployment should fail with rollback: %v", err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+
+	lts := backend.LaunchTemplateNames()
+	if len(lts) != 1 || !strings.HasPrefix(lts[0], "hello-dev_apnortheast2-v000") {
+		t.Errorf("launch template of new version is not deleted: %v", lts)
+	}
+
+	states := backend.TargetHealth("hello-dev-tg")
+	if len(states) != 2 {
+		t.Errorf("expected 2 targets of previous version, got %d", len(states))
+	}
+	for id, state := range states {
+		if state != "healthy" {
+			t.Errorf("target is not healthy: %s(%s)", id, state)
+		}
+	}
+}
+
+func TestRunner_UpdateWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v003", "ami-0000000000000001", schemas.Capacity{Min: 1, Max: 1, Desired: 1}, "hello-dev-tg")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Config.Min = 3
+	r.Builder.Config.Max = 3
+	r.Builder.Config.Desired = 3
+
+	if err := r.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	group := backend.AutoScalingGroup("hello-dev_apnortheast2-v003")
+	if *group.DesiredCapacity != 3 || len(group.Instances) != 3 {
+		t.Errorf("autoscaling group is not updated: desired %d, instances %d", *group.DesiredCapacity, len(group.Instances))
+	}
+}
+
+func TestRunner_PlanWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	tgARN := backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v003", "ami-0000000000000001", schemas.Capacity{Min: 1, Max: 1, Desired: 1}, "hello-dev-tg")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Ami = "ami-0000000000000002"
+	r.Builder.Config.Region = backend.Region
+
+	plans, err := r.MakePlans()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plans) != 1 {
+		t.Fatalf("expected 1 plan, got %d", len(plans))
+	}
+
+	plan := plans[0]
+	if plan.AutoscalingGroupName != "hello-dev_apnortheast2-v004" {
+		t.Errorf("unexpected autoscaling group name: %s", plan.AutoscalingGroupName)
+	}
+	if diff := deep.Equal(plan.PreviousVersions, []string{"hello-dev_apnortheast2-v003"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(plan.TargetGroupARNs, []string{tgARN}); diff != nil {
+		t.Error(diff)
+	}
+	if plan.LaunchTemplate.AMI != "ami-0000000000000002" {
+		t.Errorf("unexpected ami: %s", plan.LaunchTemplate.AMI)
+	}
+	if diff := deep.Equal(plan.Capacity, schemas.Capacity{Min: 2, Max: 2, Desired: 2}); diff != nil {
+		t.Error(diff)
+	}
+
+	// plan should not change anything
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v003"}); diff != nil {
+		t.Error(diff)
+	}
+	if len(backend.LaunchTemplateNames()) != 1 {
+		t.Errorf("launch template is created by plan: %v", backend.LaunchTemplateNames())
+	}
+
+	for _, format := range []string{constants.TextOutput, constants.JSONOutput} {
+		var out bytes.Buffer
+		if err := printPlans(&out, format, plans); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "hello-dev_apnortheast2-v004") {
+			t.Errorf("%s output does not contain new autoscaling group name: %s", format, out.String())
+		}
+	}
+}
+
+func TestRunner_RollbackWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 0, Max: 0, Desired: 0}, "hello-dev-tg")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v001", "ami-0000000000000002", schemas.Capacity{Min: 3, Max: 3, Desired: 3}, "hello-dev-tg")
+
+	store := lock.FileStore{Dir: t.TempDir()}
+	now := time.Now()
+	if err := store.Acquire(lock.Lock{Key: "hello-dev_apnortheast2", Holder: "ci-1", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Config.Stack = "artd"
+	r.LockStore = store
+
+	// rollback does not touch autoscaling groups while another deployment holds the lock
+	if err := r.Rollback(); !errors.Is(err, lock.ErrLocked) {
+		t.Fatalf("rollback should fail while locked: %v", err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000", "hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := store.Release("hello-dev_apnortheast2", constants.EmptyString); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if current, _ := store.Get("hello-dev_apnortheast2"); current != nil {
+		t.Errorf("lock is not released after rollback: %v", current)
+	}
+
+	group := backend.AutoScalingGroup("hello-dev_apnortheast2-v000")
+	if *group.DesiredCapacity != 3 || len(group.Instances) != 3 {
+		t.Errorf("previous version is not restored: desired %d, instances %d", *group.DesiredCapacity, len(group.Instances))
+	}
+}
+
+func TestRunner_RollbackToMissingVersion(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v001", "ami-0000000000000002", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Config.Stack = "artd"
+	r.Builder.Config.RollbackVersion = "v000"
+
+	if err := r.Rollback(); err == nil {
+		t.Fatal("rollback should fail without autoscaling group or deployment record")
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRunner_CanaryTrafficShiftingWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddLoadBalancer("hello-dev-alb", "hello-dev-tg")
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+	r := newFakeRunner(t, backend, constants.CanaryDeployment)
+	r.Builder.Config.Ami = "ami-0000000000000002"
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Stacks[0].CanaryTrafficShifting = &schemas.CanaryTrafficShifting{
+		Steps: []schemas.CanaryStep{
+			{Weight: 25, Pause: 10 * time.Millisecond},
+			{Weight: 50, Pause: 10 * time.Millisecond},
+		},
+	}
+
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]int64{
+		{"hello-dev-tg": 75, "hello-dev-canary-v001": 25},
+		{"hello-dev-tg": 50, "hello-dev-canary-v001": 50},
+		{"hello-dev-tg": 0, "hello-dev-canary-v001": 100},
+	}
+	if diff := deep.Equal(backend.WeightChanges("hello-dev-alb"), expected); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(backend.ListenerTargetGroups("hello-dev-alb"), []string{"hello-dev-tg"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+
+	group := backend.AutoScalingGroup("hello-dev_apnortheast2-v001")
+	if len(group.Instances) != 2 {
+		t.Errorf("new version should have the full capacity: %d", len(group.Instances))
+	}
+
+	for _, tag := range group.Tags {
+		if *tag.Key == constants.DeploymentTagKey {
+			t.Errorf("canary tag is not removed: %s", *tag.Value)
+		}
+	}
+
+	if states := backend.TargetHealth("hello-dev-tg"); len(states) != 2 {
+		t.Errorf("expected 2 targets of new version in original target group, got %d", len(states))
+	}
+}
+
+func TestRunner_CanaryTrafficShiftingListenerRuleWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddTargetGroup("hello-dev-default", 8080)
+	backend.AddLoadBalancer("hello-dev-alb", "hello-dev-default")
+	backend.AddListenerRule("hello-dev-alb", "hello-dev-tg")
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+	r := newFakeRunner(t, backend, constants.CanaryDeployment)
+	r.Builder.Config.Ami = "ami-0000000000000002"
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Stacks[0].CanaryTrafficShifting = &schemas.CanaryTrafficShifting{
+		Steps: []schemas.CanaryStep{
+			{Weight: 25, Pause: 10 * time.Millisecond},
+		},
+	}
+
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]int64{
+		{"hello-dev-tg": 75, "hello-dev-canary-v001": 25},
+		{"hello-dev-tg": 0, "hello-dev-canary-v001": 100},
+	}
+	if diff := deep.Equal(backend.WeightChanges("hello-dev-alb"), expected); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(backend.RuleTargetGroups("hello-dev-alb"), []string{"hello-dev-tg"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(backend.ListenerTargetGroups("hello-dev-alb"), []string{"hello-dev-default"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if states := backend.TargetHealth("hello-dev-tg"); len(states) != 2 {
+		t.Errorf("expected 2 targets of new version in original target group, got %d", len(states))
+	}
+}
+
+func TestRunner_CanaryAnalysisWithFakeBackend(t *testing.T) {
+	analysis := &schemas.CanaryAnalysis{
+		Metrics: []schemas.CanaryMetric{
+			{
+				Name:       "5xx",
+				Metric:     "HTTPCode_Target_5XX_Count",
+				Statistic:  "Sum",
+				Comparison: constants.AbsoluteComparison,
+				Threshold:  5,
+			},
+			{
+				Metric:     "TargetResponseTime",
+				Statistic:  "p99",
+				Comparison: constants.RatioComparison,
+				Threshold:  1.5,
+			},
+		},
+	}
+
+	testData := []struct {
+		name          string
+		canary5xx     float64
+		canaryLatency float64
+		expectedAsgs  []string
+		expectedErr   bool
+	}{
+		{
+			name:          "promoted",
+			canary5xx:     2,
+			canaryLatency: 0.12,
+			expectedAsgs:  []string{"hello-dev_apnortheast2-v001"},
+		},
+		{
+			name:          "aborted",
+			canary5xx:     2,
+			canaryLatency: 0.3,
+			expectedAsgs:  []string{"hello-dev_apnortheast2-v000"},
+			expectedErr:   true,
+		},
+	}
+
+	for _, td := range testData {
+		t.Run(td.name, func(t *testing.T) {
+			backend := fake.NewBackend("ap-northeast-2")
+			backend.AddTargetGroup("hello-dev-tg", 8080)
+			backend.AddLoadBalancer("hello-dev-alb", "hello-dev-tg")
+			backend.AddSecurityGroup("hello-dev")
+			backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+			backend.SetMetric("HTTPCode_Target_5XX_Count", "hello-dev-canary-v001", td.canary5xx)
+			backend.SetMetric("TargetResponseTime", "hello-dev-tg", 0.1)
+			backend.SetMetric("TargetResponseTime", "hello-dev-canary-v001", td.canaryLatency)
+
+			r := newFakeRunner(t, backend, constants.CanaryDeployment)
+			r.Builder.Config.Ami = "ami-0000000000000002"
+			r.Builder.Config.Region = backend.Region
+			r.Builder.Stacks[0].CanaryTrafficShifting = &schemas.CanaryTrafficShifting{
+				Steps: []schemas.CanaryStep{
+					{Weight: 25, Pause: 10 * time.Millisecond},
+				},
+				Analysis: analysis,
+			}
+
+			err := r.Deploy()
+			if td.expectedErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(backend.AutoScalingGroupNames(), td.expectedAsgs); diff != nil {
+				t.Error(diff)
+			}
+
+			if diff := deep.Equal(backend.ListenerTargetGroups("hello-dev-alb"), []string{"hello-dev-tg"}); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRunner_RollingUpdateWithSurgeWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 4, Max: 4, Desired: 4}, "hello-dev-tg")
+
+	r := newFakeRunner(t, backend, constants.RollingUpdateDeployment)
+	r.Builder.Config.Ami = "ami-0000000000000002"
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Stacks[0].Capacity = schemas.Capacity{Min: 4, Max: 4, Desired: 4}
+	r.Builder.Stacks[0].MaxSurge = "25%"
+	r.Builder.Stacks[0].MaxUnavailable = "1"
+
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if group := backend.AutoScalingGroup("hello-dev_apnortheast2-v001"); *group.DesiredCapacity != 4 || len(group.Instances) != 4 {
+		t.Errorf("new version should have the full capacity: %d", len(group.Instances))
+	}
+
+	if states := backend.TargetHealth("hello-dev-tg"); len(states) != 4 {
+		t.Errorf("expected 4 targets of new version, got %d", len(states))
+	}
+}
+
+func TestRunner_BlueGreenSwitchWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-blue", 8080)
+	backend.AddTargetGroup("hello-dev-green", 8080)
+	backend.AddLoadBalancer("hello-dev-alb", "hello-dev-blue")
+	backend.AddListenerRule("hello-dev-alb", "hello-dev-blue")
+	backend.SetListenerStickiness("hello-dev-alb", 300)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-blue")
+
+	idle := map[string]string{"hello-dev-green": "hello-dev-blue", "hello-dev-blue": "hello-dev-green"}
+	for _, active := range []string{"hello-dev-green", "hello-dev-blue"} {
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Config.Ami = "ami-0000000000000002"
+		r.Builder.Config.Region = backend.Region
+		r.Builder.Stacks[0].Regions[0].HealthcheckTargetGroup = ""
+		r.Builder.Stacks[0].Regions[0].TargetGroups = nil
+		r.Builder.Stacks[0].Regions[0].BlueGreenTargetGroups = []string{"hello-dev-blue", "hello-dev-green"}
+
+		if err := r.Deploy(); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := deep.Equal(backend.ListenerTargetGroups("hello-dev-alb"), []string{active}); diff != nil {
+			t.Error(diff)
+		}
+
+		if diff := deep.Equal(backend.RuleTargetGroups("hello-dev-alb"), []string{active}); diff != nil {
+			t.Error(diff)
+		}
+
+		// only the target group is switched in the default action of listener
+		for _, a := range backend.ListenerDefaultActions("hello-dev-alb") {
+			if a.ForwardConfig == nil || a.ForwardConfig.TargetGroupStickinessConfig == nil || *a.ForwardConfig.TargetGroupStickinessConfig.DurationSeconds != 300 {
+				t.Errorf("stickiness of listener is not kept: %v", a)
+			}
+		}
+
+		if states := backend.TargetHealth(active); len(states) != 2 {
+			t.Errorf("expected 2 targets of new version in %s, got %d", active, len(states))
+		}
+
+		if states := backend.TargetHealth(idle[active]); len(states) != 0 {
+			t.Errorf("previous version remains in %s: %v", idle[active], states)
+		}
+
+		if names := backend.AutoScalingGroupNames(); len(names) != 1 {
+			t.Errorf("previous version is not deleted: %v", names)
+		}
+	}
+}
+
+func TestRunner_ResumeWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-blue", 8080)
+	backend.AddTargetGroup("hello-dev-green", 8080)
+	backend.AddLoadBalancer("hello-dev-alb", "hello-dev-blue")
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-blue")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.CheckpointStore = checkpoint.FileStore{Dir: t.TempDir()}
+	r.Builder.Config.Ami = "ami-0000000000000002"
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Stacks[0].Regions[0].HealthcheckTargetGroup = ""
+	r.Builder.Stacks[0].Regions[0].TargetGroups = nil
+	r.Builder.Stacks[0].Regions[0].BlueGreenTargetGroups = []string{"hello-dev-blue", "hello-dev-green"}
+
+	// goployer stops right after the deploy step
+	d := getDeployer(r.Logger, r.Builder.Stacks[0], r.Builder.AwsConfig, nil, r.Builder.Config.Region, r.emitter(), r.Collector, r.ClientFactory)
+	if err := d.CheckPreviousResources(r.Builder.Config); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Deploy(r.Builder.Config); err != nil {
+		t.Fatal(err)
+	}
+	newCheckpointRecorder(r.CheckpointStore, r.Logger, checkpoint.Deployment{
+		ID:        "hello-test",
+		Config:    r.Builder.Config,
+		AwsConfig: r.Builder.AwsConfig,
+	}).record(d)
+
+	if diff := deep.Equal(backend.ListenerTargetGroups("hello-dev-alb"), []string{"hello-dev-blue"}); diff != nil {
+		t.Error(diff)
+	}
+
+	r.Builder.Stacks = nil
+	r.Builder.Config = schemas.Config{DeploymentID: "hello-test", AutoApply: true}
+	if err := r.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(backend.ListenerTargetGroups("hello-dev-alb"), []string{"hello-dev-green"}); diff != nil {
+		t.Error(diff)
+	}
+
+	cp, err := r.CheckpointStore.Load("hello-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Status != constants.CheckpointCompleted {
+		t.Errorf("checkpoint is not completed: %s", cp.Status)
+	}
+
+	if step := cp.Stacks[0].LastStep(); step != constants.StepCleanChecking {
+		t.Errorf("expected last step %d, got %d", constants.StepCleanChecking, step)
+	}
+
+	if err := r.Resume(); err == nil || !strings.Contains(err.Error(), "already completed") {
+		t.Errorf("completed deployment should not be resumed: %v", err)
+	}
+}
+
+func TestRunner_ApprovalWithFakeBackend(t *testing.T) {
+	for _, action := range []string{constants.ApproveAction, constants.RejectAction} {
+		backend := fake.NewBackend("ap-northeast-2")
+		backend.AddTargetGroup("hello-dev-tg", 8080)
+		backend.AddSecurityGroup("hello-dev")
+		backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Config.Ami = "ami-0000000000000002"
+		r.Builder.Config.Region = backend.Region
+		r.Builder.Stacks[0].Approval = &schemas.Approval{Method: constants.ServerApproval, Address: "127.0.0.1:0", Timeout: time.Minute}
+
+		go func(action string) {
+			for len(approval.Pending.List()) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			// both versions are up while waiting for approval
+			if names := backend.AutoScalingGroupNames(); len(names) != 2 {
+				t.Errorf("expected both versions during approval, got %v", names)
+			}
+
+			if err := approval.Pending.Resolve("hello/artd", action); err != nil {
+				t.Error(err)
+			}
+		}(action)
+
+		err := r.Deploy()
+		expected := "hello-dev_apnortheast2-v001"
+		if action == constants.RejectAction {
+			if err == nil || !strings.Contains(err.Error(), "new version is rejected") {
+				t.Errorf("rejected version should be rolled back: %v", err)
+			}
+			expected = "hello-dev_apnortheast2-v000"
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{expected}); diff != nil {
+			t.Errorf("%s: %v", action, diff)
+		}
+	}
+}
+
+func TestRunner_RefreshWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.UnhealthyImages = []string{"ami-0000000000000003"}
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+
+	r := newFakeRunner(t, backend, constants.RefreshDeployment)
+	r.Builder.Config.Region = backend.Region
+	r.Builder.Stacks[0].RollbackOnFailure = true
+	r.Builder.Stacks[0].InstanceRefresh = &schemas.InstanceRefresh{AutoRollback: true, SkipMatching: true}
+
+	images := func() []string {
+		group := backend.AutoScalingGroup("hello-dev_apnortheast2-v000")
+		var ids []*string
+		for _, instance := range group.Instances {
+			ids = append(ids, instance.InstanceId)
+		}
+
+		instances, err := backend.Client().EC2Service.DescribeInstances(ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ret []string
+		for _, instance := range instances {
+			ret = append(ret, *instance.ImageId)
+		}
+		return ret
+	}
+
+	for _, ami := range []string{"ami-0000000000000001", "ami-0000000000000002"} {
+		r.Builder.Config.Ami = ami
+		r.Builder.Config.StartTimestamp = time.Now().Unix()
+		if err := r.Deploy(); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+			t.Error(diff)
+		}
+
+		if diff := deep.Equal(images(), []string{ami, ami}); diff != nil {
+			t.Errorf("%s: %v", ami, diff)
+		}
+	}
+
+	if lts := backend.LaunchTemplateNames(); len(lts) != 1 || !strings.HasPrefix(lts[0], "hello-dev_apnortheast2-v000") {
+		t.Errorf("launch template should be kept: %v", lts)
+	}
+
+	r.Builder.Config.Ami = "ami-0000000000000003"
+	r.Builder.Config.StartTimestamp = time.Now().Unix()
+	err := r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("deployment should fail with rollback: %v", err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(images(), []string{"ami-0000000000000002", "ami-0000000000000002"}); diff != nil {
+		t.Errorf("previous image is not restored: %v", diff)
+	}
+}
+
+func TestRunner_BakeWithFakeBackend(t *testing.T) {
+	for _, alarm := range []bool{false, true} {
+		backend := fake.NewBackend("ap-northeast-2")
+		backend.AddTargetGroup("hello-dev-tg", 8080)
+		backend.AddSecurityGroup("hello-dev")
+		backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+		backend.SetAlarmState("hello-5xx", "OK")
+
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Config.Ami = "ami-0000000000000002"
+		r.Builder.Config.Region = backend.Region
+		r.Builder.Stacks[0].Bake = &schemas.Bake{Duration: 200 * time.Millisecond, Alarms: []string{"hello-5xx"}}
+
+		if alarm {
+			r.Builder.Stacks[0].Bake.Duration = 30 * time.Second
+			go func() {
+				// alarm goes off after the previous version is detached from traffic
+				for {
+					group := backend.AutoScalingGroup("hello-dev_apnortheast2-v000")
+					if group != nil && len(group.TargetGroupARNs) == 0 {
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+
+				if names := backend.AutoScalingGroupNames(); len(names) != 2 {
+					t.Errorf("expected both versions during bake period, got %v", names)
+				}
+				backend.SetAlarmState("hello-5xx", "ALARM")
+			}()
+		}
+
+		err := r.Deploy()
+		expected := "hello-dev_apnortheast2-v001"
+		if alarm {
+			if err == nil || !strings.Contains(err.Error(), "rolled back") {
+				t.Errorf("new version should be rolled back by bake alarm: %v", err)
+			}
+			expected = "hello-dev_apnortheast2-v000"
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{expected}); diff != nil {
+			t.Errorf("alarm %t: %v", alarm, diff)
+		}
+
+		if group := backend.AutoScalingGroup(expected); len(group.TargetGroupARNs) != 1 {
+			t.Errorf("alarm %t: %s should be attached to target group: %v", alarm, expected, group.TargetGroupARNs)
+		}
+
+		if health := backend.TargetHealth("hello-dev-tg"); len(health) != 2 {
+			t.Errorf("alarm %t: expected 2 targets, got %v", alarm, health)
+		}
+	}
+}
+
+func TestRunner_DependsOnWithFakeBackend(t *testing.T) {
+	for _, healthy := range []bool{true, false} {
+		backend := fake.NewBackend("ap-northeast-2")
+		backend.UnhealthyImages = []string{"ami-0000000000000002"}
+		backend.AddTargetGroup("hello-dev-tg", 8080)
+		backend.AddTargetGroup("hello-prod-tg", 8080)
+		backend.AddSecurityGroup("hello-dev")
+		backend.AddSecurityGroup("hello-prod")
+
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Config.Timeout = 2 * time.Second
+		r.Builder.Stacks[0].RollbackOnFailure = true
+
+		worker := r.Builder.Stacks[0]
+		worker.Stack = "worker"
+		worker.Env = "prod"
+		worker.DependsOn = []string{"artd"}
+		worker.Regions = []schemas.RegionConfig{worker.Regions[0]}
+		worker.Regions[0].SecurityGroups = []string{"hello-prod"}
+		worker.Regions[0].HealthcheckTargetGroup = "hello-prod-tg"
+		worker.Regions[0].TargetGroups = []string{"hello-prod-tg"}
+		r.Builder.Stacks = []schemas.Stack{worker, r.Builder.Stacks[0]}
+
+		expected := []string{"hello-dev_apnortheast2-v000", "hello-prod_apnortheast2-v000"}
+		if !healthy {
+			r.Builder.Stacks[1].Regions[0].AmiID = "ami-0000000000000002"
+			expected = nil
+		}
+
+		err := r.Deploy()
+		if healthy && err != nil {
+			t.Fatal(err)
+		}
+
+		if !healthy && (err == nil || !strings.Contains(err.Error(), "[worker] not deployed because dependency failed: artd")) {
+			t.Errorf("worker should not be deployed when artd fails: %v", err)
+		}
+
+		if diff := deep.Equal(backend.AutoScalingGroupNames(), expected); diff != nil {
+			t.Errorf("healthy %t: %v", healthy, diff)
+		}
+	}
+}
+
+func TestRunner_DeployFailureStopsDependentsWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+
+	// artd cannot be deployed without its security group, and only worker depends on it
+	newStack := func(name, env string, dependsOn ...string) schemas.Stack {
+		backend.AddTargetGroup(fmt.Sprintf("hello-%s-tg", env), 8080)
+		backend.AddSecurityGroup(fmt.Sprintf("hello-%s", env))
+
+		stack := r.Builder.Stacks[0]
+		stack.Stack = name
+		stack.Env = env
+		stack.DependsOn = dependsOn
+		stack.Regions = []schemas.RegionConfig{stack.Regions[0]}
+		stack.Regions[0].SecurityGroups = []string{fmt.Sprintf("hello-%s", env)}
+		stack.Regions[0].HealthcheckTargetGroup = fmt.Sprintf("hello-%s-tg", env)
+		stack.Regions[0].TargetGroups = []string{fmt.Sprintf("hello-%s-tg", env)}
+		return stack
+	}
+
+	artd := newStack("artd", "dev")
+	artd.Regions[0].SecurityGroups = []string{"hello-missing"}
+	r.Builder.Stacks = []schemas.Stack{
+		artd,
+		newStack("api", "qa"),
+		newStack("worker", "prod", "artd"),
+		newStack("batch", "stage", "api"),
+	}
+
+	err := r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "[artd] not deployed") || !strings.Contains(err.Error(), "[worker] not deployed because dependency failed: artd") {
+		t.Errorf("artd and worker should fail: %v", err)
+	}
+
+	expected := []string{"hello-qa_apnortheast2-v000", "hello-stage_apnortheast2-v000"}
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRunner_SequentialRegionRolloutWithFakeBackend(t *testing.T) {
+	testData := []struct {
+		title    string
+		setup    func(first *fake.Backend)
+		err      string
+		first    []string
+		second   []string
+		gateWith []string
+	}{
+		{
+			title:  "all regions",
+			first:  []string{"hello-dev_apnortheast2-v000"},
+			second: []string{"hello-dev_useast1-v000"},
+		},
+		{
+			title: "first region is rolled back",
+			setup: func(first *fake.Backend) {
+				first.UnhealthyImages = []string{"ami-0000000000000001"}
+			},
+			err: "[artd] us-east-1 is not deployed because previous region failed",
+		},
+		{
+			title: "gate alarm",
+			setup: func(first *fake.Backend) {
+				first.SetAlarmState("hello-5xx", "ALARM")
+			},
+			err:      "region gate is not passed: hello-5xx is in ALARM state in ap-northeast-2",
+			first:    []string{"hello-dev_apnortheast2-v000"},
+			gateWith: []string{"hello-5xx"},
+		},
+	}
+
+	for _, td := range testData {
+		first := fake.NewBackend("ap-northeast-2")
+		second := fake.NewBackend("us-east-1")
+		for _, backend := range []*fake.Backend{first, second} {
+			backend.AddTargetGroup("hello-dev-tg", 8080)
+			backend.AddSecurityGroup("hello-dev")
+		}
+
+		if td.setup != nil {
+			td.setup(first)
+		}
+
+		r := newFakeRunner(t, first, constants.BlueGreenDeployment)
+		r.ClientFactory = fake.ClientFactory(first, second)
+		r.Builder.Config.Timeout = 2 * time.Second
+
+		stack := &r.Builder.Stacks[0]
+		stack.RollbackOnFailure = true
+		stack.RegionRollout = constants.SequentialRollout
+		stack.PauseBetweenRegions = 10 * time.Millisecond
+		stack.RegionGate = &schemas.RegionGate{HealthCheck: true, Alarms: td.gateWith}
+
+		region := stack.Regions[0]
+		region.Region = second.Region
+		stack.Regions = append(stack.Regions, region)
+
+		err := r.Deploy()
+		if len(td.err) == 0 && err != nil {
+			t.Fatalf("%s: %s", td.title, err.Error())
+		}
+
+		if len(td.err) > 0 && (err == nil || !strings.Contains(err.Error(), td.err)) {
+			t.Errorf("%s: expected error %q, got %v", td.title, td.err, err)
+		}
+
+		if diff := deep.Equal(first.AutoScalingGroupNames(), td.first); diff != nil {
+			t.Errorf("%s: %v", td.title, diff)
+		}
+
+		if diff := deep.Equal(second.AutoScalingGroupNames(), td.second); diff != nil {
+			t.Errorf("%s: %v", td.title, diff)
+		}
+	}
+}
+
+func TestRunner_DeploymentLockWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+
+	store := lock.FileStore{Dir: t.TempDir()}
+	now := time.Now()
+	if err := store.Acquire(lock.Lock{Key: "hello-dev_apnortheast2", Holder: "ci-1", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.LockStore = store
+
+	err := r.Deploy()
+	if !errors.Is(err, lock.ErrLocked) || !strings.Contains(err.Error(), "ci-1") {
+		t.Fatalf("deployment should fail with the lock holder: %v", err)
+	}
+
+	if names := backend.AutoScalingGroupNames(); len(names) != 0 {
+		t.Errorf("autoscaling groups are created while locked: %v", names)
+	}
+
+	// lock which is not expired is released only with --force
+	r.Builder.Config.LockKey = "hello-dev_apnortheast2"
+	if err := r.Unlock(); err == nil {
+		t.Fatal("lock should not be released without force")
+	}
+
+	r.Builder.Config.Force = true
+	if err := r.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if current, _ := store.Get("hello-dev_apnortheast2"); current != nil {
+		t.Errorf("lock is not released after deployment: %v", current)
+	}
+}
+
+func TestRunner_WarmPoolWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Stacks[0].WarmPool = &schemas.WarmPool{MinSize: 1, MaxPreparedCapacity: 4, PoolState: "Stopped"}
+
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	instances := backend.WarmPoolInstances("hello-dev_apnortheast2-v000")
+	if len(instances) != 2 {
+		t.Fatalf("expected 2 instances in warm pool, got %d", len(instances))
+	}
+	for _, instance := range instances {
+		if *instance.LifecycleState != "Warmed:Stopped" {
+			t.Errorf("unexpected lifecycle state of warm pool instance: %s", *instance.LifecycleState)
+		}
+	}
+
+	// previous version is deleted with its warm pool
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if instances := backend.WarmPoolInstances("hello-dev_apnortheast2-v000"); len(instances) != 0 {
+		t.Errorf("warm pool of previous version is not deleted: %v", instances)
+	}
+}
+
+func TestRunner_WeightedCapacityWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Timeout = 2 * time.Second
+	r.Builder.Stacks[0].Capacity = schemas.Capacity{Min: 4, Max: 8, Desired: 4}
+	r.Builder.Stacks[0].MixedInstancesPolicy = schemas.MixedInstancesPolicy{
+		Enabled:          true,
+		Override:         []string{"c5.large", "c5.xlarge"},
+		WeightedCapacity: map[string]int64{"c5.large": 1, "c5.xlarge": 3},
+	}
+
+	// 2 instances provide 4 units of capacity
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if states := backend.TargetHealth("hello-dev-tg"); len(states) != 2 {
+		t.Errorf("expected 2 targets in target group, got %d", len(states))
+	}
+}
+
+func TestRunner_LifecycleHookValidationWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.FailingCommands = []string{"exit 1"}
+
+	// validation is retried until SSM agent of launching instance is registered,
+	// and lifecycle action is completed again when it fails
+	backend.UnregisteredSends = 2
+	backend.ThrottledCompletions = 1
+
+	hooks := &schemas.LifecycleHooks{
+		LaunchTransition: []schemas.LifecycleHookSpecification{
+			{
+				LifecycleHookName: "validate-app",
+				DefaultResult:     constants.LifecycleActionAbandon,
+				HeartbeatTimeout:  300,
+				Validation: &schemas.LifecycleHookValidation{
+					Commands: []string{"curl -f localhost:8080/health"},
+				},
+			},
+		},
+	}
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Stacks[0].LifecycleHooks = hooks
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if commands := backend.Commands(); len(commands) != 2 {
+		t.Errorf("expected validation on 2 instances, got %d", len(commands))
+	}
+	backend.ThrottledCompletions = 0
+
+	if states := backend.TargetHealth("hello-dev-tg"); len(states) != 2 {
+		t.Errorf("expected 2 targets in target group, got %d", len(states))
+	}
+
+	// instances failing validation are abandoned and replaced until timeout
+	hooks.LaunchTransition[0].Validation.Commands = []string{"exit 1"}
+	r = newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Timeout = time.Second
+	r.Builder.Stacks[0].RollbackOnFailure = true
+	r.Builder.Stacks[0].LifecycleHooks = hooks
+	if err := r.Deploy(); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("deployment should fail with rollback: %v", err)
+	}
+
+	if commands := backend.Commands(); len(commands) <= 4 {
+		t.Errorf("expected replaced instances to be validated again, got %d commands", len(commands))
+	}
+
+	// instances whose SSM agent is never registered are abandoned after timeout of validation
+	backend.UnregisteredSends = 1000
+	sent := len(backend.Commands())
+	hooks.LaunchTransition[0].Validation.Commands = []string{"curl -f localhost:8080/health"}
+	hooks.LaunchTransition[0].Validation.Timeout = 50 * time.Millisecond
+	r = newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Timeout = time.Second
+	r.Builder.Stacks[0].RollbackOnFailure = true
+	r.Builder.Stacks[0].LifecycleHooks = hooks
+	if err := r.Deploy(); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("deployment should fail with rollback: %v", err)
+	}
+
+	if commands := backend.Commands(); len(commands) != sent {
+		t.Errorf("commands should not be sent to unregistered instances, got %d commands", len(commands)-sent)
+	}
+}
+
+func TestRunner_LifecycleCallbacksWithFakeBackend(t *testing.T) {
+	setup := func(callbacks *schemas.LifecycleCallbacks) (*fake.Backend, Runner) {
+		backend := fake.NewBackend("ap-northeast-2")
+		backend.FailingCommands = []string{"exit 1"}
+		backend.HangingCommands = []string{"sleep infinity"}
+		backend.AddTargetGroup("hello-dev-tg", 8080)
+		backend.AddSecurityGroup("hello-dev")
+		backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Config.Region = backend.Region
+		r.Builder.Stacks[0].LifecycleCallbacks = callbacks
+		return backend, r
+	}
+
+	// previous version is terminated even though commands fail with continue policy
+	backend, r := setup(&schemas.LifecycleCallbacks{PreTerminatePastClusters: []string{"exit 1"}})
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// failed instances are retried, and previous version is kept when they still fail
+	backend, r = setup(&schemas.LifecycleCallbacks{
+		PreTerminatePastClusters: []string{"exit 1"},
+		FailurePolicy:            constants.RetryPolicy,
+		Retries:                  1,
+	})
+	r.Deploy()
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000", "hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if commands := backend.Commands(); len(commands) != 2 {
+		t.Errorf("expected commands to be retried once, got %d commands", len(commands))
+	}
+
+	// commands which do not finish in time fail the deployment with abort policy
+	backend, r = setup(&schemas.LifecycleCallbacks{
+		PreTerminatePastClusters: []string{"sleep infinity"},
+		FailurePolicy:            constants.AbortPolicy,
+		Timeout:                  50 * time.Millisecond,
+	})
+	r.Builder.Stacks[0].RollbackOnFailure = true
+
+	err := r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "lifecycle callbacks failed") || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("deployment should fail with rollback: %v", err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRunner_HooksWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+	output := filepath.Join(t.TempDir(), "hooks")
+	record := schemas.Hook{Command: "echo $GOPLOYER_STEP $GOPLOYER_AUTOSCALING_GROUP $GOPLOYER_VERSION $GOPLOYER_ERROR >> " + output}
+	hooks := &schemas.Hooks{
+		BeforeDeploy:     []schemas.Hook{record},
+		AfterHealthCheck: []schemas.Hook{record},
+		BeforeCleanup:    []schemas.Hook{record},
+		AfterDeploy:      []schemas.Hook{record},
+		OnFailure:        []schemas.Hook{record},
+	}
+
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Region = backend.Region
+	r.Builder.AwsConfig.Hooks = hooks
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := func() []string {
+		b, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSpace(string(b)), "\n")
+	}
+
+	expected := []string{
+		"before_deploy hello-dev_apnortheast2-v001 1",
+		"after_health_check hello-dev_apnortheast2-v001 1",
+		"before_cleanup hello-dev_apnortheast2-v001 1",
+		"after_deploy hello-dev_apnortheast2-v001 1",
+	}
+	if diff := deep.Equal(lines(), expected); diff != nil {
+		t.Error(diff)
+	}
+
+	// previous version is kept when a hook fails before cleanup
+	os.Remove(output)
+	hooks.BeforeCleanup = append(hooks.BeforeCleanup, schemas.Hook{Name: "freeze", Command: "exit 1"})
+	r = newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.Config.Region = backend.Region
+	r.Builder.AwsConfig.Hooks = hooks
+
+	err := r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "before_cleanup hook freeze failed") {
+		t.Fatalf("deployment should fail with hook: %v", err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001", "hello-dev_apnortheast2-v002"}); diff != nil {
+		t.Error(diff)
+	}
+
+	failure := lines()[len(lines())-1]
+	if !strings.HasPrefix(failure, "on_failure hello-dev_apnortheast2-v002 2 before_cleanup hook freeze failed") {
+		t.Errorf("on_failure hook should receive the cause: %s", failure)
+	}
+}
+
+func TestRunner_InstanceHealthCheckWithFakeBackend(t *testing.T) {
+	var mu sync.Mutex
+	body := "starting"
+	probes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		probes++
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.ParseInt(u.Port(), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup := func() (*fake.Backend, Runner) {
+		backend := fake.NewBackend("ap-northeast-2")
+		backend.PrivateIPAddress = u.Hostname()
+		backend.AddTargetGroup("hello-dev-tg", 8080)
+		backend.AddSecurityGroup("hello-dev")
+
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Stacks[0].InstanceHealthCheck = &schemas.InstanceHealthCheck{
+			Path:      "/health",
+			Port:      port,
+			BodyRegex: "^ok$",
+			Timeout:   time.Second,
+		}
+		return backend, r
+	}
+
+	// instances of worker stack without load balancer are not healthy until the response matches
+	backend, r := setup()
+	r.Builder.Stacks[0].Regions[0].HealthcheckTargetGroup = ""
+	r.Builder.Stacks[0].Regions[0].TargetGroups = nil
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		body = "ok"
+		mu.Unlock()
+	}()
+
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+
+	mu.Lock()
+	if probes < 2 {
+		t.Errorf("instances should be probed directly: %d", probes)
+	}
+	body = "broken"
+	mu.Unlock()
+
+	// instances which are healthy in target group still fail the probe
+	backend, r = setup()
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+	r.Builder.Config.Timeout = 2 * time.Second
+	r.Builder.Stacks[0].RollbackOnFailure = true
+
+	err = r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("deployment should fail with rollback: %v", err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRunner_FailFastWithFakeBackend(t *testing.T) {
+	setup := func(ami string) (*fake.Backend, Runner) {
+		backend := fake.NewBackend("ap-northeast-2")
+		backend.CrashingImages = []string{"ami-crashing"}
+		backend.LaunchErrors = map[string]string{"ami-missing": "The image id '[ami-missing]' does not exist. Launching EC2 instance failed."}
+		backend.AddTargetGroup("hello-dev-tg", 8080)
+		backend.AddSecurityGroup("hello-dev")
+		backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Stacks[0].Regions[0].AmiID = ami
+		r.Builder.Stacks[0].RollbackOnFailure = true
+		return backend, r
+	}
+
+	// launch which never succeeds fails before timeout with the cause of activity
+	start := time.Now()
+	backend, r := setup("ami-missing")
+	err := r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "scaling activity failed") || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("deployment should fail with the cause of activity: %v", err)
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// instances which keep being replaced are never healthy for healthy_for, and deployment fails fast
+	backend, r = setup("ami-crashing")
+	r.Builder.Stacks[0].HealthyFor = time.Second
+	err = r.Deploy()
+	if err == nil || !strings.Contains(err.Error(), "instances are replaced for failed health check") || !strings.Contains(err.Error(), "EC2 health check") {
+		t.Fatalf("deployment should fail with replacements: %v", err)
+	}
+
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("deployment should fail before timeout: %s", time.Since(start))
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v000"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// stable instances are healthy after healthy_for
+	backend, r = setup("ami-0000000000000001")
+	r.Builder.Stacks[0].HealthyFor = 100 * time.Millisecond
+	start = time.Now()
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(start) < 100*time.Millisecond {
+		t.Errorf("instances should stay healthy for healthy_for: %s", time.Since(start))
+	}
+
+	if diff := deep.Equal(backend.AutoScalingGroupNames(), []string{"hello-dev_apnortheast2-v001"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRunner_EventSinksWithFakeBackend(t *testing.T) {
+	backend := fake.NewBackend("ap-northeast-2")
+	backend.AddTargetGroup("hello-dev-tg", 8080)
+	backend.AddSecurityGroup("hello-dev")
+	backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+	r.Builder.AwsConfig.EventSinks = []schemas.EventSink{{Type: "file", Path: path}}
+
+	if err := r.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []event.Type
+	emitted := map[event.Type]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var e event.Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("each line should be an event: %s", line)
+		}
+
+		if e.Application != "hello" {
+			t.Errorf("event should have application: %s", line)
+		}
+		types = append(types, e.Type)
+		emitted[e.Type] = true
+	}
+
+	for _, expected := range []event.Type{event.DeploymentStarted, event.StepStarted, event.HealthProgress, event.StepFinished, event.CleanupStarted, event.DeploymentFinished} {
+		if !emitted[expected] {
+			t.Errorf("%s is not emitted: %v", expected, types)
+		}
+	}
+
+	if diff := deep.Equal(types[0], event.DeploymentStarted); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(types[len(types)-1], event.DeploymentFinished); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRunner_NotifiersWithFakeBackend(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var m struct {
+			Title string `json:"title"`
+			Text  string `json:"text"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		received[req.URL.Path] = append(received[req.URL.Path], m.Title+m.Text)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	setup := func(ami string) Runner {
+		backend := fake.NewBackend("ap-northeast-2")
+		backend.LaunchErrors = map[string]string{"ami-missing": "The image id '[ami-missing]' does not exist. Launching EC2 instance failed."}
+		backend.AddTargetGroup("hello-dev-tg", 8080)
+		backend.AddSecurityGroup("hello-dev")
+		backend.AddAutoScalingGroup("hello-dev_apnortheast2-v000", "ami-0000000000000001", schemas.Capacity{Min: 2, Max: 2, Desired: 2}, "hello-dev-tg")
+
+		r := newFakeRunner(t, backend, constants.BlueGreenDeployment)
+		r.Builder.Stacks[0].Regions[0].AmiID = ami
+		r.Builder.Stacks[0].RollbackOnFailure = true
+		r.Builder.AwsConfig.Notifiers = []schemas.Notifier{{Type: "webhook", URL: server.URL + "/failures", Events: []string{"failures"}}}
+		r.Builder.Stacks[0].Notifiers = []schemas.Notifier{{Type: "webhook", URL: server.URL + "/artd", Events: []string{"DeploymentStarted", "DeploymentFinished"}}}
+		return r
+	}
+
+	if err := setup("ami-0000000000000001").Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(received["/artd"], []string{"[ hello ] Deployment has been started", "💯 Deployment is done: hello"}); diff != nil {
+		t.Error(diff)
+	}
+	if len(received["/failures"]) > 0 {
+		t.Errorf("failure notifier should not receive messages of successful deployment: %v", received["/failures"])
+	}
+
+	if err := setup("ami-missing").Deploy(); err == nil {
+		t.Fatal("deployment should fail")
+	}
+
+	var failures []string
+	for _, m := range received["/failures"] {
+		failures = append(failures, strings.SplitN(m, "\n", 2)[0])
+	}
+	if diff := deep.Equal(failures, []string{
+		"❌ Deployment of artd failed: error happened while health checking: scaling activity failed in hello-dev_apnortheast2-v001: The image id '[ami-missing]' does not exist. Launching EC2 instance failed.",
+		"⏪ Rolling back the new autoscaling group : hello-dev_apnortheast2-v001/ap-northeast-2",
+		"⏪ Rollback is finished : hello-dev_apnortheast2-v001/ap-northeast-2",
+		"❌ Deployment failed: hello",
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -33,7 +33,11 @@ type Config struct { // Do not add comments for this struct
 	OverrideSpotType       string `json:"override_spot_types"`
 	ReleaseNotes           string `json:"release_notes"`
 	ReleaseNotesBase64     string `json:"release_notes_base64"`
+	Output                 string `json:"output"`
+	RollbackVersion        string `json:"to"`
 	Application            string
+	DeploymentID           string
+	LockKey                string
 	TargetAutoscalingGroup string
 	Min                    int64 `json:"min"`
 	Max                    int64 `json:"max"`
@@ -48,6 +52,7 @@ type Config struct { // Do not add comments for this struct
 	SlackOff               bool          `json:"slack_off"`
 	ForceManifestCapacity  bool          `json:"force_manifest_capacity"`
 	CompleteCanary         bool          `json:"complete_canary"`
+	Force                  bool          `json:"force"`
 	DownSizingUpdate       bool
 }
 
@@ -70,6 +75,15 @@ type YamlConfig struct {
 
 	// API Test configuration
 	APITestTemplates []*APITestTemplate `yaml:"api_test_templates,omitempty"`
+
+	// Hooks run around steps of deployment
+	Hooks *Hooks `yaml:"hooks,omitempty"`
+
+	// Destinations of deployment events in addition to slack
+	EventSinks []EventSink `yaml:"event_sinks,omitempty"`
+
+	// Notifiers which receive messages of every stack in addition to slack
+	Notifiers []Notifier `yaml:"notifiers,omitempty"`
 }
 
 // AWS Related Configurations except for stack
@@ -85,6 +99,117 @@ type AWSConfig struct {
 
 	// List of scheduled action configuration
 	ScheduledActions []ScheduledAction
+
+	// Hooks run around steps of deployment
+	Hooks *Hooks
+
+	// Destinations of deployment events in addition to slack
+	EventSinks []EventSink
+
+	// Notifiers which receive messages of every stack in addition to slack
+	Notifiers []Notifier
+}
+
+// Hooks run around steps of deployment
+type Hooks struct {
+	// Hooks run before the new version is deployed
+	BeforeDeploy []Hook `yaml:"before_deploy,omitempty"`
+
+	// Hooks run after the new version becomes healthy
+	AfterHealthCheck []Hook `yaml:"after_health_check,omitempty"`
+
+	// Hooks run before previous versions are terminated
+	BeforeCleanup []Hook `yaml:"before_cleanup,omitempty"`
+
+	// Hooks run after the deployment is finished
+	AfterDeploy []Hook `yaml:"after_deploy,omitempty"`
+
+	// Hooks run when the deployment of stack fails
+	OnFailure []Hook `yaml:"on_failure,omitempty"`
+}
+
+// Hook is a local command or an HTTP request run in a step of deployment
+type Hook struct {
+	// Name of hook
+	Name string `yaml:"name,omitempty"`
+
+	// Local command run with shell. Deployment context is passed as environment variables
+	Command string `yaml:"command,omitempty"`
+
+	// HTTP request which sends deployment context as JSON body
+	HTTP *HTTPHook `yaml:"http,omitempty"`
+
+	// How long to wait for the command or response
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// HTTP request of hook
+type HTTPHook struct {
+	// URL of request
+	URL string `yaml:"url"`
+
+	// Method of request: GET, POST or PUT
+	Method string `yaml:"method,omitempty"`
+
+	// Headers of request. Environment variables like ${TOKEN} in values are expanded
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// Destination of deployment events
+type EventSink struct {
+	// Type of sink: file or webhook
+	Type string `yaml:"type"`
+
+	// Path of file which events are appended to as NDJSON. "-" writes events to stdout
+	Path string `yaml:"path,omitempty"`
+
+	// URL which each event is posted to as JSON
+	URL string `yaml:"url,omitempty"`
+
+	// Headers of webhook request. Environment variables like ${TOKEN} in values are expanded
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// Notification channel besides slack
+type Notifier struct {
+	// Type of notifier: teams, discord, webhook or email
+	Type string `yaml:"type"`
+
+	// Incoming webhook URL of teams, discord or webhook notifier. Environment variables like ${URL} are expanded
+	URL string `yaml:"url,omitempty"`
+
+	// Headers of webhook notifier request. Environment variables like ${TOKEN} in values are expanded
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Go template of webhook notifier request body. Title, Text and Sections of message are available
+	Template string `yaml:"template,omitempty"`
+
+	// SMTP configuration of email notifier
+	SMTP *SMTP `yaml:"smtp,omitempty"`
+
+	// Types of events which are notified. "failures" stands for failed deployments, bake alarms and rollbacks. If empty, email only notifies failures and others notify every event except progress of each polling
+	Events []string `yaml:"events,omitempty"`
+}
+
+// SMTP server which sends email notification
+type SMTP struct {
+	// Host of SMTP server
+	Host string `yaml:"host"`
+
+	// Port of SMTP server. 587 by default
+	Port int64 `yaml:"port,omitempty"`
+
+	// Username of SMTP authentication
+	Username string `yaml:"username,omitempty"`
+
+	// Password of SMTP authentication. Environment variables like ${SMTP_PASSWORD} are expanded
+	Password string `yaml:"password,omitempty"`
+
+	// Sender address
+	From string `yaml:"from"`
+
+	// Recipient addresses
+	To []string `yaml:"to"`
 }
 
 // Userdata configuration
@@ -122,12 +247,48 @@ type Stack struct {
 	// Type of Replacement for deployment
 	ReplacementType string `yaml:"replacement_type"`
 
+	// Names of stacks which have to become healthy before this stack is deployed
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	// Order of deployment; stacks wait until every stack with a lower wave becomes healthy
+	Wave int `yaml:"wave,omitempty"`
+
 	// Percentage of instances to terminate in one batch during termination process in BlueGreen deployment for termination delay
 	TerminationDelayRate int64 `yaml:"termination_delay_rate"`
 
 	// Instance count per round in rolling update replacement type
 	RollingUpdateInstanceCount int64 `yaml:"rolling_update_instance_count"`
 
+	// Maximum number or percentage of instances above desired capacity during rolling update
+	MaxSurge string `yaml:"max_surge,omitempty"`
+
+	// Maximum number or percentage of instances which can be unavailable during rolling update
+	MaxUnavailable string `yaml:"max_unavailable,omitempty"`
+
+	// Whether to delete the new version and restore previous versions when health check fails
+	RollbackOnFailure bool `yaml:"rollback_on_failure,omitempty"`
+
+	// How regions are deployed: parallel or sequential
+	RegionRollout string `yaml:"region_rollout,omitempty"`
+
+	// How long to wait before the next region in sequential region rollout
+	PauseBetweenRegions time.Duration `yaml:"pause_between_regions,omitempty"`
+
+	// Checks of the deployed region before the next region in sequential region rollout
+	RegionGate *RegionGate `yaml:"region_gate,omitempty"`
+
+	// Traffic shifting steps with weighted target groups in canary deployment
+	CanaryTrafficShifting *CanaryTrafficShifting `yaml:"canary_traffic_shifting,omitempty"`
+
+	// Manual approval before traffic cutover and cleaning previous versions
+	Approval *Approval `yaml:"approval,omitempty"`
+
+	// Bake period which watches the new version before cleaning previous versions
+	Bake *Bake `yaml:"bake,omitempty"`
+
+	// Preferences of instance refresh in refresh replacement type
+	InstanceRefresh *InstanceRefresh `yaml:"instance_refresh,omitempty"`
+
 	// Userdata configuration for stack deployment
 	Userdata Userdata `yaml:"userdata,omitempty"`
 
@@ -167,6 +328,9 @@ type Stack struct {
 	// Autoscaling Capacity
 	Capacity Capacity `yaml:"capacity,omitempty"`
 
+	// Warm pool of pre-initialized instances for the autoscaling group
+	WarmPool *WarmPool `yaml:"warm_pool,omitempty"`
+
 	// Autoscaling Policy according to the metrics
 	Autoscaling []ScalePolicy `yaml:"autoscaling,omitempty"`
 
@@ -179,10 +343,163 @@ type Stack struct {
 	// Lifecycle hooks of autoscaling group
 	LifecycleHooks *LifecycleHooks `yaml:"lifecycle_hooks,omitempty"`
 
+	// HTTP health check which probes private IP of each new instance directly
+	InstanceHealthCheck *InstanceHealthCheck `yaml:"instance_health_check,omitempty"`
+
+	// How long an instance should stay healthy across consecutive polls to be counted as healthy
+	HealthyFor time.Duration `yaml:"healthy_for,omitempty"`
+
+	// Number of instances which can be replaced for failed health check before deployment fails. 3 by default
+	MaxInstanceReplacements int64 `yaml:"max_instance_replacements,omitempty"`
+
+	// Notifiers which receive messages of this stack in addition to slack
+	Notifiers []Notifier `yaml:"notifiers,omitempty"`
+
 	// List of region configurations
 	Regions []RegionConfig `yaml:"regions"`
 }
 
+// Traffic shifting configuration of canary deployment
+type CanaryTrafficShifting struct {
+	// List of steps which are applied in order
+	Steps []CanaryStep `yaml:"steps"`
+
+	// Metric analysis which decides whether the canary is promoted
+	Analysis *CanaryAnalysis `yaml:"analysis,omitempty"`
+}
+
+// Metric analysis of canary traffic shifting
+type CanaryAnalysis struct {
+	// List of metrics compared between canary and baseline after each step
+	Metrics []CanaryMetric `yaml:"metrics"`
+}
+
+// Metric compared between canary and baseline
+type CanaryMetric struct {
+	// Name of the check which is shown in the result
+	Name string `yaml:"name,omitempty"`
+
+	// Namespace of metric, AWS/ApplicationELB by default
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Name of CloudWatch metric
+	Metric string `yaml:"metric"`
+
+	// Statistic of metric like Sum, Average or p99
+	Statistic string `yaml:"statistic"`
+
+	// Dimension which distinguishes canary from baseline: target_group or autoscaling_group
+	Dimension string `yaml:"dimension,omitempty"`
+
+	// How canary value is compared: absolute, ratio or difference. Sum and SampleCount are scaled to the full traffic by weight for ratio and difference
+	Comparison string `yaml:"comparison"`
+
+	// Maximum value allowed for the comparison
+	Threshold float64 `yaml:"threshold"`
+}
+
+// Step of canary traffic shifting
+type CanaryStep struct {
+	// Percentage of traffic sent to the canary target group
+	Weight int64 `yaml:"weight"`
+
+	// How long to wait before moving to the next step. Required unless weight is 100
+	Pause time.Duration `yaml:"pause,omitempty"`
+}
+
+// Manual approval gate of deployment
+type Approval struct {
+	// Where to ask for approval: cli, server or slack
+	Method string `yaml:"method,omitempty"`
+
+	// Address which goployer listens on for approval API of server and slack method while waiting
+	Address string `yaml:"address,omitempty"`
+
+	// How long to wait for the decision
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Decision when nobody approves or rejects until timeout: approve or reject
+	DefaultAction string `yaml:"default_action,omitempty"`
+}
+
+// Gate between regions in sequential region rollout
+type RegionGate struct {
+	// Whether to check health of the new version again
+	HealthCheck bool `yaml:"health_check,omitempty"`
+
+	// Names of CloudWatch alarms which must not be in ALARM state
+	Alarms []string `yaml:"alarms,omitempty"`
+}
+
+// Bake period after cutover
+type Bake struct {
+	// How long previous versions are kept with full capacity but without traffic
+	Duration time.Duration `yaml:"duration"`
+
+	// Names of CloudWatch alarms which trigger rollback when they enter ALARM state
+	Alarms []string `yaml:"alarms,omitempty"`
+
+	// Metrics of the new version which trigger rollback when they exceed thresholds
+	Metrics []BakeMetric `yaml:"metrics,omitempty"`
+}
+
+// Metric threshold watched during bake period
+type BakeMetric struct {
+	// Name of the check which is shown in the result
+	Name string `yaml:"name,omitempty"`
+
+	// Namespace of metric, AWS/ApplicationELB by default
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Name of CloudWatch metric
+	Metric string `yaml:"metric"`
+
+	// Statistic of metric like Sum, Average or p99
+	Statistic string `yaml:"statistic"`
+
+	// Dimension of the new version: target_group or autoscaling_group
+	Dimension string `yaml:"dimension,omitempty"`
+
+	// Maximum value of metric since the bake period started
+	Threshold float64 `yaml:"threshold"`
+}
+
+// Preferences of instance refresh which replaces instances of the autoscaling group
+type InstanceRefresh struct {
+	// Seconds until a new instance is counted as healthy, 300 by default
+	InstanceWarmup int64 `yaml:"instance_warmup,omitempty"`
+
+	// Percentage of capacity which has to stay healthy during refresh, 90 by default
+	MinHealthyPercentage int64 `yaml:"min_healthy_percentage,omitempty"`
+
+	// Percentages of replaced instances where refresh waits for checkpoint_delay
+	CheckpointPercentages []int64 `yaml:"checkpoint_percentages,omitempty"`
+
+	// How long to wait at each checkpoint
+	CheckpointDelay time.Duration `yaml:"checkpoint_delay,omitempty"`
+
+	// Whether to skip instances which already run with the new launch template
+	SkipMatching bool `yaml:"skip_matching,omitempty"`
+
+	// Whether to roll back to the previous launch template when refresh fails
+	AutoRollback bool `yaml:"auto_rollback,omitempty"`
+}
+
+// Warm pool configuration of autoscaling group
+type WarmPool struct {
+	// Minimum number of instances in the warm pool
+	MinSize int64 `yaml:"min_size,omitempty" json:"min_size"`
+
+	// Maximum number of instances in the autoscaling group and the warm pool together. Max size of the group is used if it is not specified
+	MaxPreparedCapacity int64 `yaml:"max_prepared_capacity,omitempty" json:"max_prepared_capacity,omitempty"`
+
+	// State of instances in the warm pool: Stopped, Running or Hibernated. Stopped by default
+	PoolState string `yaml:"pool_state,omitempty" json:"pool_state"`
+
+	// Whether instances return to the warm pool instead of being terminated on scale in
+	ReuseOnScaleIn bool `yaml:"reuse_on_scale_in,omitempty" json:"reuse_on_scale_in"`
+}
+
 // Instance Market Options Configuration
 type InstanceMarketOptions struct {
 	// Type of market for EC2 instance
@@ -200,6 +517,12 @@ type MixedInstancesPolicy struct {
 	// List of EC2 instance types for spot instance
 	Override []string `yaml:"override_instance_types"`
 
+	// Units of capacity which each instance type in override_instance_types provides
+	WeightedCapacity map[string]int64 `yaml:"weighted_capacity,omitempty"`
+
+	// Attributes of instance types which autoscaling group selects instead of override_instance_types
+	InstanceRequirements *InstanceRequirements `yaml:"instance_requirements,omitempty"`
+
 	// Minimum capacity of on-demand instance
 	OnDemandBaseCapacity int64 `yaml:"on_demand_base_capacity"`
 
@@ -216,6 +539,36 @@ type MixedInstancesPolicy struct {
 	SpotMaxPrice string `yaml:"spot_max_price,omitempty"`
 }
 
+// Attributes of instance types for attribute-based instance type selection
+type InstanceRequirements struct {
+	// Range of the number of vCPUs
+	VCpuCount RequirementRange `yaml:"vcpu_count"`
+
+	// Range of memory size in MiB
+	MemoryMiB RequirementRange `yaml:"memory_mib"`
+
+	// CPU manufacturers: intel, amd or amazon-web-services
+	CPUManufacturers []string `yaml:"cpu_manufacturers,omitempty"`
+
+	// Whether to use burstable performance instance types: included, excluded or required
+	BurstablePerformance string `yaml:"burstable_performance,omitempty"`
+
+	// Generations of instance types: current or previous
+	InstanceGenerations []string `yaml:"instance_generations,omitempty"`
+
+	// Maximum price of spot instances as a percentage over the cheapest instance type
+	SpotMaxPricePercentage int64 `yaml:"spot_max_price_percentage,omitempty"`
+}
+
+// Range of an attribute of instance types. Max is unlimited if it is not specified
+type RequirementRange struct {
+	// Minimum value
+	Min int64 `yaml:"min"`
+
+	// Maximum value
+	Max int64 `yaml:"max,omitempty"`
+}
+
 // Spot configurations
 type SpotOptions struct {
 	// BlockDurationMinutes menas How long you want to use spot instance for sure
@@ -263,6 +616,33 @@ type BlockDevice struct {
 type LifecycleCallbacks struct {
 	// List of command before terminating previous autoscaling group
 	PreTerminatePastClusters []string `yaml:"pre_terminate_past_cluster"`
+
+	// How long to wait for commands to finish on each instance
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// What to do when commands fail on any instance: continue, abort or retry
+	FailurePolicy string `yaml:"failure_policy,omitempty"`
+
+	// Number of retries on instances where commands failed with retry policy
+	Retries int64 `yaml:"retries,omitempty"`
+}
+
+// HTTP health check of instances
+type InstanceHealthCheck struct {
+	// Path of request
+	Path string `yaml:"path"`
+
+	// Port which application listens on
+	Port int64 `yaml:"port"`
+
+	// Expected status code of response. 200 by default
+	ExpectedStatus int64 `yaml:"expected_status,omitempty"`
+
+	// Regular expression which response body should match
+	BodyRegex string `yaml:"body_regex,omitempty"`
+
+	// How long to wait for response of each request
+	Timeout time.Duration `yaml:"timeout,omitempty"`
 }
 
 // Policy of scaling policy
@@ -353,6 +733,9 @@ type RegionConfig struct {
 	// Target group list of load balancer
 	TargetGroups []string `yaml:"target_groups"`
 
+	// Pair of target groups for blue/green switch. The new version is registered to the idle one and listeners are switched to it
+	BlueGreenTargetGroups []string `yaml:"blue_green_target_groups,omitempty"`
+
 	// List of  load balancers
 	LoadBalancers []string `yaml:"loadbalancers"`
 
@@ -393,13 +776,13 @@ type ENIConfig struct {
 // Instance capacity of autoscaling group
 type Capacity struct {
 	// Minimum number of instances
-	Min int64 `yaml:"min"`
+	Min int64 `yaml:"min" json:"min"`
 
 	// Maximum number of instances
-	Max int64 `yaml:"max"`
+	Max int64 `yaml:"max" json:"max"`
 
 	// Desired number of instances
-	Desired int64 `yaml:"desired"`
+	Desired int64 `yaml:"desired" json:"desired"`
 }
 
 // Lifecycle Hooks
@@ -430,6 +813,18 @@ type LifecycleHookSpecification struct {
 
 	// IAM Role ARN for notification
 	RoleARN string `yaml:"role_arn"`
+
+	// Validation which goployer runs on launching instances before completing the lifecycle action
+	Validation *LifecycleHookValidation `yaml:"validation,omitempty"`
+}
+
+// Validation of instances waiting in launch lifecycle hook
+type LifecycleHookValidation struct {
+	// Commands run on the instance through SSM. The instance continues launching only when all commands succeed
+	Commands []string `yaml:"commands"`
+
+	// How long to wait for the result before abandoning the instance, counted from the start of lifecycle action. Default is a polling interval before heartbeat_timeout
+	Timeout time.Duration `yaml:"timeout,omitempty"`
 }
 
 // Templates for API Test
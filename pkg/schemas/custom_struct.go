@@ -23,3 +23,21 @@ type MetricResult struct {
 	Method string
 	Data   vegeta.Metrics
 }
+
+// CanaryAnalysisResult is the verdict of canary analysis in a region
+type CanaryAnalysisResult struct {
+	Region  string                       `json:"region"`
+	Weight  int64                        `json:"weight"`
+	Passed  bool                         `json:"passed"`
+	Metrics []CanaryMetricAnalysisResult `json:"metrics"`
+}
+
+// CanaryMetricAnalysisResult is the compared values of a single canary metric
+type CanaryMetricAnalysisResult struct {
+	Name       string  `json:"name"`
+	Canary     float64 `json:"canary"`
+	Baseline   float64 `json:"baseline"`
+	Comparison string  `json:"comparison"`
+	Threshold  float64 `json:"threshold"`
+	Passed     bool    `json:"passed"`
+}
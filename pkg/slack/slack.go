@@ -556,6 +556,36 @@ func (s Slack) SendSummaryMessageWithWebHook(config schemas.Config, stacks []sch
 	return sendSlackRequest(slackBody, s.WebhookURL)
 }
 
+// SendApprovalMessage sends an interactive message with buttons for approving the new version.
+// Actions of the buttons are delivered to the goployer server with the request id.
+func (s Slack) SendApprovalMessage(id, message, defaultAction string) error {
+	if !s.ValidClient() {
+		return errors.New("slack is not configured for approval")
+	}
+
+	approve := slack.NewButtonBlockElement(constants.ApproveAction, id, slack.NewTextBlockObject("plain_text", "Approve", false, false))
+	approve.WithStyle(slack.StylePrimary)
+	reject := slack.NewButtonBlockElement(constants.RejectAction, id, slack.NewTextBlockObject("plain_text", "Reject", false, false))
+	reject.WithStyle(slack.StyleDanger)
+
+	blocks := []slack.Block{
+		s.CreateTitleSection(fmt.Sprintf(":raising_hand: *Approval is requested: %s*", id)),
+		s.CreateSimpleSection(message),
+		s.CreateSimpleSection(fmt.Sprintf("Default action: %s", defaultAction)),
+		slack.NewActionBlock(id, approve, reject),
+	}
+
+	if len(s.WebhookURL) > 0 {
+		slackBody, err := json.Marshal(map[string][]slack.Block{"blocks": blocks})
+		if err != nil {
+			return err
+		}
+		return sendSlackRequest(slackBody, s.WebhookURL)
+	}
+
+	return s.SendMessage(slack.MsgOptionBlocks(blocks...))
+}
+
 // sendSlackRequest sends request for slack message
 func sendSlackRequest(slackBody []byte, url string) error {
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(slackBody))
@@ -31,6 +31,12 @@ Configurations of stacks
 {{ decorate "underline bold" "Account" }}:	{{ $stack.Account }}
 {{ decorate "underline bold" "Replacement Type" }}:	{{ $stack.ReplacementType }}
 {{ decorate "underline bold" "Rolling Update Instance Count" }}:	{{ $stack.RollingUpdateInstanceCount }}
+{{- if gt (len $stack.MaxSurge) 0 }}
+{{ decorate "underline bold" "Max Surge" }}:	{{ $stack.MaxSurge }}
+{{- end }}
+{{- if gt (len $stack.MaxUnavailable) 0 }}
+{{ decorate "underline bold" "Max Unavailable" }}:	{{ $stack.MaxUnavailable }}
+{{- end }}
 {{ decorate "underline bold" "Environment" }}:	{{ $stack.Env }}
 {{ decorate "underline bold" "IAM Instance Profile" }}:	{{ $stack.IamInstanceProfile }}
 {{- if gt (len $stack.Tags) 0 }}
@@ -55,7 +61,14 @@ NAME	TYPE	SIZE	IOPS
 
 {{- if eq $stack.MixedInstancesPolicy.Enabled true }}
 {{ decorate "underline bold" "Mixed Instance policy" }}
+{{- with $stack.MixedInstancesPolicy.InstanceRequirements }}
+{{ decorate "bullet" "Instance Requirements" }}: vCPU {{ .VCpuCount.Min }}-{{ if gt .VCpuCount.Max 0 }}{{ .VCpuCount.Max }}{{ end }}, Memory {{ .MemoryMiB.Min }}-{{ if gt .MemoryMiB.Max 0 }}{{ .MemoryMiB.Max }}{{ end }} MiB
+{{- else }}
 {{ decorate "bullet" "Override" }}: {{ joinString $stack.MixedInstancesPolicy.Override "," }}
+{{- end }}
+{{- if gt (len $stack.MixedInstancesPolicy.WeightedCapacity) 0 }}
+{{ decorate "bullet" "Weighted Capacity" }}:{{ range $k, $v := $stack.MixedInstancesPolicy.WeightedCapacity }} {{ $k }}={{ $v }}{{ end }}
+{{- end }}
 {{ decorate "bullet" "On-Demand Percentage" }}: {{ $stack.MixedInstancesPolicy.OnDemandPercentage }}
 {{ decorate "bullet" "Spot Allocation Strategy" }}: {{ $stack.MixedInstancesPolicy.SpotAllocationStrategy }}
 {{ decorate "bullet" "Spot Instance Pools" }}: {{ $stack.MixedInstancesPolicy.SpotInstancePools }}
@@ -120,6 +133,20 @@ MINIMUM 	DESIRED 	MAXIMUM
  {{decorate "bullet" $k }}: {{ $v }}
 {{- end }}
 {{- end }}
+{{- if .Summary.WarmPool }}
+
+{{decorate "instance_statistics" ""}}{{decorate "underline bold" "Warm Pool"}}
+MIN SIZE	MAX PREPARED CAPACITY	POOL STATE	STATUS
+{{ .Summary.WarmPool.MinSize }}	{{ if gt .Summary.WarmPool.MaxPreparedCapacity 0 }}{{ .Summary.WarmPool.MaxPreparedCapacity }}{{ else }}-{{ end }}	{{ .Summary.WarmPool.PoolState }}	{{ .Summary.WarmPool.Status }}
+{{- if eq (len .Summary.WarmPool.Instances) 0 }}
+ No instance exists in warm pool
+{{- else }}
+ID	LIFECYCLE STATE	HEALTH STATUS
+{{- range $instance := .Summary.WarmPool.Instances }}
+ {{decorate "bullet" $instance.ID }}	{{ $instance.LifecycleState }}	{{ $instance.HealthStatus }}
+{{- end }}
+{{- end }}
+{{- end }}
 
 {{decorate "tags" ""}}{{decorate "underline bold" "Tags"}}
 
@@ -191,3 +218,86 @@ const InstanceRefreshStatusTemplate = `{{decorate "bold" "Name"}}:	{{ .Target.Au
 {{decorate "bold" "End Time"}}:	{{ .Summary.EndTime }}
 {{decorate "bold" "Status"}}:	{{ .Summary.Status }}
 `
+
+const DeploymentPlanTemplate = `{{- if eq (len .Plans) 0 }}
+No stack selected
+{{- end }}
+{{- range $plan := .Plans }}
+============================================================
+{{ decorate "bold" "Stack" }}:	{{ $plan.Stack }}
+{{ decorate "bold" "Region" }}:	{{ $plan.Region }}
+{{ decorate "bold" "Replacement Type" }}:	{{ $plan.ReplacementType }}
+============================================================
+{{ decorate "underline bold" "Autoscaling Group" }}
+{{ decorate "bullet" "Name" }}:	{{ $plan.AutoscalingGroupName }}
+{{ decorate "bullet" "Health Check" }}:	{{ $plan.HealthcheckType }} ({{ $plan.HealthcheckGracePeriod }}s grace period)
+{{ decorate "bullet" "Availability Zones" }}:	{{ joinString $plan.AvailabilityZones "," }}
+{{ decorate "bullet" "Subnets" }}:	{{ joinString $plan.Subnets "," }}
+{{- if gt (len $plan.LoadBalancers) 0 }}
+{{ decorate "bullet" "Load Balancers" }}:	{{ joinString $plan.LoadBalancers "," }}
+{{- end }}
+{{- if gt (len $plan.TargetGroupARNs) 0 }}
+{{ decorate "bullet" "Target Groups" }}:	{{ joinString $plan.TargetGroupARNs "," }}
+{{- end }}
+{{- if gt (len $plan.TerminationPolicies) 0 }}
+{{ decorate "bullet" "Termination Policies" }}:	{{ joinString $plan.TerminationPolicies "," }}
+{{- end }}
+{{- if gt (len $plan.InstanceOverrides) 0 }}
+{{ decorate "bullet" "Instance Overrides" }}:	{{ joinString $plan.InstanceOverrides "," }}
+{{- end }}
+
+{{ decorate "underline bold" "Capacity" }}
+MINIMUM 	DESIRED 	MAXIMUM
+{{ $plan.Capacity.Min }}	{{ $plan.Capacity.Desired }}	{{ $plan.Capacity.Max }}
+{{- if $plan.WarmPool }}
+
+{{ decorate "underline bold" "Warm Pool" }}
+{{ decorate "bullet" "Min Size" }}:	{{ $plan.WarmPool.MinSize }}
+{{- if gt $plan.WarmPool.MaxPreparedCapacity 0 }}
+{{ decorate "bullet" "Max Prepared Capacity" }}:	{{ $plan.WarmPool.MaxPreparedCapacity }}
+{{- end }}
+{{ decorate "bullet" "Pool State" }}:	{{ $plan.WarmPool.PoolState }}
+{{ decorate "bullet" "Reuse On Scale In" }}:	{{ $plan.WarmPool.ReuseOnScaleIn }}
+{{- end }}
+
+{{ decorate "underline bold" "Launch Template" }}
+{{ decorate "bullet" "Name" }}:	{{ $plan.LaunchTemplate.Name }}
+{{ decorate "bullet" "AMI" }}:	{{ $plan.LaunchTemplate.AMI }}
+{{ decorate "bullet" "Instance Type" }}:	{{ $plan.LaunchTemplate.InstanceType }}
+{{ decorate "bullet" "SSH Key" }}:	{{ $plan.LaunchTemplate.KeyName }}
+{{ decorate "bullet" "IAM Instance Profile" }}:	{{ $plan.LaunchTemplate.IamInstanceProfile }}
+{{ decorate "bullet" "EBS Optimized" }}:	{{ $plan.LaunchTemplate.EbsOptimized }}
+{{- if gt (len $plan.LaunchTemplate.SecurityGroups) 0 }}
+{{ decorate "bullet" "Security Groups" }}:	{{ joinString $plan.LaunchTemplate.SecurityGroups "," }}
+{{- end }}
+{{- if gt (len $plan.LaunchTemplate.BlockDevices) 0 }}
+
+{{ decorate "underline bold" "Block Devices" }}
+NAME	TYPE	SIZE	IOPS	ENCRYPTED
+{{- range $ebs := $plan.LaunchTemplate.BlockDevices }}
+{{ $ebs.DeviceName }}	{{ $ebs.VolumeType }}	{{ $ebs.VolumeSize }}	{{ $ebs.Iops }}	{{ $ebs.Encrypted }}
+{{- end }}
+{{- end }}
+
+{{ decorate "underline bold" "Tags" }}
+{{- range $tag := $plan.Tags }}
+ {{ decorate "bullet" $tag.Key }}:	{{ $tag.Value }}
+{{- end }}
+
+{{ decorate "underline bold" "Previous Versions" }}
+{{- if eq (len $plan.PreviousVersions) 0 }}
+ No previous version exists
+{{- else }}
+{{- range $asg := $plan.PreviousVersions }}
+ {{ decorate "bullet" $asg }}
+{{- end }}
+{{- end }}
+{{- end }}
+`
+
+const RegionRolloutResultTemplate = `================REGION ROLLOUT RESULT================
+STACK	REGION	RESULT	REASON
+{{- range $result := .Results }}
+{{ $result.Stack }}	{{ $result.Region }}	{{ $result.Status }}	{{ $result.Reason }}
+{{- end }}
+`
@@ -25,6 +25,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -156,11 +157,48 @@ func RoundNum(n float64) string {
 	return fmt.Sprintf("%.2f", n)
 }
 
+// ParseCountOrPercentage parses an absolute number or a percentage of total like "25%".
+// A percentage is rounded up if roundUp is true, otherwise it is rounded down.
+func ParseCountOrPercentage(value string, total int64, roundUp bool) (int64, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		pct, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return 0, fmt.Errorf("percentage should be an integer between 0%% and 100%%: %s", value)
+		}
+
+		if roundUp {
+			return (total*pct + 99) / 100, nil
+		}
+		return total * pct / 100, nil
+	}
+
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("value should be a non-negative number or a percentage: %s", value)
+	}
+
+	return count, nil
+}
+
 // JoinString joins strings in the slice
 func JoinString(arr []string, delimiter string) string {
 	return strings.Join(arr, delimiter)
 }
 
+// Excerpt returns the trimmed end of output within length, which usually contains the cause of failure
+func Excerpt(output string, length int) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= length {
+		return output
+	}
+
+	return "..." + output[len(output)-length:]
+}
+
 // CreateBodyStruct creates body with slice
 func CreateBodyStruct(slice []string) ([]byte, error) {
 	bd := map[string]string{}
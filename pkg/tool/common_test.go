@@ -55,3 +55,44 @@ func TestIsTargetGroupArn(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCountOrPercentage(t *testing.T) {
+	testData := []struct {
+		Input    string
+		RoundUp  bool
+		Expected int64
+		Err      bool
+	}{
+		{Input: "", Expected: 0},
+		{Input: "3", Expected: 3},
+		{Input: "25%", RoundUp: true, Expected: 3},
+		{Input: "25%", RoundUp: false, Expected: 2},
+		{Input: "100%", Expected: 10},
+		{Input: "120%", Err: true},
+		{Input: "-1", Err: true},
+		{Input: "half", Err: true},
+	}
+
+	for _, td := range testData {
+		output, err := ParseCountOrPercentage(td.Input, 10, td.RoundUp)
+		if td.Err != (err != nil) {
+			t.Errorf("unexpected error for %s: %v", td.Input, err)
+		}
+
+		if output != td.Expected {
+			t.Errorf("expected: %d, output: %d, input: %s", td.Expected, output, td.Input)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	if ret := Excerpt("  ok\n", 10); ret != "ok" {
+		t.Errorf("short output should be trimmed only: %q", ret)
+	}
+
+	output := strings.Repeat("a", 10) + "error: connection refused"
+	ret := Excerpt(output, 25)
+	if ret != "...error: connection refused" {
+		t.Errorf("long output should keep its end: %q", ret)
+	}
+}